@@ -72,7 +72,7 @@ export function ProductItem({
       onClick={() => navigate(`/products/${product.id}`)}
       title={
         <div className="flex items-center gap-2">
-          {product.latest_versions && <LatestChip />}
+          {!!product.latest_versions?.length && <LatestChip />}
           <p>{product.name}</p>
         </div>
       }
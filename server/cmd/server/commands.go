@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"product-database-api/internal"
+	"strings"
+)
+
+const commandUsage = `usage:
+  server                  start the API server
+  server dump [-o file]   write a database dump as JSON to stdout or a file
+  server restore [-mode empty|merge] [-on-conflict skip|overwrite|new_id] [-dry-run] file
+                          restore a database dump, "-" reads from stdin
+  server import-cpe -vendor v1,v2 [-product p1,p2] [-include-deprecated] [-dry-run] file
+                          import vendors, products and versions from an NVD CPE dictionary or match feed`
+
+// runCommand executes a maintenance subcommand instead of starting the server.
+// Its changes are recorded with the actor "cli".
+func runCommand(ctx context.Context, svc *internal.Service, args []string, stdin io.Reader, stdout io.Writer) error {
+	ctx = internal.WithAuditContext(ctx, internal.AuditContext{Actor: "cli"})
+	switch args[0] {
+	case "dump":
+		return runDump(ctx, svc, args[1:], stdout)
+	case "restore":
+		return runRestore(ctx, svc, args[1:], stdin, stdout)
+	case "import-cpe":
+		return runImportCPE(ctx, svc, args[1:], stdin, stdout)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+	}
+}
+
+func runDump(ctx context.Context, svc *internal.Service, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	output := flags.String("o", "", "write the dump to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dump, err := svc.Dump(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o600)
+}
+
+func runRestore(ctx context.Context, svc *internal.Service, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	mode := flags.String("mode", internal.RestoreModeEmpty, "restore into an empty database or merge into existing data")
+	onConflict := flags.String("on-conflict", internal.OnConflictSkip, "handling of rows whose ID exists with different content")
+	dryRun := flags.Bool("dry-run", false, "only report the changes without persisting them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("restore expects exactly one dump file\n" + commandUsage)
+	}
+
+	var data []byte
+	var err error
+	if file := flags.Arg(0); file == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := svc.RestoreDump(ctx, data, *mode, *onConflict, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	printReport(stdout, report)
+	return nil
+}
+
+func runImportCPE(ctx context.Context, svc *internal.Service, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import-cpe", flag.ContinueOnError)
+	vendors := flags.String("vendor", "", "comma separated CPE vendors to import")
+	products := flags.String("product", "", "comma separated CPE products to import, all products of the vendors if empty")
+	includeDeprecated := flags.Bool("include-deprecated", false, "also import deprecated CPE names")
+	dryRun := flags.Bool("dry-run", false, "only report the changes without persisting them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("import-cpe expects exactly one dictionary file\n" + commandUsage)
+	}
+
+	input := stdin
+	if file := flags.Arg(0); file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+
+	filter := internal.CPEDictionaryFilter{
+		Vendors:           splitList(*vendors),
+		Products:          splitList(*products),
+		IncludeDeprecated: *includeDeprecated,
+	}
+	report, err := svc.ImportCPEDictionary(ctx, input, filter, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	printReport(stdout, report)
+	return nil
+}
+
+// printReport prints the items of an import report that carry a reason and
+// its summary.
+func printReport(stdout io.Writer, report internal.ImportReportDTO) {
+	for _, item := range report.Items {
+		if item.Reason != "" {
+			fmt.Fprintf(stdout, "%s %s %s (%s): %s\n", item.Action, item.Category, item.Name, item.ID, item.Reason)
+		}
+	}
+	fmt.Fprintf(stdout, "created %d, matched %d, updated %d, conflicts %d, skipped %d, errors %d\n",
+		report.Summary.Created, report.Summary.Matched, report.Summary.Updated,
+		report.Summary.Conflicts, report.Summary.Skipped, report.Summary.Errors)
+	if report.DryRun {
+		fmt.Fprintln(stdout, "dry run, nothing was persisted")
+	}
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"product-database-api/internal"
+	"product-database-api/internal/database"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newCommandTestService(t *testing.T) *internal.Service {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	testutils.AssertNoError(t, err, "Should connect to test database")
+	database.AutoMigrate(db, internal.Models()...)
+
+	return internal.NewService(internal.NewRepository(db))
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+
+	source := newCommandTestService(t)
+	vendor, err := source.CreateVendor(ctx, internal.CreateVendorDTO{Name: "Command Vendor"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+
+	dumpFile := filepath.Join(t.TempDir(), "dump.json")
+
+	t.Run("Dump", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := runCommand(ctx, source, []string{"dump"}, nil, &stdout)
+		testutils.AssertNoError(t, err, "Dump should succeed")
+		if !strings.Contains(stdout.String(), vendor.ID) {
+			t.Errorf("Expected dump to contain the vendor, got %s", stdout.String())
+		}
+
+		err = runCommand(ctx, source, []string{"dump", "-o", dumpFile}, nil, &stdout)
+		testutils.AssertNoError(t, err, "Dump into file should succeed")
+		data, err := os.ReadFile(dumpFile)
+		testutils.AssertNoError(t, err, "Should read dump file")
+		testutils.AssertEqual(t, true, strings.Contains(string(data), `"format": "product-database-dump"`), "Dump file format")
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		target := newCommandTestService(t)
+
+		var stdout bytes.Buffer
+		err := runCommand(ctx, target, []string{"restore", "-dry-run", dumpFile}, nil, &stdout)
+		testutils.AssertNoError(t, err, "Dry run restore should succeed")
+		testutils.AssertEqual(t, true, strings.Contains(stdout.String(), "created 1,"), "Dry run summary")
+		_, err = target.GetVendorByID(ctx, vendor.ID)
+		if err == nil {
+			t.Error("Dry run must not persist the vendor")
+		}
+
+		data, err := os.ReadFile(dumpFile)
+		testutils.AssertNoError(t, err, "Should read dump file")
+		stdout.Reset()
+		err = runCommand(ctx, target, []string{"restore", "-"}, bytes.NewReader(data), &stdout)
+		testutils.AssertNoError(t, err, "Restore from stdin should succeed")
+		_, err = target.GetVendorByID(ctx, vendor.ID)
+		testutils.AssertNoError(t, err, "Restored vendor should exist")
+
+		stdout.Reset()
+		err = runCommand(ctx, target, []string{"restore", "-mode", "merge", dumpFile}, nil, &stdout)
+		testutils.AssertNoError(t, err, "Merge restore should succeed")
+		testutils.AssertEqual(t, true, strings.Contains(stdout.String(), "matched 1,"), "Merge summary")
+
+		err = runCommand(ctx, target, []string{"restore", dumpFile}, nil, &stdout)
+		if err == nil {
+			t.Error("Restoring into a non-empty database without merge should fail")
+		}
+	})
+
+	t.Run("ImportCPE", func(t *testing.T) {
+		dictionary := `{"products": [
+			{"cpe": {"cpeName": "cpe:2.3:a:gnu:bash:5.2:*:*:*:*:*:*:*", "titles": [{"title": "GNU Bash 5.2", "lang": "en"}]}},
+			{"cpe": {"cpeName": "cpe:2.3:a:gnu:glibc:2.38:*:*:*:*:*:*:*", "titles": [{"title": "GNU glibc 2.38", "lang": "en"}]}}
+		]}`
+
+		var stdout bytes.Buffer
+		err := runCommand(ctx, source, []string{"import-cpe", "-vendor", "gnu", "-product", "bash", "-"}, strings.NewReader(dictionary), &stdout)
+		testutils.AssertNoError(t, err, "CPE import should succeed")
+		testutils.AssertEqual(t, true, strings.Contains(stdout.String(), "created 4,"), "Vendor, product, version and helper")
+
+		if err := runCommand(ctx, source, []string{"import-cpe", "-"}, strings.NewReader(dictionary), &stdout); err == nil {
+			t.Error("CPE import without vendor should fail")
+		}
+	})
+
+	t.Run("InvalidUsage", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := runCommand(ctx, source, []string{"unknown"}, nil, &stdout); err == nil {
+			t.Error("Unknown command should fail")
+		}
+		if err := runCommand(ctx, source, []string{"restore"}, nil, &stdout); err == nil {
+			t.Error("Restore without file should fail")
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"product-database-api/internal/database"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/go-fuego/fuego"
 	"github.com/joho/godotenv"
@@ -48,10 +49,71 @@ func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 	}
 }
 
+// trashPurgeInterval is how often deleted nodes past their retention are
+// purged.
+const trashPurgeInterval = time.Hour
+
+// purgeExpiredTrash purges the trash on startup and then periodically.
+func purgeExpiredTrash(svc *internal.Service) {
+	for {
+		purged, err := svc.PurgeExpiredTrash(context.Background())
+		if err != nil {
+			slog.Error("purging the trash failed", "err", err)
+		} else if purged > 0 {
+			slog.Info("purged expired trash", "count", purged)
+		}
+		time.Sleep(trashPurgeInterval)
+	}
+}
+
 func main() {
 	godotenv.Load()
 
 	db := database.Connect()
+	database.AutoMigrate(db, internal.Models()...)
+	if err := internal.MigrateVersionLineage(db); err != nil {
+		slog.Error("migrating the version lineage failed", "err", err)
+		panic(err)
+	}
+
+	trashRetention, err := internal.TrashRetentionFromEnv()
+	if err != nil {
+		slog.Error("invalid trash retention", "err", err)
+		panic(err)
+	}
+
+	trustProxyHeaders, err := internal.TrustProxyHeadersFromEnv()
+	if err != nil {
+		slog.Error("invalid audit configuration", "err", err)
+		panic(err)
+	}
+
+	repo := internal.NewRepository(db)
+	svc := internal.NewService(repo,
+		internal.WithCSAFConfig(internal.CSAFConfigFromEnv()),
+		internal.WithTrashRetention(trashRetention),
+		internal.WithTrustedProxyHeaders(trustProxyHeaders))
+
+	if err := svc.RebuildLookupIndex(context.Background()); err != nil {
+		slog.Error("rebuilding the lookup index failed", "err", err)
+		panic(err)
+	}
+	if err := svc.RebuildSearchIndex(context.Background()); err != nil {
+		slog.Error("rebuilding the search index failed", "err", err)
+		panic(err)
+	}
+	if err := svc.EnsureRowVersions(context.Background()); err != nil {
+		slog.Error("recording row versions failed", "err", err)
+		panic(err)
+	}
+
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), svc, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+			slog.Error("Command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	corsOrigin := os.Getenv("CORS_ORIGIN")
 	var allowedOrigins []string
@@ -98,13 +160,9 @@ func main() {
 		fuego.WithGlobalMiddlewares(corsMiddleware(allowedOrigins)),
 	)
 
-	database.AutoMigrate(db, internal.Models()...)
-
-	repo := internal.NewRepository(db)
-	svc := internal.NewService(repo)
-
 	internal.RegisterRoutes(s, svc)
 
+	go purgeExpiredTrash(svc)
 	go s.Run()
 	slog.Info("Server is running", "addr", s.Addr)
 
@@ -88,12 +88,16 @@ func TestEnvironmentVariables(t *testing.T) {
 
 func TestModelsRegistration(t *testing.T) {
 	models := internal.Models()
-	testutils.AssertCount(t, 3, len(models), "Should register 3 models")
+	testutils.AssertCount(t, 7, len(models), "Should register 7 models")
 
 	// Verify model types
 	hasNode := false
 	hasRelationship := false
 	hasIdentificationHelper := false
+	hasIdentifierIndex := false
+	hasAuditEntry := false
+	hasRowVersion := false
+	hasTrashedReference := false
 
 	for _, model := range models {
 		switch model.(type) {
@@ -103,10 +107,22 @@ func TestModelsRegistration(t *testing.T) {
 			hasRelationship = true
 		case *internal.IdentificationHelper:
 			hasIdentificationHelper = true
+		case *internal.IdentifierIndex:
+			hasIdentifierIndex = true
+		case *internal.AuditEntry:
+			hasAuditEntry = true
+		case *internal.RowVersion:
+			hasRowVersion = true
+		case *internal.TrashedReference:
+			hasTrashedReference = true
 		}
 	}
 
 	testutils.AssertEqual(t, true, hasNode, "Should include Node model")
 	testutils.AssertEqual(t, true, hasRelationship, "Should include Relationship model")
 	testutils.AssertEqual(t, true, hasIdentificationHelper, "Should include IdentificationHelper model")
+	testutils.AssertEqual(t, true, hasIdentifierIndex, "Should include IdentifierIndex model")
+	testutils.AssertEqual(t, true, hasAuditEntry, "Should include AuditEntry model")
+	testutils.AssertEqual(t, true, hasRowVersion, "Should include RowVersion model")
+	testutils.AssertEqual(t, true, hasTrashedReference, "Should include TrashedReference model")
 }
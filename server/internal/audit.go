@@ -0,0 +1,350 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-fuego/fuego"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit actions.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// Entity types of audit entries.
+const (
+	AuditEntityVendor               = "vendor"
+	AuditEntityProductFamily        = "product_family"
+	AuditEntityProduct              = "product"
+	AuditEntityProductVersion       = "product_version"
+	AuditEntityVersionRange         = "version_range"
+	AuditEntityRelationship         = "relationship"
+	AuditEntityIdentificationHelper = "identification_helper"
+)
+
+var auditNodeEntityTypes = map[NodeCategory]string{
+	Vendor:              AuditEntityVendor,
+	ProductFamily:       AuditEntityProductFamily,
+	ProductName:         AuditEntityProduct,
+	ProductVersion:      AuditEntityProductVersion,
+	ProductVersionRange: AuditEntityVersionRange,
+}
+
+type auditContextKey struct{}
+
+// AuditContext identifies who caused the changes made with a context.
+type AuditContext struct {
+	Actor     string
+	RequestID string
+}
+
+// WithAuditContext returns a context whose changes are recorded with the
+// given actor and request ID.
+func WithAuditContext(ctx context.Context, audit AuditContext) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, audit)
+}
+
+func auditContextFrom(ctx context.Context) AuditContext {
+	audit, _ := ctx.Value(auditContextKey{}).(AuditContext)
+	return audit
+}
+
+// TrustProxyHeadersFromEnv reads the AUDIT_TRUST_PROXY_HEADERS environment
+// variable. Proxy headers are not trusted if it is unset.
+func TrustProxyHeadersFromEnv() (bool, error) {
+	value := os.Getenv("AUDIT_TRUST_PROXY_HEADERS")
+	if value == "" {
+		return false, nil
+	}
+
+	trust, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("AUDIT_TRUST_PROXY_HEADERS must be true or false, got %q", value)
+	}
+	return trust, nil
+}
+
+// WithTrustedProxyHeaders records the user named by an authenticating proxy
+// as actor of the changes of a request. Only enable it if every request
+// passes the proxy, otherwise clients can name any actor.
+func WithTrustedProxyHeaders(trust bool) ServiceOption {
+	return func(s *Service) {
+		s.trustProxyHeaders = trust
+	}
+}
+
+// AuditMiddleware stores the actor and request ID of a request in its
+// context. If trustProxyHeaders is set, the actor is taken from the
+// X-Forwarded-User or X-Remote-User header set by an authenticating proxy,
+// otherwise it is unknown. The request ID is taken from X-Request-ID as set
+// by the client or the request logger.
+func AuditMiddleware(trustProxyHeaders bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			audit := AuditContext{RequestID: r.Header.Get("X-Request-ID")}
+			if trustProxyHeaders {
+				audit.Actor = r.Header.Get("X-Forwarded-User")
+				if audit.Actor == "" {
+					audit.Actor = r.Header.Get("X-Remote-User")
+				}
+			}
+			if audit.RequestID == "" {
+				audit.RequestID = w.Header().Get("X-Request-ID")
+			}
+			next.ServeHTTP(w, r.WithContext(WithAuditContext(r.Context(), audit)))
+		})
+	}
+}
+
+// auditSubject is the entity an audit entry is recorded for.
+type auditSubject struct {
+	Type   string
+	ID     string
+	NodeID string
+}
+
+func nodeAuditSubject(node Node) auditSubject {
+	return auditSubject{Type: auditNodeEntityTypes[node.Category], ID: node.ID, NodeID: node.ID}
+}
+
+func relationshipAuditSubject(rel Relationship) auditSubject {
+	return auditSubject{Type: AuditEntityRelationship, ID: rel.ID, NodeID: rel.SourceNodeID}
+}
+
+func helperAuditSubject(helper IdentificationHelper) auditSubject {
+	return auditSubject{Type: AuditEntityIdentificationHelper, ID: helper.ID, NodeID: helper.NodeID}
+}
+
+func optionalAuditValue(value *string) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func nodeAuditFields(node Node) map[string]any {
+	fields := map[string]any{
+		"name":              node.Name,
+		"description":       node.Description,
+		"parent_id":         optionalAuditValue(node.ParentID),
+		"product_type":      string(node.ProductType),
+		"product_family_id": optionalAuditValue(node.ProductFamilyID),
+		"predecessor_id":    optionalAuditValue(node.PredecessorID),
+		"version_scheme":    string(node.VersionScheme),
+		"version_pattern":   node.VersionPattern,
+		"support_status":    string(node.SupportStatus),
+	}
+	for field, date := range map[string]sql.NullTime{
+		"released_at":             node.ReleasedAt,
+		"end_of_support":          node.EndOfSupport,
+		"end_of_security_updates": node.EndOfSecurityUpdates,
+		"end_of_life":             node.EndOfLife,
+	} {
+		fields[field] = nil
+		if date.Valid {
+			fields[field] = date.Time.Format("2006-01-02")
+		}
+	}
+	return fields
+}
+
+func relationshipAuditFields(rel Relationship) map[string]any {
+	return map[string]any{
+		"category":       string(rel.Category),
+		"source_node_id": rel.SourceNodeID,
+		"target_node_id": rel.TargetNodeID,
+	}
+}
+
+func helperAuditFields(helper IdentificationHelper) map[string]any {
+	fields := map[string]any{
+		"category": string(helper.Category),
+		"metadata": nil,
+	}
+	if json.Valid(helper.Metadata) {
+		fields["metadata"] = json.RawMessage(helper.Metadata)
+	} else if len(helper.Metadata) > 0 {
+		fields["metadata"] = string(helper.Metadata)
+	}
+	return fields
+}
+
+func isEmptyAuditValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case json.RawMessage:
+		return len(v) == 0
+	}
+	return false
+}
+
+// diffAuditFields returns the changed fields, sorted by name. Before is nil
+// for creates and after is nil for deletes, empty values are left out then.
+func diffAuditFields(before, after map[string]any) []AuditChange {
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	var changes []AuditChange
+	for name := range names {
+		old, new := before[name], after[name]
+		if isEmptyAuditValue(old) && isEmptyAuditValue(new) {
+			continue
+		}
+		if reflect.DeepEqual(old, new) {
+			continue
+		}
+		if isEmptyAuditValue(old) {
+			old = nil
+		}
+		if isEmptyAuditValue(new) {
+			new = nil
+		}
+		changes = append(changes, AuditChange{Field: name, Before: old, After: new})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// recordAudit writes an audit entry for a change of subject within tx.
+// Updates without any changed field are not recorded.
+func recordAudit(ctx context.Context, tx *gorm.DB, action string, subject auditSubject, before, after map[string]any) error {
+	changes := diffAuditFields(before, after)
+	if action == AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	audit := auditContextFrom(ctx)
+	entry := AuditEntry{
+		ID:         uuid.New().String(),
+		EntityType: subject.Type,
+		EntityID:   subject.ID,
+		NodeID:     subject.NodeID,
+		Action:     action,
+		Changes:    changes,
+		Actor:      audit.Actor,
+		RequestID:  audit.RequestID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	return tx.Create(&entry).Error
+}
+
+// HistoryQuery restricts the entries of a history to a time window and an
+// actor. Zero values do not filter.
+type HistoryQuery struct {
+	Since time.Time
+	Until time.Time
+	Actor string
+}
+
+// History returns the audit entries of an entity, newest first. The history
+// of a vendor, product family, product, version or version range includes
+// the changes of its identification helpers and outgoing relationships.
+func (s *Service) History(ctx context.Context, entityType, id string, query HistoryQuery) ([]AuditEntryDTO, error) {
+	if !query.Since.IsZero() && !query.Until.IsZero() && query.Since.After(query.Until) {
+		return nil, fuego.BadRequestError{
+			Title: "Invalid history query",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "since",
+					Reason: "since must not be after until",
+				},
+			},
+		}
+	}
+
+	auditQuery := AuditQuery{
+		EntityID: id,
+		Since:    query.Since,
+		Until:    query.Until,
+		Actor:    query.Actor,
+	}
+	if entityType != AuditEntityRelationship && entityType != AuditEntityIdentificationHelper {
+		auditQuery.EntityID, auditQuery.NodeID = "", id
+	}
+
+	entries, err := s.repo.GetAuditEntries(ctx, auditQuery)
+	if err != nil {
+		return nil, fuego.InternalServerError{
+			Title: "Failed to fetch history",
+			Err:   err,
+		}
+	}
+
+	history := make([]AuditEntryDTO, 0, len(entries))
+	for _, entry := range entries {
+		// Node IDs are unique across categories, but a node must not show the
+		// history of an entity of another type with the same ID
+		if entry.EntityID == id && entry.EntityType != entityType {
+			continue
+		}
+		history = append(history, AuditEntryToDTO(entry))
+	}
+
+	return history, nil
+}
+
+// parseHistoryTime parses a history time bound given as RFC 3339 timestamp
+// or as date. A date as upper bound includes the whole day.
+func parseHistoryTime(value string, upper bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// historyQuery reads the time window and actor of the history endpoints.
+func historyQuery(c fuego.ContextNoBody) (HistoryQuery, error) {
+	var errorItems []fuego.ErrorItem
+	since, err := parseHistoryTime(c.QueryParam("since"), false)
+	if err != nil {
+		errorItems = append(errorItems, fuego.ErrorItem{Name: "since", Reason: "since must be an RFC 3339 timestamp or a date"})
+	}
+	until, err := parseHistoryTime(c.QueryParam("until"), true)
+	if err != nil {
+		errorItems = append(errorItems, fuego.ErrorItem{Name: "until", Reason: "until must be an RFC 3339 timestamp or a date"})
+	}
+	if len(errorItems) > 0 {
+		return HistoryQuery{}, fuego.BadRequestError{
+			Title:  "Invalid history query",
+			Errors: errorItems,
+		}
+	}
+
+	return HistoryQuery{Since: since, Until: until, Actor: c.QueryParam("actor")}, nil
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestAuditLog(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := WithAuditContext(context.Background(), AuditContext{Actor: "alice", RequestID: "req-1"})
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+
+	bob := WithAuditContext(context.Background(), AuditContext{Actor: "bob"})
+	name := "Acme Corp"
+	_, err = svc.UpdateVendor(bob, vendor.ID, UpdateVendorDTO{Name: &name})
+	testutils.AssertNoError(t, err, "Should update vendor")
+	_, err = svc.UpdateVendor(bob, vendor.ID, UpdateVendorDTO{Name: &name})
+	testutils.AssertNoError(t, err, "Should update vendor without changes")
+
+	helper, err := svc.CreateIdentificationHelper(bob, CreateIdentificationHelperDTO{
+		ProductVersionID: version.ID,
+		Category:         "cpe",
+		Metadata:         `{"cpe": "cpe:2.3:h:acme:router:1.0:*:*:*:*:*:*:*"}`,
+	})
+	testutils.AssertNoError(t, err, "Should create identification helper")
+
+	history := func(t *testing.T, entityType, id string, query HistoryQuery) []AuditEntryDTO {
+		entries, err := svc.History(context.Background(), entityType, id, query)
+		testutils.AssertNoError(t, err, "History should succeed")
+		return entries
+	}
+	changes := func(entry AuditEntryDTO) string {
+		fields := make([]string, len(entry.Changes))
+		for i, change := range entry.Changes {
+			fields[i] = fmt.Sprintf("%s:%v->%v", change.Field, change.Before, change.After)
+		}
+		return strings.Join(fields, ",")
+	}
+
+	t.Run("Vendor", func(t *testing.T) {
+		entries := history(t, AuditEntityVendor, vendor.ID, HistoryQuery{})
+		testutils.AssertCount(t, 2, len(entries), "Unchanged update is not recorded")
+
+		testutils.AssertEqual(t, AuditUpdate, entries[0].Action, "Newest entry first")
+		testutils.AssertEqual(t, "bob", entries[0].Actor, "Actor")
+		testutils.AssertEqual(t, "name:Acme->Acme Corp", changes(entries[0]), "Changes")
+
+		testutils.AssertEqual(t, AuditCreate, entries[1].Action, "Create")
+		testutils.AssertEqual(t, "alice", entries[1].Actor, "Actor")
+		testutils.AssertEqual(t, "req-1", entries[1].RequestID, "Request ID")
+		testutils.AssertEqual(t, "name:<nil>->Acme", changes(entries[1]), "Only set fields are recorded")
+	})
+
+	t.Run("VersionIncludesHelpers", func(t *testing.T) {
+		entries := history(t, AuditEntityProductVersion, version.ID, HistoryQuery{})
+		testutils.AssertCount(t, 2, len(entries), "Entries")
+		testutils.AssertEqual(t, AuditEntityIdentificationHelper, entries[0].EntityType, "Helper entry")
+		testutils.AssertEqual(t, helper.ID, entries[0].EntityID, "Helper ID")
+
+		entries = history(t, AuditEntityIdentificationHelper, helper.ID, HistoryQuery{})
+		testutils.AssertCount(t, 1, len(entries), "Helper entries")
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		testutils.AssertCount(t, 1, len(history(t, AuditEntityVendor, vendor.ID, HistoryQuery{Actor: "alice"})), "Actor filter")
+		testutils.AssertCount(t, 0, len(history(t, AuditEntityVendor, vendor.ID, HistoryQuery{Since: time.Now().Add(time.Hour)})), "Since filter")
+		testutils.AssertCount(t, 0, len(history(t, AuditEntityVendor, vendor.ID, HistoryQuery{Until: time.Now().Add(-time.Hour)})), "Until filter")
+		testutils.AssertCount(t, 2, len(history(t, AuditEntityVendor, vendor.ID, HistoryQuery{Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour)})), "Time window")
+		testutils.AssertCount(t, 0, len(history(t, AuditEntityProduct, vendor.ID, HistoryQuery{})), "Other entity type")
+
+		_, err := svc.History(context.Background(), AuditEntityVendor, vendor.ID, HistoryQuery{Since: time.Now(), Until: time.Now().Add(-time.Hour)})
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Errorf("Expected BadRequestError, got %v", err)
+		}
+	})
+
+	t.Run("DeleteKeepsHistory", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteProductVersion(bob, version.ID), "Should delete version")
+
+		entries := history(t, AuditEntityProductVersion, version.ID, HistoryQuery{})
+		testutils.AssertEqual(t, AuditDelete, entries[0].Action, "Delete")
+		testutils.AssertEqual(t, "name:1.0-><nil>,parent_id:"+product.ID+"-><nil>", changes(entries[0]), "Deleted values")
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, NewService(NewRepository(db), WithTrustedProxyHeaders(true)))
+
+		body := strings.NewReader(`{"name": "Switch", "vendor_id": "` + vendor.ID + `", "type": "hardware"}`)
+		req := httptest.NewRequest("POST", "/api/v1/products", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-User", "carol")
+		req.Header.Set("X-Request-ID", "req-2")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code of create")
+
+		var created ProductDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &created), "Should decode product")
+
+		req = httptest.NewRequest("GET", "/api/v1/products/"+created.ID+"/history?actor=carol&since=2000-01-01", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var entries []AuditEntryDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &entries), "Should decode history")
+		testutils.AssertCount(t, 1, len(entries), "Entries")
+		testutils.AssertEqual(t, "carol", entries[0].Actor, "Actor from proxy header")
+		testutils.AssertEqual(t, "req-2", entries[0].RequestID, "Request ID from header")
+
+		req = httptest.NewRequest("GET", "/api/v1/vendors/"+vendor.ID+"/history?until=yesterday", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusBadRequest, w.Code, "Status code of invalid time")
+	})
+
+	t.Run("UntrustedProxyHeaders", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		body := strings.NewReader(`{"name": "Firewall", "vendor_id": "` + vendor.ID + `", "type": "hardware"}`)
+		req := httptest.NewRequest("POST", "/api/v1/products", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-User", "mallory")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code of create")
+
+		var created ProductDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &created), "Should decode product")
+
+		entries := history(t, AuditEntityProduct, created.ID, HistoryQuery{})
+		testutils.AssertCount(t, 1, len(entries), "Entries")
+		testutils.AssertEqual(t, "", entries[0].Actor, "Actor without trusted proxy")
+	})
+}
+
+func TestTrustProxyHeadersFromEnv(t *testing.T) {
+	defer os.Unsetenv("AUDIT_TRUST_PROXY_HEADERS")
+
+	trust, err := TrustProxyHeadersFromEnv()
+	testutils.AssertNoError(t, err, "Unset")
+	testutils.AssertEqual(t, false, trust, "Default")
+
+	os.Setenv("AUDIT_TRUST_PROXY_HEADERS", "true")
+	trust, err = TrustProxyHeadersFromEnv()
+	testutils.AssertNoError(t, err, "True")
+	testutils.AssertEqual(t, true, trust, "Trusted")
+
+	os.Setenv("AUDIT_TRUST_PROXY_HEADERS", "sometimes")
+	_, err = TrustProxyHeadersFromEnv()
+	testutils.AssertError(t, err, "Invalid value")
+}
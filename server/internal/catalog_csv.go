@@ -0,0 +1,545 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+// catalogCSVColumns is the header of the catalog CSV. Families are written as
+// path, e.g. "Network / Wireless", with "/" and "\" in family names escaped
+// by "\". Multiple SKUs are separated by "|".
+var catalogCSVColumns = []string{"vendor", "product", "product_type", "family", "version", "released_at", "cpe", "purl", "skus"}
+
+const (
+	catalogCSVFamilySeparator = "/"
+	catalogCSVListSeparator   = "|"
+)
+
+// catalogCSVRow is a single line of the catalog CSV.
+type catalogCSVRow struct {
+	Vendor      string
+	Product     string
+	ProductType string
+	Family      []string
+	Version     string
+	ReleasedAt  string
+	CPE         string
+	Purl        string
+	SKUs        []string
+}
+
+func (r catalogCSVRow) record() []string {
+	family := make([]string, len(r.Family))
+	for i, name := range r.Family {
+		family[i] = escapeCatalogCSVFamily(name)
+	}
+
+	record := []string{
+		r.Vendor,
+		r.Product,
+		r.ProductType,
+		strings.Join(family, " "+catalogCSVFamilySeparator+" "),
+		r.Version,
+		r.ReleasedAt,
+		r.CPE,
+		r.Purl,
+		strings.Join(r.SKUs, catalogCSVListSeparator),
+	}
+	for i, value := range record {
+		record[i] = escapeCatalogCSVFormula(value)
+	}
+	return record
+}
+
+// catalogCSVFormulaPrefixes start cells that spreadsheets evaluate as
+// formulas.
+const catalogCSVFormulaPrefixes = "=+-@\t\r"
+
+// escapeCatalogCSVFormula prefixes cells that would be evaluated as formulas
+// with "'", so opening an export in a spreadsheet cannot run them.
+func escapeCatalogCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune(catalogCSVFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCatalogCSVFormula removes the prefix added by
+// escapeCatalogCSVFormula.
+func unescapeCatalogCSVFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(catalogCSVFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+func escapeCatalogCSVFamily(name string) string {
+	name = strings.ReplaceAll(name, "\\", "\\\\")
+	return strings.ReplaceAll(name, catalogCSVFamilySeparator, "\\"+catalogCSVFamilySeparator)
+}
+
+// splitCatalogCSVFamily splits a family path at the separators that are not
+// escaped.
+func splitCatalogCSVFamily(path string) []string {
+	var names []string
+	var name strings.Builder
+	escaped := false
+	for _, r := range path {
+		switch {
+		case escaped:
+			name.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case string(r) == catalogCSVFamilySeparator:
+			names = append(names, name.String())
+			name.Reset()
+		default:
+			name.WriteRune(r)
+		}
+	}
+	return append(names, name.String())
+}
+
+// ExportCatalogCSV writes all vendors, products, versions and their CPE, purl
+// and SKU identification helpers as CSV. Every version is a row, vendors
+// without products and products without versions get a row of their own.
+func (s *Service) ExportCatalogCSV(ctx context.Context) ([]byte, error) {
+	nodes := make(map[NodeCategory][]Node)
+	for _, category := range []NodeCategory{Vendor, ProductFamily, ProductName, ProductVersion} {
+		categoryNodes, err := s.repo.GetNodesByCategory(ctx, category)
+		if err != nil {
+			return nil, fuego.InternalServerError{
+				Title: "Failed to fetch catalog",
+				Err:   err,
+			}
+		}
+		nodes[category] = categoryNodes
+	}
+
+	helpers := make(map[string][]IdentificationHelper)
+	for _, category := range []string{"cpe", "purl", "sku"} {
+		categoryHelpers, err := s.repo.GetIdentificationHelpersByCategory(ctx, category)
+		if err != nil {
+			return nil, fuego.InternalServerError{
+				Title: "Failed to fetch identification helpers",
+				Err:   err,
+			}
+		}
+		for _, helper := range categoryHelpers {
+			helpers[helper.NodeID] = append(helpers[helper.NodeID], helper)
+		}
+	}
+
+	families := make(map[string]Node, len(nodes[ProductFamily]))
+	for _, family := range nodes[ProductFamily] {
+		families[family.ID] = family
+	}
+
+	children := make(map[string][]Node)
+	for _, category := range []NodeCategory{ProductName, ProductVersion} {
+		for _, node := range nodes[category] {
+			if node.ParentID != nil {
+				children[*node.ParentID] = append(children[*node.ParentID], node)
+			}
+		}
+	}
+	for _, list := range children {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Category == ProductVersion {
+				return compareVersions(list[i].Name, list[j].Name) < 0
+			}
+			return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+		})
+	}
+
+	vendors := nodes[Vendor]
+	sort.Slice(vendors, func(i, j int) bool {
+		return strings.ToLower(vendors[i].Name) < strings.ToLower(vendors[j].Name)
+	})
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(catalogCSVColumns); err != nil {
+		return nil, fuego.InternalServerError{Title: "Failed to write CSV", Err: err}
+	}
+
+	for _, vendor := range vendors {
+		var rows []catalogCSVRow
+
+		for _, product := range children[vendor.ID] {
+			row := catalogCSVRow{
+				Vendor:      vendor.Name,
+				Product:     product.Name,
+				ProductType: string(product.ProductType),
+			}
+			if product.ProductFamilyID != nil {
+				row.Family = familyPath(families, *product.ProductFamilyID)
+			}
+
+			versions := children[product.ID]
+			if len(versions) == 0 {
+				rows = append(rows, row)
+				continue
+			}
+
+			for _, version := range versions {
+				versionRow := row
+				versionRow.Version = version.Name
+				if version.ReleasedAt.Valid {
+					versionRow.ReleasedAt = version.ReleasedAt.Time.Format("2006-01-02")
+				}
+				versionRow.setIdentifiers(helpers[version.ID])
+				rows = append(rows, versionRow)
+			}
+		}
+
+		if len(rows) == 0 {
+			rows = append(rows, catalogCSVRow{Vendor: vendor.Name})
+		}
+
+		for _, row := range rows {
+			if err := writer.Write(row.record()); err != nil {
+				return nil, fuego.InternalServerError{Title: "Failed to write CSV", Err: err}
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fuego.InternalServerError{Title: "Failed to write CSV", Err: err}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (r *catalogCSVRow) setIdentifiers(helpers []IdentificationHelper) {
+	for _, helper := range helpers {
+		var metadata struct {
+			CPE  string   `json:"cpe"`
+			Purl string   `json:"purl"`
+			SKUs []string `json:"skus"`
+		}
+		if json.Unmarshal(helper.Metadata, &metadata) != nil {
+			continue
+		}
+
+		switch helper.Category {
+		case "cpe":
+			if r.CPE == "" {
+				r.CPE = metadata.CPE
+			}
+		case "purl":
+			if r.Purl == "" {
+				r.Purl = metadata.Purl
+			}
+		case "sku":
+			r.SKUs = append(r.SKUs, metadata.SKUs...)
+		}
+	}
+}
+
+// familyPath returns the names of a family and all of its parents, starting
+// with the root family.
+func familyPath(families map[string]Node, id string) []string {
+	var path []string
+	visited := make(map[string]bool)
+	for current, ok := families[id]; ok && !visited[current.ID]; {
+		visited[current.ID] = true
+		path = append([]string{current.Name}, path...)
+		if current.ParentID == nil {
+			break
+		}
+		current, ok = families[*current.ParentID]
+	}
+	return path
+}
+
+// ImportCatalogCSV upserts the rows of a catalog CSV by vendor, product and
+// version name. Product type, family, release date and identification
+// helpers of existing entries are updated. Invalid rows are reported with
+// their line number and skipped, all other rows are imported.
+func (s *Service) ImportCatalogCSV(ctx context.Context, data []byte, dryRun bool) (ImportReportDTO, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid CSV",
+			Err:   err,
+		}
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["vendor"]; !ok {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid CSV",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "vendor",
+					Reason: "The header must contain a vendor column",
+				},
+			},
+		}
+	}
+
+	return s.runImport(ctx, "Failed to import CSV", dryRun, func(repo Repository, report *ImportReportDTO) error {
+		importer, err := newNodeImporter(ctx, repo, report)
+		if err != nil {
+			return err
+		}
+
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				importer.row = parseErr.StartLine
+				importer.add(ImportActionError, "row", "", nil, "", parseErr.Err.Error())
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			importer.row, _ = reader.FieldPos(0)
+			if err := importer.importCatalogCSVRecord(record, columns); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (im *nodeImporter) importCatalogCSVRecord(record []string, columns map[string]int) error {
+	if strings.TrimSpace(strings.Join(record, "")) == "" {
+		return nil
+	}
+
+	value := func(column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return unescapeCatalogCSVFormula(strings.TrimSpace(record[i]))
+		}
+		return ""
+	}
+
+	row := catalogCSVRow{
+		Vendor:      value("vendor"),
+		Product:     value("product"),
+		ProductType: strings.ToLower(value("product_type")),
+		Version:     value("version"),
+		ReleasedAt:  value("released_at"),
+		CPE:         value("cpe"),
+		Purl:        value("purl"),
+	}
+	for _, name := range splitCatalogCSVFamily(value("family")) {
+		if name = strings.TrimSpace(name); name != "" {
+			row.Family = append(row.Family, name)
+		}
+	}
+	for _, sku := range strings.Split(value("skus"), catalogCSVListSeparator) {
+		if sku = strings.TrimSpace(sku); sku != "" {
+			row.SKUs = append(row.SKUs, sku)
+		}
+	}
+
+	var releasedAt sql.NullTime
+	invalid := false
+	fail := func(column, value, reason string) {
+		im.add(ImportActionError, column, value, nil, "", reason)
+		invalid = true
+	}
+
+	if row.Vendor == "" {
+		fail("vendor", "", "Vendor is required")
+	}
+	if row.Product == "" && (row.ProductType != "" || len(row.Family) > 0 || row.Version != "") {
+		fail("product", "", "Product is required for product type, family and version")
+	}
+	switch ProductType(row.ProductType) {
+	case "", Software, Hardware, Firmware:
+	default:
+		fail("product_type", row.ProductType, "Product type must be software, hardware or firmware")
+	}
+	if row.Version == "" && (row.ReleasedAt != "" || row.CPE != "" || row.Purl != "" || len(row.SKUs) > 0) {
+		fail("version", "", "Version is required for release date and identification helpers")
+	}
+	if row.ReleasedAt != "" {
+		parsed, err := time.Parse("2006-01-02", row.ReleasedAt)
+		if err != nil {
+			fail("released_at", row.ReleasedAt, "Release date must be in YYYY-MM-DD format")
+		}
+		releasedAt = sql.NullTime{Time: parsed, Valid: err == nil}
+	}
+	if row.CPE != "" && !strings.HasPrefix(strings.ToLower(row.CPE), "cpe:") {
+		fail("cpe", row.CPE, "CPE must start with 'cpe:'")
+	}
+	if row.Purl != "" {
+		if _, err := parsePurl(row.Purl); err != nil {
+			fail("purl", row.Purl, "purl is invalid: "+err.Error())
+		}
+	}
+	if invalid {
+		return nil
+	}
+
+	path := []string{row.Vendor}
+	vendor, err := im.importVendor(row.Vendor, path)
+	if err != nil || row.Product == "" {
+		return err
+	}
+
+	var family *Node
+	for i, name := range row.Family {
+		created, err := im.importFamily(name, family, row.Family[:i+1])
+		if err != nil {
+			return err
+		}
+		family = &created
+	}
+
+	productNode := Node{
+		Name:        row.Product,
+		Category:    ProductName,
+		ParentID:    &vendor.ID,
+		ProductType: Software,
+	}
+	if row.ProductType != "" {
+		productNode.ProductType = ProductType(row.ProductType)
+	}
+	if family != nil {
+		productNode.ProductFamilyID = &family.ID
+	}
+
+	path = append(path, row.Product)
+	product, created, err := im.matchOrCreate(productNode, path)
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		changed := false
+		if row.ProductType != "" && product.ProductType != ProductType(row.ProductType) {
+			product.ProductType = ProductType(row.ProductType)
+			changed = true
+		}
+		if family != nil && (product.ProductFamilyID == nil || *product.ProductFamilyID != family.ID) {
+			product.ProductFamilyID = &family.ID
+			changed = true
+		}
+		if changed {
+			if err := im.update(product, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if row.Version == "" {
+		return nil
+	}
+
+	path = append(path, row.Version)
+	version, created, err := im.matchOrCreate(Node{
+		Name:       row.Version,
+		Category:   ProductVersion,
+		ParentID:   &product.ID,
+		ReleasedAt: releasedAt,
+	}, path)
+	if err != nil {
+		return err
+	}
+
+	if !created && releasedAt.Valid && (!version.ReleasedAt.Valid || !version.ReleasedAt.Time.Equal(releasedAt.Time)) {
+		version.ReleasedAt = releasedAt
+		if err := im.update(version, path); err != nil {
+			return err
+		}
+	}
+
+	var helpers []sbomHelper
+	if row.CPE != "" {
+		helpers = append(helpers, sbomHelper{"cpe", map[string]interface{}{"cpe": row.CPE}})
+	}
+	if row.Purl != "" {
+		helpers = append(helpers, sbomHelper{"purl", map[string]interface{}{"purl": row.Purl}})
+	}
+	if len(row.SKUs) > 0 {
+		helpers = append(helpers, sbomHelper{"sku", map[string]interface{}{"skus": row.SKUs}})
+	}
+
+	for _, helper := range helpers {
+		metadata, err := json.Marshal(helper.metadata)
+		if err != nil {
+			return err
+		}
+		if err := im.upsertHelper(version, helper.category, metadata, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// update stores a changed node and replaces it in the importer's cache.
+func (im *nodeImporter) update(node Node, path []string) error {
+	if err := im.repo.UpdateNode(im.ctx, node); err != nil {
+		return err
+	}
+
+	for i, cached := range im.nodes[node.Category] {
+		if cached.ID == node.ID {
+			im.nodes[node.Category][i] = node
+		}
+	}
+
+	im.add(ImportActionUpdated, string(node.Category), node.Name, path, node.ID, "")
+	return nil
+}
+
+// upsertHelper attaches an identification helper to a version or replaces
+// the metadata of an existing helper of the same category.
+func (im *nodeImporter) upsertHelper(version Node, category string, metadata []byte, path []string) error {
+	existing, err := im.repo.GetIdentificationHelpersByProductVersion(im.ctx, version.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, helper := range existing {
+		if string(helper.Category) != category {
+			continue
+		}
+		if jsonEqual(helper.Metadata, metadata) {
+			im.add(ImportActionMatched, "identification_helper", category, path, helper.ID, "")
+			return nil
+		}
+
+		helper.Metadata = metadata
+		if err := im.repo.UpdateIdentificationHelper(im.ctx, helper); err != nil {
+			return err
+		}
+		im.add(ImportActionUpdated, "identification_helper", category, path, helper.ID, "")
+		return nil
+	}
+
+	return im.importHelper(version, category, metadata, path)
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+const testCatalogCSV = `vendor,product,product_type,family,version,released_at,cpe,purl,skus
+Acme,Cloud,software,,,,,,
+Acme,Router,hardware,Network / Wireless,1.0.0,2024-01-15,cpe:2.3:h:acme:router:1.0.0:*:*:*:*:*:*:*,,R-100|R-100-EU
+Acme,Router,hardware,Network / Wireless,2.0.0,,,pkg:generic/acme/router@2.0.0,
+Globex,,,,,,,,
+`
+
+func TestCatalogCSV(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	repo := NewRepository(db)
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	countAction := func(report ImportReportDTO, action, category string) int {
+		count := 0
+		for _, item := range report.Items {
+			if item.Action == action && item.Category == category {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := svc.ImportCatalogCSV(ctx, []byte(testCatalogCSV), true)
+		testutils.AssertNoError(t, err, "Dry run should succeed")
+		testutils.AssertEqual(t, true, report.DryRun, "Dry run flag")
+		testutils.AssertEqual(t, 2, countAction(report, ImportActionCreated, string(ProductVersion)), "Versions to create")
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 0, len(vendors), "Dry run must not persist vendors")
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		report, err := svc.ImportCatalogCSV(ctx, []byte(testCatalogCSV), false)
+		testutils.AssertNoError(t, err, "Import should succeed")
+		testutils.AssertEqual(t, 0, report.Summary.Errors, "Errors")
+		testutils.AssertEqual(t, 2, countAction(report, ImportActionCreated, string(Vendor)), "Created vendors")
+		testutils.AssertEqual(t, 2, countAction(report, ImportActionCreated, string(ProductFamily)), "Created families")
+		testutils.AssertEqual(t, 2, countAction(report, ImportActionCreated, string(ProductName)), "Created products")
+		testutils.AssertEqual(t, 2, countAction(report, ImportActionCreated, string(ProductVersion)), "Created versions")
+		testutils.AssertEqual(t, 3, countAction(report, ImportActionCreated, "identification_helper"), "Created helpers")
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		data, err := svc.ExportCatalogCSV(ctx)
+		testutils.AssertNoError(t, err, "Export should succeed")
+		testutils.AssertEqual(t, testCatalogCSV, string(data), "Exported CSV")
+	})
+
+	t.Run("ReimportMatches", func(t *testing.T) {
+		data, err := svc.ExportCatalogCSV(ctx)
+		testutils.AssertNoError(t, err, "Export should succeed")
+
+		report, err := svc.ImportCatalogCSV(ctx, data, false)
+		testutils.AssertNoError(t, err, "Import should succeed")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Created")
+		testutils.AssertEqual(t, 0, report.Summary.Updated, "Updated")
+		testutils.AssertEqual(t, 0, report.Summary.Errors, "Errors")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		input := "vendor,product,product_type,version,released_at,skus\n" +
+			"Acme,Router,firmware,1.0.0,2024-02-01,R-200\n"
+
+		report, err := svc.ImportCatalogCSV(ctx, []byte(input), false)
+		testutils.AssertNoError(t, err, "Import should succeed")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Created")
+		testutils.AssertEqual(t, 3, report.Summary.Updated, "Updated")
+
+		data, err := svc.ExportCatalogCSV(ctx)
+		testutils.AssertNoError(t, err, "Export should succeed")
+		if !strings.Contains(string(data), "Acme,Router,firmware,Network / Wireless,1.0.0,2024-02-01,cpe:2.3:h:acme:router:1.0.0:*:*:*:*:*:*:*,,R-200\n") {
+			t.Errorf("Expected updated row in export, got:\n%s", data)
+		}
+	})
+
+	t.Run("RowErrors", func(t *testing.T) {
+		input := "vendor,product,product_type,version,released_at,cpe\n" +
+			",Orphan,,,,\n" +
+			"Acme,Switch,appliance,1.0,,\n" +
+			"Acme,Switch,,1.0,15.01.2024,foo\n" +
+			"Acme,Switch,hardware,2.0,2024-03-01,\n"
+
+		report, err := svc.ImportCatalogCSV(ctx, []byte(input), false)
+		testutils.AssertNoError(t, err, "Import should succeed despite invalid rows")
+		testutils.AssertEqual(t, 4, report.Summary.Errors, "Errors")
+		testutils.AssertEqual(t, 1, countAction(report, ImportActionCreated, string(ProductVersion)), "Created versions")
+
+		rows := make(map[string]int)
+		for _, item := range report.Items {
+			if item.Action == ImportActionError {
+				rows[item.Category] = item.Row
+			}
+		}
+		testutils.AssertEqual(t, 2, rows["vendor"], "Row of missing vendor")
+		testutils.AssertEqual(t, 3, rows["product_type"], "Row of invalid product type")
+		testutils.AssertEqual(t, 4, rows["released_at"], "Row of invalid release date")
+		testutils.AssertEqual(t, 4, rows["cpe"], "Row of invalid CPE")
+
+		for _, item := range report.Items {
+			if item.Action == ImportActionCreated && item.Row != 5 {
+				t.Errorf("Expected created items in row 5, got %d for %s", item.Row, item.Category)
+			}
+		}
+	})
+
+	t.Run("EscapedCells", func(t *testing.T) {
+		vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "=HYPERLINK(\"x\")"})
+		testutils.AssertNoError(t, err, "Should create vendor")
+		parent, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: `Back\slash`})
+		testutils.AssertNoError(t, err, "Should create family")
+		family, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "TCP/IP", ParentID: &parent.ID})
+		testutils.AssertNoError(t, err, "Should create family")
+		product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "@Stack", VendorID: vendor.ID, Type: "software", FamilyID: &family.ID})
+		testutils.AssertNoError(t, err, "Should create product")
+		_, err = svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "-1", ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+
+		data, err := svc.ExportCatalogCSV(ctx)
+		testutils.AssertNoError(t, err, "Export should succeed")
+		row := `"'=HYPERLINK(""x"")",'@Stack,software,Back\\slash / TCP\/IP,'-1,,,,`
+		if !strings.Contains(string(data), row+"\n") {
+			t.Errorf("Expected escaped row in export, got:\n%s", data)
+		}
+
+		report, err := svc.ImportCatalogCSV(ctx, data, false)
+		testutils.AssertNoError(t, err, "Import should succeed")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Created")
+		testutils.AssertEqual(t, 0, report.Summary.Updated, "Updated")
+		testutils.AssertEqual(t, 0, report.Summary.Errors, "Errors")
+	})
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		_, err := svc.ImportCatalogCSV(ctx, []byte("name,version\nRouter,1.0\n"), false)
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Errorf("Expected BadRequestError, got %v", err)
+		}
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("POST", "/api/v1/catalog/csv?dry_run=true", bytes.NewBufferString("vendor,product\nInitech,Printer\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Import status code")
+
+		var report ImportReportDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &report), "Should decode report")
+		testutils.AssertEqual(t, true, report.DryRun, "Dry run flag")
+		testutils.AssertEqual(t, 2, report.Summary.Created, "Created")
+
+		req = httptest.NewRequest("GET", "/api/v1/catalog/csv", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Export status code")
+		testutils.AssertEqual(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"), "Content type")
+		if strings.Contains(w.Body.String(), "Initech") {
+			t.Error("Dry run must not persist the imported vendor")
+		}
+	})
+}
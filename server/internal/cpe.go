@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cpeName is a CPE 2.3 formatted string split into its eleven attributes,
+// e.g. "cpe:2.3:a:openssl:openssl:3.0.13:*:*:*:*:*:*:*". The attributes are
+// kept as written, including escapes and the logical values "*" and "-".
+type cpeName struct {
+	Part      string
+	Vendor    string
+	Product   string
+	Version   string
+	Update    string
+	Edition   string
+	Language  string
+	SWEdition string
+	TargetSW  string
+	TargetHW  string
+	Other     string
+}
+
+// parseCPE23 splits a CPE 2.3 formatted string at its unescaped colons.
+// Missing trailing attributes default to "*".
+func parseCPE23(cpe string) (cpeName, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(cpe), "cpe:2.3:")
+	if !ok {
+		return cpeName{}, fmt.Errorf("CPE must start with 'cpe:2.3:'")
+	}
+
+	var attributes []string
+	var current strings.Builder
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			current.WriteByte(rest[i])
+			if i+1 < len(rest) {
+				i++
+				current.WriteByte(rest[i])
+			}
+		case ':':
+			attributes = append(attributes, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(rest[i])
+		}
+	}
+	attributes = append(attributes, current.String())
+
+	if len(attributes) > 11 {
+		return cpeName{}, fmt.Errorf("CPE has more than 11 attributes")
+	}
+	for len(attributes) < 11 {
+		attributes = append(attributes, "*")
+	}
+	for i, attribute := range attributes {
+		if attribute == "" {
+			return cpeName{}, fmt.Errorf("CPE attribute %d is empty", i+1)
+		}
+	}
+
+	switch attributes[0] {
+	case "a", "o", "h", "*", "-":
+	default:
+		return cpeName{}, fmt.Errorf("invalid CPE part %q", attributes[0])
+	}
+
+	return cpeName{
+		Part:      attributes[0],
+		Vendor:    attributes[1],
+		Product:   attributes[2],
+		Version:   attributes[3],
+		Update:    attributes[4],
+		Edition:   attributes[5],
+		Language:  attributes[6],
+		SWEdition: attributes[7],
+		TargetSW:  attributes[8],
+		TargetHW:  attributes[9],
+		Other:     attributes[10],
+	}, nil
+}
+
+// cpeUnescape removes the escaping backslashes of a CPE attribute, e.g.
+// "node\.js" becomes "node.js".
+func cpeUnescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		result.WriteByte(value[i])
+	}
+	return result.String()
+}
+
+// cpeIsLogical reports whether an attribute is ANY ("*") or NA ("-").
+func cpeIsLogical(value string) bool {
+	return value == "*" || value == "-"
+}
@@ -0,0 +1,353 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+)
+
+// CPEDictionaryFilter selects the entries of a CPE dictionary to import by
+// their CPE vendor and product attributes. Vendors is required, an empty
+// Products list imports all products of the vendors.
+type CPEDictionaryFilter struct {
+	Vendors           []string
+	Products          []string
+	IncludeDeprecated bool
+}
+
+func (f CPEDictionaryFilter) matches(cpe cpeName) bool {
+	contains := func(values []string, value string) bool {
+		for _, v := range values {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return contains(f.Vendors, cpeUnescape(cpe.Vendor)) &&
+		(len(f.Products) == 0 || contains(f.Products, cpeUnescape(cpe.Product)))
+}
+
+// cpeDictionaryEntry is a single CPE name of a dictionary or match feed.
+type cpeDictionaryEntry struct {
+	Name       string
+	Title      string
+	Deprecated bool
+}
+
+// cpeDictionaryXMLItem is a cpe-item of the official CPE 2.3 XML dictionary.
+type cpeDictionaryXMLItem struct {
+	Deprecated bool `xml:"deprecated,attr"`
+	Titles     []struct {
+		Lang  string `xml:"lang,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"title"`
+	CPE23 struct {
+		Name string `xml:"name,attr"`
+	} `xml:"cpe23-item"`
+}
+
+// cpeDictionaryProduct is an element of "products" in the NVD CPE API 2.0
+// format.
+type cpeDictionaryProduct struct {
+	CPE struct {
+		CPEName    string `json:"cpeName"`
+		Deprecated bool   `json:"deprecated"`
+		Titles     []struct {
+			Title string `json:"title"`
+			Lang  string `json:"lang"`
+		} `json:"titles"`
+	} `json:"cpe"`
+}
+
+// cpeMatchFeedMatch is an element of "matches" in the NVD CPE Match feed 1.0.
+type cpeMatchFeedMatch struct {
+	CPEName []struct {
+		CPE23URI string `json:"cpe23Uri"`
+	} `json:"cpe_name"`
+}
+
+// cpeMatchString is an element of "matchStrings" in the NVD CPE Match API
+// 2.0 format.
+type cpeMatchString struct {
+	MatchString struct {
+		Status  string `json:"status"`
+		Matches []struct {
+			CPEName string `json:"cpeName"`
+		} `json:"matches"`
+	} `json:"matchString"`
+}
+
+// ImportCPEDictionary imports the entries of an NVD CPE dictionary or CPE
+// Match feed that pass the filter. Supported are the XML dictionary, the
+// JSON formats of the CPE and CPE Match APIs and the CPE Match feed 1.0,
+// optionally gzip compressed. Every CPE name becomes a vendor, product and
+// version named after its CPE attributes with the CPE as identification
+// helper. Names without a version only create the vendor and product.
+func (s *Service) ImportCPEDictionary(ctx context.Context, r io.Reader, filter CPEDictionaryFilter, dryRun bool) (ImportReportDTO, error) {
+	if len(filter.Vendors) == 0 {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid CPE dictionary filter",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "vendor",
+					Reason: "At least one vendor is required",
+				},
+			},
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	if magic, _ := reader.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return ImportReportDTO{}, fuego.BadRequestError{
+				Title: "Invalid CPE dictionary",
+				Err:   err,
+			}
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+
+	return s.runImport(ctx, "Failed to import CPE dictionary", dryRun, func(repo Repository, report *ImportReportDTO) error {
+		importer, err := newNodeImporter(ctx, repo, report)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool)
+		err = readCPEDictionary(reader, func(entry cpeDictionaryEntry) error {
+			if seen[entry.Name] || (entry.Deprecated && !filter.IncludeDeprecated) {
+				return nil
+			}
+			seen[entry.Name] = true
+
+			cpe, err := parseCPE23(entry.Name)
+			if err != nil || !filter.matches(cpe) {
+				return nil
+			}
+			return importer.importCPEDictionaryEntry(cpe, entry)
+		})
+
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		var xmlErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &xmlErr) || errors.Is(err, errUnknownCPEDictionary) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fuego.BadRequestError{
+				Title: "Invalid CPE dictionary",
+				Err:   err,
+			}
+		}
+		return err
+	})
+}
+
+func (im *nodeImporter) importCPEDictionaryEntry(cpe cpeName, entry cpeDictionaryEntry) error {
+	vendorName := cpeUnescape(cpe.Vendor)
+	productName := cpeUnescape(cpe.Product)
+	if cpeIsLogical(vendorName) || cpeIsLogical(productName) {
+		return nil
+	}
+
+	path := []string{vendorName}
+	vendor, err := im.importVendor(vendorName, path)
+	if err != nil {
+		return err
+	}
+
+	productType := Software
+	if cpe.Part == "h" {
+		productType = Hardware
+	}
+
+	path = append(path, productName)
+	product, err := im.importProduct(productName, vendor, nil, productType, path)
+	if err != nil {
+		return err
+	}
+
+	if cpeIsLogical(cpe.Version) {
+		return nil
+	}
+
+	versionName := cpeUnescape(cpe.Version)
+	if !cpeIsLogical(cpe.Update) {
+		versionName += " " + cpeUnescape(cpe.Update)
+	}
+
+	path = append(path, versionName)
+	version, _, err := im.matchOrCreate(Node{
+		Name:        versionName,
+		Description: entry.Title,
+		Category:    ProductVersion,
+		ParentID:    &product.ID,
+	}, path)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.Marshal(map[string]string{"cpe": entry.Name})
+	if err != nil {
+		return err
+	}
+	return im.importHelper(version, "cpe", metadata, path)
+}
+
+var errUnknownCPEDictionary = errors.New("expected a CPE dictionary in XML or JSON format")
+
+// readCPEDictionary streams the CPE names of a dictionary or match feed to
+// fn, so files of several hundred megabytes can be imported.
+func readCPEDictionary(reader *bufio.Reader, fn func(cpeDictionaryEntry) error) error {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return errUnknownCPEDictionary
+		}
+		switch {
+		case b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n':
+			_, _ = reader.ReadByte()
+			continue
+		case b[0] == '<':
+			return readCPEDictionaryXML(reader, fn)
+		case b[0] == '{':
+			return readCPEDictionaryJSON(reader, fn)
+		default:
+			return errUnknownCPEDictionary
+		}
+	}
+}
+
+func readCPEDictionaryXML(r io.Reader, fn func(cpeDictionaryEntry) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "cpe-item" {
+			continue
+		}
+
+		var item cpeDictionaryXMLItem
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		if item.CPE23.Name == "" {
+			continue
+		}
+
+		entry := cpeDictionaryEntry{Name: item.CPE23.Name, Deprecated: item.Deprecated}
+		for _, title := range item.Titles {
+			if entry.Title == "" || strings.HasPrefix(title.Lang, "en") {
+				entry.Title = strings.TrimSpace(title.Value)
+			}
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+func readCPEDictionaryJSON(r io.Reader, fn func(cpeDictionaryEntry) error) error {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return err
+	} else if token != json.Delim('{') {
+		return errUnknownCPEDictionary
+	}
+
+	found := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		var decode func() error
+		switch token {
+		case "products":
+			decode = func() error {
+				var product cpeDictionaryProduct
+				if err := decoder.Decode(&product); err != nil {
+					return err
+				}
+				entry := cpeDictionaryEntry{Name: product.CPE.CPEName, Deprecated: product.CPE.Deprecated}
+				for _, title := range product.CPE.Titles {
+					if entry.Title == "" || strings.HasPrefix(title.Lang, "en") {
+						entry.Title = strings.TrimSpace(title.Title)
+					}
+				}
+				return fn(entry)
+			}
+		case "matches":
+			decode = func() error {
+				var match cpeMatchFeedMatch
+				if err := decoder.Decode(&match); err != nil {
+					return err
+				}
+				for _, name := range match.CPEName {
+					if err := fn(cpeDictionaryEntry{Name: name.CPE23URI}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		case "matchStrings":
+			decode = func() error {
+				var match cpeMatchString
+				if err := decoder.Decode(&match); err != nil {
+					return err
+				}
+				inactive := strings.EqualFold(match.MatchString.Status, "Inactive")
+				for _, name := range match.MatchString.Matches {
+					if err := fn(cpeDictionaryEntry{Name: name.CPEName, Deprecated: inactive}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		default:
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		found = true
+		if token, err := decoder.Token(); err != nil {
+			return err
+		} else if token != json.Delim('[') {
+			return fmt.Errorf("%w: %v must be an array", errUnknownCPEDictionary, token)
+		}
+		for decoder.More() {
+			if err := decode(); err != nil {
+				return err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return errUnknownCPEDictionary
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+const testCPEDictionaryXML = `<?xml version="1.0" encoding="UTF-8"?>
+<cpe-list xmlns="http://cpe.mitre.org/dictionary/2.0" xmlns:cpe-23="http://scap.nist.gov/schema/cpe-extension/2.3">
+  <cpe-item name="cpe:/a:openssl:openssl:3.0.12">
+    <title xml:lang="de-DE">OpenSSL Projekt OpenSSL 3.0.12</title>
+    <title xml:lang="en-US">OpenSSL Project OpenSSL 3.0.12</title>
+    <cpe-23:cpe23-item name="cpe:2.3:a:openssl:openssl:3.0.12:*:*:*:*:*:*:*"/>
+  </cpe-item>
+  <cpe-item name="cpe:/a:openssl:openssl:3.0.13">
+    <title xml:lang="en-US">OpenSSL Project OpenSSL 3.0.13</title>
+    <cpe-23:cpe23-item name="cpe:2.3:a:openssl:openssl:3.0.13:*:*:*:*:*:*:*"/>
+  </cpe-item>
+  <cpe-item name="cpe:/a:openssl:openssl:0.9.1" deprecated="true">
+    <title xml:lang="en-US">OpenSSL 0.9.1</title>
+    <cpe-23:cpe23-item name="cpe:2.3:a:openssl:openssl:0.9.1:*:*:*:*:*:*:*"/>
+  </cpe-item>
+  <cpe-item name="cpe:/a:openssl:libcrypto:1.0">
+    <title xml:lang="en-US">OpenSSL libcrypto 1.0</title>
+    <cpe-23:cpe23-item name="cpe:2.3:a:openssl:libcrypto:1.0:*:*:*:*:*:*:*"/>
+  </cpe-item>
+  <cpe-item name="cpe:/a:gnu:bash:5.2">
+    <title xml:lang="en-US">GNU Bash 5.2</title>
+    <cpe-23:cpe23-item name="cpe:2.3:a:gnu:bash:5.2:*:*:*:*:*:*:*"/>
+  </cpe-item>
+</cpe-list>`
+
+const testCPEDictionaryJSON = `{
+  "resultsPerPage": 2,
+  "format": "NVD_CPE",
+  "version": "2.0",
+  "products": [
+    {"cpe": {"deprecated": false, "cpeName": "cpe:2.3:h:cisco:asa_5505:-:*:*:*:*:*:*:*", "titles": [{"title": "Cisco ASA 5505", "lang": "en"}]}},
+    {"cpe": {"deprecated": false, "cpeName": "cpe:2.3:o:cisco:adaptive_security_appliance_software:9.8\\(4\\):sp1:*:*:*:*:*:*", "titles": [{"title": "Cisco ASA Software 9.8(4) SP1", "lang": "en"}]}}
+  ]
+}`
+
+const testCPEMatchFeed = `{
+  "matches": [
+    {"cpe23Uri": "cpe:2.3:a:gnu:bash:*:*:*:*:*:*:*:*", "versionEndExcluding": "5.0", "cpe_name": [
+      {"cpe23Uri": "cpe:2.3:a:gnu:bash:4.3:*:*:*:*:*:*:*"},
+      {"cpe23Uri": "cpe:2.3:a:gnu:bash:4.4:*:*:*:*:*:*:*"}
+    ]},
+    {"cpe23Uri": "cpe:2.3:a:gnu:bash:4.4:*:*:*:*:*:*:*", "cpe_name": [
+      {"cpe23Uri": "cpe:2.3:a:gnu:bash:4.4:*:*:*:*:*:*:*"}
+    ]}
+  ]
+}`
+
+const testCPEMatchStrings = `{
+  "format": "NVD_CPEMatchString",
+  "version": "2.0",
+  "matchStrings": [
+    {"matchString": {"criteria": "cpe:2.3:a:gnu:glibc:*:*:*:*:*:*:*:*", "status": "Active", "matches": [
+      {"cpeName": "cpe:2.3:a:gnu:glibc:2.38:*:*:*:*:*:*:*"}
+    ]}},
+    {"matchString": {"criteria": "cpe:2.3:a:gnu:glibc:2.1:*:*:*:*:*:*:*", "status": "Inactive", "matches": [
+      {"cpeName": "cpe:2.3:a:gnu:glibc:2.1:*:*:*:*:*:*:*"}
+    ]}}
+  ]
+}`
+
+func TestImportCPEDictionary(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	// created lists the names of the created items of a category
+	created := func(report ImportReportDTO, category NodeCategory) string {
+		var names []string
+		for _, item := range report.Items {
+			if item.Action == ImportActionCreated && item.Category == string(category) {
+				names = append(names, item.Name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+
+	t.Run("XMLDictionary", func(t *testing.T) {
+		filter := CPEDictionaryFilter{Vendors: []string{"OpenSSL"}, Products: []string{"openssl"}}
+		report, err := svc.ImportCPEDictionary(ctx, strings.NewReader(testCPEDictionaryXML), filter, false)
+		testutils.AssertNoError(t, err, "Should import dictionary")
+		testutils.AssertEqual(t, "openssl", created(report, Vendor), "Created vendors")
+		testutils.AssertEqual(t, "openssl", created(report, ProductName), "Created products")
+		testutils.AssertEqual(t, "3.0.12, 3.0.13", created(report, ProductVersion), "Created versions")
+		testutils.AssertEqual(t, "cpe, cpe", created(report, "identification_helper"), "Created CPE helpers")
+
+		var productID string
+		for _, item := range report.Items {
+			if item.Category == string(ProductName) {
+				productID = item.ID
+			}
+		}
+		versions, err := svc.ListProductVersions(ctx, productID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		for _, version := range versions {
+			if version.Name == "3.0.12" {
+				testutils.AssertEqual(t, "OpenSSL Project OpenSSL 3.0.12", version.Description, "English title as description")
+				helpers, err := svc.GetIdentificationHelpersByProductVersion(ctx, version.ID)
+				testutils.AssertNoError(t, err, "Should list helpers")
+				testutils.AssertCount(t, 1, len(helpers), "CPE helpers")
+				testutils.AssertEqual(t, `{"cpe":"cpe:2.3:a:openssl:openssl:3.0.12:*:*:*:*:*:*:*"}`, helpers[0].Metadata, "CPE helper")
+			}
+		}
+	})
+
+	t.Run("ReimportMatches", func(t *testing.T) {
+		filter := CPEDictionaryFilter{Vendors: []string{"openssl"}, IncludeDeprecated: true}
+		report, err := svc.ImportCPEDictionary(ctx, strings.NewReader(testCPEDictionaryXML), filter, true)
+		testutils.AssertNoError(t, err, "Should import dictionary")
+		testutils.AssertEqual(t, "libcrypto", created(report, ProductName), "Only new products are created")
+		testutils.AssertEqual(t, "0.9.1, 1.0", created(report, ProductVersion), "Deprecated and new versions")
+	})
+
+	t.Run("CPEAPIJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write([]byte(testCPEDictionaryJSON))
+		testutils.AssertNoError(t, gz.Close(), "Should compress dictionary")
+
+		report, err := svc.ImportCPEDictionary(ctx, &buf, CPEDictionaryFilter{Vendors: []string{"cisco"}}, true)
+		testutils.AssertNoError(t, err, "Should import compressed dictionary")
+		testutils.AssertEqual(t, "asa_5505, adaptive_security_appliance_software", created(report, ProductName), "Created products")
+		testutils.AssertEqual(t, "9.8(4) sp1", created(report, ProductVersion), "Versions without NA version")
+	})
+
+	t.Run("MatchFeeds", func(t *testing.T) {
+		report, err := svc.ImportCPEDictionary(ctx, strings.NewReader(testCPEMatchFeed), CPEDictionaryFilter{Vendors: []string{"gnu"}}, true)
+		testutils.AssertNoError(t, err, "Should import match feed")
+		testutils.AssertEqual(t, "4.3, 4.4", created(report, ProductVersion), "Versions of match feed 1.0")
+
+		report, err = svc.ImportCPEDictionary(ctx, strings.NewReader(testCPEMatchStrings), CPEDictionaryFilter{Vendors: []string{"gnu"}}, true)
+		testutils.AssertNoError(t, err, "Should import match strings")
+		testutils.AssertEqual(t, "2.38", created(report, ProductVersion), "Versions of active match strings")
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		inputs := []string{"", "vendor,product", `{"products": {}}`, `{"other": []}`, `<cpe-list><cpe-item>`}
+		for _, input := range inputs {
+			_, err := svc.ImportCPEDictionary(ctx, strings.NewReader(input), CPEDictionaryFilter{Vendors: []string{"gnu"}}, true)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %q, got %v", input, err)
+			}
+		}
+
+		_, err := svc.ImportCPEDictionary(ctx, strings.NewReader(testCPEMatchFeed), CPEDictionaryFilter{}, true)
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Errorf("Expected BadRequestError without vendor filter, got %v", err)
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("POST", "/api/v1/catalog/cpe-dictionary?vendor=gnu&product=bash,glibc", strings.NewReader(testCPEDictionaryXML))
+		req.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+		if !strings.Contains(w.Body.String(), `"name":"5.2"`) {
+			t.Errorf("Expected bash 5.2 to be imported, got %s", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+)
+
+// CPEVersionRange restricts the version attribute of matched CPE names, as
+// used by the versionStart*/versionEnd* fields of NVD applicability
+// statements. At most one start and one end bound may be set.
+type CPEVersionRange struct {
+	StartIncluding string
+	StartExcluding string
+	EndIncluding   string
+	EndExcluding   string
+}
+
+// constraints converts the range into vers constraints. It returns nil if no
+// bound is set.
+func (r CPEVersionRange) constraints() ([]versConstraint, error) {
+	if r.StartIncluding != "" && r.StartExcluding != "" {
+		return nil, errors.New("version_start_including and version_start_excluding are mutually exclusive")
+	}
+	if r.EndIncluding != "" && r.EndExcluding != "" {
+		return nil, errors.New("version_end_including and version_end_excluding are mutually exclusive")
+	}
+
+	start, startInclusive := r.StartExcluding, false
+	if r.StartIncluding != "" {
+		start, startInclusive = r.StartIncluding, true
+	}
+	end, endInclusive := r.EndExcluding, false
+	if r.EndIncluding != "" {
+		end, endInclusive = r.EndIncluding, true
+	}
+	if start == "" && end == "" {
+		return nil, nil
+	}
+
+	vers, err := versFromBounds(versGenericScheme, start, end, startInclusive, endInclusive, compareVersions)
+	if err != nil {
+		return nil, err
+	}
+	_, constraints, err := parseVers(vers)
+	return constraints, err
+}
+
+// matches reports whether the target name is matched by the source name
+// under the CPE name matching specification: every attribute of the target
+// must be a superset-or-equal match of the source attribute.
+func (source cpeName) matches(target cpeName) bool {
+	pairs := [][2]string{
+		{source.Part, target.Part},
+		{source.Vendor, target.Vendor},
+		{source.Product, target.Product},
+		{source.Version, target.Version},
+		{source.Update, target.Update},
+		{source.Edition, target.Edition},
+		{source.Language, target.Language},
+		{source.SWEdition, target.SWEdition},
+		{source.TargetSW, target.TargetSW},
+		{source.TargetHW, target.TargetHW},
+		{source.Other, target.Other},
+	}
+	for _, pair := range pairs {
+		if !cpeAttributeMatches(pair[0], pair[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cpeAttributeMatches compares a single source and target attribute. ANY in
+// the source matches everything, NA only matches NA, and a value with the
+// unescaped wildcards "*" and "?" is matched case-insensitive against the
+// target value. A logical target value never matches a concrete source.
+func cpeAttributeMatches(source, target string) bool {
+	switch {
+	case source == "*":
+		return true
+	case source == "-":
+		return target == "-"
+	case cpeIsLogical(target):
+		return false
+	}
+
+	if !strings.ContainsAny(source, "*?") {
+		return strings.EqualFold(cpeUnescape(source), cpeUnescape(target))
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("(?is)^")
+	for i := 0; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			if i+1 < len(source) {
+				i++
+			}
+			pattern.WriteString(regexp.QuoteMeta(string(source[i])))
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(source[i])))
+		}
+	}
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	return err == nil && re.MatchString(cpeUnescape(target))
+}
+
+// MatchCPE returns the product versions whose cpe identification helper is
+// matched by the given CPE 2.3 formatted string. The string may contain the
+// wildcards "*" and "?" and the logical values ANY and NA. If versionRange
+// is set, the version of the stored CPE must also lie within the range.
+func (s *Service) MatchCPE(ctx context.Context, criteria string, versionRange CPEVersionRange) (LookupResultDTO, error) {
+	source, err := parseCPE23(criteria)
+	if err != nil {
+		return LookupResultDTO{}, fuego.BadRequestError{
+			Title: "Invalid CPE match",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "cpe",
+					Reason: err.Error(),
+				},
+			},
+		}
+	}
+
+	constraints, err := versionRange.constraints()
+	if err != nil {
+		return LookupResultDTO{}, fuego.BadRequestError{
+			Title: "Invalid CPE match",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "version",
+					Reason: err.Error(),
+				},
+			},
+		}
+	}
+
+	helpers, err := s.repo.GetIdentificationHelpersByCategory(ctx, "cpe")
+	if err != nil {
+		return LookupResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch identification helpers",
+			Err:   err,
+		}
+	}
+
+	result := LookupResultDTO{
+		Identifier: criteria,
+		Types:      []string{IdentifierCPE},
+		Matches:    []LookupMatchDTO{},
+	}
+
+	seen := make(map[string]bool)
+	nodes := make(map[string]Node)
+	getNode := func(id string) (Node, error) {
+		if node, ok := nodes[id]; ok {
+			return node, nil
+		}
+		node, err := s.repo.GetNodeByID(ctx, id)
+		if err != nil {
+			return Node{}, err
+		}
+		nodes[id] = node
+		return node, nil
+	}
+	versionDTOs := s.newVersionDTOs()
+
+	for _, helper := range helpers {
+		if seen[helper.NodeID] {
+			continue
+		}
+
+		var metadata struct {
+			CPE string `json:"cpe"`
+		}
+		if json.Unmarshal(helper.Metadata, &metadata) != nil {
+			continue
+		}
+		target, err := parseCPE23(metadata.CPE)
+		if err != nil || !source.matches(target) {
+			continue
+		}
+		if constraints != nil {
+			// A range can only be applied to a concrete version
+			if cpeIsLogical(target.Version) || !versContains(constraints, cpeUnescape(target.Version), compareVersions) {
+				continue
+			}
+		}
+
+		entry := IdentifierIndex{Type: IdentifierCPE, HelperID: helper.ID, NodeID: helper.NodeID}
+		match, ok, err := s.lookupMatch(ctx, entry, getNode, versionDTOs)
+		if err != nil {
+			return LookupResultDTO{}, fuego.InternalServerError{
+				Title: "Failed to fetch product version",
+				Err:   err,
+			}
+		}
+		if ok {
+			seen[helper.NodeID] = true
+			result.Matches = append(result.Matches, match)
+		}
+	}
+
+	sortLookupMatches(result.Matches)
+
+	return result, nil
+}
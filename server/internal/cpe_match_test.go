@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestCPEAttributeMatches(t *testing.T) {
+	tests := []struct {
+		source   string
+		target   string
+		expected bool
+	}{
+		{"*", "openssl", true},
+		{"*", "-", true},
+		{"-", "-", true},
+		{"-", "openssl", false},
+		{"openssl", "*", false},
+		{"openssl", "-", false},
+		{"OpenSSL", "openssl", true},
+		{"openssl", "libressl", false},
+		{"open*", "openssl", true},
+		{"*ssl", "libressl", true},
+		{"1.?", "1.1", true},
+		{"1.?", "1.10", false},
+		{`node\.js`, `node\.js`, true},
+		{`node\*`, `node\*`, true},
+		{`node\*`, "nodejs", false},
+		{`3\.0\.*`, `3\.0\.13`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source+"_"+tt.target, func(t *testing.T) {
+			testutils.AssertEqual(t, tt.expected, cpeAttributeMatches(tt.source, tt.target), "Attribute match")
+		})
+	}
+}
+
+func TestMatchCPE(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "OpenSSL"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "OpenSSL", VendorID: vendor.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create product")
+
+	cpes := map[string]string{
+		"1.1.1w":  `cpe:2.3:a:openssl:openssl:1.1.1w:*:*:*:*:*:*:*`,
+		"3.0.13":  `cpe:2.3:a:openssl:openssl:3.0.13:*:*:*:*:*:*:*`,
+		"3.2.1":   `cpe:2.3:a:openssl:openssl:3.2.1:*:*:*:*:*:*:*`,
+		"fips":    `cpe:2.3:a:openssl:openssl:3.0.13:-:fips:*:*:*:*:*`,
+		"any":     `cpe:2.3:a:openssl:openssl:*:*:*:*:*:*:*:*`,
+		"libssl":  `cpe:2.3:a:openssl:libssl:3.0.13:*:*:*:*:*:*:*`,
+		"invalid": `not a cpe`,
+	}
+	for name, cpe := range cpes {
+		version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+		_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+			ProductVersionID: version.ID,
+			Category:         "cpe",
+			Metadata:         `{"cpe": "` + strings.ReplaceAll(cpe, `\`, `\\`) + `"}`,
+		})
+		testutils.AssertNoError(t, err, "Should create identification helper")
+	}
+
+	matched := func(t *testing.T, criteria string, versionRange CPEVersionRange) string {
+		result, err := svc.MatchCPE(ctx, criteria, versionRange)
+		testutils.AssertNoError(t, err, "Match should succeed")
+
+		var versions []string
+		for _, match := range result.Matches {
+			testutils.AssertEqual(t, IdentifierCPE, match.IdentifierType, "Identifier type")
+			versions = append(versions, match.ProductVersion.Name)
+		}
+		return strings.Join(versions, ",")
+	}
+
+	t.Run("Wildcards", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			criteria string
+			expected string
+		}{
+			{"Exact", "cpe:2.3:a:openssl:openssl:3.2.1:*:*:*:*:*:*:*", "3.2.1"},
+			{"AnyVersion", "cpe:2.3:a:openssl:openssl:*:*:*:*:*:*:*:*", "1.1.1w,3.0.13,3.2.1,any,fips"},
+			{"VersionPrefix", "cpe:2.3:a:openssl:openssl:3.0.*:*:*:*:*:*:*:*", "3.0.13,fips"},
+			{"NotApplicableUpdate", "cpe:2.3:a:openssl:openssl:*:-:*:*:*:*:*:*", "fips"},
+			{"Edition", "cpe:2.3:a:openssl:openssl:*:*:FIPS:*:*:*:*:*", "fips"},
+			{"ProductWildcard", "cpe:2.3:a:openssl:*ssl:3.0.13", "3.0.13,fips,libssl"},
+			{"SingleCharacter", "cpe:2.3:a:openssl:openssl:3.?.1:*:*:*:*:*:*:*", "3.2.1"},
+			{"OtherPart", "cpe:2.3:o:openssl:openssl:*:*:*:*:*:*:*:*", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				testutils.AssertEqual(t, tt.expected, matched(t, tt.criteria, CPEVersionRange{}), "Matched versions")
+			})
+		}
+	})
+
+	t.Run("VersionRange", func(t *testing.T) {
+		const criteria = "cpe:2.3:a:openssl:openssl:*:*:*:*:*:*:*:*"
+		tests := []struct {
+			name     string
+			bounds   CPEVersionRange
+			expected string
+		}{
+			{"StartIncluding", CPEVersionRange{StartIncluding: "3.0.13"}, "3.0.13,3.2.1,fips"},
+			{"StartExcluding", CPEVersionRange{StartExcluding: "3.0.13"}, "3.2.1"},
+			{"EndExcluding", CPEVersionRange{EndExcluding: "3.0.13"}, "1.1.1w"},
+			{"Interval", CPEVersionRange{StartIncluding: "3.0.0", EndIncluding: "3.2.1"}, "3.0.13,3.2.1,fips"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				testutils.AssertEqual(t, tt.expected, matched(t, criteria, tt.bounds), "Matched versions")
+			})
+		}
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		inputs := []struct {
+			criteria string
+			bounds   CPEVersionRange
+		}{
+			{"", CPEVersionRange{}},
+			{"cpe:/a:openssl:openssl:3.0.13", CPEVersionRange{}},
+			{"cpe:2.3:x:openssl:openssl", CPEVersionRange{}},
+			{"cpe:2.3:a:openssl:openssl", CPEVersionRange{StartIncluding: "1.0", StartExcluding: "1.0"}},
+			{"cpe:2.3:a:openssl:openssl", CPEVersionRange{StartIncluding: "3.0", EndExcluding: "2.0"}},
+		}
+		for _, input := range inputs {
+			_, err := svc.MatchCPE(ctx, input.criteria, input.bounds)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %v, got %v", input, err)
+			}
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		query := url.Values{
+			"cpe":                     {"cpe:2.3:a:openssl:openssl:*:*:*:*:*:*:*:*"},
+			"version_start_including": {"3.0.0"},
+			"version_end_excluding":   {"3.1"},
+		}
+		req := httptest.NewRequest("GET", "/api/v1/lookup/cpe?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var result LookupResultDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Should decode result")
+		testutils.AssertCount(t, 2, len(result.Matches), "Matches")
+		testutils.AssertEqual(t, product.ID, result.Matches[0].Product.ID, "Matched product")
+		testutils.AssertEqual(t, vendor.ID, result.Matches[0].Vendor.ID, "Matched vendor")
+
+		req = httptest.NewRequest("GET", "/api/v1/lookup/cpe?cpe=openssl", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusBadRequest, w.Code, "Status code of invalid CPE")
+	})
+}
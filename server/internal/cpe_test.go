@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"product-database-api/testutils"
+	"testing"
+)
+
+func TestParseCPE23(t *testing.T) {
+	tests := []struct {
+		cpe       string
+		expected  cpeName
+		expectErr bool
+	}{
+		{
+			cpe: "cpe:2.3:a:openssl:openssl:3.0.13:*:*:*:*:*:*:*",
+			expected: cpeName{Part: "a", Vendor: "openssl", Product: "openssl", Version: "3.0.13", Update: "*", Edition: "*",
+				Language: "*", SWEdition: "*", TargetSW: "*", TargetHW: "*", Other: "*"},
+		},
+		{
+			cpe: `cpe:2.3:a:nodejs:node\.js:18.0.0:-:*:*:*:*:*:*`,
+			expected: cpeName{Part: "a", Vendor: "nodejs", Product: `node\.js`, Version: "18.0.0", Update: "-", Edition: "*",
+				Language: "*", SWEdition: "*", TargetSW: "*", TargetHW: "*", Other: "*"},
+		},
+		{
+			cpe: `cpe:2.3:h:acme:router\:x:1.0`,
+			expected: cpeName{Part: "h", Vendor: "acme", Product: `router\:x`, Version: "1.0", Update: "*", Edition: "*",
+				Language: "*", SWEdition: "*", TargetSW: "*", TargetHW: "*", Other: "*"},
+		},
+		{cpe: "cpe:/a:openssl:openssl:3.0.13", expectErr: true},
+		{cpe: "cpe:2.3:x:openssl:openssl", expectErr: true},
+		{cpe: "cpe:2.3:a::openssl", expectErr: true},
+		{cpe: "cpe:2.3:a:b:c:d:e:f:g:h:i:j:k:l", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cpe, func(t *testing.T) {
+			cpe, err := parseCPE23(tt.cpe)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error for %s", tt.cpe)
+				}
+				return
+			}
+			testutils.AssertNoError(t, err, "Should parse CPE")
+			testutils.AssertEqual(t, tt.expected, cpe, "Parsed CPE")
+		})
+	}
+}
+
+func TestCPEUnescape(t *testing.T) {
+	testutils.AssertEqual(t, "node.js", cpeUnescape(`node\.js`), "Escaped dot")
+	testutils.AssertEqual(t, `a\b`, cpeUnescape(`a\\b`), "Escaped backslash")
+	testutils.AssertEqual(t, "openssl", cpeUnescape("openssl"), "Unescaped value")
+}
@@ -0,0 +1,617 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+const (
+	csafVersion       = "2.0"
+	csafGeneratorName = "Product Database"
+)
+
+// CSAFConfig holds the publisher metadata and defaults used when a complete
+// CSAF document is exported.
+type CSAFConfig struct {
+	PublisherName           string
+	PublisherNamespace      string
+	PublisherCategory       string
+	PublisherContactDetails string
+	TrackingIDPrefix        string
+	ProductIDPrefix         string
+	Lang                    string
+}
+
+func DefaultCSAFConfig() CSAFConfig {
+	return CSAFConfig{
+		PublisherCategory: "vendor",
+		TrackingIDPrefix:  "PDB",
+		ProductIDPrefix:   "CSAFPID",
+		Lang:              "en",
+	}
+}
+
+// CSAFConfigFromEnv reads the CSAF_* environment variables and falls back to
+// the defaults for unset values.
+func CSAFConfigFromEnv() CSAFConfig {
+	config := DefaultCSAFConfig()
+
+	if v := os.Getenv("CSAF_PUBLISHER_NAME"); v != "" {
+		config.PublisherName = v
+	}
+	if v := os.Getenv("CSAF_PUBLISHER_NAMESPACE"); v != "" {
+		config.PublisherNamespace = v
+	}
+	if v := os.Getenv("CSAF_PUBLISHER_CATEGORY"); v != "" {
+		config.PublisherCategory = v
+	}
+	if v := os.Getenv("CSAF_PUBLISHER_CONTACT_DETAILS"); v != "" {
+		config.PublisherContactDetails = v
+	}
+	if v := os.Getenv("CSAF_TRACKING_ID_PREFIX"); v != "" {
+		config.TrackingIDPrefix = v
+	}
+	if v := os.Getenv("CSAF_PRODUCT_ID_PREFIX"); v != "" {
+		config.ProductIDPrefix = v
+	}
+	if v := os.Getenv("CSAF_LANG"); v != "" {
+		config.Lang = v
+	}
+
+	return config
+}
+
+// CSAFProductTree is the subset of a CSAF product_tree the database can
+// import.
+type CSAFProductTree struct {
+	Branches      []CSAFBranch       `json:"branches,omitempty"`
+	Relationships []CSAFRelationship `json:"relationships,omitempty"`
+}
+
+type CSAFBranch struct {
+	Category string               `json:"category"`
+	Name     string               `json:"name"`
+	Branches []CSAFBranch         `json:"branches,omitempty"`
+	Product  *CSAFFullProductName `json:"product,omitempty"`
+}
+
+type CSAFFullProductName struct {
+	Name                        string                 `json:"name"`
+	ProductID                   string                 `json:"product_id"`
+	ProductIdentificationHelper map[string]interface{} `json:"product_identification_helper,omitempty"`
+}
+
+type CSAFRelationship struct {
+	Category                  string              `json:"category"`
+	ProductReference          string              `json:"product_reference"`
+	RelatesToProductReference string              `json:"relates_to_product_reference"`
+	FullProductName           CSAFFullProductName `json:"full_product_name"`
+}
+
+const (
+	ProductIDSchemeUUID  = "uuid"
+	ProductIDSchemeShort = "short"
+)
+
+// ExportOptions control how a CSAF product tree is rendered.
+type ExportOptions struct {
+	// ProductIDScheme is either ProductIDSchemeUUID (the default), which uses
+	// the database IDs as product IDs, or ProductIDSchemeShort.
+	ProductIDScheme string
+	// ProductIDPrefix is prepended to short product IDs.
+	ProductIDPrefix string
+	// VendorIDs, FamilyIDs and AllProducts add every product of the vendors,
+	// of the families including their subfamilies, or of the whole database
+	// to the selection.
+	VendorIDs   []string
+	FamilyIDs   []string
+	AllProducts bool
+	// VersionIDs select single versions. Products that are selected only
+	// through some of their versions are exported with just these versions.
+	VersionIDs []string
+	// ProductGroups lists the groupings (ProductGroupByFamily,
+	// ProductGroupByVendor, ProductGroupByProduct, ProductGroupByLifecycle)
+	// emitted as product_groups.
+	ProductGroups []string
+}
+
+type ExportOption func(*ExportOptions)
+
+// WithProductIDScheme selects the product ID scheme. An empty prefix keeps
+// the configured one.
+func WithProductIDScheme(scheme, prefix string) ExportOption {
+	return func(o *ExportOptions) {
+		o.ProductIDScheme = scheme
+		if prefix != "" {
+			o.ProductIDPrefix = prefix
+		}
+	}
+}
+
+// WithVendors selects all products of the given vendors.
+func WithVendors(ids ...string) ExportOption {
+	return func(o *ExportOptions) {
+		o.VendorIDs = append(o.VendorIDs, ids...)
+	}
+}
+
+// WithFamilies selects all products of the given families and their
+// subfamilies.
+func WithFamilies(ids ...string) ExportOption {
+	return func(o *ExportOptions) {
+		o.FamilyIDs = append(o.FamilyIDs, ids...)
+	}
+}
+
+// WithVersions selects single product versions.
+func WithVersions(ids ...string) ExportOption {
+	return func(o *ExportOptions) {
+		o.VersionIDs = append(o.VersionIDs, ids...)
+	}
+}
+
+// WithAllProducts selects every product in the database.
+func WithAllProducts() ExportOption {
+	return func(o *ExportOptions) {
+		o.AllProducts = true
+	}
+}
+
+// WithProductGroups emits a product group per family, vendor or product
+// containing the product IDs of all exported versions beneath it, or per
+// support status containing the versions with that status.
+func WithProductGroups(groupings ...string) ExportOption {
+	return func(o *ExportOptions) {
+		o.ProductGroups = append(o.ProductGroups, groupings...)
+	}
+}
+
+// ExportCSAFDocument wraps the product tree of the given products in a
+// complete CSAF 2.0 document with publisher and tracking information.
+func (s *Service) ExportCSAFDocument(ctx context.Context, productIDs []string, options ExportDocumentDTO, opts ...ExportOption) (map[string]interface{}, error) {
+	publisher, err := s.resolveCSAFPublisher(options.Publisher)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := s.buildCSAFProductTree(ctx, productIDs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	timestamp := now.Format(time.RFC3339)
+
+	title := options.Title
+	if title == "" {
+		title = "Product tree export"
+	}
+
+	category := options.Category
+	if category == "" {
+		category = "csaf_base"
+	}
+
+	lang := options.Lang
+	if lang == "" {
+		lang = s.csaf.Lang
+	}
+
+	status := options.Status
+	if status == "" {
+		status = "draft"
+	}
+
+	version := csafTrackingVersion(status)
+
+	trackingID := options.TrackingID
+	if trackingID == "" {
+		trackingID = s.generateTrackingID(productIDs, now)
+	}
+
+	document := map[string]interface{}{
+		"csaf_version": csafVersion,
+		"category":     category,
+		"title":        title,
+		"publisher":    publisher,
+		"tracking": map[string]interface{}{
+			"id":                   trackingID,
+			"status":               status,
+			"version":              version,
+			"initial_release_date": timestamp,
+			"current_release_date": timestamp,
+			"revision_history": []interface{}{
+				map[string]interface{}{
+					"date":    timestamp,
+					"number":  version,
+					"summary": "Initial version.",
+				},
+			},
+			"generator": map[string]interface{}{
+				"date": timestamp,
+				"engine": map[string]interface{}{
+					"name": csafGeneratorName,
+				},
+			},
+		},
+	}
+	if lang != "" {
+		document["lang"] = lang
+	}
+
+	tree["document"] = document
+
+	if err := validateCSAFExport(tree); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// csafTrackingVersion returns the semantic version of a newly exported
+// document. Drafts must have a version 0.y.z (CSAF mandatory test 6.1.17),
+// interim and final documents start at 1.0.0.
+func csafTrackingVersion(status string) string {
+	if status == "draft" {
+		return "0.1.0"
+	}
+	return "1.0.0"
+}
+
+// resolveCSAFPublisher merges the configured publisher with the overrides of
+// a single request. Name and namespace are mandatory in CSAF.
+func (s *Service) resolveCSAFPublisher(override *CSAFPublisherDTO) (map[string]interface{}, error) {
+	name := s.csaf.PublisherName
+	namespace := s.csaf.PublisherNamespace
+	category := s.csaf.PublisherCategory
+	contactDetails := s.csaf.PublisherContactDetails
+
+	if override != nil {
+		if override.Name != "" {
+			name = override.Name
+		}
+		if override.Namespace != "" {
+			namespace = override.Namespace
+		}
+		if override.Category != "" {
+			category = override.Category
+		}
+		if override.ContactDetails != "" {
+			contactDetails = override.ContactDetails
+		}
+	}
+
+	var errs []fuego.ErrorItem
+	if name == "" {
+		errs = append(errs, fuego.ErrorItem{
+			Name:   "ExportDocumentDTO.Publisher.Name",
+			Reason: "Publisher name must be configured via CSAF_PUBLISHER_NAME or provided in the request",
+		})
+	}
+	if namespace == "" {
+		errs = append(errs, fuego.ErrorItem{
+			Name:   "ExportDocumentDTO.Publisher.Namespace",
+			Reason: "Publisher namespace must be configured via CSAF_PUBLISHER_NAMESPACE or provided in the request",
+		})
+	}
+	if len(errs) > 0 {
+		return nil, fuego.BadRequestError{
+			Title:  "Incomplete CSAF publisher",
+			Errors: errs,
+		}
+	}
+
+	publisher := map[string]interface{}{
+		"category":  category,
+		"name":      name,
+		"namespace": namespace,
+	}
+	if contactDetails != "" {
+		publisher["contact_details"] = contactDetails
+	}
+
+	return publisher, nil
+}
+
+// generateTrackingID derives a tracking ID from the configured prefix, the
+// export date and a short hash of the exported products.
+func (s *Service) generateTrackingID(productIDs []string, now time.Time) string {
+	ids := append([]string(nil), productIDs...)
+	sort.Strings(ids)
+
+	hash := sha256.Sum256([]byte(strings.Join(ids, ",") + "|" + now.Format(time.RFC3339Nano)))
+	suffix := strings.ToUpper(hex.EncodeToString(hash[:])[:8])
+
+	parts := []string{now.Format("20060102"), suffix}
+	if s.csaf.TrackingIDPrefix != "" {
+		parts = append([]string{s.csaf.TrackingIDPrefix}, parts...)
+	}
+	return strings.Join(parts, "-")
+}
+
+// csafRelationshipPhrases describes how a relationship category reads inside
+// the generated full product name of a CSAF relationship.
+var csafRelationshipPhrases = map[RelationshipCategory]string{
+	DefaultComponentOf:  "as a default component of",
+	ExternalComponentOf: "as an external component of",
+	InstalledOn:         "installed on",
+	InstalledWith:       "installed with",
+	OptionalComponentOf: "as an optional component of",
+}
+
+// collectCSAFRelationships loads all relationships touching a selected
+// version of the given products. Products that are not part of the selection
+// but are referenced by one of these relationships are added to it with only
+// the referenced versions; their IDs are returned as well.
+func (s *Service) collectCSAFRelationships(ctx context.Context, selection *exportSelection) ([]Relationship, []string, error) {
+	selected := make(map[string]bool)
+	var versionIDs []string
+
+	for _, id := range selection.ProductIDs {
+		if selected[id] {
+			continue
+		}
+		selected[id] = true
+
+		// Invalid products are reported while the tree is built
+		product, err := s.repo.GetNodeByID(ctx, id, WithChildren())
+		if err != nil || product.Category != ProductName {
+			continue
+		}
+
+		for _, child := range product.Children {
+			if (child.Category == ProductVersion || child.Category == ProductVersionRange) && selection.includesVersion(id, child.ID) {
+				versionIDs = append(versionIDs, child.ID)
+			}
+		}
+	}
+
+	relationships, err := s.repo.GetRelationshipsByNodeIDs(ctx, versionIDs)
+	if err != nil {
+		return nil, nil, fuego.InternalServerError{
+			Title: "Failed to fetch relationships",
+			Err:   err,
+		}
+	}
+
+	if selection.Versions == nil {
+		selection.Versions = make(map[string]map[string]bool)
+	}
+	related := make(map[string]bool)
+	var relatedProductIDs []string
+	var result []Relationship
+	for _, rel := range relationships {
+		if rel.SourceNode == nil || rel.TargetNode == nil ||
+			rel.SourceNode.ParentID == nil || rel.TargetNode.ParentID == nil {
+			continue
+		}
+
+		for _, node := range []*Node{rel.SourceNode, rel.TargetNode} {
+			productID := *node.ParentID
+			if !selected[productID] {
+				selected[productID] = true
+				related[productID] = true
+				relatedProductIDs = append(relatedProductIDs, productID)
+				selection.ProductIDs = append(selection.ProductIDs, productID)
+				selection.Versions[productID] = make(map[string]bool)
+			}
+			if related[productID] {
+				selection.Versions[productID][node.ID] = true
+			}
+		}
+		result = append(result, rel)
+	}
+
+	return result, relatedProductIDs, nil
+}
+
+// convertRelationshipsToCSAF builds the product_tree.relationships entries.
+// Every relationship gets a composite product combining source and target.
+func (s *Service) convertRelationshipsToCSAF(relationships []Relationship, fullNames map[string]string) []interface{} {
+	sorted := append([]Relationship(nil), relationships...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].SourceNodeID != sorted[j].SourceNodeID {
+			return sorted[i].SourceNodeID < sorted[j].SourceNodeID
+		}
+		if sorted[i].Category != sorted[j].Category {
+			return sorted[i].Category < sorted[j].Category
+		}
+		return sorted[i].TargetNodeID < sorted[j].TargetNodeID
+	})
+
+	seen := make(map[string]bool)
+	var result []interface{}
+
+	for _, rel := range sorted {
+		sourceName, hasSource := fullNames[rel.SourceNodeID]
+		targetName, hasTarget := fullNames[rel.TargetNodeID]
+		if !hasSource || !hasTarget {
+			continue
+		}
+
+		productID := rel.SourceNodeID + ":" + string(rel.Category) + ":" + rel.TargetNodeID
+		if seen[productID] {
+			continue
+		}
+		seen[productID] = true
+
+		// Categories outside the CSAF enumeration are only meaningful inside
+		// the database and would make the document invalid
+		phrase, ok := csafRelationshipPhrases[rel.Category]
+		if !ok {
+			continue
+		}
+
+		result = append(result, map[string]interface{}{
+			"category":                     string(rel.Category),
+			"product_reference":            rel.SourceNodeID,
+			"relates_to_product_reference": rel.TargetNodeID,
+			"full_product_name": map[string]interface{}{
+				"name":       sourceName + " " + phrase + " " + targetName,
+				"product_id": productID,
+			},
+		})
+	}
+
+	return result
+}
+
+// sortCSAFBranches orders sibling branches by name and branches of the same
+// name by the first product they contain.
+func sortCSAFBranches(branches []interface{}) {
+	sort.SliceStable(branches, func(i, j int) bool {
+		a, b := csafBranchName(branches[i]), csafBranchName(branches[j])
+		if a != b {
+			return a < b
+		}
+		return csafBranchID(branches[i]) < csafBranchID(branches[j])
+	})
+}
+
+func csafBranchName(branch interface{}) string {
+	if m, ok := branch.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// csafBranchID returns the ID of the product of a branch or of the first
+// product in its sub-branches.
+func csafBranchID(branch interface{}) string {
+	m, ok := branch.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if product, ok := m["product"].(map[string]interface{}); ok {
+		id, _ := product["product_id"].(string)
+		return id
+	}
+	if branches, ok := m["branches"].([]interface{}); ok && len(branches) > 0 {
+		return csafBranchID(branches[0])
+	}
+	return ""
+}
+
+// applyShortCSAFProductIDs replaces the database IDs used as product and
+// group IDs with "<prefix>-<first characters of the ID>". Every ID keeps
+// eight characters and only as many more as it needs to differ from the other
+// IDs of the tree, so exporting more products only lengthens the IDs they
+// collide with. Other IDs, such as those of the lifecycle groups, are kept.
+func applyShortCSAFProductIDs(tree map[string]interface{}, prefix string, databaseIDs map[string]bool) {
+	var ids []string
+	walkCSAFProductIDs(tree, func(key, value string) string {
+		if (key == "product_id" || key == "group_id") && databaseIDs[value] {
+			ids = append(ids, value)
+		}
+		return value
+	})
+
+	mapping := shortProductIDs(uniqueStrings(ids), prefix)
+
+	walkCSAFProductIDs(tree, func(key, value string) string {
+		parts := strings.Split(value, ":")
+		for i, part := range parts {
+			if short, ok := mapping[part]; ok {
+				parts[i] = short
+			}
+		}
+		return strings.Join(parts, ":")
+	})
+}
+
+// shortProductIDs shortens every ID to the shortest prefix of at least eight
+// characters that no other ID starts with. IDs that only differ in dashes or
+// case are kept whole.
+func shortProductIDs(ids []string, prefix string) map[string]string {
+	compact := make(map[string]string, len(ids))
+	sorted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		compact[id] = strings.ToUpper(strings.ReplaceAll(id, "-", ""))
+		sorted = append(sorted, compact[id])
+	}
+	sort.Strings(sorted)
+
+	// The longest prefix an ID shares with another one is the one shared
+	// with a neighbor in sorted order
+	shared := make(map[string]int, len(sorted))
+	for i := 1; i < len(sorted); i++ {
+		length := commonPrefixLength(sorted[i-1], sorted[i])
+		shared[sorted[i-1]] = max(shared[sorted[i-1]], length)
+		shared[sorted[i]] = max(shared[sorted[i]], length)
+	}
+
+	mapping := make(map[string]string, len(ids))
+	for _, id := range ids {
+		short := compact[id]
+		if shared[short] == len(short) {
+			short = id
+		} else {
+			short = short[:min(len(short), max(8, shared[short]+1))]
+		}
+		if prefix != "" {
+			short = prefix + "-" + short
+		}
+		mapping[id] = short
+	}
+	return mapping
+}
+
+func commonPrefixLength(a, b string) int {
+	length := 0
+	for length < len(a) && length < len(b) && a[length] == b[length] {
+		length++
+	}
+	return length
+}
+
+// walkCSAFProductIDs calls replace for every product and group reference in
+// the tree and stores the returned value.
+func walkCSAFProductIDs(node interface{}, replace func(key, value string) string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			switch key {
+			case "product_id", "product_reference", "relates_to_product_reference", "group_id":
+				if id, ok := value.(string); ok {
+					n[key] = replace(key, id)
+					continue
+				}
+			case "product_ids":
+				if ids, ok := value.([]interface{}); ok {
+					for i, id := range ids {
+						if id, ok := id.(string); ok {
+							ids[i] = replace(key, id)
+						}
+					}
+					continue
+				}
+			}
+			walkCSAFProductIDs(value, replace)
+		}
+	case []interface{}:
+		for _, item := range n {
+			walkCSAFProductIDs(item, replace)
+		}
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	ProductGroupByFamily  = "family"
+	ProductGroupByVendor  = "vendor"
+	ProductGroupByProduct = "product"
+	// ProductGroupByLifecycle groups the versions by their support status.
+	ProductGroupByLifecycle = "lifecycle"
+)
+
+// lifecycleGroupSummaries are the summaries of the support status groups.
+var lifecycleGroupSummaries = map[SupportStatus]string{
+	SupportStatusSupported:    "All supported versions",
+	SupportStatusSecurityOnly: "All versions receiving only security updates",
+	SupportStatusUnsupported:  "All versions no longer receiving updates",
+	SupportStatusEndOfLife:    "All versions at end of life",
+}
+
+type csafProductGroup struct {
+	ID         string
+	Summary    string
+	ProductIDs []string
+}
+
+// csafProductGroups collects the product IDs of all exported versions per
+// family, vendor, product or support status while the product tree is
+// built.
+type csafProductGroups struct {
+	groupings map[string]bool
+	families  map[string]Node
+	groups    map[string]*csafProductGroup
+}
+
+func newCSAFProductGroups(groupings []string, families []Node) *csafProductGroups {
+	g := &csafProductGroups{
+		groupings: make(map[string]bool, len(groupings)),
+		families:  make(map[string]Node, len(families)),
+		groups:    make(map[string]*csafProductGroup),
+	}
+	for _, grouping := range groupings {
+		g.groupings[grouping] = true
+	}
+	for _, family := range families {
+		g.families[family.ID] = family
+	}
+	return g
+}
+
+// add records the product IDs of one exported product. A product belongs to
+// the group of its family and to the groups of all parent families.
+func (g *csafProductGroups) add(vendor VendorDTO, product ProductDTO, productIDs []string) {
+	if g.groupings[ProductGroupByVendor] {
+		g.group(vendor.ID, "All versions of "+vendor.Name+" products", productIDs)
+	}
+
+	if g.groupings[ProductGroupByProduct] {
+		g.group(product.ID, "All versions of "+vendor.Name+" "+product.Name, productIDs)
+	}
+
+	if g.groupings[ProductGroupByFamily] && product.FamilyID != nil {
+		visited := make(map[string]bool)
+		for id := product.FamilyID; id != nil && !visited[*id]; {
+			visited[*id] = true
+			family, ok := g.families[*id]
+			if !ok {
+				break
+			}
+			g.group(family.ID, "All versions of the "+strings.Join(g.familyPath(family.ID), " / ")+" family", productIDs)
+			id = family.ParentID
+		}
+	}
+}
+
+// addLifecycle records the exported versions of a product by their support
+// status. Versions without known status are left out.
+func (g *csafProductGroups) addLifecycle(versions []ProductVersionDTO) {
+	if !g.groupings[ProductGroupByLifecycle] {
+		return
+	}
+	for _, version := range versions {
+		summary, ok := lifecycleGroupSummaries[SupportStatus(version.SupportStatus)]
+		if !ok {
+			continue
+		}
+		g.group("lifecycle-"+version.SupportStatus, summary, []string{version.ID})
+	}
+}
+
+func (g *csafProductGroups) group(id, summary string, productIDs []string) {
+	group, ok := g.groups[id]
+	if !ok {
+		group = &csafProductGroup{ID: id, Summary: summary}
+		g.groups[id] = group
+	}
+	group.ProductIDs = append(group.ProductIDs, productIDs...)
+}
+
+func (g *csafProductGroups) familyPath(id string) []string {
+	return familyPath(g.families, id)
+}
+
+// toCSAF returns the product_groups entries. CSAF requires at least two
+// products per group, smaller groups are left out.
+func (g *csafProductGroups) toCSAF() []interface{} {
+	groups := make([]*csafProductGroup, 0, len(g.groups))
+	for _, group := range g.groups {
+		group.ProductIDs = uniqueStrings(group.ProductIDs)
+		if len(group.ProductIDs) < 2 {
+			continue
+		}
+		sort.Strings(group.ProductIDs)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Summary != groups[j].Summary {
+			return groups[i].Summary < groups[j].Summary
+		}
+		return groups[i].ID < groups[j].ID
+	})
+
+	var result []interface{}
+	for _, group := range groups {
+		productIDs := make([]interface{}, len(group.ProductIDs))
+		for i, id := range group.ProductIDs {
+			productIDs[i] = id
+		}
+		result = append(result, map[string]interface{}{
+			"group_id":    group.ID,
+			"summary":     group.Summary,
+			"product_ids": productIDs,
+		})
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+)
+
+func TestExportCSAFProductGroups(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	network, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Network"})
+	testutils.AssertNoError(t, err, "Should create family")
+	wireless, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Wireless", ParentID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create subfamily")
+
+	router, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware", FamilyID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create product")
+	accessPoint, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Access Point", VendorID: vendor.ID, Type: "hardware", FamilyID: &wireless.ID})
+	testutils.AssertNoError(t, err, "Should create product")
+	cloud, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Cloud", VendorID: vendor.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create product")
+
+	var routerVersions []string
+	for _, name := range []string{"1.0", "2.0"} {
+		version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: router.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+		routerVersions = append(routerVersions, version.ID)
+	}
+	apVersion, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: accessPoint.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+
+	productIDs := []string{router.ID, accessPoint.ID, cloud.ID}
+
+	exportGroups := func(t *testing.T, opts ...ExportOption) []map[string]interface{} {
+		result, err := svc.ExportCSAFProductTree(ctx, productIDs, opts...)
+		testutils.AssertNoError(t, err, "Should export product groups")
+
+		tree := result["product_tree"].(map[string]interface{})
+		raw, _ := tree["product_groups"].([]interface{})
+		groups := make([]map[string]interface{}, len(raw))
+		for i, group := range raw {
+			groups[i] = group.(map[string]interface{})
+		}
+		return groups
+	}
+
+	t.Run("WithoutGroups", func(t *testing.T) {
+		testutils.AssertCount(t, 0, len(exportGroups(t)), "No product groups by default")
+	})
+
+	t.Run("Families", func(t *testing.T) {
+		groups := exportGroups(t, WithProductGroups(ProductGroupByFamily))
+
+		// The subfamily only contains a single version, which is too small
+		// for a CSAF product group
+		testutils.AssertCount(t, 1, len(groups), "Family groups")
+		testutils.AssertEqual(t, network.ID, groups[0]["group_id"], "Group ID")
+		testutils.AssertEqual(t, "All versions of the Network family", groups[0]["summary"], "Summary")
+		testutils.AssertCount(t, 3, len(groups[0]["product_ids"].([]interface{})), "Subfamily versions belong to the parent family")
+	})
+
+	t.Run("VendorsAndProducts", func(t *testing.T) {
+		groups := exportGroups(t, WithProductGroups(ProductGroupByVendor, ProductGroupByProduct))
+		testutils.AssertCount(t, 2, len(groups), "Vendor and product groups")
+
+		testutils.AssertEqual(t, "All versions of Acme Router", groups[0]["summary"], "Product group")
+		productGroupIDs := groups[0]["product_ids"].([]interface{})
+		testutils.AssertCount(t, 2, len(productGroupIDs), "Router versions")
+
+		testutils.AssertEqual(t, "All versions of Acme products", groups[1]["summary"], "Vendor group")
+		vendorGroupIDs := groups[1]["product_ids"].([]interface{})
+		testutils.AssertCount(t, 4, len(vendorGroupIDs), "Versions and the product without versions")
+
+		expected := map[string]bool{routerVersions[0]: true, routerVersions[1]: true, apVersion.ID: true, cloud.ID: true}
+		for _, id := range vendorGroupIDs {
+			if !expected[id.(string)] {
+				t.Errorf("Unexpected product ID %v in vendor group", id)
+			}
+		}
+	})
+
+	t.Run("ShortProductIDs", func(t *testing.T) {
+		groups := exportGroups(t, WithProductGroups(ProductGroupByFamily), WithProductIDScheme(ProductIDSchemeShort, "EXAMPLE"))
+		testutils.AssertCount(t, 1, len(groups), "Family groups")
+
+		if id, _ := groups[0]["group_id"].(string); !strings.HasPrefix(id, "EXAMPLE-") {
+			t.Errorf("Expected short group ID, got %q", id)
+		}
+		for _, id := range groups[0]["product_ids"].([]interface{}) {
+			if !strings.HasPrefix(id.(string), "EXAMPLE-") {
+				t.Errorf("Expected short product ID in group, got %q", id)
+			}
+		}
+	})
+	t.Run("RelatedProducts", func(t *testing.T) {
+		library, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Crypto Library", VendorID: vendor.ID, Type: "software", FamilyID: &network.ID})
+		testutils.AssertNoError(t, err, "Should create product")
+		var libraryVersions []string
+		for _, name := range []string{"3.0", "3.1"} {
+			version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: library.ID})
+			testutils.AssertNoError(t, err, "Should create version")
+			libraryVersions = append(libraryVersions, version.ID)
+		}
+		err = svc.CreateRelationship(ctx, CreateRelationshipDTO{Category: string(DefaultComponentOf), SourceNodeIDs: libraryVersions, TargetNodeIDs: routerVersions})
+		testutils.AssertNoError(t, err, "Should create relationships")
+
+		result, err := svc.ExportCSAFProductTree(ctx, []string{router.ID}, WithProductGroups(ProductGroupByFamily, ProductGroupByVendor, ProductGroupByProduct))
+		testutils.AssertNoError(t, err, "Should export product groups")
+
+		tree := result["product_tree"].(map[string]interface{})
+		testutils.AssertCount(t, 4, len(tree["relationships"].([]interface{})), "Relationships of the related product")
+		groups := tree["product_groups"].([]interface{})
+		testutils.AssertCount(t, 3, len(groups), "Family, vendor and product group of the router")
+		for _, raw := range groups {
+			group := raw.(map[string]interface{})
+			if group["group_id"] == library.ID {
+				t.Error("Related product must not get a product group")
+			}
+			for _, id := range group["product_ids"].([]interface{}) {
+				if id == libraryVersions[0] || id == libraryVersions[1] {
+					t.Errorf("Related version %v must not be in group %v", id, group["summary"])
+				}
+			}
+		}
+	})
+}
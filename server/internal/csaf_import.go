@@ -0,0 +1,466 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+	"github.com/google/uuid"
+)
+
+// csafHelperCategories maps the keys of a CSAF product_identification_helper
+// to the identification helper category and metadata key used in the
+// database. It is the inverse of convertIdentificationHelpersToCSAF.
+var csafHelperCategories = map[string]struct {
+	category string
+	key      string
+}{
+	"cpe":            {"cpe", "cpe"},
+	"purl":           {"purl", "purl"},
+	"model_numbers":  {"models", "models"},
+	"sbom_urls":      {"sbom", "sbom_urls"},
+	"skus":           {"sku", "skus"},
+	"x_generic_uris": {"uri", "uris"},
+	"serial_numbers": {"serial", "serial_numbers"},
+	"hashes":         {"hashes", "file_hashes"},
+}
+
+// ImportCSAFProductTree creates or matches the vendors, product families,
+// products, versions, identification helpers and relationships described by
+// a CSAF product tree. With dryRun set nothing is persisted, but the report
+// lists what would have happened.
+func (s *Service) ImportCSAFProductTree(ctx context.Context, productTree map[string]interface{}, dryRun bool) (ImportReportDTO, error) {
+	var tree CSAFProductTree
+	raw, err := json.Marshal(productTree)
+	if err == nil {
+		err = json.Unmarshal(raw, &tree)
+	}
+	if err != nil {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid CSAF product tree",
+			Err:   err,
+		}
+	}
+
+	return s.runImport(ctx, "Failed to import product tree", dryRun, func(repo Repository, report *ImportReportDTO) error {
+		importer, err := newNodeImporter(ctx, repo, report)
+		if err != nil {
+			return err
+		}
+
+		productIDs := make(map[string]string)
+		for _, branch := range tree.Branches {
+			if err := importer.importCSAFBranch(branch, nodeImportScope{}, productIDs); err != nil {
+				return err
+			}
+		}
+
+		return importer.importCSAFRelationships(tree.Relationships, productIDs)
+	})
+}
+
+// nodeImportScope is the position of an imported item inside the tree.
+type nodeImportScope struct {
+	path    []string
+	vendor  *Node
+	family  *Node
+	product *Node
+}
+
+func (sc nodeImportScope) with(name string) nodeImportScope {
+	sc.path = append(append([]string(nil), sc.path...), name)
+	return sc
+}
+
+// nodeImporter matches imported items against the existing nodes and creates
+// the missing ones, recording every decision in the import report.
+type nodeImporter struct {
+	ctx    context.Context
+	repo   Repository
+	report *ImportReportDTO
+	nodes  map[NodeCategory][]Node
+
+	// identifiers maps purl, CPE and hash keys to product version IDs. It is
+	// loaded on first use.
+	identifiers map[string]string
+
+	// row is the line of the imported file the current items belong to.
+	row int
+}
+
+func newNodeImporter(ctx context.Context, repo Repository, report *ImportReportDTO) (*nodeImporter, error) {
+	importer := &nodeImporter{
+		ctx:    ctx,
+		repo:   repo,
+		report: report,
+		nodes:  make(map[NodeCategory][]Node),
+	}
+
+	for _, category := range []NodeCategory{Vendor, ProductFamily, ProductName, ProductVersion, ProductVersionRange} {
+		nodes, err := repo.GetNodesByCategory(ctx, category)
+		if err != nil {
+			return nil, err
+		}
+		importer.nodes[category] = nodes
+	}
+
+	return importer, nil
+}
+
+func (im *nodeImporter) add(action, category, name string, path []string, id, reason string) {
+	im.report.Items = append(im.report.Items, ImportItemDTO{
+		Action:   action,
+		Category: category,
+		Name:     name,
+		Path:     path,
+		ID:       id,
+		Reason:   reason,
+		Row:      im.row,
+	})
+}
+
+// findNode returns the node of the given category with the given name and
+// parent. Names are compared case-insensitively.
+func (im *nodeImporter) findNode(category NodeCategory, name string, parentID *string) (Node, bool) {
+	for _, node := range im.nodes[category] {
+		if !strings.EqualFold(strings.TrimSpace(node.Name), strings.TrimSpace(name)) {
+			continue
+		}
+		if (parentID == nil) != (node.ParentID == nil) {
+			continue
+		}
+		if parentID != nil && *parentID != *node.ParentID {
+			continue
+		}
+		return node, true
+	}
+	return Node{}, false
+}
+
+// matchOrCreate looks up a node by category, name and parent and creates it
+// if it does not exist yet. The boolean reports whether a node was created.
+func (im *nodeImporter) matchOrCreate(node Node, path []string) (Node, bool, error) {
+	if existing, ok := im.findNode(node.Category, node.Name, node.ParentID); ok {
+		im.add(ImportActionMatched, string(node.Category), node.Name, path, existing.ID, "")
+		return existing, false, nil
+	}
+
+	node.ID = uuid.New().String()
+	created, err := im.repo.CreateNode(im.ctx, node)
+	if err != nil {
+		return Node{}, false, err
+	}
+	im.nodes[node.Category] = append(im.nodes[node.Category], created)
+
+	im.add(ImportActionCreated, string(node.Category), node.Name, path, created.ID, "")
+	return created, true, nil
+}
+
+func (im *nodeImporter) importVendor(name string, path []string) (Node, error) {
+	vendor, _, err := im.matchOrCreate(Node{Name: name, Category: Vendor}, path)
+	return vendor, err
+}
+
+func (im *nodeImporter) importFamily(name string, parent *Node, path []string) (Node, error) {
+	family := Node{Name: name, Category: ProductFamily}
+	if parent != nil {
+		family.ParentID = &parent.ID
+	}
+	created, _, err := im.matchOrCreate(family, path)
+	return created, err
+}
+
+func (im *nodeImporter) importProduct(name string, vendor Node, family *Node, productType ProductType, path []string) (Node, error) {
+	product := Node{
+		Name:        name,
+		Category:    ProductName,
+		ParentID:    &vendor.ID,
+		ProductType: productType,
+	}
+	if family != nil {
+		product.ProductFamilyID = &family.ID
+	}
+
+	result, created, err := im.matchOrCreate(product, path)
+	if err != nil {
+		return Node{}, err
+	}
+
+	if !created && family != nil && (result.ProductFamilyID == nil || *result.ProductFamilyID != family.ID) {
+		im.add(ImportActionConflict, string(ProductName), name, path, result.ID,
+			"Product already exists in a different product family and was left unchanged")
+	}
+
+	return result, nil
+}
+
+func (im *nodeImporter) importVersion(name string, product Node, path []string) (Node, error) {
+	version, _, err := im.matchOrCreate(Node{
+		Name:     name,
+		Category: ProductVersion,
+		ParentID: &product.ID,
+	}, path)
+	return version, err
+}
+
+func (im *nodeImporter) importVersionRange(vers string, product Node, path []string) (Node, error) {
+	versionRange, _, err := im.matchOrCreate(Node{
+		Name:     vers,
+		Category: ProductVersionRange,
+		ParentID: &product.ID,
+	}, path)
+	return versionRange, err
+}
+
+// importHelper attaches an identification helper to a version unless an
+// identical one exists. A different helper of the same category is reported
+// as conflict and left untouched.
+func (im *nodeImporter) importHelper(version Node, category string, metadata []byte, path []string) error {
+	existing, err := im.repo.GetIdentificationHelpersByProductVersion(im.ctx, version.ID)
+	if err != nil {
+		return err
+	}
+
+	sameCategory := false
+	for _, helper := range existing {
+		if string(helper.Category) != category {
+			continue
+		}
+		if jsonEqual(helper.Metadata, metadata) {
+			im.add(ImportActionMatched, "identification_helper", category, path, helper.ID, "")
+			return nil
+		}
+		sameCategory = true
+	}
+
+	if sameCategory {
+		im.add(ImportActionConflict, "identification_helper", category, path, "",
+			"A different identification helper of this category already exists")
+		return nil
+	}
+
+	helper, err := im.repo.CreateIdentificationHelper(im.ctx, IdentificationHelper{
+		ID:       uuid.New().String(),
+		Category: IdentificationHelperCategory(category),
+		Metadata: metadata,
+		NodeID:   version.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	im.add(ImportActionCreated, "identification_helper", category, path, helper.ID, "")
+	return nil
+}
+
+// importRelationship creates a relationship between two versions unless it
+// already exists.
+func (im *nodeImporter) importRelationship(category RelationshipCategory, sourceID, targetID, name string) error {
+	existing, err := im.repo.GetRelationshipsBySourceAndCategory(im.ctx, sourceID, string(category))
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range existing {
+		if rel.TargetNodeID == targetID {
+			im.add(ImportActionMatched, "relationship", name, nil, rel.ID, "")
+			return nil
+		}
+	}
+
+	rel, err := im.repo.CreateRelationship(im.ctx, Relationship{
+		ID:           uuid.New().String(),
+		Category:     category,
+		SourceNodeID: sourceID,
+		TargetNodeID: targetID,
+	})
+	if err != nil {
+		return err
+	}
+
+	im.add(ImportActionCreated, "relationship", name, nil, rel.ID, "")
+	return nil
+}
+
+func (im *nodeImporter) importCSAFBranch(branch CSAFBranch, scope nodeImportScope, productIDs map[string]string) error {
+	scope = scope.with(branch.Name)
+
+	switch branch.Category {
+	case "vendor":
+		vendor, err := im.importVendor(branch.Name, scope.path)
+		if err != nil {
+			return err
+		}
+		scope.vendor = &vendor
+		scope.family = nil
+		scope.product = nil
+
+	case "product_family":
+		family, err := im.importFamily(branch.Name, scope.family, scope.path)
+		if err != nil {
+			return err
+		}
+		scope.family = &family
+
+	case "product_name":
+		if scope.vendor == nil {
+			im.add(ImportActionConflict, branch.Category, branch.Name, scope.path, "", "Product is not located below a vendor")
+			return nil
+		}
+		product, err := im.importProduct(branch.Name, *scope.vendor, scope.family, Software, scope.path)
+		if err != nil {
+			return err
+		}
+		scope.product = &product
+
+		if branch.Product != nil && len(branch.Product.ProductIdentificationHelper) > 0 {
+			im.add(ImportActionSkipped, "identification_helper", branch.Name, scope.path, "",
+				"Identification helpers are only supported on product versions")
+		}
+
+	case "product_version":
+		if scope.product == nil {
+			im.add(ImportActionConflict, branch.Category, branch.Name, scope.path, "", "Version is not located below a product")
+			return nil
+		}
+		version, err := im.importVersion(branch.Name, *scope.product, scope.path)
+		if err != nil {
+			return err
+		}
+
+		if branch.Product != nil {
+			productIDs[branch.Product.ProductID] = version.ID
+			if err := im.importCSAFHelpers(version, branch.Product.ProductIdentificationHelper, scope.path); err != nil {
+				return err
+			}
+		}
+
+	case "product_version_range":
+		if scope.product == nil {
+			im.add(ImportActionConflict, branch.Category, branch.Name, scope.path, "", "Version range is not located below a product")
+			return nil
+		}
+		scheme, constraints, err := parseVers(branch.Name)
+		if err != nil {
+			im.add(ImportActionSkipped, branch.Category, branch.Name, scope.path, "",
+				"Only version ranges given as vers expression can be imported")
+			return nil
+		}
+		versionRange, err := im.importVersionRange(formatVers(scheme, constraints), *scope.product, scope.path)
+		if err != nil {
+			return err
+		}
+
+		if branch.Product != nil {
+			productIDs[branch.Product.ProductID] = versionRange.ID
+			if len(branch.Product.ProductIdentificationHelper) > 0 {
+				im.add(ImportActionSkipped, "identification_helper", branch.Name, scope.path, "",
+					"Identification helpers are only supported on product versions")
+			}
+		}
+
+	default:
+		im.add(ImportActionSkipped, branch.Category, branch.Name, scope.path, "",
+			"Branch category is not supported, only its children are imported")
+	}
+
+	for _, child := range branch.Branches {
+		if err := im.importCSAFBranch(child, scope, productIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (im *nodeImporter) importCSAFHelpers(version Node, helpers map[string]interface{}, path []string) error {
+	keys := make([]string, 0, len(helpers))
+	for key := range helpers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		mapping, ok := csafHelperCategories[key]
+		if !ok {
+			im.add(ImportActionSkipped, "identification_helper", key, path, "", "Identification helper is not supported")
+			continue
+		}
+
+		value := helpers[key]
+		if key == "hashes" {
+			value = convertCSAFHashesToMetadata(value)
+		}
+
+		metadata, err := json.Marshal(map[string]interface{}{mapping.key: value})
+		if err != nil {
+			return err
+		}
+
+		if err := im.importHelper(version, mapping.category, metadata, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (im *nodeImporter) importCSAFRelationships(relationships []CSAFRelationship, productIDs map[string]string) error {
+	for _, rel := range relationships {
+		name := rel.FullProductName.Name
+		category := RelationshipCategory(rel.Category)
+
+		if _, ok := csafRelationshipPhrases[category]; !ok {
+			im.add(ImportActionSkipped, "relationship", name, nil, "", "Relationship category is not supported")
+			continue
+		}
+
+		sourceID, hasSource := productIDs[rel.ProductReference]
+		targetID, hasTarget := productIDs[rel.RelatesToProductReference]
+		if !hasSource || !hasTarget {
+			im.add(ImportActionConflict, "relationship", name, nil, "",
+				"Relationship does not reference product versions of the imported tree")
+			continue
+		}
+
+		if err := im.importRelationship(category, sourceID, targetID, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertCSAFHashesToMetadata turns CSAF hashes into the file_hashes layout
+// stored in hashes identification helpers.
+func convertCSAFHashesToMetadata(value interface{}) interface{} {
+	hashes, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+
+	var fileHashes []interface{}
+	for _, hash := range hashes {
+		hashMap, ok := hash.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fileHashes = append(fileHashes, map[string]interface{}{
+			"filename": hashMap["filename"],
+			"items":    hashMap["file_hashes"],
+		})
+	}
+	return fileHashes
+}
+
+// jsonEqual reports whether two JSON documents are semantically equal.
+func jsonEqual(a, b []byte) bool {
+	var valueA, valueB interface{}
+	if json.Unmarshal(a, &valueA) != nil || json.Unmarshal(b, &valueB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"product-database-api/testutils"
+	"testing"
+)
+
+func TestImportCSAFProductTree(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	var productTree map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"branches": [{
+			"category": "vendor",
+			"name": "Example",
+			"branches": [{
+				"category": "product_family",
+				"name": "Networking",
+				"branches": [{
+					"category": "product_name",
+					"name": "Router",
+					"branches": [{
+						"category": "product_version",
+						"name": "1.0",
+						"product": {
+							"name": "Example Router 1.0",
+							"product_id": "CSAFPID-0001",
+							"product_identification_helper": {
+								"cpe": "cpe:2.3:h:example:router:1.0:*:*:*:*:*:*:*",
+								"hashes": [{"filename": "router.bin", "file_hashes": [{"algorithm": "sha256", "value": "abc"}]}]
+							}
+						}
+					}, {
+						"category": "product_version",
+						"name": "2.0",
+						"product": {"name": "Example Router 2.0", "product_id": "CSAFPID-0002"}
+					}]
+				}]
+			}]
+		}],
+		"relationships": [{
+			"category": "default_component_of",
+			"product_reference": "CSAFPID-0001",
+			"relates_to_product_reference": "CSAFPID-0002",
+			"full_product_name": {"name": "Example Router 1.0 as a default component of Example Router 2.0", "product_id": "CSAFPID-0003"}
+		}]
+	}`), &productTree)
+	testutils.AssertNoError(t, err, "Should parse product tree")
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := svc.ImportCSAFProductTree(ctx, productTree, true)
+		testutils.AssertNoError(t, err, "Dry run should succeed")
+		testutils.AssertEqual(t, true, report.DryRun, "Report should be marked as dry run")
+		// vendor, family, product, two versions, two helpers, one relationship
+		testutils.AssertEqual(t, 8, report.Summary.Created, "Created items")
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 0, len(vendors), "Dry run must not persist anything")
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		report, err := svc.ImportCSAFProductTree(ctx, productTree, false)
+		testutils.AssertNoError(t, err, "Import should succeed")
+		testutils.AssertEqual(t, 8, report.Summary.Created, "Created items")
+		testutils.AssertEqual(t, 0, report.Summary.Conflicts, "Conflicts")
+
+		products, err := svc.ListProducts(ctx)
+		testutils.AssertNoError(t, err, "Should list products")
+		testutils.AssertCount(t, 1, len(products), "Imported products")
+		testutils.AssertCount(t, 2, len(products[0].Versions), "Imported versions")
+		if products[0].FamilyID == nil {
+			t.Error("Imported product should belong to the imported family")
+		}
+	})
+
+	t.Run("ReimportMatchesEverything", func(t *testing.T) {
+		report, err := svc.ImportCSAFProductTree(ctx, productTree, false)
+		testutils.AssertNoError(t, err, "Re-import should succeed")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Nothing should be created")
+		testutils.AssertEqual(t, 8, report.Summary.Matched, "Everything should be matched")
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		var conflicting map[string]interface{}
+		err := json.Unmarshal([]byte(`{
+			"branches": [{
+				"category": "vendor",
+				"name": "example",
+				"branches": [{
+					"category": "product_name",
+					"name": "Router",
+					"branches": [{
+						"category": "product_version",
+						"name": "1.0",
+						"product": {
+							"name": "Example Router 1.0",
+							"product_id": "CSAFPID-0001",
+							"product_identification_helper": {"cpe": "cpe:2.3:h:example:router:1.0.1:*:*:*:*:*:*:*"}
+						}
+					}]
+				}]
+			}, {
+				"category": "product_version",
+				"name": "orphan"
+			}]
+		}`), &conflicting)
+		testutils.AssertNoError(t, err, "Should parse product tree")
+
+		report, err := svc.ImportCSAFProductTree(ctx, conflicting, true)
+		testutils.AssertNoError(t, err, "Dry run should succeed")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Nothing should be created")
+		testutils.AssertEqual(t, 2, report.Summary.Conflicts, "Changed CPE and orphaned version")
+	})
+}
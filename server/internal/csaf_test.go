@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestExportCSAFDocument(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	config := DefaultCSAFConfig()
+	config.PublisherName = "Example PSIRT"
+	config.PublisherNamespace = "https://psirt.example.com"
+
+	svc := NewService(NewRepository(db), WithCSAFConfig(config))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Example"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	_, err = svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+
+	t.Run("DocumentEnvelope", func(t *testing.T) {
+		result, err := svc.ExportCSAFDocument(ctx, []string{product.ID}, ExportDocumentDTO{Title: "Router products"})
+		testutils.AssertNoError(t, err, "Should export CSAF document")
+
+		if _, ok := result["product_tree"]; !ok {
+			t.Fatal("Expected product_tree in document")
+		}
+
+		document, ok := result["document"].(map[string]interface{})
+		if !ok {
+			t.Fatal("Expected document section")
+		}
+		testutils.AssertEqual(t, "2.0", document["csaf_version"], "CSAF version")
+		testutils.AssertEqual(t, "csaf_base", document["category"], "Default category")
+		testutils.AssertEqual(t, "Router products", document["title"], "Title")
+		testutils.AssertEqual(t, "en", document["lang"], "Default language")
+
+		publisher := document["publisher"].(map[string]interface{})
+		testutils.AssertEqual(t, "Example PSIRT", publisher["name"], "Publisher name")
+		testutils.AssertEqual(t, "https://psirt.example.com", publisher["namespace"], "Publisher namespace")
+		testutils.AssertEqual(t, "vendor", publisher["category"], "Publisher category")
+
+		tracking := document["tracking"].(map[string]interface{})
+		testutils.AssertEqual(t, "draft", tracking["status"], "Default status")
+		testutils.AssertEqual(t, "0.1.0", tracking["version"], "Draft tracking version")
+		testutils.AssertCount(t, 0, len(checkCSAFDocumentStatus(document)), "Draft passes CSAF mandatory test 6.1.17")
+		if id, _ := tracking["id"].(string); !strings.HasPrefix(id, "PDB-") {
+			t.Errorf("Expected generated tracking ID with prefix, got %q", id)
+		}
+		history := tracking["revision_history"].([]interface{})
+		testutils.AssertCount(t, 1, len(history), "Revision history entries")
+		testutils.AssertEqual(t, tracking["version"], history[0].(map[string]interface{})["number"], "Revision number matches version")
+	})
+
+	t.Run("RequestOverrides", func(t *testing.T) {
+		result, err := svc.ExportCSAFDocument(ctx, []string{product.ID}, ExportDocumentDTO{
+			TrackingID: "EXAMPLE-2023-0001",
+			Status:     "final",
+			Publisher:  &CSAFPublisherDTO{Name: "Other", Category: "coordinator"},
+		})
+		testutils.AssertNoError(t, err, "Should export CSAF document with overrides")
+
+		document := result["document"].(map[string]interface{})
+		tracking := document["tracking"].(map[string]interface{})
+		testutils.AssertEqual(t, "EXAMPLE-2023-0001", tracking["id"], "Tracking ID override")
+		testutils.AssertEqual(t, "final", tracking["status"], "Status override")
+		testutils.AssertEqual(t, "1.0.0", tracking["version"], "Final tracking version")
+		testutils.AssertEqual(t, "1.0.0", tracking["revision_history"].([]interface{})[0].(map[string]interface{})["number"], "Revision number matches version")
+
+		publisher := document["publisher"].(map[string]interface{})
+		testutils.AssertEqual(t, "Other", publisher["name"], "Publisher name override")
+		testutils.AssertEqual(t, "coordinator", publisher["category"], "Publisher category override")
+		testutils.AssertEqual(t, "https://psirt.example.com", publisher["namespace"], "Configured namespace is kept")
+	})
+
+	t.Run("DocumentStatus", func(t *testing.T) {
+		for _, tt := range []struct {
+			status, version string
+			valid           bool
+		}{
+			{"draft", "0.1.0", true},
+			{"draft", "1.0.0-rc.1", true},
+			{"final", "1.0.0", true},
+			{"final", "2", true},
+			{"interim", "0.1.0", false},
+			{"final", "0", false},
+			{"final", "1.0.0-rc.1", false},
+		} {
+			document := map[string]interface{}{
+				"tracking": map[string]interface{}{"status": tt.status, "version": tt.version},
+			}
+			testutils.AssertEqual(t, tt.valid, len(checkCSAFDocumentStatus(document)) == 0, tt.status+" "+tt.version)
+		}
+	})
+
+	t.Run("MissingPublisher", func(t *testing.T) {
+		unconfigured := NewService(NewRepository(db))
+		_, err := unconfigured.ExportCSAFDocument(ctx, []string{product.ID}, ExportDocumentDTO{})
+
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Fatalf("Expected BadRequestError, got %v", err)
+		}
+		testutils.AssertCount(t, 2, len(badRequest.Errors), "Missing name and namespace")
+	})
+}
+
+func TestExportCSAFRelationships(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Example"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	app, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "App", VendorID: vendor.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create app")
+	platform, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "OS", VendorID: vendor.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create os")
+	appVersion, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: app.ID})
+	testutils.AssertNoError(t, err, "Should create app version")
+	osVersion, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "11", ProductID: platform.ID})
+	testutils.AssertNoError(t, err, "Should create os version")
+	_, err = svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "12", ProductID: platform.ID})
+	testutils.AssertNoError(t, err, "Should create unrelated os version")
+
+	err = svc.CreateRelationship(ctx, CreateRelationshipDTO{
+		Category:      string(InstalledOn),
+		SourceNodeIDs: []string{appVersion.ID},
+		TargetNodeIDs: []string{osVersion.ID},
+	})
+	testutils.AssertNoError(t, err, "Should create relationship")
+
+	// Products without vendor cannot be placed in the tree
+	orphan := testutils.Node{ID: "orphan", Name: "Orphan", Category: testutils.ProductName}
+	testutils.AssertNoError(t, db.Create(&orphan).Error, "Should create product without vendor")
+	orphanVersion := testutils.CreateTestProductVersion(t, db, "1.0", "", orphan.ID, nil)
+	testutils.CreateTestRelationship(t, db, appVersion.ID, orphanVersion.ID, testutils.InstalledWith)
+
+	// Only the app is selected, the OS is pulled in through the relationship
+	result, err := svc.ExportCSAFProductTree(ctx, []string{app.ID})
+	testutils.AssertNoError(t, err, "Should export product tree")
+
+	tree := result["product_tree"].(map[string]interface{})
+	vendors := tree["branches"].([]interface{})
+	products := vendors[0].(map[string]interface{})["branches"].([]interface{})
+	testutils.AssertCount(t, 2, len(products), "Related product should be exported")
+	osBranch := products[1].(map[string]interface{})
+	testutils.AssertEqual(t, "OS", osBranch["name"], "Related product")
+	testutils.AssertCount(t, 1, len(osBranch["branches"].([]interface{})), "Only the related version is exported")
+
+	relationships, ok := tree["relationships"].([]interface{})
+	if !ok {
+		t.Fatal("Expected relationships in product tree")
+	}
+	testutils.AssertCount(t, 1, len(relationships), "Relationship count")
+
+	rel := relationships[0].(map[string]interface{})
+	testutils.AssertEqual(t, "installed_on", rel["category"], "Relationship category")
+	testutils.AssertEqual(t, appVersion.ID, rel["product_reference"], "Product reference")
+	testutils.AssertEqual(t, osVersion.ID, rel["relates_to_product_reference"], "Relates to product reference")
+
+	fullProductName := rel["full_product_name"].(map[string]interface{})
+	testutils.AssertEqual(t, "Example App 1.0 installed on Example OS 11", fullProductName["name"], "Composite name")
+	testutils.AssertEqual(t, appVersion.ID+":installed_on:"+osVersion.ID, fullProductName["product_id"], "Composite product ID")
+}
+
+func TestExportCSAFStableOutput(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	var productIDs []string
+	for _, vendorName := range []string{"Zeta", "Alpha", "Mu"} {
+		vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: vendorName})
+		testutils.AssertNoError(t, err, "Should create vendor")
+
+		for _, productName := range []string{"Server", "Client"} {
+			product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: productName, VendorID: vendor.ID, Type: "software"})
+			testutils.AssertNoError(t, err, "Should create product")
+			productIDs = append(productIDs, product.ID)
+
+			for _, version := range []string{"1.10.0", "1.2.0", "1.9.0"} {
+				_, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: version, ProductID: product.ID})
+				testutils.AssertNoError(t, err, "Should create version")
+			}
+		}
+	}
+
+	first, err := svc.ExportCSAFProductTree(ctx, productIDs)
+	testutils.AssertNoError(t, err, "Should export product tree")
+
+	tree := first["product_tree"].(map[string]interface{})
+	vendors := tree["branches"].([]interface{})
+	for i, expected := range []string{"Alpha", "Mu", "Zeta"} {
+		testutils.AssertEqual(t, expected, csafBranchName(vendors[i]), "Vendor order")
+	}
+
+	products := vendors[0].(map[string]interface{})["branches"].([]interface{})
+	testutils.AssertEqual(t, "Client", csafBranchName(products[0]), "Product order")
+	testutils.AssertEqual(t, "Server", csafBranchName(products[1]), "Product order")
+
+	versions := products[0].(map[string]interface{})["branches"].([]interface{})
+	for i, expected := range []string{"1.2.0", "1.9.0", "1.10.0"} {
+		testutils.AssertEqual(t, expected, csafBranchName(versions[i]), "Version order")
+	}
+
+	// The order of the requested products must not matter
+	reversed := make([]string, len(productIDs))
+	for i, id := range productIDs {
+		reversed[len(productIDs)-1-i] = id
+	}
+	second, err := svc.ExportCSAFProductTree(ctx, reversed)
+	testutils.AssertNoError(t, err, "Should export product tree again")
+
+	firstJSON, _ := json.Marshal(first)
+	secondJSON, _ := json.Marshal(second)
+	testutils.AssertEqual(t, string(firstJSON), string(secondJSON), "Exports should be identical")
+
+	t.Run("ShortProductIDs", func(t *testing.T) {
+		result, err := svc.ExportCSAFProductTree(ctx, productIDs, WithProductIDScheme(ProductIDSchemeShort, "EXAMPLE"))
+		testutils.AssertNoError(t, err, "Should export with short product IDs")
+
+		seen := make(map[string]bool)
+		walkCSAFProductIDs(result, func(key, value string) string {
+			if !strings.HasPrefix(value, "EXAMPLE-") || len(value) != len("EXAMPLE-")+8 {
+				t.Errorf("Unexpected short product ID %q", value)
+			}
+			if seen[value] {
+				t.Errorf("Duplicate product ID %q", value)
+			}
+			seen[value] = true
+			return value
+		})
+		testutils.AssertCount(t, 18, len(seen), "One product ID per version")
+
+		mapping := shortProductIDs([]string{
+			"0a1b2c3d-0000-4000-8000-000000000001",
+			"0a1b2c3d-0000-4000-8000-000000000002",
+			"9f8e7d6c-0000-4000-8000-000000000001",
+		}, "")
+		testutils.AssertEqual(t, "0A1B2C3D000040008000000000000001", mapping["0a1b2c3d-0000-4000-8000-000000000001"], "Colliding ID")
+		testutils.AssertEqual(t, "0A1B2C3D000040008000000000000002", mapping["0a1b2c3d-0000-4000-8000-000000000002"], "Colliding ID")
+		testutils.AssertEqual(t, "9F8E7D6C", mapping["9f8e7d6c-0000-4000-8000-000000000001"], "Other IDs stay short")
+	})
+
+	t.Run("SameNames", func(t *testing.T) {
+		var ids []string
+		for range 2 {
+			vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Twin"})
+			testutils.AssertNoError(t, err, "Should create vendor")
+			product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Tool", VendorID: vendor.ID, Type: "software"})
+			testutils.AssertNoError(t, err, "Should create product")
+			ids = append(ids, product.ID)
+		}
+
+		result, err := svc.ExportCSAFProductTree(ctx, ids)
+		testutils.AssertNoError(t, err, "Should export product tree")
+		vendors := result["product_tree"].(map[string]interface{})["branches"].([]interface{})
+		testutils.AssertCount(t, 2, len(vendors), "Vendors of the same name stay apart")
+
+		reversed, err := svc.ExportCSAFProductTree(ctx, []string{ids[1], ids[0]})
+		testutils.AssertNoError(t, err, "Should export product tree again")
+		firstJSON, _ := json.Marshal(result)
+		secondJSON, _ := json.Marshal(reversed)
+		testutils.AssertEqual(t, string(firstJSON), string(secondJSON), "Exports should be identical")
+	})
+}
@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-fuego/fuego"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed schema/*.json
+var csafSchemaFiles embed.FS
+
+const csafSchemaURL = "https://docs.oasis-open.org/csaf/csaf/v2.0/csaf_json_schema.json"
+
+// csafSchemaResources maps the URLs referenced by the CSAF schema to the
+// bundled copies, so validation never needs network access.
+var csafSchemaResources = map[string]string{
+	csafSchemaURL: "schema/csaf_json_schema.json",
+	"https://www.first.org/cvss/cvss-v2.0.json": "schema/cvss-v2.0.json",
+	"https://www.first.org/cvss/cvss-v3.0.json": "schema/cvss-v3.0.json",
+	"https://www.first.org/cvss/cvss-v3.1.json": "schema/cvss-v3.1.json",
+}
+
+var (
+	csafSchemaOnce        sync.Once
+	csafDocumentSchema    *jsonschema.Schema
+	csafProductTreeSchema *jsonschema.Schema
+	csafSchemaErr         error
+)
+
+type csafSchemaLoader struct{}
+
+func (csafSchemaLoader) Load(url string) (any, error) {
+	file, ok := csafSchemaResources[url]
+	if !ok {
+		return nil, fmt.Errorf("schema %s is not bundled", url)
+	}
+	data, err := csafSchemaFiles.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(data))
+}
+
+// loadCSAFSchemas compiles the bundled CSAF 2.0 schema once. The product tree
+// schema is the product_tree property of the full document schema.
+func loadCSAFSchemas() (*jsonschema.Schema, *jsonschema.Schema, error) {
+	csafSchemaOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		compiler.AssertFormat()
+		compiler.UseLoader(csafSchemaLoader{})
+
+		csafDocumentSchema, csafSchemaErr = compiler.Compile(csafSchemaURL)
+		if csafSchemaErr != nil {
+			return
+		}
+		csafProductTreeSchema, csafSchemaErr = compiler.Compile(csafSchemaURL + "#/properties/product_tree")
+	})
+	return csafDocumentSchema, csafProductTreeSchema, csafSchemaErr
+}
+
+// ValidateCSAFDocument checks an arbitrary document against the CSAF 2.0
+// schema. Schema violations are part of the result, not an error.
+func (s *Service) ValidateCSAFDocument(document map[string]interface{}) (CSAFValidationResultDTO, error) {
+	schema, _, err := loadCSAFSchemas()
+	if err != nil {
+		return CSAFValidationResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to load CSAF schema",
+			Err:   err,
+		}
+	}
+
+	return validateAgainstSchema(schema, document)
+}
+
+// validateCSAFExport validates an exported product tree, or the full document
+// if it contains a document section, and turns violations into an error.
+func validateCSAFExport(export map[string]interface{}) error {
+	documentSchema, productTreeSchema, err := loadCSAFSchemas()
+	if err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to load CSAF schema",
+			Err:   err,
+		}
+	}
+
+	schema, instance := productTreeSchema, export["product_tree"]
+	if tree, ok := instance.(map[string]interface{}); ok && isEmptyCSAFProductTree(tree) {
+		// An empty selection cannot form a valid product tree, there is
+		// nothing wrong with the stored data in that case
+		return nil
+	}
+	if _, ok := export["document"]; ok {
+		schema, instance = documentSchema, export
+	}
+
+	result, err := validateAgainstSchema(schema, instance)
+	if err != nil {
+		return err
+	}
+	if document, ok := export["document"].(map[string]interface{}); ok {
+		result.Errors = append(result.Errors, checkCSAFDocumentStatus(document)...)
+		result.Valid = result.Valid && len(result.Errors) == 0
+	}
+	if result.Valid {
+		return nil
+	}
+
+	errorItems := make([]fuego.ErrorItem, 0, len(result.Errors))
+	for _, validationErr := range result.Errors {
+		errorItems = append(errorItems, fuego.ErrorItem{
+			Name:   validationErr.InstanceLocation,
+			Reason: validationErr.Message,
+		})
+	}
+
+	return fuego.InternalServerError{
+		Title:  "Exported CSAF does not match the CSAF 2.0 schema",
+		Detail: "The stored product data produced invalid CSAF, e.g. because of malformed identification helper metadata",
+		Errors: errorItems,
+	}
+}
+
+// checkCSAFDocumentStatus runs the CSAF mandatory test 6.1.17: a document
+// whose version is 0, 0.y.z or a pre-release must have the status draft.
+// Only semantic versions can be zero versions or pre-releases.
+func checkCSAFDocumentStatus(document map[string]interface{}) []CSAFValidationErrorDTO {
+	tracking, _ := document["tracking"].(map[string]interface{})
+	status, _ := tracking["status"].(string)
+	version, _ := tracking["version"].(string)
+
+	version, _, _ = strings.Cut(version, "+")
+	release, preRelease, _ := strings.Cut(version, "-")
+	draftVersion := release == "0" || strings.HasPrefix(release, "0.") || (preRelease != "" && strings.Count(release, ".") == 2)
+	if !draftVersion || status == "draft" {
+		return nil
+	}
+
+	return []CSAFValidationErrorDTO{{
+		InstanceLocation: "/document/tracking/status",
+		KeywordLocation:  "6.1.17",
+		Message:          fmt.Sprintf("document version %s requires the status draft, got %s", version, status),
+	}}
+}
+
+func isEmptyCSAFProductTree(tree map[string]interface{}) bool {
+	branches, _ := tree["branches"].([]interface{})
+	return len(branches) == 0 && tree["relationships"] == nil
+}
+
+func validateAgainstSchema(schema *jsonschema.Schema, value interface{}) (CSAFValidationResultDTO, error) {
+	// The validator only understands the generic JSON types, so typed slices
+	// and maps built during export have to be normalized first.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return CSAFValidationResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to encode CSAF",
+			Err:   err,
+		}
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return CSAFValidationResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to decode CSAF",
+			Err:   err,
+		}
+	}
+
+	result := CSAFValidationResultDTO{Valid: true, Errors: []CSAFValidationErrorDTO{}}
+
+	err = schema.Validate(instance)
+	if err == nil {
+		return result, nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return CSAFValidationResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to validate CSAF",
+			Err:   err,
+		}
+	}
+
+	result.Valid = false
+	collectCSAFValidationErrors(validationErr, &result.Errors)
+
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		if result.Errors[i].InstanceLocation != result.Errors[j].InstanceLocation {
+			return result.Errors[i].InstanceLocation < result.Errors[j].InstanceLocation
+		}
+		return result.Errors[i].KeywordLocation < result.Errors[j].KeywordLocation
+	})
+
+	return result, nil
+}
+
+var csafValidationPrinter = message.NewPrinter(language.English)
+
+// collectCSAFValidationErrors flattens the cause tree of a validation error.
+// Only the leaves carry the actual violation, the inner nodes merely say that
+// a referenced subschema failed.
+func collectCSAFValidationErrors(validationErr *jsonschema.ValidationError, errs *[]CSAFValidationErrorDTO) {
+	if len(validationErr.Causes) > 0 {
+		for _, cause := range validationErr.Causes {
+			collectCSAFValidationErrors(cause, errs)
+		}
+		return
+	}
+
+	keywordLocation := ""
+	if i := strings.IndexByte(validationErr.SchemaURL, '#'); i >= 0 {
+		keywordLocation = validationErr.SchemaURL[i+1:]
+	}
+	if path := validationErr.ErrorKind.KeywordPath(); len(path) > 0 {
+		keywordLocation += "/" + strings.Join(path, "/")
+	}
+
+	*errs = append(*errs, CSAFValidationErrorDTO{
+		InstanceLocation: jsonPointer(validationErr.InstanceLocation),
+		KeywordLocation:  keywordLocation,
+		Message:          validationErr.ErrorKind.LocalizedString(csafValidationPrinter),
+	})
+}
+
+func jsonPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return b.String()
+}
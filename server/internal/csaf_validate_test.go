@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestValidateCSAFDocument(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	config := DefaultCSAFConfig()
+	config.PublisherName = "Example PSIRT"
+	config.PublisherNamespace = "https://psirt.example.com"
+
+	svc := NewService(NewRepository(db), WithCSAFConfig(config))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Example"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+		ProductVersionID: version.ID,
+		Category:         "cpe",
+		Metadata:         `{"cpe": "cpe:2.3:h:example:router:1.0.0:*:*:*:*:*:*:*"}`,
+	})
+	testutils.AssertNoError(t, err, "Should create helper")
+
+	t.Run("ExportedDocumentIsValid", func(t *testing.T) {
+		document, err := svc.ExportCSAFDocument(ctx, []string{product.ID}, ExportDocumentDTO{})
+		testutils.AssertNoError(t, err, "Should export CSAF document")
+
+		result, err := svc.ValidateCSAFDocument(document)
+		testutils.AssertNoError(t, err, "Should validate document")
+		testutils.AssertEqual(t, true, result.Valid, "Exported document should be valid")
+		testutils.AssertCount(t, 0, len(result.Errors), "Validation errors")
+	})
+
+	t.Run("InvalidDocument", func(t *testing.T) {
+		var document map[string]interface{}
+		err := json.Unmarshal([]byte(`{
+			"product_tree": {
+				"branches": [{"category": "vendor", "name": ""}]
+			}
+		}`), &document)
+		testutils.AssertNoError(t, err, "Should parse document")
+
+		result, err := svc.ValidateCSAFDocument(document)
+		testutils.AssertNoError(t, err, "Schema violations are not an error")
+		testutils.AssertEqual(t, false, result.Valid, "Document should be invalid")
+
+		locations := make(map[string]bool)
+		for _, validationErr := range result.Errors {
+			locations[validationErr.InstanceLocation] = true
+			if validationErr.Message == "" || validationErr.KeywordLocation == "" {
+				t.Errorf("Expected message and keyword location, got %+v", validationErr)
+			}
+		}
+		if !locations[""] {
+			t.Error("Expected the missing document section to be reported")
+		}
+		if !locations["/product_tree/branches/0/name"] {
+			t.Error("Expected the empty vendor name to be reported")
+		}
+	})
+
+	t.Run("MalformedHashesFailExport", func(t *testing.T) {
+		_, err := svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+			ProductVersionID: version.ID,
+			Category:         "hashes",
+			Metadata:         `{"file_hashes": [{"filename": "router.bin", "items": [{"algorithm": "sha256", "value": "not-a-hash"}]}]}`,
+		})
+		testutils.AssertNoError(t, err, "Should create helper")
+
+		_, err = svc.ExportCSAFProductTree(ctx, []string{product.ID})
+
+		var exportErr fuego.HTTPError
+		if !errors.As(err, &exportErr) {
+			t.Fatalf("Expected structured export error, got %v", err)
+		}
+		testutils.AssertEqual(t, http.StatusInternalServerError, exportErr.StatusCode(), "Status code")
+		if len(exportErr.Errors) == 0 {
+			t.Fatal("Expected validation error items")
+		}
+		for _, item := range exportErr.Errors {
+			if !strings.Contains(item.Name, "/product_identification_helper/hashes/0/file_hashes/0/value") {
+				t.Errorf("Unexpected error location %q", item.Name)
+			}
+		}
+	})
+
+	t.Run("ValidateEndpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("POST", "/api/v1/csaf/validate", bytes.NewBufferString(`{"document": {}}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var result CSAFValidationResultDTO
+		err := json.Unmarshal(w.Body.Bytes(), &result)
+		testutils.AssertNoError(t, err, "Should decode validation result")
+		testutils.AssertEqual(t, false, result.Valid, "Empty document should be invalid")
+		if len(result.Errors) == 0 {
+			t.Error("Expected validation errors")
+		}
+	})
+}
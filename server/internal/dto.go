@@ -1,5 +1,10 @@
 package internal
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Vendors
 type CreateVendorDTO struct {
 	Name        string `json:"name" example:"Vendor Name" validate:"required"`
@@ -20,7 +25,47 @@ type VendorDTO struct {
 
 // Products
 type ExportRequestDTO struct {
-	ProductIDs []string `json:"product_ids" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required,dive,uuid"`
+	ProductIDs      []string           `json:"product_ids,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,dive,uuid"`
+	VendorIDs       []string           `json:"vendor_ids,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,dive,uuid"`
+	FamilyIDs       []string           `json:"family_ids,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,dive,uuid"`
+	VersionIDs      []string           `json:"version_ids,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,dive,uuid"`
+	All             bool               `json:"all,omitempty" example:"false"`
+	Mode            string             `json:"mode,omitempty" example:"document" validate:"omitempty,oneof=product_tree document"`
+	Document        *ExportDocumentDTO `json:"document,omitempty"`
+	ProductIDScheme string             `json:"product_id_scheme,omitempty" example:"short" validate:"omitempty,oneof=uuid short"`
+	ProductIDPrefix string             `json:"product_id_prefix,omitempty" example:"CSAFPID"`
+	ProductGroups   []string           `json:"product_groups,omitempty" example:"family" validate:"omitempty,dive,oneof=family vendor product lifecycle"`
+}
+
+type ExportDocumentDTO struct {
+	Title      string            `json:"title,omitempty" example:"Product tree of Vendor Name"`
+	Category   string            `json:"category,omitempty" example:"csaf_base"`
+	Lang       string            `json:"lang,omitempty" example:"en"`
+	TrackingID string            `json:"tracking_id,omitempty" example:"PDB-20231001-1A2B3C4D"`
+	Status     string            `json:"status,omitempty" example:"draft" validate:"omitempty,oneof=draft interim final"`
+	Publisher  *CSAFPublisherDTO `json:"publisher,omitempty"`
+}
+
+type CSAFPublisherDTO struct {
+	Name           string `json:"name,omitempty" example:"Vendor Name"`
+	Namespace      string `json:"namespace,omitempty" example:"https://vendor.example.com" validate:"omitempty,url"`
+	Category       string `json:"category,omitempty" example:"vendor" validate:"omitempty,oneof=coordinator discoverer other translator user vendor"`
+	ContactDetails string `json:"contact_details,omitempty" example:"psirt@vendor.example.com"`
+}
+
+type CSAFImportDTO struct {
+	ProductTree map[string]interface{} `json:"product_tree" validate:"required"`
+}
+
+type CSAFValidationResultDTO struct {
+	Valid  bool                     `json:"valid" example:"false"`
+	Errors []CSAFValidationErrorDTO `json:"errors"`
+}
+
+type CSAFValidationErrorDTO struct {
+	InstanceLocation string `json:"instance_location" example:"/product_tree/branches/0/name"`
+	KeywordLocation  string `json:"keyword_location" example:"/$defs/branches_t/items/properties/name/minLength"`
+	Message          string `json:"message" example:"minLength: got 0, want 1"`
 }
 
 type CreateProductDTO struct {
@@ -29,6 +74,15 @@ type CreateProductDTO struct {
 	VendorID    string  `json:"vendor_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required,uuid"`
 	Type        string  `json:"type" example:"software" validate:"required,oneof=software hardware firmware"`
 	FamilyID    *string `json:"family_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	// VersionScheme orders the versions, natural order if empty.
+	VersionScheme  string `json:"version_scheme,omitempty" example:"semver" validate:"omitempty,oneof=natural semver calver numeric custom"`
+	VersionPattern string `json:"version_pattern,omitempty" example:"^R(\\d+)(?:SP(\\d+))?$"`
+	// EndOfSupport, EndOfSecurityUpdates, EndOfLife and SupportStatus are
+	// the lifecycle defaults of the versions of the product.
+	EndOfSupport         *string `json:"end_of_support,omitempty" example:"2027-12-31"`
+	EndOfSecurityUpdates *string `json:"end_of_security_updates,omitempty" example:"2029-12-31"`
+	EndOfLife            *string `json:"end_of_life,omitempty" example:"2030-12-31"`
+	SupportStatus        *string `json:"support_status,omitempty" example:"supported"`
 }
 
 type UpdateProductDTO struct {
@@ -37,21 +91,47 @@ type UpdateProductDTO struct {
 	VendorID    *string `json:"vendor_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
 	Type        *string `json:"type" example:"software" validate:"oneof=software hardware firmware"`
 	FamilyID    *string `json:"family_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	// VersionScheme orders the versions, natural order if empty.
+	VersionScheme  *string `json:"version_scheme,omitempty" example:"semver" validate:"omitempty,oneof=natural semver calver numeric custom"`
+	VersionPattern *string `json:"version_pattern,omitempty" example:"^R(\\d+)(?:SP(\\d+))?$"`
+	// EndOfSupport, EndOfSecurityUpdates, EndOfLife and SupportStatus are
+	// the lifecycle defaults of the versions of the product. Empty values
+	// clear them.
+	EndOfSupport         *string `json:"end_of_support,omitempty" example:"2027-12-31"`
+	EndOfSecurityUpdates *string `json:"end_of_security_updates,omitempty" example:"2029-12-31"`
+	EndOfLife            *string `json:"end_of_life,omitempty" example:"2030-12-31"`
+	SupportStatus        *string `json:"support_status,omitempty" example:"supported"`
 }
 
 type ProductDTO struct {
-	ID             string              `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
-	VendorID       *string             `json:"vendor_id" example:"123e4567-e89b-12d3-a456-426614174000"`
-	Name           string              `json:"name" example:"Product Name" validate:"required"`
-	FullName       string              `json:"full_name" example:"Vendor Name - Product Name" validate:"required"`
-	Description    string              `json:"description" example:"Product Description"`
-	Type           string              `json:"type" example:"software" validate:"required,oneof=software hardware firmware"`
-	FamilyID       *string             `json:"family_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	ID             string  `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	VendorID       *string `json:"vendor_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Name           string  `json:"name" example:"Product Name" validate:"required"`
+	FullName       string  `json:"full_name" example:"Vendor Name - Product Name" validate:"required"`
+	Description    string  `json:"description" example:"Product Description"`
+	Type           string  `json:"type" example:"software" validate:"required,oneof=software hardware firmware"`
+	FamilyID       *string `json:"family_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	VersionScheme  string  `json:"version_scheme" example:"semver"`
+	VersionPattern string  `json:"version_pattern,omitempty" example:"^R(\\d+)(?:SP(\\d+))?$"`
+	// EndOfSupport, EndOfSecurityUpdates, EndOfLife and SupportStatus are
+	// the lifecycle defaults of the versions.
+	EndOfSupport         *string `json:"end_of_support,omitempty" example:"2027-12-31"`
+	EndOfSecurityUpdates *string `json:"end_of_security_updates,omitempty" example:"2029-12-31"`
+	EndOfLife            *string `json:"end_of_life,omitempty" example:"2030-12-31"`
+	SupportStatus        string  `json:"support_status,omitempty" example:"supported"`
+	// Versions are sorted by the version scheme, LatestVersions holds the
+	// latest version of every release line, newest line first.
 	Versions       []ProductVersionDTO `json:"versions" validate:"dive"`
 	LatestVersions []ProductVersionDTO `json:"latest_versions" validate:"dive"`
 }
 
 func NodeToProductDTO(node Node) ProductDTO {
+	return nodeToProductDTO(node, time.Now())
+}
+
+// nodeToProductDTO converts a product with the support status of its
+// versions at the given time.
+func nodeToProductDTO(node Node, now time.Time) ProductDTO {
 	var fullName string
 	if node.Parent != nil {
 		fullName = node.Parent.Name + " " + node.Name
@@ -59,24 +139,25 @@ func NodeToProductDTO(node Node) ProductDTO {
 		fullName = node.Name
 	}
 
-	versions := make([]ProductVersionDTO, 0, len(node.Children))
-	for _, child := range node.Children {
-		versions = append(versions, ProductVersionDTO{
-			ID:          child.ID,
-			Name:        child.Name,
-			Description: child.Description,
-		})
-	}
+	versions, latestVersions := productVersionDTOs(node, versionsOf(node), now)
 
 	return ProductDTO{
-		ID:          node.ID,
-		VendorID:    node.ParentID,
-		Name:        node.Name,
-		FullName:    fullName,
-		Description: node.Description,
-		Type:        string(node.ProductType),
-		Versions:    versions,
-		FamilyID:    node.ProductFamilyID,
+		ID:             node.ID,
+		VendorID:       node.ParentID,
+		Name:           node.Name,
+		FullName:       fullName,
+		Description:    node.Description,
+		Type:           string(node.ProductType),
+		Versions:       versions,
+		LatestVersions: latestVersions,
+		FamilyID:       node.ProductFamilyID,
+		VersionScheme:  string(productVersionOrder(node).scheme),
+		VersionPattern: node.VersionPattern,
+
+		EndOfSupport:         formatDate(node.EndOfSupport),
+		EndOfSecurityUpdates: formatDate(node.EndOfSecurityUpdates),
+		EndOfLife:            formatDate(node.EndOfLife),
+		SupportStatus:        string(node.SupportStatus),
 	}
 }
 
@@ -86,6 +167,12 @@ type CreateProductVersionDTO struct {
 	ProductID     string  `json:"product_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required,uuid"`
 	ReleaseDate   *string `json:"release_date,omitempty" example:"2023-10-01" validate:"omitempty,datetime=2006-01-02"`
 	PredecessorID *string `json:"predecessor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	// EndOfSupport, EndOfSecurityUpdates, EndOfLife and SupportStatus
+	// override the lifecycle defaults of the product.
+	EndOfSupport         *string `json:"end_of_support,omitempty" example:"2027-12-31"`
+	EndOfSecurityUpdates *string `json:"end_of_security_updates,omitempty" example:"2029-12-31"`
+	EndOfLife            *string `json:"end_of_life,omitempty" example:"2030-12-31"`
+	SupportStatus        *string `json:"support_status,omitempty" example:"supported"`
 }
 
 type UpdateProductVersionDTO struct {
@@ -93,32 +180,141 @@ type UpdateProductVersionDTO struct {
 	ProductID     *string `json:"product_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
 	ReleaseDate   *string `json:"release_date" example:"2023-10-01" validate:"omitempty,datetime=2006-01-02"`
 	PredecessorID *string `json:"predecessor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	// EndOfSupport, EndOfSecurityUpdates, EndOfLife and SupportStatus
+	// override the lifecycle defaults of the product. Empty
+	// values clear them.
+	EndOfSupport         *string `json:"end_of_support,omitempty" example:"2027-12-31"`
+	EndOfSecurityUpdates *string `json:"end_of_security_updates,omitempty" example:"2029-12-31"`
+	EndOfLife            *string `json:"end_of_life,omitempty" example:"2030-12-31"`
+	SupportStatus        *string `json:"support_status,omitempty" example:"supported"`
 }
 
 type ProductVersionDTO struct {
-	ID            string  `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
-	ProductID     *string `json:"product_id" example:"123e4567-e89b-12d3-a456-426614174000"`
-	Name          string  `json:"name" example:"Version Name" validate:"required"`
-	FullName      string  `json:"full_name" example:"Product Name - Version Name" validate:"required"`
-	Description   string  `json:"description" example:"Version Description"`
-	IsLatest      bool    `json:"is_latest" example:"true" validate:"required"`
-	PredecessorID *string `json:"predecessor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
-	ReleasedAt    *string `json:"released_at,omitempty" example:"2023-10-01" validate:"omitempty,datetime=2006-01-02"`
+	ID          string  `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	ProductID   *string `json:"product_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Name        string  `json:"name" example:"Version Name" validate:"required"`
+	FullName    string  `json:"full_name" example:"Product Name - Version Name" validate:"required"`
+	Description string  `json:"description" example:"Version Description"`
+	IsLatest    bool    `json:"is_latest" example:"true" validate:"required"`
+	// IsLatestInLine is set for the latest version of a release line, e.g.
+	// of a major version.
+	IsLatestInLine bool    `json:"is_latest_in_line" example:"true"`
+	PredecessorID  *string `json:"predecessor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	SuccessorID    *string `json:"successor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
+	ReleasedAt     *string `json:"released_at,omitempty" example:"2023-10-01" validate:"omitempty,datetime=2006-01-02"`
+	// EndOfSupport, EndOfSecurityUpdates and EndOfLife are those of the
+	// version or else of its product. SupportStatus is the explicit status
+	// or the one reached today.
+	EndOfSupport         *string `json:"end_of_support,omitempty" example:"2027-12-31"`
+	EndOfSecurityUpdates *string `json:"end_of_security_updates,omitempty" example:"2029-12-31"`
+	EndOfLife            *string `json:"end_of_life,omitempty" example:"2030-12-31"`
+	SupportStatus        string  `json:"support_status,omitempty" example:"supported"`
 }
 
+// NodeToProductVersionDTO converts a version. The lifecycle defaults of the
+// product are only applied if its parent is loaded.
 func NodeToProductVersionDTO(node Node) ProductVersionDTO {
 	formattedDate := node.ReleasedAt.Time.Format("2006-01-02")
 
-	return ProductVersionDTO{
+	dto := ProductVersionDTO{
 		ID:            node.ID,
 		ProductID:     node.ParentID,
 		Name:          node.Name,
 		FullName:      node.Name,
 		Description:   node.Description,
 		IsLatest:      false,
-		PredecessorID: nil,
+		PredecessorID: node.PredecessorID,
 		ReleasedAt:    &formattedDate,
 	}
+
+	var product Node
+	if node.Parent != nil {
+		product = *node.Parent
+	}
+	versionLifecycle(product, node).apply(&dto, time.Now())
+
+	return dto
+}
+
+// VersionLineageDTO is a version with the chain of versions it succeeds and
+// the chain of versions succeeding it.
+type VersionLineageDTO struct {
+	Version ProductVersionDTO `json:"version"`
+	// Ancestors are the predecessors of the version, oldest first.
+	Ancestors []ProductVersionDTO `json:"ancestors"`
+	// Descendants are the successors of the version, nearest first.
+	Descendants []ProductVersionDTO `json:"descendants"`
+}
+
+// LifecycleMilestoneDTO is a version reaching a lifecycle milestone.
+type LifecycleMilestoneDTO struct {
+	Milestone      string            `json:"milestone" example:"end_of_support" validate:"required"`
+	Date           string            `json:"date" example:"2027-12-31" validate:"required"`
+	DaysLeft       int               `json:"days_left" example:"42"`
+	ProductVersion ProductVersionDTO `json:"product_version" validate:"required"`
+	Product        LookupNodeDTO     `json:"product" validate:"required"`
+	Vendor         LookupNodeDTO     `json:"vendor"`
+}
+
+// Version Ranges
+type CreateVersionRangeDTO struct {
+	ProductID    string  `json:"product_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required,uuid"`
+	Vers         *string `json:"vers,omitempty" example:"vers:generic/>=1.0.0|<4.2.1"`
+	Scheme       string  `json:"scheme,omitempty" example:"generic"`
+	Min          *string `json:"min,omitempty" example:"1.0.0"`
+	MinInclusive *bool   `json:"min_inclusive,omitempty" example:"true"`
+	Max          *string `json:"max,omitempty" example:"4.2.1"`
+	MaxInclusive *bool   `json:"max_inclusive,omitempty" example:"false"`
+	Description  string  `json:"description" example:"All versions before the fix"`
+}
+
+type UpdateVersionRangeDTO struct {
+	Vers         *string `json:"vers,omitempty" example:"vers:generic/>=1.0.0|<4.2.1"`
+	Scheme       string  `json:"scheme,omitempty" example:"generic"`
+	Min          *string `json:"min,omitempty" example:"1.0.0"`
+	MinInclusive *bool   `json:"min_inclusive,omitempty" example:"true"`
+	Max          *string `json:"max,omitempty" example:"4.2.1"`
+	MaxInclusive *bool   `json:"max_inclusive,omitempty" example:"false"`
+	Description  *string `json:"description" example:"All versions before the fix"`
+}
+
+type VersionRangeDTO struct {
+	ID           string  `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	ProductID    *string `json:"product_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Vers         string  `json:"vers" example:"vers:generic/>=1.0.0|<4.2.1" validate:"required"`
+	Description  string  `json:"description" example:"All versions before the fix"`
+	Min          *string `json:"min,omitempty" example:"1.0.0"`
+	MinInclusive *bool   `json:"min_inclusive,omitempty" example:"true"`
+	Max          *string `json:"max,omitempty" example:"4.2.1"`
+	MaxInclusive *bool   `json:"max_inclusive,omitempty" example:"false"`
+}
+
+// NodeToVersionRangeDTO converts a version range node. Min and max are only
+// set if the range is a single interval.
+func NodeToVersionRangeDTO(node Node) VersionRangeDTO {
+	dto := VersionRangeDTO{
+		ID:          node.ID,
+		ProductID:   node.ParentID,
+		Vers:        node.Name,
+		Description: node.Description,
+	}
+
+	if _, constraints, err := parseVers(node.Name); err == nil {
+		if min, max, ok := versBounds(constraints); ok {
+			if min != nil {
+				inclusive := min.Comparator == ">="
+				dto.Min = &min.Version
+				dto.MinInclusive = &inclusive
+			}
+			if max != nil {
+				inclusive := max.Comparator == "<="
+				dto.Max = &max.Version
+				dto.MaxInclusive = &inclusive
+			}
+		}
+	}
+
+	return dto
 }
 
 // Relationships
@@ -219,3 +415,187 @@ type UpdateProductFamilyDTO struct {
 	Name     string  `json:"name" example:"Family Name"`
 	ParentID *string `json:"parent_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"omitempty,uuid"`
 }
+
+// Imports
+type ImportReportDTO struct {
+	DryRun  bool             `json:"dry_run" example:"true"`
+	Summary ImportSummaryDTO `json:"summary"`
+	Items   []ImportItemDTO  `json:"items"`
+}
+
+type ImportSummaryDTO struct {
+	Created   int `json:"created" example:"3"`
+	Matched   int `json:"matched" example:"5"`
+	Conflicts int `json:"conflicts" example:"1"`
+	Skipped   int `json:"skipped" example:"0"`
+	Updated   int `json:"updated" example:"2"`
+	Errors    int `json:"errors" example:"0"`
+}
+
+type ImportItemDTO struct {
+	Action   string   `json:"action" example:"created" validate:"required,oneof=created matched conflict skipped updated error"`
+	Category string   `json:"category" example:"product_version" validate:"required"`
+	Name     string   `json:"name" example:"1.0.0"`
+	Path     []string `json:"path,omitempty" example:"['Vendor Name', 'Product Name', '1.0.0']"`
+	ID       string   `json:"id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Reason   string   `json:"reason,omitempty" example:"A different identification helper of this category already exists"`
+	Row      int      `json:"row,omitempty" example:"4"`
+}
+
+// Dumps
+type DumpDTO struct {
+	Format                string                        `json:"format" example:"product-database-dump" validate:"required"`
+	Version               int                           `json:"version" example:"1" validate:"required"`
+	CreatedAt             time.Time                     `json:"created_at" example:"2024-01-15T10:00:00Z"`
+	Nodes                 []DumpNodeDTO                 `json:"nodes"`
+	Relationships         []DumpRelationshipDTO         `json:"relationships"`
+	IdentificationHelpers []DumpIdentificationHelperDTO `json:"identification_helpers"`
+}
+
+type DumpNodeDTO struct {
+	ID              string     `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Category        string     `json:"category" example:"product_version" validate:"required,oneof=vendor product_family product_name product_version product_version_range"`
+	Name            string     `json:"name" example:"1.0.0"`
+	Description     string     `json:"description,omitempty" example:"Initial release"`
+	ParentID        *string    `json:"parent_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	ProductType     string     `json:"product_type,omitempty" example:"software"`
+	ProductFamilyID *string    `json:"product_family_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	ReleasedAt      *time.Time `json:"released_at,omitempty" example:"2023-10-01T00:00:00Z"`
+	PredecessorID   *string    `json:"predecessor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	// SuccessorID is only read from dumps of version 1, which stored the
+	// predecessor of a version under this name.
+	SuccessorID    *string `json:"successor_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	VersionScheme  string  `json:"version_scheme,omitempty" example:"semver"`
+	VersionPattern string  `json:"version_pattern,omitempty" example:"^R(\\d+)(?:SP(\\d+))?$"`
+
+	EndOfSupport         *time.Time `json:"end_of_support,omitempty" example:"2027-12-31T00:00:00Z"`
+	EndOfSecurityUpdates *time.Time `json:"end_of_security_updates,omitempty" example:"2029-12-31T00:00:00Z"`
+	EndOfLife            *time.Time `json:"end_of_life,omitempty" example:"2030-12-31T00:00:00Z"`
+	SupportStatus        string     `json:"support_status,omitempty" example:"supported"`
+}
+
+type DumpRelationshipDTO struct {
+	ID           string `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Category     string `json:"category" example:"default_component_of" validate:"required"`
+	SourceNodeID string `json:"source_node_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	TargetNodeID string `json:"target_node_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+}
+
+type DumpIdentificationHelperDTO struct {
+	ID       string          `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Category string          `json:"category" example:"cpe" validate:"required"`
+	Metadata json.RawMessage `json:"metadata"`
+	NodeID   string          `json:"node_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+}
+
+// Lookup
+type LookupResultDTO struct {
+	Identifier string           `json:"identifier" example:"pkg:npm/foo@1.2.3" validate:"required"`
+	Types      []string         `json:"types" example:"['purl']" validate:"required"`
+	Matches    []LookupMatchDTO `json:"matches" validate:"required"`
+}
+
+type LookupMatchDTO struct {
+	IdentifierType         string            `json:"identifier_type" example:"purl" validate:"required"`
+	IdentificationHelperID string            `json:"identification_helper_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	ProductVersion         ProductVersionDTO `json:"product_version" validate:"required"`
+	Product                LookupNodeDTO     `json:"product" validate:"required"`
+	Vendor                 LookupNodeDTO     `json:"vendor"`
+}
+
+type LookupNodeDTO struct {
+	ID   string `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Name string `json:"name" example:"Product Name"`
+}
+
+// Search
+type SearchResultDTO struct {
+	Query string         `json:"query" example:"router 2.0" validate:"required"`
+	Hits  []SearchHitDTO `json:"hits" validate:"required"`
+}
+
+type SearchHitDTO struct {
+	ID          string              `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Category    NodeCategory        `json:"category" example:"product_version" validate:"required"`
+	Name        string              `json:"name" example:"2.0.0" validate:"required"`
+	Description string              `json:"description" example:"Second major release"`
+	Snippet     string              `json:"snippet" example:"Acme <mark>Router</mark> 2.0.0"`
+	Score       float64             `json:"score" example:"12.5" validate:"required"`
+	Path        []SearchPathItemDTO `json:"path" validate:"required"`
+	PathLabel   string              `json:"path_label" example:"Acme › Routers › Router › 2.0.0" validate:"required"`
+}
+
+type SearchPathItemDTO struct {
+	ID       string       `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Category NodeCategory `json:"category" example:"vendor" validate:"required"`
+	Name     string       `json:"name" example:"Acme" validate:"required"`
+}
+
+// History
+type AuditEntryDTO struct {
+	ID         string           `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	EntityType string           `json:"entity_type" example:"product_version" validate:"required"`
+	EntityID   string           `json:"entity_id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Action     string           `json:"action" example:"update" validate:"required"`
+	Changes    []AuditChangeDTO `json:"changes" validate:"required"`
+	Actor      string           `json:"actor" example:"jdoe"`
+	RequestID  string           `json:"request_id" example:"5f8c3b1e-2d4a-4c6b-9e7f-1a2b3c4d5e6f"`
+	Timestamp  time.Time        `json:"timestamp" example:"2024-01-15T10:30:00Z" validate:"required"`
+}
+
+type AuditChangeDTO struct {
+	Field  string `json:"field" example:"name" validate:"required"`
+	Before any    `json:"before" example:"1.0"`
+	After  any    `json:"after" example:"1.0.0"`
+}
+
+func AuditEntryToDTO(entry AuditEntry) AuditEntryDTO {
+	changes := make([]AuditChangeDTO, len(entry.Changes))
+	for i, change := range entry.Changes {
+		changes[i] = AuditChangeDTO{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		}
+	}
+
+	return AuditEntryDTO{
+		ID:         entry.ID,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Action:     entry.Action,
+		Changes:    changes,
+		Actor:      entry.Actor,
+		RequestID:  entry.RequestID,
+		Timestamp:  entry.CreatedAt.UTC(),
+	}
+}
+
+// Trash
+type TrashItemDTO struct {
+	ID        string       `json:"id" example:"123e4567-e89b-12d3-a456-426614174000" validate:"required"`
+	Category  NodeCategory `json:"category" example:"product_name" validate:"required"`
+	Name      string       `json:"name" example:"Product Name" validate:"required"`
+	ParentID  *string      `json:"parent_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	NodeCount int64        `json:"node_count" example:"4" validate:"required"`
+	DeletedAt time.Time    `json:"deleted_at" example:"2024-01-15T10:30:00Z" validate:"required"`
+	PurgeAt   *time.Time   `json:"purge_at,omitempty" example:"2024-02-14T10:30:00Z"`
+}
+
+// TrashEntryToDTO converts a trash entry. PurgeAt is left empty if the
+// trash is not purged automatically.
+func TrashEntryToDTO(entry TrashEntry, retention time.Duration) TrashItemDTO {
+	item := TrashItemDTO{
+		ID:        entry.Node.ID,
+		Category:  entry.Node.Category,
+		Name:      entry.Node.Name,
+		ParentID:  entry.Node.ParentID,
+		NodeCount: entry.NodeCount,
+		DeletedAt: entry.Node.DeletedAt.Time.UTC(),
+	}
+	if retention > 0 {
+		purgeAt := item.DeletedAt.Add(retention)
+		item.PurgeAt = &purgeAt
+	}
+	return item
+}
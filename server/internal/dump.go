@@ -0,0 +1,626 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-fuego/fuego"
+	"github.com/google/uuid"
+)
+
+const (
+	// DumpFormat identifies a product database dump.
+	DumpFormat = "product-database-dump"
+	// DumpVersion is the version of the dump format written by Dump. It is
+	// increased whenever the format changes incompatibly.
+	DumpVersion = 2
+)
+
+const (
+	// RestoreModeEmpty restores a dump into a database without any data.
+	RestoreModeEmpty = "empty"
+	// RestoreModeMerge merges a dump into a database with existing data.
+	RestoreModeMerge = "merge"
+)
+
+// Conflict strategies for rows of a dump whose ID already exists with
+// different content.
+const (
+	OnConflictSkip      = "skip"
+	OnConflictOverwrite = "overwrite"
+	OnConflictNewID     = "new_id"
+)
+
+// dumpNodeCategories lists the node categories in the order they are dumped.
+var dumpNodeCategories = []NodeCategory{Vendor, ProductFamily, ProductName, ProductVersion, ProductVersionRange}
+
+// Dump returns all nodes, relationships and identification helpers in the
+// portable dump format.
+func (s *Service) Dump(ctx context.Context) (DumpDTO, error) {
+	dump := DumpDTO{
+		Format:                DumpFormat,
+		Version:               DumpVersion,
+		CreatedAt:             time.Now().UTC(),
+		Nodes:                 []DumpNodeDTO{},
+		Relationships:         []DumpRelationshipDTO{},
+		IdentificationHelpers: []DumpIdentificationHelperDTO{},
+	}
+
+	for _, category := range dumpNodeCategories {
+		nodes, err := s.repo.GetNodesByCategory(ctx, category)
+		if err != nil {
+			return DumpDTO{}, fuego.InternalServerError{
+				Title: "Failed to fetch nodes",
+				Err:   err,
+			}
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+		for _, node := range nodes {
+			dump.Nodes = append(dump.Nodes, dumpNode(node))
+		}
+	}
+
+	relationships, err := s.repo.GetAllRelationships(ctx)
+	if err != nil {
+		return DumpDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch relationships",
+			Err:   err,
+		}
+	}
+	for _, rel := range relationships {
+		dump.Relationships = append(dump.Relationships, dumpRelationship(rel))
+	}
+
+	helpers, err := s.repo.GetAllIdentificationHelpers(ctx)
+	if err != nil {
+		return DumpDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch identification helpers",
+			Err:   err,
+		}
+	}
+	for _, helper := range helpers {
+		dump.IdentificationHelpers = append(dump.IdentificationHelpers, dumpIdentificationHelper(helper))
+	}
+
+	return dump, nil
+}
+
+func dumpNode(node Node) DumpNodeDTO {
+	return DumpNodeDTO{
+		ID:              node.ID,
+		Category:        string(node.Category),
+		Name:            node.Name,
+		Description:     node.Description,
+		ParentID:        node.ParentID,
+		ProductType:     string(node.ProductType),
+		ProductFamilyID: node.ProductFamilyID,
+		PredecessorID:   node.PredecessorID,
+		VersionScheme:   string(node.VersionScheme),
+		VersionPattern:  node.VersionPattern,
+		SupportStatus:   string(node.SupportStatus),
+
+		ReleasedAt:           dumpTime(node.ReleasedAt),
+		EndOfSupport:         dumpTime(node.EndOfSupport),
+		EndOfSecurityUpdates: dumpTime(node.EndOfSecurityUpdates),
+		EndOfLife:            dumpTime(node.EndOfLife),
+	}
+}
+
+func dumpTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+func restoreTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func dumpRelationship(rel Relationship) DumpRelationshipDTO {
+	return DumpRelationshipDTO{
+		ID:           rel.ID,
+		Category:     string(rel.Category),
+		SourceNodeID: rel.SourceNodeID,
+		TargetNodeID: rel.TargetNodeID,
+	}
+}
+
+func dumpIdentificationHelper(helper IdentificationHelper) DumpIdentificationHelperDTO {
+	metadata := json.RawMessage(helper.Metadata)
+	if !json.Valid(metadata) {
+		// Keep metadata that is no JSON as string instead of failing the dump
+		metadata, _ = json.Marshal(string(helper.Metadata))
+	}
+	return DumpIdentificationHelperDTO{
+		ID:       helper.ID,
+		Category: string(helper.Category),
+		Metadata: metadata,
+		NodeID:   helper.NodeID,
+	}
+}
+
+// RestoreDump loads a dump created by Dump. In RestoreModeEmpty the database
+// must not contain any data. In RestoreModeMerge rows are matched by ID, rows
+// whose ID exists with different content are handled according to
+// onConflict: they are kept as they are, overwritten with the dumped content
+// or imported with a new ID. References of the dump are adjusted to new IDs.
+func (s *Service) RestoreDump(ctx context.Context, data []byte, mode, onConflict string, dryRun bool) (ImportReportDTO, error) {
+	var dump DumpDTO
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid dump",
+			Err:   err,
+		}
+	}
+
+	var errs []fuego.ErrorItem
+	if dump.Format != DumpFormat {
+		errs = append(errs, fuego.ErrorItem{Name: "format", Reason: fmt.Sprintf("Format must be '%s'", DumpFormat)})
+	}
+	if dump.Version < 1 || dump.Version > DumpVersion {
+		errs = append(errs, fuego.ErrorItem{Name: "version", Reason: fmt.Sprintf("Version %d is not supported", dump.Version)})
+	}
+	if mode != RestoreModeEmpty && mode != RestoreModeMerge {
+		errs = append(errs, fuego.ErrorItem{Name: "mode", Reason: "Mode must be empty or merge"})
+	}
+	switch onConflict {
+	case OnConflictSkip, OnConflictOverwrite, OnConflictNewID:
+	default:
+		errs = append(errs, fuego.ErrorItem{Name: "on_conflict", Reason: "Conflict strategy must be skip, overwrite or new_id"})
+	}
+	if len(errs) > 0 {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title:  "Invalid dump",
+			Errors: errs,
+		}
+	}
+	if dump.Version == 1 {
+		for i := range dump.Nodes {
+			dump.Nodes[i].PredecessorID, dump.Nodes[i].SuccessorID = dump.Nodes[i].SuccessorID, nil
+		}
+	}
+
+	return s.runImport(ctx, "Failed to restore dump", dryRun, func(repo Repository, report *ImportReportDTO) error {
+		r, err := newDumpRestorer(ctx, repo, report, onConflict)
+		if err != nil {
+			return err
+		}
+
+		if mode == RestoreModeEmpty && !r.isEmpty() {
+			return fuego.ConflictError{
+				Title:  "Database is not empty",
+				Detail: "Use the merge mode to restore a dump into a database with existing data or purge the trash",
+			}
+		}
+
+		if err := r.restoreNodes(dump.Nodes); err != nil {
+			return err
+		}
+		if err := r.restoreRelationships(dump.Relationships); err != nil {
+			return err
+		}
+		return r.restoreIdentificationHelpers(dump.IdentificationHelpers)
+	})
+}
+
+// dumpRestorer keeps the existing rows and the IDs assigned to the nodes of
+// the dump while a dump is restored.
+type dumpRestorer struct {
+	ctx        context.Context
+	repo       Repository
+	report     *ImportReportDTO
+	onConflict string
+
+	nodes         map[string]Node
+	relationships map[string]Relationship
+	helpers       map[string]IdentificationHelper
+
+	// trashed holds the IDs of the rows in the trash, which cannot be
+	// created again
+	trashed map[string]bool
+
+	// ids maps the node IDs of the dump to the IDs in the database
+	ids map[string]string
+}
+
+func newDumpRestorer(ctx context.Context, repo Repository, report *ImportReportDTO, onConflict string) (*dumpRestorer, error) {
+	r := &dumpRestorer{
+		ctx:           ctx,
+		repo:          repo,
+		report:        report,
+		onConflict:    onConflict,
+		nodes:         make(map[string]Node),
+		relationships: make(map[string]Relationship),
+		helpers:       make(map[string]IdentificationHelper),
+		trashed:       make(map[string]bool),
+		ids:           make(map[string]string),
+	}
+
+	for _, category := range dumpNodeCategories {
+		nodes, err := repo.GetNodesByCategory(ctx, category)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			r.nodes[node.ID] = node
+		}
+	}
+
+	relationships, err := repo.GetAllRelationships(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range relationships {
+		r.relationships[rel.ID] = rel
+	}
+
+	helpers, err := repo.GetAllIdentificationHelpers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, helper := range helpers {
+		r.helpers[helper.ID] = helper
+	}
+
+	trashed, err := repo.GetTrashedIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ids := range [][]string{trashed.Nodes, trashed.Relationships, trashed.IdentificationHelpers} {
+		for _, id := range ids {
+			r.trashed[id] = true
+		}
+	}
+
+	return r, nil
+}
+
+func (r *dumpRestorer) isEmpty() bool {
+	return len(r.nodes) == 0 && len(r.relationships) == 0 && len(r.helpers) == 0 && len(r.trashed) == 0
+}
+
+func (r *dumpRestorer) add(action, category, name, id, reason string) {
+	r.report.Items = append(r.report.Items, ImportItemDTO{
+		Action:   action,
+		Category: category,
+		Name:     name,
+		ID:       id,
+		Reason:   reason,
+	})
+}
+
+// resolve returns the new ID of a node of the dump or the ID itself if the
+// node already exists in the database.
+func (r *dumpRestorer) resolve(id string) (string, bool) {
+	if resolved, ok := r.ids[id]; ok {
+		return resolved, true
+	}
+	if _, ok := r.nodes[id]; ok {
+		return id, true
+	}
+	return "", false
+}
+
+// restoreNodes creates the nodes without references first, so parents,
+// families and predecessors can be set afterwards regardless of their order in
+// the dump.
+func (r *dumpRestorer) restoreNodes(dumped []DumpNodeDTO) error {
+	var pending []DumpNodeDTO
+
+	for _, dto := range dumped {
+		if _, ok := r.ids[dto.ID]; ok {
+			r.add(ImportActionError, dto.Category, dto.Name, dto.ID, "The ID occurs more than once in the dump")
+			continue
+		}
+		switch NodeCategory(dto.Category) {
+		case Vendor, ProductFamily, ProductName, ProductVersion, ProductVersionRange:
+		default:
+			r.add(ImportActionError, dto.Category, dto.Name, dto.ID, "Unknown node category")
+			continue
+		}
+
+		if r.trashed[dto.ID] {
+			if r.onConflict != OnConflictNewID {
+				r.add(ImportActionConflict, dto.Category, dto.Name, dto.ID, "A node with this ID is in the trash and was kept")
+				continue
+			}
+			id := uuid.New().String()
+			if err := r.createNode(dto, id); err != nil {
+				return err
+			}
+			r.add(ImportActionCreated, dto.Category, dto.Name, id, "A node with ID "+dto.ID+" is in the trash")
+			pending = append(pending, dto)
+			continue
+		}
+
+		existing, exists := r.nodes[dto.ID]
+		if !exists {
+			if err := r.createNode(dto, dto.ID); err != nil {
+				return err
+			}
+			r.add(ImportActionCreated, dto.Category, dto.Name, dto.ID, "")
+			pending = append(pending, dto)
+			continue
+		}
+
+		if sameDumpNode(dumpNode(existing), dto) {
+			r.ids[dto.ID] = dto.ID
+			r.add(ImportActionMatched, dto.Category, dto.Name, dto.ID, "")
+			continue
+		}
+
+		switch r.onConflict {
+		case OnConflictSkip:
+			r.ids[dto.ID] = dto.ID
+			r.add(ImportActionConflict, dto.Category, dto.Name, dto.ID, "A different node with this ID already exists and was kept")
+		case OnConflictOverwrite:
+			r.ids[dto.ID] = dto.ID
+			r.add(ImportActionUpdated, dto.Category, dto.Name, dto.ID, "")
+			pending = append(pending, dto)
+		case OnConflictNewID:
+			id := uuid.New().String()
+			if err := r.createNode(dto, id); err != nil {
+				return err
+			}
+			r.add(ImportActionCreated, dto.Category, dto.Name, id, "A different node with ID "+dto.ID+" already exists")
+			pending = append(pending, dto)
+		}
+	}
+
+	// Predecessors are set once all parents are known
+	predecessors := make(map[string]*string)
+	for _, dto := range pending {
+		id := r.ids[dto.ID]
+		node := r.nodes[id]
+		node.Category = NodeCategory(dto.Category)
+		node.Name = dto.Name
+		node.Description = dto.Description
+		node.ProductType = ProductType(dto.ProductType)
+		node.VersionScheme = VersionScheme(dto.VersionScheme)
+		node.VersionPattern = dto.VersionPattern
+		node.ReleasedAt = restoreTime(dto.ReleasedAt)
+		node.EndOfSupport = restoreTime(dto.EndOfSupport)
+		node.EndOfSecurityUpdates = restoreTime(dto.EndOfSecurityUpdates)
+		node.EndOfLife = restoreTime(dto.EndOfLife)
+		node.SupportStatus = SupportStatus(dto.SupportStatus)
+		node.ParentID = r.reference(dto, "parent_id", dto.ParentID)
+		node.ProductFamilyID = r.reference(dto, "product_family_id", dto.ProductFamilyID)
+		node.PredecessorID = nil
+		predecessors[id] = r.reference(dto, "predecessor_id", dto.PredecessorID)
+		r.nodes[id] = node
+	}
+
+	successors := make(map[string]string)
+	for _, node := range r.nodes {
+		if node.PredecessorID != nil {
+			successors[*node.PredecessorID] = node.ID
+		}
+	}
+	for _, dto := range pending {
+		id := r.ids[dto.ID]
+		predecessor := predecessors[id]
+		if predecessor == nil {
+			continue
+		}
+		if reason := r.invalidPredecessor(r.nodes[id], *predecessor, successors); reason != "" {
+			r.add(ImportActionConflict, "predecessor_id", dto.Name, id, reason)
+			continue
+		}
+		node := r.nodes[id]
+		node.PredecessorID = predecessor
+		r.nodes[id] = node
+		successors[*predecessor] = id
+	}
+
+	for _, dto := range pending {
+		id := r.ids[dto.ID]
+		if err := r.repo.UpdateNode(r.ctx, r.nodes[id]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// invalidPredecessor applies the rules of the API to a restored predecessor:
+// it must be another version of the same product without other successor
+// and must not succeed the version. It returns why the predecessor is
+// invalid or an empty string.
+func (r *dumpRestorer) invalidPredecessor(version Node, predecessorID string, successors map[string]string) string {
+	predecessor, ok := r.nodes[predecessorID]
+	switch {
+	case version.Category != ProductVersion || !ok || predecessor.Category != ProductVersion:
+		return "Predecessor " + predecessorID + " is not a product version and was removed"
+	case predecessorID == version.ID:
+		return "A version cannot be its own predecessor, the predecessor was removed"
+	case version.ParentID == nil || predecessor.ParentID == nil || *version.ParentID != *predecessor.ParentID:
+		return "Predecessor " + predecessorID + " is a version of another product and was removed"
+	}
+	if successor, ok := successors[predecessorID]; ok && successor != version.ID {
+		return "The version with ID " + successor + " already succeeds " + predecessorID + ", the predecessor was removed"
+	}
+	visited := make(map[string]bool)
+	for id := predecessor.PredecessorID; id != nil && !visited[*id]; id = r.nodes[*id].PredecessorID {
+		if *id == version.ID {
+			return "Predecessor " + predecessorID + " succeeds the version, the predecessor was removed"
+		}
+		visited[*id] = true
+	}
+	return ""
+}
+
+func (r *dumpRestorer) createNode(dto DumpNodeDTO, id string) error {
+	node, err := r.repo.CreateNode(r.ctx, Node{
+		ID:       id,
+		Category: NodeCategory(dto.Category),
+		Name:     dto.Name,
+	})
+	if err != nil {
+		return err
+	}
+	r.nodes[id] = node
+	r.ids[dto.ID] = id
+	return nil
+}
+
+// reference resolves a reference of a node of the dump. References to nodes
+// that are neither part of the dump nor of the database are removed.
+func (r *dumpRestorer) reference(dto DumpNodeDTO, field string, id *string) *string {
+	if id == nil {
+		return nil
+	}
+	resolved, ok := r.resolve(*id)
+	if !ok {
+		r.add(ImportActionSkipped, field, dto.Name, r.ids[dto.ID], "The referenced node "+*id+" does not exist")
+		return nil
+	}
+	return &resolved
+}
+
+func (r *dumpRestorer) restoreRelationships(dumped []DumpRelationshipDTO) error {
+	for _, dto := range dumped {
+		rel := Relationship{ID: dto.ID, Category: RelationshipCategory(dto.Category)}
+
+		var sourceOK, targetOK bool
+		rel.SourceNodeID, sourceOK = r.resolve(dto.SourceNodeID)
+		rel.TargetNodeID, targetOK = r.resolve(dto.TargetNodeID)
+		if !sourceOK || !targetOK {
+			r.add(ImportActionSkipped, "relationship", dto.Category, dto.ID, "Relationship references a node that does not exist")
+			continue
+		}
+
+		existing, exists := r.relationships[dto.ID]
+		if exists && existing.Category == rel.Category && existing.SourceNodeID == rel.SourceNodeID && existing.TargetNodeID == rel.TargetNodeID {
+			r.add(ImportActionMatched, "relationship", dto.Category, dto.ID, "")
+			continue
+		}
+
+		reason := ""
+		if r.trashed[dto.ID] {
+			if r.onConflict != OnConflictNewID {
+				r.add(ImportActionConflict, "relationship", dto.Category, dto.ID, "A relationship with this ID is in the trash and was kept")
+				continue
+			}
+			rel.ID = uuid.New().String()
+			reason = "A relationship with ID " + dto.ID + " is in the trash"
+		} else if exists {
+			switch r.onConflict {
+			case OnConflictSkip:
+				r.add(ImportActionConflict, "relationship", dto.Category, dto.ID, "A different relationship with this ID already exists and was kept")
+				continue
+			case OnConflictOverwrite:
+				if err := r.repo.UpdateRelationship(r.ctx, rel); err != nil {
+					return err
+				}
+				r.relationships[rel.ID] = rel
+				r.add(ImportActionUpdated, "relationship", dto.Category, rel.ID, "")
+				continue
+			case OnConflictNewID:
+				rel.ID = uuid.New().String()
+				reason = "A different relationship with ID " + dto.ID + " already exists"
+			}
+		}
+
+		created, err := r.repo.CreateRelationship(r.ctx, rel)
+		if err != nil {
+			return err
+		}
+		r.relationships[created.ID] = created
+		r.add(ImportActionCreated, "relationship", dto.Category, created.ID, reason)
+	}
+
+	return nil
+}
+
+func (r *dumpRestorer) restoreIdentificationHelpers(dumped []DumpIdentificationHelperDTO) error {
+	for _, dto := range dumped {
+		helper := IdentificationHelper{
+			ID:       dto.ID,
+			Category: IdentificationHelperCategory(dto.Category),
+			Metadata: []byte(dto.Metadata),
+		}
+
+		var ok bool
+		helper.NodeID, ok = r.resolve(dto.NodeID)
+		if !ok {
+			r.add(ImportActionSkipped, "identification_helper", dto.Category, dto.ID, "Identification helper references a node that does not exist")
+			continue
+		}
+
+		existing, exists := r.helpers[dto.ID]
+		if exists && existing.Category == helper.Category && existing.NodeID == helper.NodeID && jsonEqual(existing.Metadata, helper.Metadata) {
+			r.add(ImportActionMatched, "identification_helper", dto.Category, dto.ID, "")
+			continue
+		}
+
+		reason := ""
+		if r.trashed[dto.ID] {
+			if r.onConflict != OnConflictNewID {
+				r.add(ImportActionConflict, "identification_helper", dto.Category, dto.ID, "An identification helper with this ID is in the trash and was kept")
+				continue
+			}
+			helper.ID = uuid.New().String()
+			reason = "An identification helper with ID " + dto.ID + " is in the trash"
+		} else if exists {
+			switch r.onConflict {
+			case OnConflictSkip:
+				r.add(ImportActionConflict, "identification_helper", dto.Category, dto.ID, "A different identification helper with this ID already exists and was kept")
+				continue
+			case OnConflictOverwrite:
+				if err := r.repo.UpdateIdentificationHelper(r.ctx, helper); err != nil {
+					return err
+				}
+				r.helpers[helper.ID] = helper
+				r.add(ImportActionUpdated, "identification_helper", dto.Category, helper.ID, "")
+				continue
+			case OnConflictNewID:
+				helper.ID = uuid.New().String()
+				reason = "A different identification helper with ID " + dto.ID + " already exists"
+			}
+		}
+
+		created, err := r.repo.CreateIdentificationHelper(r.ctx, helper)
+		if err != nil {
+			return err
+		}
+		r.helpers[created.ID] = created
+		r.add(ImportActionCreated, "identification_helper", dto.Category, created.ID, reason)
+	}
+
+	return nil
+}
+
+// sameDumpNode compares two dumped nodes including their references.
+func sameDumpNode(a, b DumpNodeDTO) bool {
+	sameRef := func(x, y *string) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	sameTime := func(x, y *time.Time) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && x.Equal(*y))
+	}
+
+	return a.ID == b.ID &&
+		a.Category == b.Category &&
+		a.Name == b.Name &&
+		a.Description == b.Description &&
+		a.ProductType == b.ProductType &&
+		a.VersionScheme == b.VersionScheme &&
+		a.VersionPattern == b.VersionPattern &&
+		sameRef(a.ParentID, b.ParentID) &&
+		sameRef(a.ProductFamilyID, b.ProductFamilyID) &&
+		sameRef(a.PredecessorID, b.PredecessorID) &&
+		a.SupportStatus == b.SupportStatus &&
+		sameTime(a.ReleasedAt, b.ReleasedAt) &&
+		sameTime(a.EndOfSupport, b.EndOfSupport) &&
+		sameTime(a.EndOfSecurityUpdates, b.EndOfSecurityUpdates) &&
+		sameTime(a.EndOfLife, b.EndOfLife)
+}
@@ -0,0 +1,322 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestDumpAndRestore(t *testing.T) {
+	source := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, source)
+
+	svc := NewService(NewRepository(source))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme", Description: "Acme Corp"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	network, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Network"})
+	testutils.AssertNoError(t, err, "Should create family")
+	wireless, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Wireless", ParentID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create sub family")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware", FamilyID: &wireless.ID})
+	testutils.AssertNoError(t, err, "Should create product")
+	releaseDate := "2024-01-15"
+	first, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0.0", ProductID: product.ID, ReleaseDate: &releaseDate})
+	testutils.AssertNoError(t, err, "Should create first version")
+	second, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0.0", ProductID: product.ID, PredecessorID: &first.ID})
+	testutils.AssertNoError(t, err, "Should create second version")
+	err = svc.CreateRelationship(ctx, CreateRelationshipDTO{Category: string(DefaultComponentOf), SourceNodeIDs: []string{first.ID}, TargetNodeIDs: []string{second.ID}})
+	testutils.AssertNoError(t, err, "Should create relationship")
+	_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{ProductVersionID: first.ID, Category: "cpe", Metadata: `{"cpe": "cpe:2.3:h:acme:router:1.0.0:*:*:*:*:*:*:*"}`})
+	testutils.AssertNoError(t, err, "Should create identification helper")
+
+	dump, err := svc.Dump(ctx)
+	testutils.AssertNoError(t, err, "Should dump database")
+	testutils.AssertEqual(t, DumpFormat, dump.Format, "Format")
+	testutils.AssertEqual(t, DumpVersion, dump.Version, "Version")
+	testutils.AssertCount(t, 6, len(dump.Nodes), "Nodes")
+	testutils.AssertCount(t, 1, len(dump.Relationships), "Relationships")
+	testutils.AssertCount(t, 1, len(dump.IdentificationHelpers), "Identification helpers")
+
+	data, err := json.Marshal(dump)
+	testutils.AssertNoError(t, err, "Should encode dump")
+
+	target := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, target)
+	targetSvc := NewService(NewRepository(target))
+
+	t.Run("RestoreIntoEmptyDatabase", func(t *testing.T) {
+		report, err := targetSvc.RestoreDump(ctx, data, RestoreModeEmpty, OnConflictSkip, false)
+		testutils.AssertNoError(t, err, "Should restore dump")
+		testutils.AssertEqual(t, 8, report.Summary.Created, "Created")
+		testutils.AssertEqual(t, 0, report.Summary.Skipped, "Skipped")
+
+		restored, err := targetSvc.Dump(ctx)
+		testutils.AssertNoError(t, err, "Should dump restored database")
+		restored.CreatedAt = dump.CreatedAt
+		expected, _ := json.Marshal(dump)
+		actual, _ := json.Marshal(restored)
+		testutils.AssertEqual(t, string(expected), string(actual), "Restored dump")
+	})
+
+	t.Run("EmptyModeRequiresEmptyDatabase", func(t *testing.T) {
+		_, err := targetSvc.RestoreDump(ctx, data, RestoreModeEmpty, OnConflictSkip, false)
+		var conflict fuego.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Errorf("Expected ConflictError, got %v", err)
+		}
+	})
+
+	t.Run("MergeMatchesExistingRows", func(t *testing.T) {
+		report, err := targetSvc.RestoreDump(ctx, data, RestoreModeMerge, OnConflictSkip, false)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 8, report.Summary.Matched, "Matched")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Created")
+	})
+
+	// The dumped vendor gets renamed to conflict with the restored one
+	changed := dump
+	changed.Nodes = append([]DumpNodeDTO(nil), dump.Nodes...)
+	for i, node := range changed.Nodes {
+		if node.ID == vendor.ID {
+			changed.Nodes[i].Name = "Acme Inc."
+		}
+	}
+	changedData, err := json.Marshal(changed)
+	testutils.AssertNoError(t, err, "Should encode changed dump")
+
+	vendorName := func(t *testing.T) string {
+		v, err := targetSvc.GetVendorByID(ctx, vendor.ID)
+		testutils.AssertNoError(t, err, "Should get vendor")
+		return v.Name
+	}
+
+	t.Run("ConflictSkip", func(t *testing.T) {
+		report, err := targetSvc.RestoreDump(ctx, changedData, RestoreModeMerge, OnConflictSkip, false)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 1, report.Summary.Conflicts, "Conflicts")
+		testutils.AssertEqual(t, "Acme", vendorName(t), "Vendor must be kept")
+	})
+
+	t.Run("ConflictNewIDDryRun", func(t *testing.T) {
+		report, err := targetSvc.RestoreDump(ctx, changedData, RestoreModeMerge, OnConflictNewID, true)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 1, report.Summary.Created, "Created")
+
+		var created ImportItemDTO
+		for _, item := range report.Items {
+			if item.Action == ImportActionCreated {
+				created = item
+			}
+		}
+		testutils.AssertEqual(t, "Acme Inc.", created.Name, "Created vendor")
+		if created.ID == vendor.ID {
+			t.Error("Expected the vendor to get a new ID")
+		}
+
+		vendors, err := targetSvc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 1, len(vendors), "Dry run must not persist the vendor")
+	})
+
+	t.Run("ConflictOverwrite", func(t *testing.T) {
+		report, err := targetSvc.RestoreDump(ctx, changedData, RestoreModeMerge, OnConflictOverwrite, false)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 1, report.Summary.Updated, "Updated")
+		testutils.AssertEqual(t, "Acme Inc.", vendorName(t), "Vendor must be overwritten")
+
+		products, err := targetSvc.ListVendorProducts(ctx, vendor.ID)
+		testutils.AssertNoError(t, err, "Should list vendor products")
+		testutils.AssertCount(t, 1, len(products), "Overwriting must keep the products of the vendor")
+	})
+
+	t.Run("UnknownReferences", func(t *testing.T) {
+		partial := DumpDTO{
+			Format:  DumpFormat,
+			Version: DumpVersion,
+			Nodes: []DumpNodeDTO{
+				{ID: "8c5e8f5a-0000-4000-8000-000000000001", Category: string(ProductName), Name: "Orphan", ParentID: &[]string{"8c5e8f5a-0000-4000-8000-00000000ffff"}[0]},
+			},
+			Relationships: []DumpRelationshipDTO{
+				{ID: "8c5e8f5a-0000-4000-8000-000000000002", Category: string(DefaultComponentOf), SourceNodeID: "8c5e8f5a-0000-4000-8000-00000000ffff", TargetNodeID: first.ID},
+			},
+		}
+		partialData, _ := json.Marshal(partial)
+
+		report, err := targetSvc.RestoreDump(ctx, partialData, RestoreModeMerge, OnConflictSkip, true)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 1, report.Summary.Created, "Created")
+		testutils.AssertEqual(t, 2, report.Summary.Skipped, "Skipped references")
+	})
+
+	t.Run("InvalidPredecessors", func(t *testing.T) {
+		id := func(n int) string {
+			return fmt.Sprintf("8c5e8f5a-0000-4000-8000-%012d", n)
+		}
+		version := func(n int, productID string, predecessor int) DumpNodeDTO {
+			node := DumpNodeDTO{ID: id(n), Category: string(ProductVersion), Name: fmt.Sprintf("%d.0", n), ParentID: &productID}
+			if predecessor != 0 {
+				node.PredecessorID = &[]string{id(predecessor)}[0]
+			}
+			return node
+		}
+		switchID := id(1)
+		firewallID := id(2)
+		lineage := DumpDTO{
+			Format:  DumpFormat,
+			Version: DumpVersion,
+			Nodes: []DumpNodeDTO{
+				{ID: switchID, Category: string(ProductName), Name: "Switch", ParentID: &vendor.ID},
+				{ID: firewallID, Category: string(ProductName), Name: "Firewall", ParentID: &vendor.ID},
+				version(10, switchID, 0),
+				version(11, switchID, 10),
+				version(12, switchID, 10),   // second successor
+				version(13, firewallID, 10), // other product
+				version(14, switchID, 14),   // itself
+				version(15, switchID, 16),   // cycle
+				version(16, switchID, 15),
+			},
+		}
+		successor := version(17, product.ID, 0)
+		successor.PredecessorID = &first.ID // already succeeded by the second version
+		lineage.Nodes = append(lineage.Nodes, successor)
+		lineageData, _ := json.Marshal(lineage)
+
+		report, err := targetSvc.RestoreDump(ctx, lineageData, RestoreModeMerge, OnConflictSkip, true)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 10, report.Summary.Created, "Created")
+		conflicts := make(map[string]bool)
+		for _, item := range report.Items {
+			if item.Action == ImportActionConflict {
+				testutils.AssertEqual(t, "predecessor_id", item.Category, "Conflict category")
+				conflicts[item.ID] = true
+			}
+		}
+		testutils.AssertCount(t, 5, len(conflicts), "Invalid predecessors")
+		for _, n := range []int{12, 13, 14, 16, 17} {
+			if !conflicts[id(n)] {
+				t.Errorf("Expected a conflict for the predecessor of %s", id(n))
+			}
+		}
+	})
+
+	t.Run("InvalidDump", func(t *testing.T) {
+		for _, input := range []string{`not json`, `{"format": "other", "version": 1}`, `{"format": "product-database-dump", "version": 99}`} {
+			_, err := targetSvc.RestoreDump(ctx, []byte(input), RestoreModeMerge, OnConflictSkip, false)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %s, got %v", input, err)
+			}
+		}
+
+		_, err := targetSvc.RestoreDump(ctx, data, "replace", "ignore", false)
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Fatalf("Expected BadRequestError, got %v", err)
+		}
+		testutils.AssertCount(t, 2, len(badRequest.Errors), "Invalid mode and conflict strategy")
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, targetSvc)
+
+		req := httptest.NewRequest("GET", "/api/v1/catalog/dump", nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Dump status code")
+		body := w.Body.Bytes()
+
+		req = httptest.NewRequest("POST", "/api/v1/catalog/dump", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusConflict, w.Code, "Restore into non-empty database with default mode")
+
+		req = httptest.NewRequest("POST", "/api/v1/catalog/dump?mode=merge", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Merge status code")
+
+		var report ImportReportDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &report), "Should decode report")
+		testutils.AssertEqual(t, 8, report.Summary.Matched, "Matched")
+	})
+}
+
+func TestRestoreDumpWithTrash(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{ProductVersionID: version.ID, Category: "sku", Metadata: `{"sku": "R-100"}`})
+	testutils.AssertNoError(t, err, "Should create identification helper")
+
+	dump, err := svc.Dump(ctx)
+	testutils.AssertNoError(t, err, "Should dump database")
+	data, err := json.Marshal(dump)
+	testutils.AssertNoError(t, err, "Should encode dump")
+
+	testutils.AssertNoError(t, svc.DeleteVendor(ctx, vendor.ID), "Should trash vendor")
+
+	t.Run("EmptyModeRejectsTrash", func(t *testing.T) {
+		_, err := svc.RestoreDump(ctx, data, RestoreModeEmpty, OnConflictSkip, false)
+		var conflict fuego.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Errorf("Expected ConflictError, got %v", err)
+		}
+	})
+
+	t.Run("MergeKeepsTrash", func(t *testing.T) {
+		report, err := svc.RestoreDump(ctx, data, RestoreModeMerge, OnConflictSkip, false)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 3, report.Summary.Conflicts, "Conflicts")
+		testutils.AssertEqual(t, 1, report.Summary.Skipped, "Helper of the trashed version")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Created")
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 0, len(vendors), "Vendors")
+	})
+
+	t.Run("MergeNewID", func(t *testing.T) {
+		report, err := svc.RestoreDump(ctx, data, RestoreModeMerge, OnConflictNewID, false)
+		testutils.AssertNoError(t, err, "Should merge dump")
+		testutils.AssertEqual(t, 4, report.Summary.Created, "Created")
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 1, len(vendors), "Vendors")
+		if vendors[0].ID == vendor.ID {
+			t.Error("Restored vendor must get a new ID")
+		}
+
+		products, err := svc.ListProducts(ctx)
+		testutils.AssertNoError(t, err, "Should list products")
+		testutils.AssertCount(t, 1, len(products), "Products")
+		if products[0].VendorID == nil || *products[0].VendorID != vendors[0].ID {
+			t.Errorf("Expected the restored product to belong to vendor %s", vendors[0].ID)
+		}
+	})
+}
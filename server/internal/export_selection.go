@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// exportSelection is the resolved set of products to export. Versions maps
+// the products that were only selected through single versions to these
+// versions; all other products are exported with every version.
+type exportSelection struct {
+	ProductIDs []string
+	Versions   map[string]map[string]bool
+}
+
+// includesVersion reports whether a version of a product is part of the
+// selection.
+func (sel exportSelection) includesVersion(productID, versionID string) bool {
+	versions, restricted := sel.Versions[productID]
+	return !restricted || versions[versionID]
+}
+
+// resolveExportSelection expands vendor, family, version and all-products
+// criteria into product IDs.
+func (s *Service) resolveExportSelection(ctx context.Context, productIDs []string, options ExportOptions) (exportSelection, error) {
+	selected := append([]string(nil), productIDs...)
+
+	if options.AllProducts || len(options.FamilyIDs) > 0 {
+		products, err := s.repo.GetNodesByCategory(ctx, ProductName)
+		if err != nil {
+			return exportSelection{}, fuego.InternalServerError{
+				Title: "Failed to list products",
+				Err:   err,
+			}
+		}
+
+		var families map[string]bool
+		if !options.AllProducts {
+			families, err = s.expandFamilies(ctx, options.FamilyIDs)
+			if err != nil {
+				return exportSelection{}, err
+			}
+		}
+
+		for _, product := range products {
+			if options.AllProducts || (product.ProductFamilyID != nil && families[*product.ProductFamilyID]) {
+				selected = append(selected, product.ID)
+			}
+		}
+	}
+
+	for _, vendorID := range options.VendorIDs {
+		vendor, err := s.getNodeForExport(ctx, vendorID, Vendor, "Vendor not found")
+		if err != nil {
+			return exportSelection{}, err
+		}
+		for _, product := range vendor.Children {
+			if product.Category == ProductName {
+				selected = append(selected, product.ID)
+			}
+		}
+	}
+
+	selected = uniqueStrings(selected)
+	wholeProducts := make(map[string]bool, len(selected))
+	for _, id := range selected {
+		wholeProducts[id] = true
+	}
+
+	versions := make(map[string]map[string]bool)
+	for _, versionID := range options.VersionIDs {
+		version, err := s.getNodeForExport(ctx, versionID, ProductVersion, "Product version not found")
+		if err != nil {
+			return exportSelection{}, err
+		}
+		if version.ParentID == nil || wholeProducts[*version.ParentID] {
+			continue
+		}
+
+		productID := *version.ParentID
+		if versions[productID] == nil {
+			versions[productID] = make(map[string]bool)
+			selected = append(selected, productID)
+		}
+		versions[productID][version.ID] = true
+	}
+
+	return exportSelection{ProductIDs: selected, Versions: versions}, nil
+}
+
+// expandFamilies returns the given families together with all of their
+// subfamilies.
+func (s *Service) expandFamilies(ctx context.Context, familyIDs []string) (map[string]bool, error) {
+	families, err := s.repo.GetNodesByCategory(ctx, ProductFamily)
+	if err != nil {
+		return nil, fuego.InternalServerError{
+			Title: "Failed to list product families",
+			Err:   err,
+		}
+	}
+
+	children := make(map[string][]string)
+	known := make(map[string]bool, len(families))
+	for _, family := range families {
+		known[family.ID] = true
+		if family.ParentID != nil {
+			children[*family.ParentID] = append(children[*family.ParentID], family.ID)
+		}
+	}
+
+	result := make(map[string]bool)
+	queue := make([]string, 0, len(familyIDs))
+	for _, id := range familyIDs {
+		if !known[id] {
+			return nil, fuego.NotFoundError{
+				Title: "Product family not found",
+				Err:   nil,
+			}
+		}
+		queue = append(queue, id)
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if result[id] {
+			continue
+		}
+		result[id] = true
+		queue = append(queue, children[id]...)
+	}
+
+	return result, nil
+}
+
+func (s *Service) getNodeForExport(ctx context.Context, id string, category NodeCategory, notFoundTitle string) (Node, error) {
+	node, err := s.repo.GetNodeByID(ctx, id, WithChildren())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Node{}, fuego.NotFoundError{Title: notFoundTitle, Err: nil}
+		}
+		return Node{}, fuego.InternalServerError{
+			Title: "Failed to fetch node",
+			Err:   err,
+		}
+	}
+
+	if node.Category != category {
+		return Node{}, fuego.NotFoundError{Title: notFoundTitle, Err: nil}
+	}
+
+	return node, nil
+}
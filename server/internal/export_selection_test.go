@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"sort"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+// csafBranchNames collects the names of all branches of a category.
+func csafBranchNames(node interface{}, category string) []string {
+	var names []string
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if n["category"] == category {
+			names = append(names, csafBranchName(n))
+		}
+		for _, key := range []string{"product_tree", "branches"} {
+			if child, ok := n[key]; ok {
+				names = append(names, csafBranchNames(child, category)...)
+			}
+		}
+	case []interface{}:
+		for _, child := range n {
+			names = append(names, csafBranchNames(child, category)...)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestExportSelection(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	acme, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	other, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Other"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+
+	network, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Network"})
+	testutils.AssertNoError(t, err, "Should create family")
+	wireless, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Wireless", ParentID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create subfamily")
+
+	router, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: acme.ID, Type: "hardware", FamilyID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create product")
+	accessPoint, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Access Point", VendorID: acme.ID, Type: "hardware", FamilyID: &wireless.ID})
+	testutils.AssertNoError(t, err, "Should create product")
+	_, err = svc.CreateProduct(ctx, CreateProductDTO{Name: "Cloud", VendorID: acme.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create product")
+	_, err = svc.CreateProduct(ctx, CreateProductDTO{Name: "Switch", VendorID: other.ID, Type: "hardware", FamilyID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create product")
+
+	versionIDs := make(map[string]string)
+	for _, name := range []string{"1.0", "2.0", "2.1"} {
+		version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: router.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+		versionIDs[name] = version.ID
+	}
+
+	tests := []struct {
+		name     string
+		products []string
+		opts     []ExportOption
+		expected []string
+		versions []string
+	}{
+		{
+			name:     "Vendor",
+			opts:     []ExportOption{WithVendors(acme.ID)},
+			expected: []string{"Access Point", "Cloud", "Router"},
+			versions: []string{"1.0", "2.0", "2.1"},
+		},
+		{
+			name:     "FamilyIncludesSubfamilies",
+			opts:     []ExportOption{WithFamilies(network.ID)},
+			expected: []string{"Access Point", "Router", "Switch"},
+			versions: []string{"1.0", "2.0", "2.1"},
+		},
+		{
+			name:     "Subfamily",
+			opts:     []ExportOption{WithFamilies(wireless.ID)},
+			expected: []string{"Access Point"},
+		},
+		{
+			name:     "Versions",
+			opts:     []ExportOption{WithVersions(versionIDs["2.0"], versionIDs["2.1"])},
+			expected: []string{"Router"},
+			versions: []string{"2.0", "2.1"},
+		},
+		{
+			name:     "WholeProductWinsOverVersions",
+			products: []string{router.ID},
+			opts:     []ExportOption{WithVersions(versionIDs["2.0"])},
+			expected: []string{"Router"},
+			versions: []string{"1.0", "2.0", "2.1"},
+		},
+		{
+			name:     "AllProducts",
+			opts:     []ExportOption{WithAllProducts()},
+			expected: []string{"Access Point", "Cloud", "Router", "Switch"},
+			versions: []string{"1.0", "2.0", "2.1"},
+		},
+		{
+			name:     "Combined",
+			products: []string{accessPoint.ID},
+			opts:     []ExportOption{WithVendors(other.ID)},
+			expected: []string{"Access Point", "Switch"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.ExportCSAFProductTree(ctx, tt.products, tt.opts...)
+			testutils.AssertNoError(t, err, "Should export selection")
+
+			products := csafBranchNames(result, "product_name")
+			testutils.AssertEqual(t, len(tt.expected), len(products), "Exported product count")
+			for i := range tt.expected {
+				if i < len(products) {
+					testutils.AssertEqual(t, tt.expected[i], products[i], "Exported product")
+				}
+			}
+
+			versions := csafBranchNames(result, "product_version")
+			testutils.AssertEqual(t, len(tt.versions), len(versions), "Exported version count")
+			for i := range tt.versions {
+				if i < len(versions) {
+					testutils.AssertEqual(t, tt.versions[i], versions[i], "Exported version")
+				}
+			}
+		})
+	}
+
+	t.Run("UnknownIDs", func(t *testing.T) {
+		for _, opt := range []ExportOption{WithVendors(router.ID), WithFamilies(acme.ID), WithVersions(router.ID)} {
+			_, err := svc.ExportCSAFProductTree(ctx, nil, opt)
+
+			var notFound fuego.NotFoundError
+			if !errors.As(err, &notFound) {
+				t.Errorf("Expected NotFoundError, got %v", err)
+			}
+		}
+	})
+
+	t.Run("EmptySelectionIsRejected", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("POST", "/api/v1/products/export", bytes.NewBufferString(`{"mode": "product_tree"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusBadRequest, w.Code, "Status code")
+
+		req = httptest.NewRequest("POST", "/api/v1/products/export", bytes.NewBufferString(`{"vendor_ids": ["`+other.ID+`"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+	})
+}
@@ -1,6 +1,12 @@
 package internal
 
 import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/go-fuego/fuego"
 )
 
@@ -12,15 +18,60 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{svc: service}
 }
 
+// listQuery reads the paging, sorting and filter parameters of the list
+// endpoints.
+func listQuery(c fuego.ContextNoBody) (ListQuery, error) {
+	query := ListQuery{
+		Limit:       c.QueryParamInt("limit"),
+		Offset:      c.QueryParamInt("offset"),
+		Sort:        c.QueryParam("sort"),
+		Order:       c.QueryParam("order"),
+		Name:        c.QueryParam("name"),
+		ProductType: c.QueryParam("type"),
+		VendorID:    c.QueryParam("vendor_id"),
+		FamilyID:    c.QueryParam("family_id"),
+	}
+
+	if value := c.QueryParam("has_versions"); value != "" {
+		hasVersions, err := strconv.ParseBool(value)
+		if err != nil {
+			return ListQuery{}, fuego.BadRequestError{
+				Title: "Invalid list query",
+				Err:   err,
+				Errors: []fuego.ErrorItem{
+					{
+						Name:   "has_versions",
+						Reason: "has_versions must be true or false",
+					},
+				},
+			}
+		}
+		query.HasVersions = &hasVersions
+	}
+
+	return query, nil
+}
+
+// setTotalCount reports the number of rows matching a list query, as the
+// response body only contains the requested page.
+func setTotalCount(c fuego.ContextNoBody, total int64) {
+	c.SetHeader("X-Total-Count", strconv.FormatInt(total, 10))
+}
+
 // Vendors
 
 func (h *Handler) ListVendors(c fuego.ContextNoBody) ([]VendorDTO, error) {
-	vendors, err := h.svc.ListVendors(c.Request().Context())
+	query, err := listQuery(c)
+	if err != nil {
+		return nil, err
+	}
 
+	vendors, total, err := h.svc.QueryVendors(c.Request().Context(), query)
 	if err != nil {
 		return nil, err
 	}
 
+	setTotalCount(c, total)
 	return vendors, nil
 }
 
@@ -36,13 +87,17 @@ func (h *Handler) GetVendor(c fuego.ContextNoBody) (VendorDTO, error) {
 }
 
 func (h *Handler) ListVendorProducts(c fuego.ContextNoBody) ([]ProductDTO, error) {
-	vendorID := c.PathParam("id")
-	products, err := h.svc.ListVendorProducts(c.Request().Context(), vendorID)
+	query, err := listQuery(c)
+	if err != nil {
+		return nil, err
+	}
 
+	products, total, err := h.svc.QueryVendorProducts(c.Request().Context(), c.PathParam("id"), query)
 	if err != nil {
 		return nil, err
 	}
 
+	setTotalCount(c, total)
 	return products, nil
 }
 
@@ -88,12 +143,17 @@ func (h *Handler) CreateVendor(c fuego.ContextWithBody[CreateVendorDTO]) (Vendor
 // Products
 
 func (h *Handler) ListProducts(c fuego.ContextNoBody) ([]ProductDTO, error) {
-	products, err := h.svc.ListProducts(c.Request().Context())
+	query, err := listQuery(c)
+	if err != nil {
+		return nil, err
+	}
 
+	products, total, err := h.svc.QueryProducts(c.Request().Context(), query)
 	if err != nil {
 		return nil, err
 	}
 
+	setTotalCount(c, total)
 	return products, nil
 }
 
@@ -114,17 +174,73 @@ func (h *Handler) ExportProductTree(c fuego.ContextWithBody[ExportRequestDTO]) (
 		return nil, err
 	}
 
-	return h.svc.ExportCSAFProductTree(c.Request().Context(), body.ProductIDs)
+	if body.ProductIDs == nil && len(body.VendorIDs) == 0 && len(body.FamilyIDs) == 0 && len(body.VersionIDs) == 0 && !body.All {
+		return nil, fuego.BadRequestError{
+			Title: "Empty export selection",
+			Errors: []fuego.ErrorItem{{
+				Name:   "ExportRequestDTO.ProductIDs",
+				Reason: "Select products, vendors, families or versions, or set 'all'",
+			}},
+		}
+	}
+
+	opts := exportOptions(body)
+
+	if body.Mode == "document" {
+		var document ExportDocumentDTO
+		if body.Document != nil {
+			document = *body.Document
+		}
+		return h.svc.ExportCSAFDocument(c.Request().Context(), body.ProductIDs, document, opts...)
+	}
+
+	return h.svc.ExportCSAFProductTree(c.Request().Context(), body.ProductIDs, opts...)
+}
+
+func (h *Handler) ImportProductTree(c fuego.ContextWithBody[CSAFImportDTO]) (ImportReportDTO, error) {
+	body, err := c.Body()
+	if err != nil {
+		return ImportReportDTO{}, err
+	}
+
+	return h.svc.ImportCSAFProductTree(c.Request().Context(), body.ProductTree, c.QueryParamBool("dry_run"))
+}
+
+func exportOptions(body ExportRequestDTO) []ExportOption {
+	var opts []ExportOption
+	if body.ProductIDScheme != "" {
+		opts = append(opts, WithProductIDScheme(body.ProductIDScheme, body.ProductIDPrefix))
+	}
+	if len(body.VendorIDs) > 0 {
+		opts = append(opts, WithVendors(body.VendorIDs...))
+	}
+	if len(body.FamilyIDs) > 0 {
+		opts = append(opts, WithFamilies(body.FamilyIDs...))
+	}
+	if len(body.VersionIDs) > 0 {
+		opts = append(opts, WithVersions(body.VersionIDs...))
+	}
+	if body.All {
+		opts = append(opts, WithAllProducts())
+	}
+	if len(body.ProductGroups) > 0 {
+		opts = append(opts, WithProductGroups(body.ProductGroups...))
+	}
+	return opts
 }
 
 func (h *Handler) ListProductVersions(c fuego.ContextNoBody) ([]ProductVersionDTO, error) {
-	productID := c.PathParam("id")
-	versions, err := h.svc.ListProductVersions(c.Request().Context(), productID)
+	query, err := listQuery(c)
+	if err != nil {
+		return nil, err
+	}
 
+	versions, total, err := h.svc.QueryProductVersions(c.Request().Context(), c.PathParam("id"), query)
 	if err != nil {
 		return nil, err
 	}
 
+	setTotalCount(c, total)
 	return versions, nil
 }
 
@@ -180,6 +296,14 @@ func (h *Handler) GetProductVersion(c fuego.ContextNoBody) (ProductVersionDTO, e
 	return version, nil
 }
 
+func (h *Handler) GetProductVersionLineage(c fuego.ContextNoBody) (VersionLineageDTO, error) {
+	return h.svc.GetVersionLineage(c.Request().Context(), c.PathParam("id"))
+}
+
+func (h *Handler) ListUpcomingLifecycleMilestones(c fuego.ContextNoBody) ([]LifecycleMilestoneDTO, error) {
+	return h.svc.UpcomingLifecycleMilestones(c.Request().Context(), LifecycleMilestone(c.QueryParam("milestone")), c.QueryParamInt("days"))
+}
+
 func (h *Handler) ListRelationshipsByProductVersion(c fuego.ContextNoBody) ([]RelationshipGroupDTO, error) {
 	productVersionID := c.PathParam("id")
 	relationships, err := h.svc.GetRelationshipsByProductVersion(c.Request().Context(), productVersionID)
@@ -202,6 +326,23 @@ func (h *Handler) ListIdentificationHelpersByProductVersion(c fuego.ContextNoBod
 	return helpers, nil
 }
 
+func (h *Handler) ExportProductVersionSBOM(c fuego.ContextNoBody) (map[string]interface{}, error) {
+	productVersionID := c.PathParam("id")
+	return h.svc.ExportSBOM(c.Request().Context(), productVersionID, c.QueryParam("format"))
+}
+
+func (h *Handler) ImportProductVersionSBOM(c fuego.ContextNoBody) (ImportReportDTO, error) {
+	productVersionID := c.PathParam("id")
+
+	// The SBOM is read as is, since SPDX tag-value documents are no JSON
+	data, err := readImportBody(c)
+	if err != nil {
+		return ImportReportDTO{}, err
+	}
+
+	return h.svc.ImportSBOM(c.Request().Context(), productVersionID, data, c.QueryParamBool("dry_run"))
+}
+
 func (h *Handler) UpdateProductVersion(c fuego.ContextWithBody[UpdateProductVersionDTO]) (ProductVersionDTO, error) {
 	versionID := c.PathParam("id")
 	body, err := c.Body()
@@ -245,6 +386,80 @@ func (h *Handler) CreateProductVersion(c fuego.ContextWithBody[CreateProductVers
 	return version, nil
 }
 
+// Version Ranges
+
+func (h *Handler) GetVersionRange(c fuego.ContextNoBody) (VersionRangeDTO, error) {
+	versionRange, err := h.svc.GetVersionRangeByID(c.Request().Context(), c.PathParam("id"))
+
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	return versionRange, nil
+}
+
+func (h *Handler) ListProductVersionRanges(c fuego.ContextNoBody) ([]VersionRangeDTO, error) {
+	ranges, err := h.svc.ListProductVersionRanges(c.Request().Context(), c.PathParam("id"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
+func (h *Handler) ListVersionRangeVersions(c fuego.ContextNoBody) ([]ProductVersionDTO, error) {
+	versions, err := h.svc.ResolveVersionRange(c.Request().Context(), c.PathParam("id"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (h *Handler) CreateVersionRange(c fuego.ContextWithBody[CreateVersionRangeDTO]) (VersionRangeDTO, error) {
+	body, err := c.Body()
+
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	versionRange, err := h.svc.CreateVersionRange(c.Request().Context(), body)
+
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	return versionRange, nil
+}
+
+func (h *Handler) UpdateVersionRange(c fuego.ContextWithBody[UpdateVersionRangeDTO]) (VersionRangeDTO, error) {
+	body, err := c.Body()
+
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	versionRange, err := h.svc.UpdateVersionRange(c.Request().Context(), c.PathParam("id"), body)
+
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	return versionRange, nil
+}
+
+func (h *Handler) DeleteVersionRange(c fuego.ContextNoBody) (any, error) {
+	err := h.svc.DeleteVersionRange(c.Request().Context(), c.PathParam("id"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
 // Relationships
 
 func (h *Handler) GetRelationship(c fuego.ContextNoBody) (RelationshipDTO, error) {
@@ -357,6 +572,56 @@ func (h *Handler) CreateIdentificationHelper(c fuego.ContextWithBody[CreateIdent
 	return helper, nil
 }
 
+func (h *Handler) Lookup(c fuego.ContextNoBody) (LookupResultDTO, error) {
+	return h.svc.Lookup(c.Request().Context(), c.QueryParam("identifier"), c.QueryParam("type"))
+}
+
+func (h *Handler) MatchCPE(c fuego.ContextNoBody) (LookupResultDTO, error) {
+	versionRange := CPEVersionRange{
+		StartIncluding: c.QueryParam("version_start_including"),
+		StartExcluding: c.QueryParam("version_start_excluding"),
+		EndIncluding:   c.QueryParam("version_end_including"),
+		EndExcluding:   c.QueryParam("version_end_excluding"),
+	}
+	return h.svc.MatchCPE(c.Request().Context(), c.QueryParam("cpe"), versionRange)
+}
+
+func (h *Handler) MatchPurl(c fuego.ContextNoBody) (LookupResultDTO, error) {
+	return h.svc.MatchPurl(c.Request().Context(), c.QueryParam("purl"), c.QueryParam("vers"))
+}
+
+func (h *Handler) Search(c fuego.ContextNoBody) (SearchResultDTO, error) {
+	return h.svc.Search(c.Request().Context(), c.QueryParam("q"), c.QueryParamInt("limit"))
+}
+
+// History
+
+// History returns a handler listing the audit entries of an entity of the
+// given type.
+func (h *Handler) History(entityType string) func(c fuego.ContextNoBody) ([]AuditEntryDTO, error) {
+	return func(c fuego.ContextNoBody) ([]AuditEntryDTO, error) {
+		query, err := historyQuery(c)
+		if err != nil {
+			return nil, err
+		}
+		return h.svc.History(c.Request().Context(), entityType, c.PathParam("id"), query)
+	}
+}
+
+// Trash
+
+func (h *Handler) ListTrash(c fuego.ContextNoBody) ([]TrashItemDTO, error) {
+	return h.svc.ListTrash(c.Request().Context())
+}
+
+func (h *Handler) RestoreFromTrash(c fuego.ContextNoBody) (any, error) {
+	return nil, h.svc.RestoreFromTrash(c.Request().Context(), c.PathParam("id"))
+}
+
+func (h *Handler) PurgeFromTrash(c fuego.ContextNoBody) (any, error) {
+	return nil, h.svc.PurgeFromTrash(c.Request().Context(), c.PathParam("id"))
+}
+
 // Product Families
 
 func (h *Handler) GetProductFamily(c fuego.ContextNoBody) (ProductFamilyDTO, error) {
@@ -371,12 +636,17 @@ func (h *Handler) GetProductFamily(c fuego.ContextNoBody) (ProductFamilyDTO, err
 }
 
 func (h *Handler) ListProductFamilies(c fuego.ContextNoBody) ([]ProductFamilyDTO, error) {
-	families, err := h.svc.ListProductFamilies(c.Request().Context())
+	query, err := listQuery(c)
+	if err != nil {
+		return nil, err
+	}
 
+	families, total, err := h.svc.QueryProductFamilies(c.Request().Context(), query)
 	if err != nil {
 		return nil, err
 	}
 
+	setTotalCount(c, total)
 	return families, nil
 }
 
@@ -416,3 +686,101 @@ func (h *Handler) CreateProductFamily(c fuego.ContextWithBody[CreateProductFamil
 
 	return family, nil
 }
+
+// CSAF
+
+func (h *Handler) ValidateCSAFDocument(c fuego.ContextWithBody[map[string]interface{}]) (CSAFValidationResultDTO, error) {
+	body, err := c.Body()
+	if err != nil {
+		return CSAFValidationResultDTO{}, err
+	}
+
+	return h.svc.ValidateCSAFDocument(body)
+}
+
+// Catalog
+
+func (h *Handler) ExportCatalogCSV(c fuego.ContextNoBody) (any, error) {
+	data, err := h.svc.ExportCatalogCSV(c.Request().Context())
+	if err != nil {
+		return nil, err
+	}
+
+	c.Response().Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", `attachment; filename="catalog.csv"`)
+	_, err = c.Response().Write(data)
+	return nil, err
+}
+
+func (h *Handler) ImportCatalogCSV(c fuego.ContextNoBody) (ImportReportDTO, error) {
+	data, err := readImportBody(c)
+	if err != nil {
+		return ImportReportDTO{}, err
+	}
+
+	return h.svc.ImportCatalogCSV(c.Request().Context(), data, c.QueryParamBool("dry_run"))
+}
+
+func (h *Handler) DumpCatalog(c fuego.ContextNoBody) (DumpDTO, error) {
+	dump, err := h.svc.Dump(c.Request().Context())
+	if err != nil {
+		return DumpDTO{}, err
+	}
+
+	c.Response().Header().Set("Content-Disposition", `attachment; filename="product-database-dump.json"`)
+	return dump, nil
+}
+
+func (h *Handler) RestoreCatalogDump(c fuego.ContextNoBody) (ImportReportDTO, error) {
+	data, err := readImportBody(c)
+	if err != nil {
+		return ImportReportDTO{}, err
+	}
+
+	return h.svc.RestoreDump(c.Request().Context(), data, c.QueryParam("mode"), c.QueryParam("on_conflict"), c.QueryParamBool("dry_run"))
+}
+
+func (h *Handler) ImportCPEDictionary(c fuego.ContextNoBody) (ImportReportDTO, error) {
+	filter := CPEDictionaryFilter{
+		Vendors:           splitQueryList(c.QueryParam("vendor")),
+		Products:          splitQueryList(c.QueryParam("product")),
+		IncludeDeprecated: c.QueryParamBool("include_deprecated"),
+	}
+
+	// The dictionary is streamed, since NVD files are large
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxCPEDictionarySize)
+	report, err := h.svc.ImportCPEDictionary(c.Request().Context(), body, filter, c.QueryParamBool("dry_run"))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ImportReportDTO{}, fuego.HTTPError{
+			Status: http.StatusRequestEntityTooLarge,
+			Title:  "CPE dictionary too large",
+			Detail: "Upload the dictionary gzip compressed or import it with the 'import-cpe' command of the server binary",
+			Err:    err,
+		}
+	}
+	return report, err
+}
+
+// splitQueryList splits a comma separated query parameter.
+func splitQueryList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// readImportBody reads an uploaded file from the raw request body.
+func readImportBody(c fuego.ContextNoBody) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxImportSize))
+	if err != nil {
+		return nil, fuego.BadRequestError{
+			Title: "Failed to read request body",
+			Err:   err,
+		}
+	}
+	return data, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -5715,22 +5716,21 @@ func TestCSAFConversion(t *testing.T) {
 			}
 		}
 
-		// Test CSAF export to exercise conversion branches
-		csafData, err := svc.ExportCSAFProductTree(ctx, []string{product.ID})
-		if err != nil {
-			t.Fatalf("ExportCSAFProductTree failed: %v", err)
+		// Test CSAF export to exercise conversion branches. The invalid CPE and
+		// purl values must be reported by the schema validation.
+		_, err = svc.ExportCSAFProductTree(ctx, []string{product.ID})
+		var validationErr fuego.HTTPError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("Expected schema validation error, got %v", err)
 		}
 
-		if len(csafData) == 0 {
-			t.Error("Expected CSAF data to be generated")
+		invalidLocations := make(map[string]bool)
+		for _, item := range validationErr.Errors {
+			invalidLocations[item.Name[strings.LastIndex(item.Name, "/")+1:]] = true
 		}
-
-		// Test export with multiple products to trigger batch processing
-		csafMultiData, err := svc.ExportCSAFProductTree(ctx, []string{product.ID})
-		if err != nil {
-			t.Errorf("ExportCSAFProductTree with single product failed: %v", err)
+		if !invalidLocations["cpe"] || !invalidLocations["purl"] {
+			t.Errorf("Expected invalid cpe and purl to be reported, got %v", validationErr.Errors)
 		}
-		_ = csafMultiData
 
 		// Test with empty product list
 		csafEmptyData, err := svc.ExportCSAFProductTree(ctx, []string{})
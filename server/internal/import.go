@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-fuego/fuego"
+)
+
+const (
+	ImportActionCreated  = "created"
+	ImportActionMatched  = "matched"
+	ImportActionConflict = "conflict"
+	ImportActionSkipped  = "skipped"
+	ImportActionUpdated  = "updated"
+	ImportActionError    = "error"
+)
+
+// maxImportSize limits the size of uploaded SBOMs and CSV files.
+const maxImportSize = 32 << 20
+
+// maxCPEDictionarySize limits the size of uploaded CPE dictionaries. They are
+// streamed, so the limit only needs to fit the uncompressed NVD dictionary.
+const maxCPEDictionarySize = 2 << 30
+
+// errDryRun rolls back the import transaction after a dry run.
+var errDryRun = errors.New("dry run")
+
+// runImport executes fn inside a transaction. For dry runs the transaction is
+// rolled back after fn returned, so the report lists exactly what would have
+// been created or matched without persisting anything.
+func (s *Service) runImport(ctx context.Context, title string, dryRun bool, fn func(repo Repository, report *ImportReportDTO) error) (ImportReportDTO, error) {
+	report := ImportReportDTO{DryRun: dryRun, Items: []ImportItemDTO{}}
+
+	err := s.repo.Transaction(ctx, func(repo Repository) error {
+		if err := fn(repo, &report); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		var statusErr fuego.ErrorWithStatus
+		if errors.As(err, &statusErr) {
+			return ImportReportDTO{}, err
+		}
+		return ImportReportDTO{}, fuego.InternalServerError{
+			Title: title,
+			Err:   err,
+		}
+	}
+
+	for _, item := range report.Items {
+		switch item.Action {
+		case ImportActionCreated:
+			report.Summary.Created++
+		case ImportActionMatched:
+			report.Summary.Matched++
+		case ImportActionConflict:
+			report.Summary.Conflicts++
+		case ImportActionSkipped:
+			report.Summary.Skipped++
+		case ImportActionUpdated:
+			report.Summary.Updated++
+		case ImportActionError:
+			report.Summary.Errors++
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,350 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+// SupportStatus is the support state of a version. Without explicit status
+// it follows from the lifecycle dates.
+type SupportStatus string
+
+const (
+	// SupportStatusSupported versions receive all updates.
+	SupportStatusSupported SupportStatus = "supported"
+	// SupportStatusSecurityOnly versions are past their end of support but
+	// still receive security updates.
+	SupportStatusSecurityOnly SupportStatus = "security_only"
+	// SupportStatusUnsupported versions no longer receive any updates.
+	SupportStatusUnsupported SupportStatus = "unsupported"
+	// SupportStatusEndOfLife versions have reached their end of life.
+	SupportStatusEndOfLife SupportStatus = "end_of_life"
+)
+
+// LifecycleMilestone names one of the lifecycle dates.
+type LifecycleMilestone string
+
+const (
+	MilestoneEndOfSupport         LifecycleMilestone = "end_of_support"
+	MilestoneEndOfSecurityUpdates LifecycleMilestone = "end_of_security_updates"
+	MilestoneEndOfLife            LifecycleMilestone = "end_of_life"
+)
+
+// DefaultLifecycleDays is the period searched for upcoming lifecycle
+// milestones if none is given.
+const DefaultLifecycleDays = 90
+
+// lifecycle is the support period of a version or the defaults of a
+// product.
+type lifecycle struct {
+	EndOfSupport         sql.NullTime
+	EndOfSecurityUpdates sql.NullTime
+	EndOfLife            sql.NullTime
+	Status               SupportStatus
+}
+
+func lifecycleOf(node Node) lifecycle {
+	return lifecycle{
+		EndOfSupport:         node.EndOfSupport,
+		EndOfSecurityUpdates: node.EndOfSecurityUpdates,
+		EndOfLife:            node.EndOfLife,
+		Status:               node.SupportStatus,
+	}
+}
+
+// versionLifecycle returns the lifecycle of a version. Dates and status not
+// set on the version are taken from its product.
+func versionLifecycle(product, version Node) lifecycle {
+	l, defaults := lifecycleOf(version), lifecycleOf(product)
+	if !l.EndOfSupport.Valid {
+		l.EndOfSupport = defaults.EndOfSupport
+	}
+	if !l.EndOfSecurityUpdates.Valid {
+		l.EndOfSecurityUpdates = defaults.EndOfSecurityUpdates
+	}
+	if !l.EndOfLife.Valid {
+		l.EndOfLife = defaults.EndOfLife
+	}
+	if l.Status == "" {
+		l.Status = defaults.Status
+	}
+	return l
+}
+
+// setOn stores the lifecycle in a node.
+func (l lifecycle) setOn(node *Node) {
+	node.EndOfSupport = l.EndOfSupport
+	node.EndOfSecurityUpdates = l.EndOfSecurityUpdates
+	node.EndOfLife = l.EndOfLife
+	node.SupportStatus = l.Status
+}
+
+// date returns the date of a milestone.
+func (l lifecycle) date(milestone LifecycleMilestone) sql.NullTime {
+	switch milestone {
+	case MilestoneEndOfSupport:
+		return l.EndOfSupport
+	case MilestoneEndOfSecurityUpdates:
+		return l.EndOfSecurityUpdates
+	case MilestoneEndOfLife:
+		return l.EndOfLife
+	}
+	return sql.NullTime{}
+}
+
+// status returns the explicit status or the one reached at the given time.
+// Without end of security updates, security updates end with the support.
+// The status is empty if neither status nor dates are known.
+func (l lifecycle) status(at time.Time) SupportStatus {
+	if l.Status != "" {
+		return l.Status
+	}
+
+	reached := func(date sql.NullTime) bool {
+		return date.Valid && !at.Before(date.Time)
+	}
+	switch {
+	case reached(l.EndOfLife):
+		return SupportStatusEndOfLife
+	case reached(l.EndOfSecurityUpdates):
+		return SupportStatusUnsupported
+	case reached(l.EndOfSupport) && l.EndOfSecurityUpdates.Valid:
+		return SupportStatusSecurityOnly
+	case reached(l.EndOfSupport):
+		return SupportStatusUnsupported
+	case l.EndOfSupport.Valid || l.EndOfSecurityUpdates.Valid || l.EndOfLife.Valid:
+		return SupportStatusSupported
+	}
+	return ""
+}
+
+// apply sets the lifecycle dates and the status at the given time of a
+// version.
+func (l lifecycle) apply(version *ProductVersionDTO, at time.Time) {
+	version.EndOfSupport = formatDate(l.EndOfSupport)
+	version.EndOfSecurityUpdates = formatDate(l.EndOfSecurityUpdates)
+	version.EndOfLife = formatDate(l.EndOfLife)
+	version.SupportStatus = string(l.status(at))
+}
+
+// update changes the lifecycle by the fields of a create or update request.
+// Nil fields are left unchanged, empty ones are cleared.
+func (l *lifecycle) update(dtoName string, endOfSupport, endOfSecurityUpdates, endOfLife, status *string) error {
+	var errorItems []fuego.ErrorItem
+	for _, field := range []struct {
+		name  string
+		value *string
+		date  *sql.NullTime
+	}{
+		{"EndOfSupport", endOfSupport, &l.EndOfSupport},
+		{"EndOfSecurityUpdates", endOfSecurityUpdates, &l.EndOfSecurityUpdates},
+		{"EndOfLife", endOfLife, &l.EndOfLife},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if *field.value == "" {
+			*field.date = sql.NullTime{}
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", *field.value)
+		if err != nil {
+			errorItems = append(errorItems, fuego.ErrorItem{
+				Name:   dtoName + "." + field.name,
+				Reason: "Date must be in YYYY-MM-DD format",
+			})
+			continue
+		}
+		*field.date = sql.NullTime{Time: parsed, Valid: true}
+	}
+
+	if status != nil {
+		switch SupportStatus(*status) {
+		case "", SupportStatusSupported, SupportStatusSecurityOnly, SupportStatusUnsupported, SupportStatusEndOfLife:
+			l.Status = SupportStatus(*status)
+		default:
+			errorItems = append(errorItems, fuego.ErrorItem{
+				Name:   dtoName + ".SupportStatus",
+				Reason: "Support status must be supported, security_only, unsupported or end_of_life",
+			})
+		}
+	}
+
+	if len(errorItems) > 0 {
+		return fuego.BadRequestError{
+			Title:  "Invalid lifecycle",
+			Errors: errorItems,
+		}
+	}
+	return nil
+}
+
+// validate checks that the release, end of support, end of security updates
+// and end of life follow each other. Dates that are not set are skipped.
+func (l lifecycle) validate(dtoName string, releasedAt sql.NullTime) error {
+	dates := []struct {
+		name  string
+		label string
+		date  sql.NullTime
+	}{
+		{"ReleaseDate", "the release", releasedAt},
+		{"EndOfSupport", "the end of support", l.EndOfSupport},
+		{"EndOfSecurityUpdates", "the end of security updates", l.EndOfSecurityUpdates},
+		{"EndOfLife", "the end of life", l.EndOfLife},
+	}
+
+	var errorItems []fuego.ErrorItem
+	for i, later := range dates {
+		if !later.date.Valid {
+			continue
+		}
+		for _, earlier := range dates[:i] {
+			if earlier.date.Valid && later.date.Time.Before(earlier.date.Time) {
+				errorItems = append(errorItems, fuego.ErrorItem{
+					Name:   dtoName + "." + later.name,
+					Reason: "Date must not be before " + earlier.label,
+				})
+				break
+			}
+		}
+	}
+
+	if len(errorItems) > 0 {
+		return fuego.BadRequestError{
+			Title:  "Invalid lifecycle dates",
+			Errors: errorItems,
+		}
+	}
+	return nil
+}
+
+func formatDate(date sql.NullTime) *string {
+	if !date.Valid {
+		return nil
+	}
+	formatted := date.Time.Format("2006-01-02")
+	return &formatted
+}
+
+// UpcomingLifecycleMilestones returns the versions reaching a milestone
+// today or within the next days, soonest first. Dates are inherited from the
+// products like in the version details.
+func (s *Service) UpcomingLifecycleMilestones(ctx context.Context, milestone LifecycleMilestone, days int) ([]LifecycleMilestoneDTO, error) {
+	if milestone == "" {
+		milestone = MilestoneEndOfSupport
+	}
+
+	var errorItems []fuego.ErrorItem
+	switch milestone {
+	case MilestoneEndOfSupport, MilestoneEndOfSecurityUpdates, MilestoneEndOfLife:
+	default:
+		errorItems = append(errorItems, fuego.ErrorItem{
+			Name:   "milestone",
+			Reason: "Milestone must be end_of_support, end_of_security_updates or end_of_life",
+		})
+	}
+	if days < 0 {
+		errorItems = append(errorItems, fuego.ErrorItem{
+			Name:   "days",
+			Reason: "Days must not be negative",
+		})
+	}
+	if len(errorItems) > 0 {
+		return nil, fuego.BadRequestError{
+			Title:  "Invalid lifecycle query",
+			Errors: errorItems,
+		}
+	}
+
+	nodes := make(map[string]Node)
+	var versions []Node
+	for _, category := range []NodeCategory{Vendor, ProductName, ProductVersion} {
+		categoryNodes, err := s.repo.GetNodesByCategory(ctx, category)
+		if err != nil {
+			return nil, fuego.InternalServerError{
+				Title: "Failed to fetch catalog",
+				Err:   err,
+			}
+		}
+		for _, node := range categoryNodes {
+			nodes[node.ID] = node
+		}
+		if category == ProductVersion {
+			versions = categoryNodes
+		}
+	}
+
+	now := s.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	until := today.AddDate(0, 0, days)
+
+	// The latest flags and lineage are derived from all versions of a product
+	for _, version := range versions {
+		if version.ParentID == nil {
+			continue
+		}
+		if product, ok := nodes[*version.ParentID]; ok {
+			product.Children = append(product.Children, version)
+			nodes[product.ID] = product
+		}
+	}
+	details := make(map[string]versionDetails)
+
+	result := []LifecycleMilestoneDTO{}
+	for _, version := range versions {
+		if version.ParentID == nil {
+			continue
+		}
+		product, ok := nodes[*version.ParentID]
+		if !ok {
+			continue
+		}
+
+		l := versionLifecycle(product, version)
+		date := l.date(milestone)
+		if !date.Valid || date.Time.Before(today) || date.Time.After(until) {
+			continue
+		}
+
+		productDetails, ok := details[product.ID]
+		if !ok {
+			productDetails = newVersionDetails(product, now)
+			details[product.ID] = productDetails
+		}
+		dto := productDetails.dto(version)
+
+		item := LifecycleMilestoneDTO{
+			Milestone:      string(milestone),
+			Date:           date.Time.Format("2006-01-02"),
+			DaysLeft:       int(date.Time.Sub(today).Hours() / 24),
+			ProductVersion: dto,
+			Product:        LookupNodeDTO{ID: product.ID, Name: product.Name},
+		}
+		if product.ParentID != nil {
+			if vendor, ok := nodes[*product.ParentID]; ok {
+				item.Vendor = LookupNodeDTO{ID: vendor.ID, Name: vendor.Name}
+			}
+		}
+		result = append(result, item)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Vendor.Name != b.Vendor.Name {
+			return a.Vendor.Name < b.Vendor.Name
+		}
+		if a.Product.Name != b.Product.Name {
+			return a.Product.Name < b.Product.Name
+		}
+		return compareVersions(a.ProductVersion.Name, b.ProductVersion.Name) < 0
+	})
+
+	return result, nil
+}
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestLifecycleStatus(t *testing.T) {
+	date := func(value string) sql.NullTime {
+		parsed, err := time.Parse("2006-01-02", value)
+		testutils.AssertNoError(t, err, "Valid date")
+		return sql.NullTime{Time: parsed, Valid: true}
+	}
+	full := lifecycle{
+		EndOfSupport:         date("2025-01-01"),
+		EndOfSecurityUpdates: date("2026-01-01"),
+		EndOfLife:            date("2027-01-01"),
+	}
+
+	tests := []struct {
+		name      string
+		lifecycle lifecycle
+		at        string
+		expected  SupportStatus
+	}{
+		{"Unknown", lifecycle{}, "2025-06-01", ""},
+		{"Supported", full, "2024-12-31", SupportStatusSupported},
+		{"SecurityOnly", full, "2025-01-01", SupportStatusSecurityOnly},
+		{"Unsupported", full, "2026-06-01", SupportStatusUnsupported},
+		{"EndOfLife", full, "2027-01-01", SupportStatusEndOfLife},
+		{"SupportWithoutSecurityUpdates", lifecycle{EndOfSupport: date("2025-01-01")}, "2025-06-01", SupportStatusUnsupported},
+		{"OnlyEndOfLife", lifecycle{EndOfLife: date("2027-01-01")}, "2025-06-01", SupportStatusSupported},
+		{"Explicit", lifecycle{EndOfLife: date("2020-01-01"), Status: SupportStatusSecurityOnly}, "2025-06-01", SupportStatusSecurityOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutils.AssertEqual(t, tt.expected, tt.lifecycle.status(date(tt.at).Time), "Support status")
+		})
+	}
+}
+
+func TestLifecycleValidate(t *testing.T) {
+	date := func(value string) sql.NullTime {
+		parsed, _ := time.Parse("2006-01-02", value)
+		return sql.NullTime{Time: parsed, Valid: true}
+	}
+
+	valid := lifecycle{EndOfSupport: date("2025-01-01"), EndOfLife: date("2025-01-01")}
+	testutils.AssertNoError(t, valid.validate("Test", date("2024-01-01")), "Ordered dates")
+	testutils.AssertNoError(t, lifecycle{}.validate("Test", sql.NullTime{}), "No dates")
+
+	invalid := lifecycle{EndOfSupport: date("2026-01-01"), EndOfLife: date("2025-01-01")}
+	err := invalid.validate("Test", sql.NullTime{})
+	var badRequest fuego.BadRequestError
+	testutils.AssertEqual(t, true, errors.As(err, &badRequest), "End of life before end of support")
+	testutils.AssertEqual(t, "Test.EndOfLife", badRequest.Errors[0].Name, "Invalid field")
+
+	err = valid.validate("Test", date("2025-06-01"))
+	testutils.AssertEqual(t, true, errors.As(err, &badRequest), "Support ending before the release")
+}
+
+func TestProductVersionLifecycle(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	day := func(days int) string {
+		return time.Now().UTC().AddDate(0, 0, days).Format("2006-01-02")
+	}
+	ptr := func(value string) *string {
+		return &value
+	}
+	isBadRequest := func(err error) bool {
+		var badRequest fuego.BadRequestError
+		return errors.As(err, &badRequest)
+	}
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{
+		Name:         "Router",
+		VendorID:     vendor.ID,
+		Type:         "hardware",
+		EndOfSupport: ptr(day(30)),
+		EndOfLife:    ptr(day(400)),
+	})
+	testutils.AssertNoError(t, err, "Should create product with lifecycle defaults")
+	testutils.AssertEqual(t, day(30), *product.EndOfSupport, "Default end of support")
+
+	inherited, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	own, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{
+		Version:              "2.0",
+		ProductID:            product.ID,
+		EndOfSupport:         ptr(day(-10)),
+		EndOfSecurityUpdates: ptr(day(60)),
+	})
+	testutils.AssertNoError(t, err, "Should create version with lifecycle")
+	testutils.AssertEqual(t, "security_only", own.SupportStatus, "Status of created version")
+
+	t.Run("Inherited", func(t *testing.T) {
+		version, err := svc.GetProductVersionByID(ctx, inherited.ID)
+		testutils.AssertNoError(t, err, "Should get version")
+		testutils.AssertEqual(t, day(30), *version.EndOfSupport, "Inherited end of support")
+		testutils.AssertEqual(t, day(400), *version.EndOfLife, "Inherited end of life")
+		testutils.AssertEqual(t, "supported", version.SupportStatus, "Supported")
+
+		versions, err := svc.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		testutils.AssertEqual(t, day(-10), *versions[1].EndOfSupport, "Own end of support")
+		testutils.AssertEqual(t, day(400), *versions[1].EndOfLife, "Inherited end of life")
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := svc.UpdateProductVersion(ctx, own.ID, UpdateProductVersionDTO{EndOfLife: ptr(day(40))})
+		testutils.AssertEqual(t, true, isBadRequest(err), "End of life before end of security updates")
+		_, err = svc.UpdateProductVersion(ctx, own.ID, UpdateProductVersionDTO{ReleaseDate: ptr(day(-5))})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Release after own end of support")
+		_, err = svc.UpdateProductVersion(ctx, inherited.ID, UpdateProductVersionDTO{EndOfSupport: ptr("31.12.2030")})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Invalid date format")
+		_, err = svc.UpdateProductVersion(ctx, inherited.ID, UpdateProductVersionDTO{SupportStatus: ptr("forever")})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Invalid status")
+		_, err = svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{EndOfSecurityUpdates: ptr(day(20))})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Product defaults out of order")
+	})
+
+	t.Run("InheritedDatesNotValidated", func(t *testing.T) {
+		late, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "0.9", ProductID: product.ID, ReleaseDate: ptr(day(31))})
+		testutils.AssertNoError(t, err, "Release after the default end of support")
+		testutils.AssertEqual(t, day(30), *late.EndOfSupport, "Inherited end of support")
+
+		_, err = svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{EndOfSupport: ptr(day(20))})
+		testutils.AssertNoError(t, err, "Defaults before the release of a version")
+		updated, err := svc.UpdateProductVersion(ctx, late.ID, UpdateProductVersionDTO{Version: ptr("0.9.1")})
+		testutils.AssertNoError(t, err, "Version with inherited dates before its release")
+		testutils.AssertEqual(t, day(20), *updated.EndOfSupport, "Changed default")
+
+		_, err = svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{EndOfSupport: ptr(day(30))})
+		testutils.AssertNoError(t, err, "Should restore default")
+		testutils.AssertNoError(t, svc.DeleteProductVersion(ctx, late.ID), "Should delete version")
+	})
+
+	t.Run("UpdateAndClear", func(t *testing.T) {
+		updated, err := svc.UpdateProductVersion(ctx, inherited.ID, UpdateProductVersionDTO{SupportStatus: ptr("end_of_life")})
+		testutils.AssertNoError(t, err, "Should set status")
+		testutils.AssertEqual(t, "end_of_life", updated.SupportStatus, "Explicit status")
+
+		updated, err = svc.UpdateProductVersion(ctx, inherited.ID, UpdateProductVersionDTO{SupportStatus: ptr("")})
+		testutils.AssertNoError(t, err, "Should clear status")
+		testutils.AssertEqual(t, "supported", updated.SupportStatus, "Status from dates")
+
+		cleared, err := svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{EndOfLife: ptr("")})
+		testutils.AssertNoError(t, err, "Should clear default")
+		testutils.AssertEqual(t, true, cleared.EndOfLife == nil, "Default end of life is cleared")
+		testutils.AssertEqual(t, day(30), *cleared.EndOfSupport, "Other defaults are kept")
+	})
+
+	t.Run("Upcoming", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		get := func(query string) (*httptest.ResponseRecorder, []LifecycleMilestoneDTO) {
+			req := httptest.NewRequest("GET", "/api/v1/product-versions/lifecycle"+query, nil)
+			w := httptest.NewRecorder()
+			app.Mux.ServeHTTP(w, req)
+
+			var milestones []LifecycleMilestoneDTO
+			if w.Code == http.StatusOK {
+				testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &milestones), "Should decode milestones")
+			}
+			return w, milestones
+		}
+		names := func(milestones []LifecycleMilestoneDTO) string {
+			result := make([]string, len(milestones))
+			for i, milestone := range milestones {
+				result[i] = milestone.ProductVersion.Name + "@" + milestone.Date
+			}
+			return strings.Join(result, ",")
+		}
+
+		w, milestones := get("")
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+		testutils.AssertEqual(t, "1.0@"+day(30), names(milestones), "End of support within 90 days")
+		testutils.AssertEqual(t, 30, milestones[0].DaysLeft, "Days left")
+		testutils.AssertEqual(t, "Acme", milestones[0].Vendor.Name, "Vendor")
+
+		_, milestones = get("?days=10")
+		testutils.AssertEqual(t, "", names(milestones), "Nothing within 10 days")
+
+		_, milestones = get("?milestone=end_of_security_updates&days=60")
+		testutils.AssertEqual(t, "2.0@"+day(60), names(milestones), "End of security updates")
+
+		w, _ = get("?milestone=someday")
+		testutils.AssertEqual(t, http.StatusBadRequest, w.Code, "Invalid milestone")
+	})
+
+	t.Run("ExportGroups", func(t *testing.T) {
+		_, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "3.0", ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+
+		tree, err := svc.ExportCSAFProductTree(ctx, []string{product.ID}, WithProductGroups(ProductGroupByLifecycle))
+		testutils.AssertNoError(t, err, "Should export")
+
+		groups := tree["product_tree"].(map[string]interface{})["product_groups"].([]interface{})
+		testutils.AssertEqual(t, 1, len(groups), "Only groups with two versions")
+		group := groups[0].(map[string]interface{})
+		testutils.AssertEqual(t, "lifecycle-supported", group["group_id"], "Group ID")
+		testutils.AssertEqual(t, "All supported versions", group["summary"], "Summary")
+		testutils.AssertEqual(t, 2, len(group["product_ids"].([]interface{})), "Supported versions")
+
+		tree, err = svc.ExportCSAFProductTree(ctx, []string{product.ID}, WithProductGroups(ProductGroupByLifecycle, ProductGroupByProduct), WithProductIDScheme(ProductIDSchemeShort, "EXAMPLE"))
+		testutils.AssertNoError(t, err, "Should export with short IDs")
+
+		groupIDs := make(map[string]bool)
+		for _, group := range tree["product_tree"].(map[string]interface{})["product_groups"].([]interface{}) {
+			groupIDs[group.(map[string]interface{})["group_id"].(string)] = true
+		}
+		testutils.AssertEqual(t, true, groupIDs["lifecycle-supported"], "Lifecycle group ID is kept")
+		testutils.AssertEqual(t, true, groupIDs["EXAMPLE-"+strings.ToUpper(strings.ReplaceAll(product.ID, "-", ""))[:8]], "Product group ID is shortened")
+	})
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// versionLineage links the versions of a product to their predecessors and
+// successors. Predecessors that are not among the versions, e.g. because they
+// are in the trash, are left out.
+type versionLineage struct {
+	predecessors map[string]string
+	successors   map[string]string
+}
+
+// newVersionLineage builds the lineage of the versions of a product. Should a
+// version have several successors, the first one is used.
+func newVersionLineage(versions []Node) versionLineage {
+	lineage := versionLineage{
+		predecessors: make(map[string]string),
+		successors:   make(map[string]string),
+	}
+
+	ids := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		ids[version.ID] = true
+	}
+	for _, version := range versions {
+		if version.PredecessorID == nil || !ids[*version.PredecessorID] {
+			continue
+		}
+		predecessor := *version.PredecessorID
+		if _, ok := lineage.successors[predecessor]; ok {
+			continue
+		}
+		lineage.predecessors[version.ID] = predecessor
+		lineage.successors[predecessor] = version.ID
+	}
+
+	return lineage
+}
+
+// follow returns the chain of versions reached from id through next, nearest
+// first. It stops at a version already visited.
+func follow(id string, next map[string]string) []string {
+	var chain []string
+	visited := map[string]bool{id: true}
+	for {
+		id = next[id]
+		if id == "" || visited[id] {
+			return chain
+		}
+		visited[id] = true
+		chain = append(chain, id)
+	}
+}
+
+// ancestors returns the predecessors of a version, nearest first.
+func (l versionLineage) ancestors(id string) []string {
+	return follow(id, l.predecessors)
+}
+
+// descendants returns the successors of a version, nearest first.
+func (l versionLineage) descendants(id string) []string {
+	return follow(id, l.successors)
+}
+
+// apply sets the predecessor and successor of a version.
+func (l versionLineage) apply(version *ProductVersionDTO) {
+	version.PredecessorID, version.SuccessorID = nil, nil
+	if id, ok := l.predecessors[version.ID]; ok {
+		version.PredecessorID = &id
+	}
+	if id, ok := l.successors[version.ID]; ok {
+		version.SuccessorID = &id
+	}
+}
+
+// validatePredecessor checks that a version may succeed the version with
+// predecessorID: it must be another version of the same product that has no
+// other successor and does not succeed the version itself.
+func (s *Service) validatePredecessor(ctx context.Context, dtoName string, version Node, predecessorID string) error {
+	invalid := func(reason string) error {
+		return fuego.BadRequestError{
+			Title: "Invalid predecessor ID",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   dtoName + ".PredecessorID",
+					Reason: reason,
+				},
+			},
+		}
+	}
+
+	predecessor, err := s.repo.GetNodeByID(ctx, predecessorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && predecessor.Category != ProductVersion) {
+		return invalid("Predecessor ID must be a valid product version ID")
+	}
+	if err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to fetch predecessor",
+			Err:   err,
+		}
+	}
+	if predecessor.ID == version.ID {
+		return invalid("A version cannot be its own predecessor")
+	}
+	if predecessor.ParentID == nil || version.ParentID == nil || *predecessor.ParentID != *version.ParentID {
+		return invalid("Predecessor must be a version of the same product")
+	}
+
+	product, err := s.repo.GetNodeByID(ctx, *version.ParentID, WithChildren())
+	if err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to fetch product versions",
+			Err:   err,
+		}
+	}
+	lineage := newVersionLineage(versionsOf(product))
+
+	if successor, ok := lineage.successors[predecessor.ID]; ok && successor != version.ID {
+		return fuego.ConflictError{
+			Title:  "Predecessor already has a successor",
+			Detail: "The version with ID " + successor + " already succeeds the predecessor",
+		}
+	}
+	for _, id := range lineage.ancestors(predecessor.ID) {
+		if id == version.ID {
+			return invalid("Predecessor must not be a successor of the version")
+		}
+	}
+
+	return nil
+}
+
+// checkRestoredPredecessor rejects restoring a version whose predecessor got
+// another successor while the version was in the trash.
+func (s *Service) checkRestoredPredecessor(ctx context.Context, version Node) error {
+	product, err := s.repo.GetNodeByID(ctx, *version.ParentID, WithChildren())
+	if err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to fetch product versions",
+			Err:   err,
+		}
+	}
+
+	lineage := newVersionLineage(versionsOf(product))
+	if successor, ok := lineage.successors[*version.PredecessorID]; ok && successor != version.ID {
+		return fuego.ConflictError{
+			Title:  "Predecessor already has a successor",
+			Detail: "The version with ID " + successor + " now succeeds the predecessor, change its predecessor first",
+		}
+	}
+
+	return nil
+}
+
+// GetVersionLineage returns a version with all versions it succeeds and all
+// versions succeeding it.
+func (s *Service) GetVersionLineage(ctx context.Context, id string) (VersionLineageDTO, error) {
+	version, err := s.repo.GetNodeByID(ctx, id)
+	notFoundError := fuego.NotFoundError{
+		Title: "Product version not found",
+	}
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return VersionLineageDTO{}, notFoundError
+		}
+		return VersionLineageDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch product version",
+			Err:   err,
+		}
+	}
+
+	if version.Category != ProductVersion || version.ParentID == nil {
+		return VersionLineageDTO{}, notFoundError
+	}
+
+	product, err := s.repo.GetNodeByID(ctx, *version.ParentID, WithChildren())
+	if err != nil {
+		return VersionLineageDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch product versions",
+			Err:   err,
+		}
+	}
+
+	versions, _ := productVersionDTOs(product, versionsOf(product), s.now())
+	byID := make(map[string]ProductVersionDTO, len(versions))
+	for _, v := range versions {
+		byID[v.ID] = v
+	}
+	lineage := newVersionLineage(versionsOf(product))
+
+	result := VersionLineageDTO{
+		Version:     byID[version.ID],
+		Ancestors:   []ProductVersionDTO{},
+		Descendants: []ProductVersionDTO{},
+	}
+	ancestors := lineage.ancestors(version.ID)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		result.Ancestors = append(result.Ancestors, byID[ancestors[i]])
+	}
+	for _, id := range lineage.descendants(version.ID) {
+		result.Descendants = append(result.Descendants, byID[id])
+	}
+
+	return result, nil
+}
+
+// MigrateVersionLineage moves the predecessors that earlier releases stored
+// in the successor_id column of the nodes to predecessor_id and drops the
+// column along with its foreign key.
+func MigrateVersionLineage(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Node{}, "successor_id") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasConstraint(&Node{}, "fk_nodes_successor") {
+			if err := tx.Migrator().DropConstraint(&Node{}, "fk_nodes_successor"); err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("UPDATE nodes SET predecessor_id = successor_id WHERE successor_id IS NOT NULL AND predecessor_id IS NULL").Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&Node{}, "successor_id")
+	})
+}
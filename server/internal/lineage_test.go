@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestVersionLineage(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	other, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Switch", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+
+	v1, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	v2, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0", ProductID: product.ID, PredecessorID: &v1.ID})
+	testutils.AssertNoError(t, err, "Should create version with predecessor")
+	testutils.AssertEqual(t, v1.ID, *v2.PredecessorID, "Predecessor of created version")
+	testutils.AssertEqual(t, true, v2.IsLatest, "Created version is the latest")
+	v3, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "3.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	_, err = svc.UpdateProductVersion(ctx, v3.ID, UpdateProductVersionDTO{PredecessorID: &v2.ID})
+	testutils.AssertNoError(t, err, "Should set predecessor")
+	updated, err := svc.UpdateProductVersion(ctx, v2.ID, UpdateProductVersionDTO{})
+	testutils.AssertNoError(t, err, "Should update version")
+	testutils.AssertEqual(t, v3.ID, *updated.SuccessorID, "Successor of updated version")
+	testutils.AssertEqual(t, false, updated.IsLatest, "Updated version is not the latest")
+	switchVersion, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: other.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+
+	names := func(versions []ProductVersionDTO) string {
+		result := make([]string, len(versions))
+		for i, version := range versions {
+			result[i] = version.Name
+		}
+		return strings.Join(result, ",")
+	}
+	isBadRequest := func(err error) bool {
+		var badRequest fuego.BadRequestError
+		return errors.As(err, &badRequest)
+	}
+	isConflict := func(err error) bool {
+		var conflict fuego.ConflictError
+		return errors.As(err, &conflict)
+	}
+
+	t.Run("BothDirections", func(t *testing.T) {
+		version, err := svc.GetProductVersionByID(ctx, v2.ID)
+		testutils.AssertNoError(t, err, "Should get version")
+		testutils.AssertEqual(t, v1.ID, *version.PredecessorID, "Predecessor")
+		testutils.AssertEqual(t, v3.ID, *version.SuccessorID, "Successor")
+
+		versions, err := svc.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		testutils.AssertEqual(t, true, versions[0].PredecessorID == nil, "First version has no predecessor")
+		testutils.AssertEqual(t, v2.ID, *versions[0].SuccessorID, "Successor of first version")
+		testutils.AssertEqual(t, true, versions[2].SuccessorID == nil, "Last version has no successor")
+	})
+
+	t.Run("Lineage", func(t *testing.T) {
+		lineage, err := svc.GetVersionLineage(ctx, v2.ID)
+		testutils.AssertNoError(t, err, "Should get lineage")
+		testutils.AssertEqual(t, "2.0", lineage.Version.Name, "Version")
+		testutils.AssertEqual(t, "1.0", names(lineage.Ancestors), "Ancestors")
+		testutils.AssertEqual(t, "3.0", names(lineage.Descendants), "Descendants")
+
+		lineage, err = svc.GetVersionLineage(ctx, v1.ID)
+		testutils.AssertNoError(t, err, "Should get lineage")
+		testutils.AssertEqual(t, "", names(lineage.Ancestors), "No ancestors")
+		testutils.AssertEqual(t, "2.0,3.0", names(lineage.Descendants), "All successors")
+
+		_, err = svc.GetVersionLineage(ctx, product.ID)
+		var notFound fuego.NotFoundError
+		testutils.AssertEqual(t, true, errors.As(err, &notFound), "Product has no lineage")
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := svc.UpdateProductVersion(ctx, v1.ID, UpdateProductVersionDTO{PredecessorID: &v3.ID})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Cycle")
+		_, err = svc.UpdateProductVersion(ctx, v1.ID, UpdateProductVersionDTO{PredecessorID: &v1.ID})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Own predecessor")
+		_, err = svc.UpdateProductVersion(ctx, switchVersion.ID, UpdateProductVersionDTO{PredecessorID: &v3.ID})
+		testutils.AssertEqual(t, true, isBadRequest(err), "Other product")
+		_, err = svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.1", ProductID: product.ID, PredecessorID: &v2.ID})
+		testutils.AssertEqual(t, true, isConflict(err), "Predecessor has a successor")
+		_, err = svc.UpdateProductVersion(ctx, v2.ID, UpdateProductVersionDTO{ProductID: &other.ID})
+		testutils.AssertEqual(t, true, isConflict(err), "Version with successor cannot be moved")
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		empty := ""
+		updated, err := svc.UpdateProductVersion(ctx, v3.ID, UpdateProductVersionDTO{PredecessorID: &empty})
+		testutils.AssertNoError(t, err, "Should clear predecessor")
+		testutils.AssertEqual(t, true, updated.PredecessorID == nil, "Predecessor is cleared")
+
+		lineage, err := svc.GetVersionLineage(ctx, v1.ID)
+		testutils.AssertNoError(t, err, "Should get lineage")
+		testutils.AssertEqual(t, "2.0", names(lineage.Descendants), "Chain ends at 2.0")
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("GET", "/api/v1/product-versions/"+v2.ID+"/lineage", nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var lineage VersionLineageDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &lineage), "Should decode lineage")
+		testutils.AssertEqual(t, "1.0", names(lineage.Ancestors), "Ancestors")
+	})
+
+	t.Run("RestoreFork", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteProductVersion(ctx, v2.ID), "Should delete version")
+		replacement, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.1", ProductID: product.ID, PredecessorID: &v1.ID})
+		testutils.AssertNoError(t, err, "Predecessor of a deleted version can be reused")
+
+		err = svc.RestoreFromTrash(ctx, v2.ID)
+		testutils.AssertEqual(t, true, isConflict(err), "Restore would fork the lineage")
+
+		empty := ""
+		_, err = svc.UpdateProductVersion(ctx, replacement.ID, UpdateProductVersionDTO{PredecessorID: &empty})
+		testutils.AssertNoError(t, err, "Should clear predecessor")
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, v2.ID), "Should restore version")
+
+		version, err := svc.GetProductVersionByID(ctx, v1.ID)
+		testutils.AssertNoError(t, err, "Should get version")
+		testutils.AssertEqual(t, v2.ID, *version.SuccessorID, "Restored successor")
+	})
+}
+
+// legacyNode is the part of the node table of earlier releases that stored
+// the predecessor of a version in successor_id.
+type legacyNode struct {
+	ID          string `gorm:"primaryKey"`
+	SuccessorID *string
+	Successor   *legacyNode `gorm:"foreignKey:SuccessorID"`
+}
+
+func (legacyNode) TableName() string {
+	return "nodes"
+}
+
+func TestMigrateVersionLineage(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	testutils.AssertNoError(t, MigrateVersionLineage(db), "Nothing to migrate")
+
+	vendor := testutils.CreateTestVendor(t, db, "Acme", "")
+	product := testutils.CreateTestProduct(t, db, "Router", "", vendor.ID, testutils.Hardware)
+	first := testutils.CreateTestProductVersion(t, db, "1.0", "", product.ID, nil)
+	second := testutils.CreateTestProductVersion(t, db, "2.0", "", product.ID, nil)
+	testutils.AssertNoError(t, db.AutoMigrate(&legacyNode{}), "Should add legacy column")
+	testutils.AssertEqual(t, true, db.Migrator().HasConstraint(&Node{}, "fk_nodes_successor"), "Legacy foreign key exists")
+	testutils.AssertNoError(t, db.Exec("UPDATE nodes SET successor_id = ? WHERE id = ?", first.ID, second.ID).Error, "Should set legacy predecessor")
+
+	testutils.AssertNoError(t, MigrateVersionLineage(db), "Should migrate")
+	testutils.AssertEqual(t, false, db.Migrator().HasColumn(&Node{}, "successor_id"), "Legacy column is dropped")
+	testutils.AssertEqual(t, false, db.Migrator().HasConstraint(&Node{}, "fk_nodes_successor"), "Legacy foreign key is dropped")
+
+	var migrated testutils.Node
+	testutils.AssertNoError(t, db.First(&migrated, "id = ?", second.ID).Error, "Should load version")
+	testutils.AssertEqual(t, first.ID, *migrated.PredecessorID, "Predecessor is migrated")
+}
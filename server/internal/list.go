@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// MaxListLimit is the largest page size of the list endpoints.
+const MaxListLimit = 1000
+
+// ListQuery pages, sorts and filters the list endpoints. The zero value
+// returns all rows in insertion order, versions in version order. VendorID,
+// FamilyID, ProductType and HasVersions only apply to products.
+type ListQuery struct {
+	Limit  int
+	Offset int
+	// Sort is "name", "released_at" or "created_at".
+	Sort string
+	// Order is "asc" or "desc".
+	Order string
+	// Name selects rows whose name contains the value, ignoring case.
+	Name string
+
+	ProductType string
+	VendorID    string
+	FamilyID    string
+	HasVersions *bool
+}
+
+// nodeQuery validates the list query and converts it into a repository
+// query for the children of parentID in the given category. Only product
+// lists are filtered by type, family and versions.
+func (q ListQuery) nodeQuery(category NodeCategory, parentID string) (NodeQuery, error) {
+	var errorItems []fuego.ErrorItem
+	invalid := func(name, reason string) {
+		errorItems = append(errorItems, fuego.ErrorItem{Name: name, Reason: reason})
+	}
+
+	if q.Limit < 0 || q.Limit > MaxListLimit {
+		invalid("limit", "Limit must be between 0 and 1000")
+	}
+	if q.Offset < 0 {
+		invalid("offset", "Offset must not be negative")
+	}
+	switch q.Sort {
+	case "", SortByName, SortByReleasedAt, SortByCreatedAt:
+	default:
+		invalid("sort", "Sort must be one of name, released_at, created_at")
+	}
+	switch strings.ToLower(q.Order) {
+	case "", "asc", "desc":
+	default:
+		invalid("order", "Order must be asc or desc")
+	}
+	switch ProductType(q.ProductType) {
+	case "", Software, Hardware, Firmware:
+	default:
+		invalid("type", "Type must be one of software, hardware, firmware")
+	}
+
+	if len(errorItems) > 0 {
+		return NodeQuery{}, fuego.BadRequestError{
+			Title:  "Invalid list query",
+			Errors: errorItems,
+		}
+	}
+
+	query := NodeQuery{
+		Category: category,
+		ParentID: parentID,
+		Name:     q.Name,
+		Sort:     q.Sort,
+		Desc:     strings.EqualFold(q.Order, "desc"),
+		Limit:    q.Limit,
+		Offset:   q.Offset,
+	}
+	if category == ProductName {
+		query.FamilyID = q.FamilyID
+		query.ProductType = ProductType(q.ProductType)
+		query.HasVersions = q.HasVersions
+	}
+	return query, nil
+}
+
+var nodeCategoryPlurals = map[NodeCategory]string{
+	Vendor:         "vendors",
+	ProductFamily:  "product families",
+	ProductName:    "products",
+	ProductVersion: "product versions",
+}
+
+// queryNodes runs a list query for a category and wraps repository errors.
+func (s *Service) queryNodes(ctx context.Context, category NodeCategory, parentID string, query ListQuery, opts ...LoadOption) ([]Node, int64, error) {
+	nodeQuery, err := query.nodeQuery(category, parentID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nodes, total, err := s.repo.QueryNodes(ctx, nodeQuery, opts...)
+	if err != nil {
+		return nil, 0, fuego.InternalServerError{
+			Title: "Failed to list " + nodeCategoryPlurals[category],
+			Err:   err,
+		}
+	}
+	return nodes, total, nil
+}
+
+// QueryVendors returns a page of the vendors and the total number of
+// vendors matching the query.
+func (s *Service) QueryVendors(ctx context.Context, query ListQuery) ([]VendorDTO, int64, error) {
+	nodes, total, err := s.queryNodes(ctx, Vendor, "", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	vendors := make([]VendorDTO, len(nodes))
+	for i, node := range nodes {
+		vendors[i] = VendorDTO{
+			ID:           node.ID,
+			Name:         node.Name,
+			Description:  node.Description,
+			ProductCount: len(node.Children),
+		}
+	}
+
+	return vendors, total, nil
+}
+
+// QueryProducts returns a page of the products and the total number of
+// products matching the query. Products can be filtered by type, vendor,
+// family including its subfamilies and whether they have versions.
+func (s *Service) QueryProducts(ctx context.Context, query ListQuery) ([]ProductDTO, int64, error) {
+	nodes, total, err := s.queryNodes(ctx, ProductName, query.VendorID, query, WithParent(), WithChildren())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	products := make([]ProductDTO, len(nodes))
+	for i, node := range nodes {
+		products[i] = nodeToProductDTO(node, s.now())
+	}
+
+	return products, total, nil
+}
+
+// QueryVendorProducts is QueryProducts restricted to the products of a
+// vendor.
+func (s *Service) QueryVendorProducts(ctx context.Context, vendorID string, query ListQuery) ([]ProductDTO, int64, error) {
+	vendor, err := s.repo.GetNodeByID(ctx, vendorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && vendor.Category != Vendor) {
+		return nil, 0, fuego.NotFoundError{
+			Title: "Vendor not found",
+		}
+	}
+	if err != nil {
+		return nil, 0, fuego.InternalServerError{
+			Title: "Failed to fetch vendor",
+			Err:   err,
+		}
+	}
+
+	query.VendorID = vendor.ID
+	return s.QueryProducts(ctx, query)
+}
+
+// QueryProductVersions returns a page of the versions of a product and the
+// total number of its versions matching the query. Without sort field the
+// versions are sorted by the version scheme of the product.
+func (s *Service) QueryProductVersions(ctx context.Context, productID string, query ListQuery) ([]ProductVersionDTO, int64, error) {
+	product, err := s.repo.GetNodeByID(ctx, productID, WithChildren())
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && product.Category != ProductName) {
+		return nil, 0, fuego.NotFoundError{
+			Title: "Product not found",
+		}
+	}
+	if err != nil {
+		return nil, 0, fuego.InternalServerError{
+			Title: "Failed to fetch product",
+			Err:   err,
+		}
+	}
+
+	if query.Sort != "" {
+		nodes, total, err := s.queryNodes(ctx, ProductVersion, product.ID, query)
+		if err != nil {
+			return nil, 0, err
+		}
+		details := newVersionDetails(product, s.now())
+		versions := make([]ProductVersionDTO, len(nodes))
+		for i, node := range nodes {
+			versions[i] = details.dto(node)
+		}
+
+		return versions, total, nil
+	}
+
+	// The version order is not known to the database, so all matching
+	// versions are sorted and paged here
+	if _, err := query.nodeQuery(ProductVersion, product.ID); err != nil {
+		return nil, 0, err
+	}
+	all := query
+	all.Limit, all.Offset = 0, 0
+	nodes, _, err := s.queryNodes(ctx, ProductVersion, product.ID, all)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sorted, _ := productVersionDTOs(product, versionsOf(product), s.now())
+	matching := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		matching[node.ID] = true
+	}
+	versions := make([]ProductVersionDTO, 0, len(nodes))
+	for _, version := range sorted {
+		if matching[version.ID] {
+			versions = append(versions, version)
+		}
+	}
+	if strings.EqualFold(query.Order, "desc") {
+		slices.Reverse(versions)
+	}
+
+	total := int64(len(versions))
+	versions = versions[min(query.Offset, len(versions)):]
+	if query.Limit > 0 {
+		versions = versions[:min(query.Limit, len(versions))]
+	}
+	return versions, total, nil
+}
+
+// QueryProductFamilies returns a page of the product families and the total
+// number of families matching the query. Every family carries its full path.
+func (s *Service) QueryProductFamilies(ctx context.Context, query ListQuery) ([]ProductFamilyDTO, int64, error) {
+	nodes, total, err := s.queryNodes(ctx, ProductFamily, "", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	families := make([]*ProductFamilyDTO, len(nodes))
+	for i, node := range nodes {
+		families[i] = &ProductFamilyDTO{
+			ID:       node.ID,
+			Name:     node.Name,
+			ParentID: node.ParentID,
+		}
+	}
+	if err := s.fillPathOfFamilies(ctx, families); err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]ProductFamilyDTO, len(families))
+	for i, family := range families {
+		result[i] = *family
+	}
+
+	return result, total, nil
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestListQueries(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	acme, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	globex, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "globex"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	_, err = svc.CreateVendor(ctx, CreateVendorDTO{Name: "Bolt 100%"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+
+	network, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Network"})
+	testutils.AssertNoError(t, err, "Should create family")
+	routers, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Routers", ParentID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create subfamily")
+
+	products := []CreateProductDTO{
+		{Name: "Switch", VendorID: acme.ID, Type: "hardware", FamilyID: &network.ID},
+		{Name: "Router", VendorID: acme.ID, Type: "hardware", FamilyID: &routers.ID},
+		{Name: "Cloud", VendorID: acme.ID, Type: "software"},
+		{Name: "Agent", VendorID: globex.ID, Type: "software"},
+	}
+	productIDs := make(map[string]string)
+	for _, product := range products {
+		created, err := svc.CreateProduct(ctx, product)
+		testutils.AssertNoError(t, err, "Should create product")
+		productIDs[product.Name] = created.ID
+	}
+
+	for _, version := range []CreateProductVersionDTO{
+		{Version: "1.0", ProductID: productIDs["Router"], ReleaseDate: stringPtr("2023-05-01")},
+		{Version: "2.0", ProductID: productIDs["Router"], ReleaseDate: stringPtr("2024-01-15")},
+		{Version: "1.5", ProductID: productIDs["Router"]},
+		{Version: "1.0", ProductID: productIDs["Agent"]},
+	} {
+		_, err := svc.CreateProductVersion(ctx, version)
+		testutils.AssertNoError(t, err, "Should create version")
+	}
+
+	productNames := func(t *testing.T, query ListQuery) (string, int64) {
+		result, total, err := svc.QueryProducts(ctx, query)
+		testutils.AssertNoError(t, err, "Query should succeed")
+		names := make([]string, len(result))
+		for i, product := range result {
+			names[i] = product.Name
+		}
+		return strings.Join(names, ","), total
+	}
+
+	t.Run("ProductFilters", func(t *testing.T) {
+		hasVersions, noVersions := true, false
+		tests := []struct {
+			name     string
+			query    ListQuery
+			expected string
+		}{
+			{"All", ListQuery{}, "Switch,Router,Cloud,Agent"},
+			{"SortByName", ListQuery{Sort: SortByName}, "Agent,Cloud,Router,Switch"},
+			{"SortByNameDesc", ListQuery{Sort: SortByName, Order: "desc"}, "Switch,Router,Cloud,Agent"},
+			{"Type", ListQuery{ProductType: "software"}, "Cloud,Agent"},
+			{"Vendor", ListQuery{VendorID: globex.ID}, "Agent"},
+			{"FamilyWithSubfamilies", ListQuery{FamilyID: network.ID}, "Switch,Router"},
+			{"Subfamily", ListQuery{FamilyID: routers.ID}, "Router"},
+			{"HasVersions", ListQuery{HasVersions: &hasVersions}, "Router,Agent"},
+			{"HasNoVersions", ListQuery{HasVersions: &noVersions}, "Switch,Cloud"},
+			{"Name", ListQuery{Name: "OU"}, "Router,Cloud"},
+			{"Combined", ListQuery{VendorID: acme.ID, ProductType: "hardware", HasVersions: &noVersions}, "Switch"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				names, total := productNames(t, tt.query)
+				testutils.AssertEqual(t, tt.expected, names, "Products")
+				testutils.AssertEqual(t, int64(len(strings.Split(tt.expected, ","))), total, "Total")
+			})
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		names, total := productNames(t, ListQuery{Sort: SortByName, Limit: 2})
+		testutils.AssertEqual(t, "Agent,Cloud", names, "First page")
+		testutils.AssertEqual(t, int64(4), total, "Total of first page")
+
+		names, total = productNames(t, ListQuery{Sort: SortByName, Limit: 2, Offset: 2})
+		testutils.AssertEqual(t, "Router,Switch", names, "Second page")
+		testutils.AssertEqual(t, int64(4), total, "Total of second page")
+
+		names, _ = productNames(t, ListQuery{Sort: SortByName, Limit: 2, Offset: 4})
+		testutils.AssertEqual(t, "", names, "Page after the last")
+	})
+
+	t.Run("Vendors", func(t *testing.T) {
+		vendors, total, err := svc.QueryVendors(ctx, ListQuery{Name: "%"})
+		testutils.AssertNoError(t, err, "Query should succeed")
+		testutils.AssertEqual(t, int64(1), total, "Wildcards in the name filter are literal")
+		testutils.AssertEqual(t, "Bolt 100%", vendors[0].Name, "Vendor")
+
+		vendors, _, err = svc.QueryVendors(ctx, ListQuery{Sort: SortByName})
+		testutils.AssertNoError(t, err, "Query should succeed")
+		testutils.AssertEqual(t, "globex", vendors[2].Name, "Names sort case-insensitive")
+
+		vendors, _, err = svc.QueryVendors(ctx, ListQuery{Sort: SortByCreatedAt, Order: "desc", Limit: 1})
+		testutils.AssertNoError(t, err, "Query should succeed")
+		testutils.AssertEqual(t, "Bolt 100%", vendors[0].Name, "Newest vendor")
+	})
+
+	t.Run("ProductVersions", func(t *testing.T) {
+		versions, total, err := svc.QueryProductVersions(ctx, productIDs["Router"], ListQuery{Sort: SortByReleasedAt, Order: "desc"})
+		testutils.AssertNoError(t, err, "Query should succeed")
+		testutils.AssertEqual(t, int64(3), total, "Total")
+		testutils.AssertEqual(t, "2.0", versions[0].Name, "Latest release first")
+		testutils.AssertEqual(t, "2024-01-15", *versions[0].ReleasedAt, "Release date")
+		testutils.AssertEqual(t, "1.5", versions[2].Name, "Unreleased version last")
+
+		_, _, err = svc.QueryProductVersions(ctx, acme.ID, ListQuery{})
+		var notFound fuego.NotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("Expected NotFoundError for a vendor ID, got %v", err)
+		}
+	})
+
+	t.Run("VendorProductsAndFamilies", func(t *testing.T) {
+		products, total, err := svc.QueryVendorProducts(ctx, acme.ID, ListQuery{Sort: SortByName, Limit: 1})
+		testutils.AssertNoError(t, err, "Query should succeed")
+		testutils.AssertEqual(t, int64(3), total, "Total")
+		testutils.AssertEqual(t, "Cloud", products[0].Name, "First product")
+
+		families, total, err := svc.QueryProductFamilies(ctx, ListQuery{Name: "rout"})
+		testutils.AssertNoError(t, err, "Query should succeed")
+		testutils.AssertEqual(t, int64(1), total, "Total")
+		testutils.AssertEqual(t, "Network,Routers", strings.Join(families[0].Path, ","), "Family path")
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		for _, query := range []ListQuery{
+			{Limit: -1},
+			{Limit: MaxListLimit + 1},
+			{Offset: -1},
+			{Sort: "vendor"},
+			{Order: "up"},
+			{ProductType: "service"},
+		} {
+			_, _, err := svc.QueryProducts(ctx, query)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %+v, got %v", query, err)
+			}
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("GET", "/api/v1/products?sort=name&limit=1&offset=1&has_versions=false", nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+		testutils.AssertEqual(t, "2", w.Header().Get("X-Total-Count"), "Total count header")
+		testutils.AssertEqual(t, true, strings.Contains(w.Body.String(), `"name":"Switch"`), "Second product without versions")
+
+		req = httptest.NewRequest("GET", "/api/v1/products?has_versions=maybe", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusBadRequest, w.Code, "Status code of invalid filter")
+	})
+}
@@ -0,0 +1,288 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// Identifier types of the lookup index.
+const (
+	IdentifierPurl    = "purl"
+	IdentifierCPE     = "cpe"
+	IdentifierHash    = "hash"
+	IdentifierSKU     = "sku"
+	IdentifierSerial  = "serial"
+	IdentifierModel   = "model"
+	IdentifierURI     = "uri"
+	IdentifierSBOMURL = "sbom_url"
+)
+
+var identifierTypes = []string{
+	IdentifierPurl, IdentifierCPE, IdentifierHash, IdentifierSKU,
+	IdentifierSerial, IdentifierModel, IdentifierURI, IdentifierSBOMURL,
+}
+
+// identifierIndexEntries extracts the normalized identifiers of a helper's
+// metadata. Helpers whose metadata cannot be parsed are not indexed.
+func identifierIndexEntries(helper IdentificationHelper) []IdentifierIndex {
+	var metadata struct {
+		CPE        string   `json:"cpe"`
+		Purl       string   `json:"purl"`
+		SKUs       []string `json:"skus"`
+		Serials    []string `json:"serial_numbers"`
+		Models     []string `json:"models"`
+		SBOMURLs   []string `json:"sbom_urls"`
+		FileHashes []struct {
+			Items []struct {
+				Value string `json:"value"`
+			} `json:"items"`
+		} `json:"file_hashes"`
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+	}
+	if json.Unmarshal(helper.Metadata, &metadata) != nil {
+		return nil
+	}
+
+	var entries []IdentifierIndex
+	add := func(identifierType string, values ...string) {
+		for _, value := range values {
+			if value = normalizeIdentifier(identifierType, value); value != "" {
+				entries = append(entries, IdentifierIndex{
+					Type:     identifierType,
+					Value:    value,
+					HelperID: helper.ID,
+					NodeID:   helper.NodeID,
+				})
+			}
+		}
+	}
+
+	switch helper.Category {
+	case "cpe":
+		add(IdentifierCPE, metadata.CPE)
+	case "purl":
+		add(IdentifierPurl, metadata.Purl)
+	case "hashes":
+		for _, file := range metadata.FileHashes {
+			for _, item := range file.Items {
+				add(IdentifierHash, item.Value)
+			}
+		}
+	case "sku":
+		add(IdentifierSKU, metadata.SKUs...)
+	case "serial":
+		add(IdentifierSerial, metadata.Serials...)
+	case "models":
+		add(IdentifierModel, metadata.Models...)
+	case "sbom":
+		add(IdentifierSBOMURL, metadata.SBOMURLs...)
+	case "uri":
+		for _, uri := range metadata.URIs {
+			add(IdentifierURI, uri.URI)
+		}
+	}
+
+	return entries
+}
+
+var hashValue = regexp.MustCompile(`^(?:[a-z0-9-]+:)?([0-9a-f]{32}|[0-9a-f]{40}|[0-9a-f]{56}|[0-9a-f]{64}|[0-9a-f]{96}|[0-9a-f]{128})$`)
+
+// normalizeIdentifier brings an identifier into the form stored in the
+// index. Package URLs get their normalized canonical form, hashes lose an algorithm
+// prefix such as "sha256:" and everything else is compared case-insensitive.
+func normalizeIdentifier(identifierType, value string) string {
+	value = strings.TrimSpace(value)
+
+	switch identifierType {
+	case IdentifierPurl:
+		if purl, err := parsePurl(value); err == nil {
+			return purl.normalize().String()
+		}
+		return value
+	case IdentifierHash:
+		value = strings.ToLower(value)
+		if match := hashValue.FindStringSubmatch(value); match != nil {
+			return match[1]
+		}
+		return value
+	default:
+		return strings.ToLower(value)
+	}
+}
+
+// detectIdentifierTypes guesses the index types an identifier may belong to.
+func detectIdentifierTypes(identifier string) []string {
+	lower := strings.ToLower(strings.TrimSpace(identifier))
+
+	switch {
+	case strings.HasPrefix(lower, "pkg:"):
+		return []string{IdentifierPurl}
+	case strings.HasPrefix(lower, "cpe:"):
+		return []string{IdentifierCPE}
+	case hashValue.MatchString(lower):
+		return []string{IdentifierHash}
+	case strings.Contains(lower, "://"):
+		return []string{IdentifierURI, IdentifierSBOMURL}
+	default:
+		return []string{IdentifierSKU, IdentifierSerial, IdentifierModel}
+	}
+}
+
+// Lookup returns the product versions whose identification helpers contain
+// the given identifier. Without identifierType the type is derived from the
+// identifier, e.g. "pkg:" for package URLs or hex strings for hashes.
+func (s *Service) Lookup(ctx context.Context, identifier, identifierType string) (LookupResultDTO, error) {
+	if strings.TrimSpace(identifier) == "" {
+		return LookupResultDTO{}, fuego.BadRequestError{
+			Title: "Invalid lookup",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "identifier",
+					Reason: "Identifier is required",
+				},
+			},
+		}
+	}
+
+	types := detectIdentifierTypes(identifier)
+	if identifierType != "" {
+		valid := false
+		for _, t := range identifierTypes {
+			valid = valid || t == identifierType
+		}
+		if !valid {
+			return LookupResultDTO{}, fuego.BadRequestError{
+				Title: "Invalid lookup",
+				Errors: []fuego.ErrorItem{
+					{
+						Name:   "type",
+						Reason: "Type must be one of " + strings.Join(identifierTypes, ", "),
+					},
+				},
+			}
+		}
+		types = []string{identifierType}
+	}
+
+	result := LookupResultDTO{
+		Identifier: identifier,
+		Types:      types,
+		Matches:    []LookupMatchDTO{},
+	}
+
+	seen := make(map[string]bool)
+	nodes := make(map[string]Node)
+	getNode := func(id string) (Node, error) {
+		if node, ok := nodes[id]; ok {
+			return node, nil
+		}
+		node, err := s.repo.GetNodeByID(ctx, id)
+		if err != nil {
+			return Node{}, err
+		}
+		nodes[id] = node
+		return node, nil
+	}
+	versionDTOs := s.newVersionDTOs()
+
+	for _, t := range types {
+		entries, err := s.repo.LookupIdentifier(ctx, t, normalizeIdentifier(t, identifier))
+		if err != nil {
+			return LookupResultDTO{}, fuego.InternalServerError{
+				Title: "Failed to look up identifier",
+				Err:   err,
+			}
+		}
+
+		for _, entry := range entries {
+			key := t + "|" + entry.NodeID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			match, ok, err := s.lookupMatch(ctx, entry, getNode, versionDTOs)
+			if err != nil {
+				return LookupResultDTO{}, fuego.InternalServerError{
+					Title: "Failed to fetch product version",
+					Err:   err,
+				}
+			}
+			if ok {
+				result.Matches = append(result.Matches, match)
+			}
+		}
+	}
+
+	sortLookupMatches(result.Matches)
+
+	return result, nil
+}
+
+// sortLookupMatches orders matches by vendor, product and version.
+func sortLookupMatches(matches []LookupMatchDTO) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Vendor.Name != b.Vendor.Name {
+			return a.Vendor.Name < b.Vendor.Name
+		}
+		if a.Product.Name != b.Product.Name {
+			return a.Product.Name < b.Product.Name
+		}
+		return compareVersions(a.ProductVersion.Name, b.ProductVersion.Name) < 0
+	})
+}
+
+// lookupMatch resolves the version, product and vendor of an index entry.
+// Entries of nodes other than product versions are ignored.
+func (s *Service) lookupMatch(ctx context.Context, entry IdentifierIndex, getNode func(string) (Node, error), versionDTOs *versionDTOs) (LookupMatchDTO, bool, error) {
+	version, err := getNode(entry.NodeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && (version.Category != ProductVersion || version.ParentID == nil)) {
+		return LookupMatchDTO{}, false, nil
+	}
+	if err != nil {
+		return LookupMatchDTO{}, false, err
+	}
+
+	product, err := getNode(*version.ParentID)
+	if err != nil {
+		return LookupMatchDTO{}, false, err
+	}
+
+	versionDTO, err := versionDTOs.convert(ctx, version)
+	if err != nil {
+		return LookupMatchDTO{}, false, err
+	}
+
+	match := LookupMatchDTO{
+		IdentifierType:         entry.Type,
+		IdentificationHelperID: entry.HelperID,
+		ProductVersion:         versionDTO,
+		Product:                LookupNodeDTO{ID: product.ID, Name: product.Name},
+	}
+
+	if product.ParentID != nil {
+		vendor, err := getNode(*product.ParentID)
+		if err != nil {
+			return LookupMatchDTO{}, false, err
+		}
+		match.Vendor = LookupNodeDTO{ID: vendor.ID, Name: vendor.Name}
+	}
+
+	return match, true, nil
+}
+
+// RebuildLookupIndex recreates the lookup index from all identification
+// helpers, e.g. after helpers were written without the repository.
+func (s *Service) RebuildLookupIndex(ctx context.Context) error {
+	return s.repo.RebuildIdentifierIndex(ctx)
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"product-database-api/testutils"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestLookup(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	first, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create first version")
+	second, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create second version")
+
+	const sha256 = "88525753f1777573a7bd3bd6e4a8a6a8a7a8c7a6e4a8a6a8a7a8c7a6e4a8a6a8"
+	helpers := []CreateIdentificationHelperDTO{
+		{ProductVersionID: first.ID, Category: "purl", Metadata: `{"purl": "pkg:npm/%40acme/router@1.0.0?os=linux&arch=arm64"}`},
+		{ProductVersionID: first.ID, Category: "cpe", Metadata: `{"cpe": "cpe:2.3:h:acme:router:1.0.0:*:*:*:*:*:*:*"}`},
+		{ProductVersionID: first.ID, Category: "hashes", Metadata: `{"file_hashes": [{"filename": "router.bin", "items": [{"algorithm": "sha256", "value": "` + sha256 + `"}]}]}`},
+		{ProductVersionID: first.ID, Category: "sku", Metadata: `{"skus": ["R-100", "R-100-EU"]}`},
+		{ProductVersionID: second.ID, Category: "sku", Metadata: `{"skus": ["r-100"]}`},
+		{ProductVersionID: second.ID, Category: "serial", Metadata: `{"serial_numbers": ["SN-0042"]}`},
+		{ProductVersionID: second.ID, Category: "uri", Metadata: `{"uris": [{"namespace": "https://acme.example", "uri": "https://acme.example/router/2.0.0"}]}`},
+	}
+	created := make([]IdentificationHelperDTO, len(helpers))
+	for i, helper := range helpers {
+		created[i], err = svc.CreateIdentificationHelper(ctx, helper)
+		testutils.AssertNoError(t, err, "Should create identification helper")
+	}
+
+	versionsOf := func(t *testing.T, identifier, identifierType string) []string {
+		result, err := svc.Lookup(ctx, identifier, identifierType)
+		testutils.AssertNoError(t, err, "Lookup should succeed")
+
+		var versions []string
+		for _, match := range result.Matches {
+			testutils.AssertEqual(t, "Acme", match.Vendor.Name, "Vendor of match")
+			testutils.AssertEqual(t, "Router", match.Product.Name, "Product of match")
+			versions = append(versions, match.ProductVersion.Name)
+		}
+		return versions
+	}
+
+	t.Run("ByIdentifier", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			identifier string
+			expected   string
+		}{
+			{"CanonicalPurl", "pkg:npm/%40acme/router@1.0.0?arch=arm64&os=linux", "1.0.0"},
+			{"PurlQualifierOrder", "pkg:NPM/%40acme/router@1.0.0?os=linux&arch=arm64", "1.0.0"},
+			{"CPE", "CPE:2.3:h:acme:router:1.0.0:*:*:*:*:*:*:*", "1.0.0"},
+			{"Hash", sha256, "1.0.0"},
+			{"HashWithAlgorithm", "SHA256:" + sha256, "1.0.0"},
+			{"SKUOfBothVersions", "r-100", "1.0.0,2.0.0"},
+			{"Serial", "sn-0042", "2.0.0"},
+			{"URI", "https://acme.example/router/2.0.0", "2.0.0"},
+			{"Unknown", "pkg:npm/%40acme/router@3.0.0", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				versions := versionsOf(t, tt.identifier, "")
+				actual := ""
+				for i, version := range versions {
+					if i > 0 {
+						actual += ","
+					}
+					actual += version
+				}
+				testutils.AssertEqual(t, tt.expected, actual, "Matched versions")
+			})
+		}
+	})
+
+	t.Run("ExplicitType", func(t *testing.T) {
+		testutils.AssertCount(t, 0, len(versionsOf(t, "R-100", IdentifierSerial)), "SKU is no serial number")
+		testutils.AssertCount(t, 2, len(versionsOf(t, "R-100", IdentifierSKU)), "SKU matches")
+	})
+
+	t.Run("IndexFollowsUpdatesAndDeletes", func(t *testing.T) {
+		metadata := `{"serial_numbers": ["SN-0043"]}`
+		_, err := svc.UpdateIdentificationHelper(ctx, created[5].ID, UpdateIdentificationHelperDTO{Metadata: &metadata})
+		testutils.AssertNoError(t, err, "Should update identification helper")
+		testutils.AssertCount(t, 0, len(versionsOf(t, "SN-0042", "")), "Old serial number")
+		testutils.AssertCount(t, 1, len(versionsOf(t, "SN-0043", "")), "New serial number")
+
+		testutils.AssertNoError(t, svc.DeleteIdentificationHelper(ctx, created[5].ID), "Should delete identification helper")
+		testutils.AssertCount(t, 0, len(versionsOf(t, "SN-0043", "")), "Deleted serial number")
+	})
+
+	t.Run("Rebuild", func(t *testing.T) {
+		// Helpers written directly to the database are not indexed until the index is rebuilt
+		testutils.CreateTestIdentificationHelper(t, db, second.ID, "models", []byte(`{"models": ["RT-2000"]}`))
+		testutils.AssertCount(t, 0, len(versionsOf(t, "RT-2000", "")), "Before rebuild")
+
+		testutils.AssertNoError(t, svc.RebuildLookupIndex(ctx), "Should rebuild index")
+		testutils.AssertCount(t, 1, len(versionsOf(t, "RT-2000", "")), "After rebuild")
+		testutils.AssertCount(t, 1, len(versionsOf(t, sha256, "")), "Existing helpers after rebuild")
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		for _, input := range [][2]string{{"", ""}, {"R-100", "gtin"}} {
+			_, err := svc.Lookup(ctx, input[0], input[1])
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %v, got %v", input, err)
+			}
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("GET", "/api/v1/lookup?identifier="+url.QueryEscape("pkg:npm/%40acme/router@1.0.0?arch=arm64&os=linux"), nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var result LookupResultDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Should decode result")
+		testutils.AssertCount(t, 1, len(result.Matches), "Matches")
+		testutils.AssertEqual(t, IdentifierPurl, result.Matches[0].IdentifierType, "Identifier type")
+		testutils.AssertEqual(t, first.ID, result.Matches[0].ProductVersion.ID, "Matched version")
+		testutils.AssertEqual(t, product.ID, result.Matches[0].Product.ID, "Matched product")
+		testutils.AssertEqual(t, vendor.ID, result.Matches[0].Vendor.ID, "Matched vendor")
+	})
+}
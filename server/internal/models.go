@@ -1,14 +1,20 @@
 package internal
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type NodeCategory string
 
 const (
-	Vendor         NodeCategory = "vendor"
-	ProductFamily  NodeCategory = "product_family"
-	ProductName    NodeCategory = "product_name"
-	ProductVersion NodeCategory = "product_version"
+	Vendor              NodeCategory = "vendor"
+	ProductFamily       NodeCategory = "product_family"
+	ProductName         NodeCategory = "product_name"
+	ProductVersion      NodeCategory = "product_version"
+	ProductVersionRange NodeCategory = "product_version_range"
 )
 
 type RelationshipCategory string
@@ -31,6 +37,10 @@ const (
 
 type IdentificationHelperCategory string
 
+// Node is a vendor, product family, product, version or version range.
+// Deleted nodes are moved to the trash: DeletedAt is set and DeletionID is
+// the ID of the node whose deletion trashed it, so everything deleted
+// together is restored or purged together.
 type Node struct {
 	ID       string `gorm:"primaryKey"`
 	Category NodeCategory
@@ -49,8 +59,27 @@ type Node struct {
 	ProductFamilyID *string     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	ReleasedAt      sql.NullTime
 
-	SuccessorID *string
-	Successor   *Node `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	// EndOfSupport, EndOfSecurityUpdates, EndOfLife and SupportStatus are the
+	// lifecycle of a version. Those of a product are the defaults of its
+	// versions.
+	EndOfSupport         sql.NullTime
+	EndOfSecurityUpdates sql.NullTime
+	EndOfLife            sql.NullTime
+	SupportStatus        SupportStatus
+
+	// VersionScheme and VersionPattern define the order of the versions of
+	// a product.
+	VersionScheme  VersionScheme
+	VersionPattern string
+
+	// PredecessorID is the version a version succeeds. The versions of a
+	// product form chains, every version has at most one successor.
+	PredecessorID *string `gorm:"index"`
+	Predecessor   *Node   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+
+	CreatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	DeletionID *string        `gorm:"index"`
 }
 
 type Relationship struct {
@@ -62,6 +91,9 @@ type Relationship struct {
 
 	TargetNodeID string
 	TargetNode   *Node `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	DeletionID *string        `gorm:"index"`
 }
 
 type IdentificationHelper struct {
@@ -71,6 +103,68 @@ type IdentificationHelper struct {
 
 	NodeID string
 	Node   *Node `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	DeletionID *string        `gorm:"index"`
+}
+
+// IdentifierIndex is a normalized projection of the identifiers contained in
+// the metadata of identification helpers, used for reverse lookups. It is
+// maintained by the repository and can be rebuilt at any time.
+type IdentifierIndex struct {
+	ID    uint   `gorm:"primaryKey"`
+	Type  string `gorm:"index:idx_identifier_lookup,priority:1"`
+	Value string `gorm:"index:idx_identifier_lookup,priority:2"`
+
+	HelperID string                `gorm:"index"`
+	Helper   *IdentificationHelper `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	NodeID   string
+}
+
+// AuditEntry records a create, update or delete of a node, relationship or
+// identification helper. NodeID is the node the entity belongs to, so the
+// history of a node includes its helpers and outgoing relationships.
+type AuditEntry struct {
+	ID         string `gorm:"primaryKey"`
+	EntityType string `gorm:"index:idx_audit_entity,priority:1"`
+	EntityID   string `gorm:"index:idx_audit_entity,priority:2"`
+	NodeID     string `gorm:"index"`
+	Action     string
+	Changes    []AuditChange `gorm:"serializer:json"`
+	Actor      string
+	RequestID  string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// AuditChange is the value of a single field before and after a change.
+// Before is nil for creates and After is nil for deletes.
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// RowVersion is the state of a node, relationship or identification helper
+// during a time span, stored as JSON of the row. ValidTo is nil for the
+// current state, deleted rows have no current version.
+type RowVersion struct {
+	ID         uint   `gorm:"primaryKey"`
+	EntityType string `gorm:"index:idx_row_version_entity,priority:1"`
+	EntityID   string `gorm:"index:idx_row_version_entity,priority:2"`
+	Data       []byte
+	ValidFrom  time.Time  `gorm:"index"`
+	ValidTo    *time.Time `gorm:"index"`
+}
+
+// TrashedReference is a product family or predecessor reference to a node in
+// the trash. It is cleared while the node is in the trash and set again when
+// the node with DeletionID is restored.
+type TrashedReference struct {
+	ID         uint   `gorm:"primaryKey"`
+	DeletionID string `gorm:"index"`
+	NodeID     string `gorm:"index"`
+	Field      string
+	TargetID   string
 }
 
 func Models() []interface{} {
@@ -78,5 +172,9 @@ func Models() []interface{} {
 		&Node{},
 		&Relationship{},
 		&IdentificationHelper{},
+		&IdentifierIndex{},
+		&AuditEntry{},
+		&RowVersion{},
+		&TrashedReference{},
 	}
 }
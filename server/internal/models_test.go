@@ -102,9 +102,9 @@ func TestModels(t *testing.T) {
 
 	t.Run("ModelsFunction", func(t *testing.T) {
 		models := Models()
-		testutils.AssertCount(t, 3, len(models), "Should return 3 models")
+		testutils.AssertCount(t, 7, len(models), "Should return 7 models")
 		// Check that models contain the expected types
-		var hasNode, hasRelationship, hasIdentificationHelper bool
+		var hasNode, hasRelationship, hasIdentificationHelper, hasIdentifierIndex, hasAuditEntry, hasRowVersion, hasTrashedReference bool
 		for _, model := range models {
 			switch model.(type) {
 			case *Node:
@@ -113,36 +113,48 @@ func TestModels(t *testing.T) {
 				hasRelationship = true
 			case *IdentificationHelper:
 				hasIdentificationHelper = true
+			case *IdentifierIndex:
+				hasIdentifierIndex = true
+			case *AuditEntry:
+				hasAuditEntry = true
+			case *RowVersion:
+				hasRowVersion = true
+			case *TrashedReference:
+				hasTrashedReference = true
 			}
 		}
 		testutils.AssertEqual(t, true, hasNode, "Should include Node model")
 		testutils.AssertEqual(t, true, hasRelationship, "Should include Relationship model")
 		testutils.AssertEqual(t, true, hasIdentificationHelper, "Should include IdentificationHelper model")
+		testutils.AssertEqual(t, true, hasIdentifierIndex, "Should include IdentifierIndex model")
+		testutils.AssertEqual(t, true, hasAuditEntry, "Should include AuditEntry model")
+		testutils.AssertEqual(t, true, hasRowVersion, "Should include RowVersion model")
+		testutils.AssertEqual(t, true, hasTrashedReference, "Should include TrashedReference model")
 	})
 
-	t.Run("SuccessorRelationship", func(t *testing.T) {
+	t.Run("PredecessorRelationship", func(t *testing.T) {
 		vendor := testutils.CreateTestVendor(t, db, "Test Vendor", "A test vendor")
 		product := testutils.CreateTestProduct(t, db, "Test Product", "A test product", vendor.ID, testutils.Software)
 
 		version1 := testutils.CreateTestProductVersion(t, db, "1.0.0", "First version", product.ID, nil)
 
-		// Create version2 with version1 as successor
+		// Create version2 with version1 as predecessor
 		version2 := testutils.Node{
-			ID:          uuid.New().String(),
-			Name:        "2.0.0",
-			Description: "Second version",
-			Category:    testutils.ProductVersion,
-			ParentID:    &product.ID,
-			SuccessorID: &version1.ID,
+			ID:            uuid.New().String(),
+			Name:          "2.0.0",
+			Description:   "Second version",
+			Category:      testutils.ProductVersion,
+			ParentID:      &product.ID,
+			PredecessorID: &version1.ID,
 		}
 
 		result := db.Create(&version2)
-		testutils.AssertNoError(t, result.Error, "Should create version with successor")
+		testutils.AssertNoError(t, result.Error, "Should create version with predecessor")
 
-		// Load with successor
+		// Load with predecessor
 		var loadedVersion testutils.Node
-		result = db.Preload("Successor").First(&loadedVersion, "id = ?", version2.ID)
-		testutils.AssertNoError(t, result.Error, "Should load version with successor")
-		testutils.AssertEqual(t, version1.ID, loadedVersion.Successor.ID, "Successor should be correct")
+		result = db.Preload("Predecessor").First(&loadedVersion, "id = ?", version2.ID)
+		testutils.AssertNoError(t, result.Error, "Should load version with predecessor")
+		testutils.AssertEqual(t, version1.ID, loadedVersion.Predecessor.ID, "Predecessor should be correct")
 	})
 }
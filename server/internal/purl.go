@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// packageURL is a parsed package URL as described by the purl specification,
+// e.g. "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1".
+type packageURL struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+	Subpath    string
+}
+
+// parsePurl splits a package URL into its components. Percent-encoded
+// characters are decoded.
+func parsePurl(purl string) (packageURL, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(purl), "pkg:")
+	if !ok {
+		return packageURL{}, fmt.Errorf("purl must start with 'pkg:'")
+	}
+	rest = strings.TrimLeft(rest, "/")
+
+	var result packageURL
+	var err error
+
+	if before, subpath, ok := strings.Cut(rest, "#"); ok {
+		rest = before
+		if result.Subpath, err = url.PathUnescape(strings.Trim(subpath, "/")); err != nil {
+			return packageURL{}, fmt.Errorf("invalid purl subpath: %w", err)
+		}
+	}
+
+	if before, query, ok := strings.Cut(rest, "?"); ok {
+		rest = before
+		result.Qualifiers = make(map[string]string)
+		for _, pair := range strings.Split(query, "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			if key == "" || value == "" {
+				continue
+			}
+			if value, err = url.QueryUnescape(value); err != nil {
+				return packageURL{}, fmt.Errorf("invalid purl qualifier %q: %w", key, err)
+			}
+			result.Qualifiers[strings.ToLower(key)] = value
+		}
+	}
+
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		if result.Version, err = url.PathUnescape(rest[at+1:]); err != nil {
+			return packageURL{}, fmt.Errorf("invalid purl version: %w", err)
+		}
+		rest = rest[:at]
+	}
+
+	typ, path, ok := strings.Cut(strings.Trim(rest, "/"), "/")
+	if !ok || typ == "" {
+		return packageURL{}, fmt.Errorf("purl must contain a type and a name")
+	}
+	result.Type = strings.ToLower(typ)
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if segments[i], err = url.PathUnescape(segment); err != nil {
+			return packageURL{}, fmt.Errorf("invalid purl segment %q: %w", segment, err)
+		}
+	}
+	result.Name = segments[len(segments)-1]
+	result.Namespace = strings.Join(segments[:len(segments)-1], "/")
+	if result.Name == "" {
+		return packageURL{}, fmt.Errorf("purl must contain a name")
+	}
+
+	return result, nil
+}
+
+// String returns the canonical form of the package URL with encoded
+// components and sorted qualifiers, so equal packages compare equal.
+func (p packageURL) String() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(p.Type)
+	if p.Namespace != "" {
+		for _, segment := range strings.Split(p.Namespace, "/") {
+			b.WriteString("/")
+			b.WriteString(purlEscape(segment))
+		}
+	}
+	b.WriteString("/")
+	b.WriteString(purlEscape(p.Name))
+	if p.Version != "" {
+		b.WriteString("@")
+		b.WriteString(purlEscape(p.Version))
+	}
+
+	if len(p.Qualifiers) > 0 {
+		keys := make([]string, 0, len(p.Qualifiers))
+		for key := range p.Qualifiers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i == 0 {
+				b.WriteString("?")
+			} else {
+				b.WriteString("&")
+			}
+			b.WriteString(key + "=" + url.QueryEscape(p.Qualifiers[key]))
+		}
+	}
+
+	if p.Subpath != "" {
+		b.WriteString("#" + p.Subpath)
+	}
+	return b.String()
+}
+
+// purlEscape percent-encodes a path component of a package URL. Other than
+// url.PathEscape it also encodes "@", which separates the version.
+func purlEscape(value string) string {
+	return strings.ReplaceAll(url.PathEscape(value), "@", "%40")
+}
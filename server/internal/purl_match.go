@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+)
+
+// purlLowercaseTypes are the package types whose namespace and name are case
+// insensitive according to the purl specification.
+var purlLowercaseTypes = map[string]bool{
+	"alpm":      true,
+	"apk":       true,
+	"bitbucket": true,
+	"composer":  true,
+	"deb":       true,
+	"github":    true,
+	"hex":       true,
+	"npm":       true,
+	"pypi":      true,
+}
+
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalize applies the type-specific rules of the purl specification, so
+// that package URLs an ecosystem considers equal are equal. pypi names are
+// normalized as in PEP 503, maven group IDs written as path segments are
+// joined with dots and the default maven qualifier "type=jar" is dropped.
+func (p packageURL) normalize() packageURL {
+	if purlLowercaseTypes[p.Type] {
+		p.Namespace = strings.ToLower(p.Namespace)
+		p.Name = strings.ToLower(p.Name)
+	}
+
+	switch p.Type {
+	case "pypi":
+		p.Name = pypiSeparators.ReplaceAllString(p.Name, "-")
+	case "maven":
+		p.Namespace = strings.ReplaceAll(p.Namespace, "/", ".")
+		if p.Qualifiers["type"] == "jar" {
+			qualifiers := make(map[string]string, len(p.Qualifiers))
+			for key, value := range p.Qualifiers {
+				if key != "type" {
+					qualifiers[key] = value
+				}
+			}
+			p.Qualifiers = qualifiers
+		}
+	}
+
+	return p
+}
+
+// matches reports whether the stored package URL is matched by the query.
+// Type, namespace and name must be equal, qualifiers and subpath of the query
+// must be present in the stored package URL and a query version must equal
+// the stored version. Both package URLs must be normalized.
+func (query packageURL) matches(stored packageURL) bool {
+	if query.Type != stored.Type || query.Namespace != stored.Namespace || query.Name != stored.Name {
+		return false
+	}
+	for key, value := range query.Qualifiers {
+		if stored.Qualifiers[key] != value {
+			return false
+		}
+	}
+	if query.Subpath != "" && query.Subpath != stored.Subpath {
+		return false
+	}
+	if query.Version != "" && compareVersions(query.Version, stored.Version) != 0 {
+		return false
+	}
+	return true
+}
+
+// MatchPurl returns the product versions whose purl identification helper is
+// matched by the given package URL. The query purl may omit the version and
+// qualifiers to match every version of a package. With vers, e.g.
+// "vers:npm/>=1.0.0|<2.0.0", only stored versions within the range match.
+func (s *Service) MatchPurl(ctx context.Context, purl, vers string) (LookupResultDTO, error) {
+	invalid := func(name string, err error) error {
+		return fuego.BadRequestError{
+			Title: "Invalid purl match",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   name,
+					Reason: err.Error(),
+				},
+			},
+		}
+	}
+
+	query, err := parsePurl(purl)
+	if err != nil {
+		return LookupResultDTO{}, invalid("purl", err)
+	}
+	query = query.normalize()
+
+	var constraints []versConstraint
+	if vers != "" {
+		if query.Version != "" {
+			return LookupResultDTO{}, invalid("vers", fmt.Errorf("vers cannot be combined with a purl version"))
+		}
+		var scheme string
+		if scheme, constraints, err = parseVers(vers); err != nil {
+			return LookupResultDTO{}, invalid("vers", err)
+		}
+		if scheme != query.Type && scheme != versGenericScheme {
+			return LookupResultDTO{}, invalid("vers", fmt.Errorf("vers scheme %q does not match purl type %q", scheme, query.Type))
+		}
+	}
+
+	helpers, err := s.repo.GetIdentificationHelpersByCategory(ctx, "purl")
+	if err != nil {
+		return LookupResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch identification helpers",
+			Err:   err,
+		}
+	}
+
+	result := LookupResultDTO{
+		Identifier: purl,
+		Types:      []string{IdentifierPurl},
+		Matches:    []LookupMatchDTO{},
+	}
+
+	seen := make(map[string]bool)
+	nodes := make(map[string]Node)
+	getNode := func(id string) (Node, error) {
+		if node, ok := nodes[id]; ok {
+			return node, nil
+		}
+		node, err := s.repo.GetNodeByID(ctx, id)
+		if err != nil {
+			return Node{}, err
+		}
+		nodes[id] = node
+		return node, nil
+	}
+	versionDTOs := s.newVersionDTOs()
+
+	for _, helper := range helpers {
+		if seen[helper.NodeID] {
+			continue
+		}
+
+		var metadata struct {
+			Purl string `json:"purl"`
+		}
+		if json.Unmarshal(helper.Metadata, &metadata) != nil {
+			continue
+		}
+		stored, err := parsePurl(metadata.Purl)
+		if err != nil {
+			continue
+		}
+		stored = stored.normalize()
+		if !query.matches(stored) {
+			continue
+		}
+		if constraints != nil && (stored.Version == "" || !versContains(constraints, stored.Version, compareVersions)) {
+			continue
+		}
+
+		entry := IdentifierIndex{Type: IdentifierPurl, HelperID: helper.ID, NodeID: helper.NodeID}
+		match, ok, err := s.lookupMatch(ctx, entry, getNode, versionDTOs)
+		if err != nil {
+			return LookupResultDTO{}, fuego.InternalServerError{
+				Title: "Failed to fetch product version",
+				Err:   err,
+			}
+		}
+		if ok {
+			seen[helper.NodeID] = true
+			result.Matches = append(result.Matches, match)
+		}
+	}
+
+	sortLookupMatches(result.Matches)
+
+	return result, nil
+}
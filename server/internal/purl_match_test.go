@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestPackageURLNormalize(t *testing.T) {
+	tests := []struct {
+		purl     string
+		expected string
+	}{
+		{"pkg:pypi/Django_REST.framework@3.14.0", "pkg:pypi/django-rest-framework@3.14.0"},
+		{"pkg:npm/%40Angular/Core@16.0.0", "pkg:npm/%40angular/core@16.0.0"},
+		{"pkg:maven/org/apache/logging/log4j/log4j-core@2.17.1?type=jar", "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1"},
+		{"pkg:maven/org.example/App@1.0?type=war", "pkg:maven/org.example/App@1.0?type=war"},
+		{"pkg:golang/github.com/Masterminds/semver@v3.2.0", "pkg:golang/github.com/Masterminds/semver@v3.2.0"},
+		{"pkg:GitHub/Package-URL/Purl-Spec", "pkg:github/package-url/purl-spec"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.purl, func(t *testing.T) {
+			purl, err := parsePurl(tt.purl)
+			testutils.AssertNoError(t, err, "Should parse purl")
+			testutils.AssertEqual(t, tt.expected, purl.normalize().String(), "Normalized purl")
+		})
+	}
+}
+
+func TestMatchPurl(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Web", VendorID: vendor.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create product")
+
+	purls := []struct {
+		version string
+		purl    string
+	}{
+		{"lodash-1", "pkg:npm/Lodash@1.3.1"},
+		{"lodash-2", "pkg:npm/lodash@2.0.0"},
+		{"lodash-4", "pkg:npm/lodash@4.17.21?os=linux&arch=x64"},
+		{"django", "pkg:pypi/Django@4.2.1"},
+		{"log4j", "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1?type=jar"},
+		{"unversioned", "pkg:npm/lodash"},
+	}
+	for _, p := range purls {
+		version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: p.version, ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+		_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+			ProductVersionID: version.ID,
+			Category:         "purl",
+			Metadata:         `{"purl": "` + p.purl + `"}`,
+		})
+		testutils.AssertNoError(t, err, "Should create identification helper")
+	}
+
+	matched := func(t *testing.T, purl, vers string) string {
+		result, err := svc.MatchPurl(ctx, purl, vers)
+		testutils.AssertNoError(t, err, "Match should succeed")
+
+		var versions []string
+		for _, match := range result.Matches {
+			testutils.AssertEqual(t, IdentifierPurl, match.IdentifierType, "Identifier type")
+			versions = append(versions, match.ProductVersion.Name)
+		}
+		return strings.Join(versions, ",")
+	}
+
+	tests := []struct {
+		name     string
+		purl     string
+		vers     string
+		expected string
+	}{
+		{"AllVersions", "pkg:npm/LODASH", "", "lodash-1,lodash-2,lodash-4,unversioned"},
+		{"Version", "pkg:npm/lodash@4.17.21", "", "lodash-4"},
+		{"QualifierOrder", "pkg:npm/lodash@4.17.21?arch=x64&os=linux", "", "lodash-4"},
+		{"QualifierMismatch", "pkg:npm/lodash?os=windows", "", ""},
+		{"Range", "pkg:npm/lodash", "vers:npm/>=1.0.0|<3.0.0", "lodash-1,lodash-2"},
+		{"GenericRange", "pkg:npm/lodash", "vers:generic/>2.0.0", "lodash-4"},
+		{"PypiName", "pkg:pypi/django", "vers:pypi/>=4.2", "django"},
+		{"MavenNamespace", "pkg:maven/org/apache/logging/log4j/log4j-core@2.17.1", "", "log4j"},
+		{"OtherType", "pkg:pypi/lodash", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutils.AssertEqual(t, tt.expected, matched(t, tt.purl, tt.vers), "Matched versions")
+		})
+	}
+
+	t.Run("ExactLookupIsNormalized", func(t *testing.T) {
+		result, err := svc.Lookup(ctx, "pkg:pypi/django@4.2.1", "")
+		testutils.AssertNoError(t, err, "Lookup should succeed")
+		testutils.AssertCount(t, 1, len(result.Matches), "Matches")
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		for _, input := range [][2]string{
+			{"", ""},
+			{"npm/lodash", ""},
+			{"pkg:npm/lodash", ">=1.0.0"},
+			{"pkg:npm/lodash", "vers:pypi/>=1.0.0"},
+			{"pkg:npm/lodash@1.0.0", "vers:npm/>=1.0.0"},
+		} {
+			_, err := svc.MatchPurl(ctx, input[0], input[1])
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %v, got %v", input, err)
+			}
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		query := url.Values{"purl": {"pkg:npm/lodash"}, "vers": {"vers:npm/>=4.0.0"}}
+		req := httptest.NewRequest("GET", "/api/v1/lookup/purl?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var result LookupResultDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Should decode result")
+		testutils.AssertCount(t, 1, len(result.Matches), "Matches")
+		testutils.AssertEqual(t, "lodash-4", result.Matches[0].ProductVersion.Name, "Matched version")
+		testutils.AssertEqual(t, vendor.ID, result.Matches[0].Vendor.ID, "Matched vendor")
+	})
+}
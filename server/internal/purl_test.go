@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestParsePurl(t *testing.T) {
+	tests := []struct {
+		purl      string
+		expected  packageURL
+		expectErr bool
+	}{
+		{
+			purl:     "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1",
+			expected: packageURL{Type: "maven", Namespace: "org.apache.logging.log4j", Name: "log4j-core", Version: "2.17.1"},
+		},
+		{
+			purl:     "pkg:npm/%40angular/core@16.0.0",
+			expected: packageURL{Type: "npm", Namespace: "@angular", Name: "core", Version: "16.0.0"},
+		},
+		{
+			purl: "pkg:deb/debian/curl@7.50.3-1?arch=i386&distro=jessie#src/lib",
+			expected: packageURL{
+				Type:       "deb",
+				Namespace:  "debian",
+				Name:       "curl",
+				Version:    "7.50.3-1",
+				Qualifiers: map[string]string{"arch": "i386", "distro": "jessie"},
+				Subpath:    "src/lib",
+			},
+		},
+		{
+			purl:     "pkg:GitHub/package-url/purl-spec",
+			expected: packageURL{Type: "github", Namespace: "package-url", Name: "purl-spec"},
+		},
+		{purl: "maven/org.example/app@1.0", expectErr: true},
+		{purl: "pkg:generic@1.0", expectErr: true},
+		{purl: "pkg:generic/", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.purl, func(t *testing.T) {
+			result, err := parsePurl(tt.purl)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.purl)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if result.Type != tt.expected.Type || result.Namespace != tt.expected.Namespace ||
+				result.Name != tt.expected.Name || result.Version != tt.expected.Version ||
+				result.Subpath != tt.expected.Subpath {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+			for key, value := range tt.expected.Qualifiers {
+				if result.Qualifiers[key] != value {
+					t.Errorf("Expected qualifier %s=%s, got %q", key, value, result.Qualifiers[key])
+				}
+			}
+		})
+	}
+}
+
+func TestPackageURLString(t *testing.T) {
+	tests := map[string]string{
+		"pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1":   "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1",
+		"pkg:NPM/%40angular/core@16.0.0":                         "pkg:npm/%40angular/core@16.0.0",
+		"pkg:deb/debian/curl@7.50.3-1?distro=jessie&arch=i386":   "pkg:deb/debian/curl@7.50.3-1?arch=i386&distro=jessie",
+		"pkg://golang/github.com/go-fuego/fuego@v0.18.8#param/":  "pkg:golang/github.com/go-fuego/fuego@v0.18.8#param",
+		"pkg:generic/openssl@3.0.13?download_url=https://x.test": "pkg:generic/openssl@3.0.13?download_url=https%3A%2F%2Fx.test",
+	}
+
+	for input, expected := range tests {
+		purl, err := parsePurl(input)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", input, err)
+		}
+		if purl.String() != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, input, purl.String())
+		}
+	}
+}
@@ -2,6 +2,12 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -10,8 +16,14 @@ type Repository interface {
 	GetNodeByID(ctx context.Context, id string, opts ...LoadOption) (Node, error)
 	CreateNode(ctx context.Context, node Node) (Node, error)
 	GetNodesByCategory(ctx context.Context, category NodeCategory, opts ...LoadOption) ([]Node, error)
+	QueryNodes(ctx context.Context, query NodeQuery, opts ...LoadOption) ([]Node, int64, error)
 	UpdateNode(ctx context.Context, node Node) error
 	DeleteNode(ctx context.Context, id string) error
+	GetTrash(ctx context.Context) ([]TrashEntry, error)
+	GetDeletedNodeByID(ctx context.Context, id string) (Node, error)
+	GetTrashedIDs(ctx context.Context) (TrashedIDs, error)
+	RestoreNode(ctx context.Context, id string) error
+	PurgeNode(ctx context.Context, id string) error
 	CreateRelationship(ctx context.Context, rel Relationship) (Relationship, error)
 	GetRelationshipByID(ctx context.Context, id string) (Relationship, error)
 	UpdateRelationship(ctx context.Context, rel Relationship) error
@@ -22,7 +34,19 @@ type Repository interface {
 	UpdateIdentificationHelper(ctx context.Context, helper IdentificationHelper) error
 	DeleteIdentificationHelper(ctx context.Context, id string) error
 	GetIdentificationHelpersByProductVersion(ctx context.Context, productVersionID string) ([]IdentificationHelper, error)
+	GetIdentificationHelpersByCategory(ctx context.Context, category string) ([]IdentificationHelper, error)
 	GetRelationshipsBySourceAndCategory(ctx context.Context, sourceNodeID, category string) ([]Relationship, error)
+	GetRelationshipsByNodeIDs(ctx context.Context, nodeIDs []string) ([]Relationship, error)
+	GetAllRelationships(ctx context.Context) ([]Relationship, error)
+	GetAllIdentificationHelpers(ctx context.Context) ([]IdentificationHelper, error)
+	LookupIdentifier(ctx context.Context, identifierType, value string) ([]IdentifierIndex, error)
+	RebuildIdentifierIndex(ctx context.Context) error
+	RebuildSearchIndex(ctx context.Context) error
+	SearchNodes(ctx context.Context, query string, limit int) ([]NodeSearchHit, error)
+	GetAuditEntries(ctx context.Context, query AuditQuery) ([]AuditEntry, error)
+	GetSnapshot(ctx context.Context, at time.Time) (CatalogSnapshot, error)
+	EnsureRowVersions(ctx context.Context) error
+	Transaction(ctx context.Context, fn func(repo Repository) error) error
 }
 
 type repository struct{ db *gorm.DB }
@@ -85,7 +109,16 @@ func (r *repository) GetNodeByID(ctx context.Context, id string, opts ...LoadOpt
 }
 
 func (r *repository) CreateNode(ctx context.Context, node Node) (Node, error) {
-	if err := r.db.WithContext(ctx).Create(&node).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&node).Error; err != nil {
+			return err
+		}
+		if err := recordRowVersion(tx, rowVersionNode, node.ID, node, time.Now().UTC()); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditCreate, nodeAuditSubject(node), nil, nodeAuditFields(node))
+	})
+	if err != nil {
 		return Node{}, err
 	}
 	return node, nil
@@ -118,22 +151,489 @@ func (r *repository) GetNodesByCategory(ctx context.Context, category NodeCatego
 	return nodes, nil
 }
 
+// NodeQuery selects, orders and pages the nodes of a category. Empty fields
+// do not filter.
+type NodeQuery struct {
+	Category NodeCategory
+	ParentID string
+	// FamilyID selects products of the family and all of its subfamilies.
+	FamilyID    string
+	ProductType ProductType
+	// Name selects nodes whose name contains the value, ignoring case.
+	Name string
+	// HasVersions selects nodes with or without product versions.
+	HasVersions *bool
+
+	// Sort is one of the node sort fields, the insertion order if empty.
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// Node sort fields of NodeQuery.
+const (
+	SortByName       = "name"
+	SortByReleasedAt = "released_at"
+	SortByCreatedAt  = "created_at"
+)
+
+var nodeSortColumns = map[string]string{
+	SortByName:       "nodes.name COLLATE NOCASE",
+	SortByReleasedAt: "nodes.released_at",
+	SortByCreatedAt:  "nodes.created_at",
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with backslashes.
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// QueryNodes returns a page of the nodes matching the query together with
+// the total number of matching nodes. A limit of 0 returns all nodes.
+func (r *repository) QueryNodes(ctx context.Context, query NodeQuery, opts ...LoadOption) ([]Node, int64, error) {
+	options := &LoadOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	db := r.db.WithContext(ctx).Model(&Node{}).Where("nodes.category = ?", query.Category)
+	if query.ParentID != "" {
+		db = db.Where("nodes.parent_id = ?", query.ParentID)
+	}
+	if query.FamilyID != "" {
+		db = db.Where(`nodes.product_family_id IN (
+			WITH RECURSIVE families(id) AS (
+				SELECT ? UNION
+				SELECT child.id FROM nodes child JOIN families ON child.parent_id = families.id
+				WHERE child.category = ? AND child.deleted_at IS NULL
+			) SELECT id FROM families)`, query.FamilyID, ProductFamily)
+	}
+	if query.ProductType != "" {
+		db = db.Where("nodes.product_type = ?", query.ProductType)
+	}
+	if query.Name != "" {
+		db = db.Where(`nodes.name LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(query.Name)+"%")
+	}
+	if query.HasVersions != nil {
+		exists := "EXISTS (SELECT 1 FROM nodes versions WHERE versions.parent_id = nodes.id AND versions.category = ? AND versions.deleted_at IS NULL)"
+		if !*query.HasVersions {
+			exists = "NOT " + exists
+		}
+		db = db.Where(exists, ProductVersion)
+	}
+
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := nodeSortColumns[query.Sort]
+	if !ok && query.Sort != "" {
+		return nil, 0, fmt.Errorf("unknown sort field %q", query.Sort)
+	}
+	direction := ""
+	if query.Desc {
+		direction = " DESC"
+	}
+	if column != "" {
+		db = db.Order(column + direction)
+	}
+	db = db.Order("nodes.rowid" + direction)
+
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+
+	if options.LoadChildren {
+		db = db.Preload("Children")
+	}
+	if options.LoadRelationships {
+		db = db.Preload("SourceRelationships")
+	}
+	if options.LoadParent {
+		db = db.Preload("Parent")
+	}
+
+	var nodes []Node
+	if err := db.Find(&nodes).Error; err != nil {
+		return nil, 0, err
+	}
+	return nodes, total, nil
+}
+
 func (r *repository) UpdateNode(ctx context.Context, node Node) error {
-	if err := r.db.WithContext(ctx).Save(&node).Error; err != nil {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old Node
+		err := tx.Where("id = ?", node.ID).First(&old).Error
+		found := !errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && found {
+			return err
+		}
+		if err := tx.Save(&node).Error; err != nil {
+			return err
+		}
+		if err := recordRowVersion(tx, rowVersionNode, node.ID, node, time.Now().UTC()); err != nil {
+			return err
+		}
+		if !found {
+			return recordAudit(ctx, tx, AuditCreate, nodeAuditSubject(node), nil, nodeAuditFields(node))
+		}
+		return recordAudit(ctx, tx, AuditUpdate, nodeAuditSubject(node), nodeAuditFields(old), nodeAuditFields(node))
+	})
+}
+
+// subtreeQuery selects a node and its descendants that are in the trash
+// (deleted) or not.
+const subtreeQuery = `WITH RECURSIVE subtree(id) AS (
+		SELECT ? UNION
+		SELECT child.id FROM nodes child JOIN subtree ON child.parent_id = subtree.id
+		WHERE child.deleted_at IS %s NULL
+	) SELECT id FROM subtree`
+
+// DeleteNode moves a node, all of its descendants, their identification
+// helpers and the relationships from or to them to the trash.
+func (r *repository) DeleteNode(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old Node
+		if err := tx.Where("id = ?", id).First(&old).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		var ids []string
+		if err := tx.Raw(fmt.Sprintf(subtreeQuery, ""), id).Scan(&ids).Error; err != nil {
+			return err
+		}
+
+		var helperIDs, relationshipIDs []string
+		if err := tx.Model(&IdentificationHelper{}).Where("node_id IN ?", ids).Pluck("id", &helperIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Relationship{}).
+			Where("(source_node_id IN ? OR target_node_id IN ?)", ids, ids).
+			Pluck("id", &relationshipIDs).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		trashed := map[string]any{"deleted_at": now, "deletion_id": id}
+		if err := tx.Model(&Node{}).Where("id IN ?", ids).Updates(trashed).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&IdentificationHelper{}).Where("id IN ?", helperIDs).Updates(trashed).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&IdentifierIndex{}, "node_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Relationship{}).Where("id IN ?", relationshipIDs).Updates(trashed).Error; err != nil {
+			return err
+		}
+
+		if err := endRowVersions(tx, rowVersionNode, ids, now); err != nil {
+			return err
+		}
+		if err := endRowVersions(tx, rowVersionHelper, helperIDs, now); err != nil {
+			return err
+		}
+		if err := endRowVersions(tx, rowVersionRelationship, relationshipIDs, now); err != nil {
+			return err
+		}
+		if err := clearTrashedReferences(tx, now); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, AuditDelete, nodeAuditSubject(old), nodeAuditFields(old), nil)
+	})
+}
+
+// referenceColumns are the node columns referencing other nodes besides the
+// parent.
+var referenceColumns = []string{"product_family_id", "predecessor_id"}
+
+// clearTrashedReferences clears the references of nodes outside of the trash
+// to nodes in the trash and keeps them as trashed references, so they are
+// treated as absent everywhere until the referenced node is restored.
+func clearTrashedReferences(tx *gorm.DB, at time.Time) error {
+	var references []TrashedReference
+	for _, column := range referenceColumns {
+		var found []TrashedReference
+		err := tx.Raw(fmt.Sprintf(`SELECT COALESCE(target.deletion_id, target.id) AS deletion_id,
+				node.id AS node_id, ? AS field, target.id AS target_id
+			FROM nodes node JOIN nodes target ON target.id = node.%s
+			WHERE node.deleted_at IS NULL AND target.deleted_at IS NOT NULL`, column), column).
+			Scan(&found).Error
+		if err != nil {
+			return err
+		}
+		references = append(references, found...)
+	}
+
+	var nodeIDs []string
+	for _, reference := range references {
+		if err := tx.Create(&reference).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Node{}).Where("id = ?", reference.NodeID).Update(reference.Field, nil).Error; err != nil {
+			return err
+		}
+		nodeIDs = append(nodeIDs, reference.NodeID)
+	}
+
+	return recordNodeVersions(tx, nodeIDs, at)
+}
+
+// restoreTrashedReferences sets the references to the nodes restored with
+// deletionID again, also of nodes deleted in the meantime. References changed
+// in the meantime are kept.
+func restoreTrashedReferences(tx *gorm.DB, deletionID string, at time.Time) error {
+	var references []TrashedReference
+	if err := tx.Where("deletion_id = ?", deletionID).Find(&references).Error; err != nil {
 		return err
 	}
-	return nil
+
+	var nodeIDs []string
+	for _, reference := range references {
+		result := tx.Unscoped().Model(&Node{}).
+			Where("id = ?", reference.NodeID).
+			Where(reference.Field+" IS NULL").
+			Update(reference.Field, reference.TargetID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			nodeIDs = append(nodeIDs, reference.NodeID)
+		}
+	}
+	if err := tx.Delete(&TrashedReference{}, "deletion_id = ?", deletionID).Error; err != nil {
+		return err
+	}
+
+	return recordNodeVersions(tx, nodeIDs, at)
 }
 
-func (r *repository) DeleteNode(ctx context.Context, id string) error {
-	if err := r.db.WithContext(ctx).Delete(&Node{}, "id = ?", id).Error; err != nil {
+// recordNodeVersions records the current state of changed nodes.
+func recordNodeVersions(tx *gorm.DB, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var nodes []Node
+	if err := tx.Where("id IN ?", ids).Find(&nodes).Error; err != nil {
 		return err
 	}
+	for _, node := range nodes {
+		if err := recordRowVersion(tx, rowVersionNode, node.ID, node, at); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// TrashEntry is a node deleted by the user together with the number of
+// nodes, including itself, that were moved to the trash with it.
+type TrashEntry struct {
+	Node      Node
+	NodeCount int64
+}
+
+// GetTrash returns the nodes deleted by the user, most recently deleted
+// first. Descendants deleted along with them are only counted.
+func (r *repository) GetTrash(ctx context.Context) ([]TrashEntry, error) {
+	var nodes []Node
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deletion_id = id").
+		Order("deleted_at DESC").Order("rowid DESC").
+		Find(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		DeletionID string
+		Count      int64
+	}
+	err = r.db.WithContext(ctx).Unscoped().Model(&Node{}).
+		Select("deletion_id, COUNT(*) AS count").
+		Where("deleted_at IS NOT NULL").
+		Group("deletion_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	nodeCounts := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		nodeCounts[count.DeletionID] = count.Count
+	}
+
+	entries := make([]TrashEntry, len(nodes))
+	for i, node := range nodes {
+		entries[i] = TrashEntry{Node: node, NodeCount: nodeCounts[node.ID]}
+	}
+	return entries, nil
+}
+
+// GetDeletedNodeByID returns a node that is in the trash.
+func (r *repository) GetDeletedNodeByID(ctx context.Context, id string) (Node, error) {
+	var node Node
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&node).Error
+	if err != nil {
+		return Node{}, err
+	}
+	return node, nil
+}
+
+// TrashedIDs are the IDs of the rows in the trash. They are still taken and
+// cannot be used for new rows until the trash is purged.
+type TrashedIDs struct {
+	Nodes                 []string
+	Relationships         []string
+	IdentificationHelpers []string
+}
+
+// GetTrashedIDs returns the IDs of all nodes, relationships and
+// identification helpers in the trash.
+func (r *repository) GetTrashedIDs(ctx context.Context) (TrashedIDs, error) {
+	var ids TrashedIDs
+	db := r.db.WithContext(ctx)
+	if err := db.Unscoped().Model(&Node{}).Where("deleted_at IS NOT NULL").Pluck("id", &ids.Nodes).Error; err != nil {
+		return TrashedIDs{}, err
+	}
+	if err := db.Unscoped().Model(&Relationship{}).Where("deleted_at IS NOT NULL").Pluck("id", &ids.Relationships).Error; err != nil {
+		return TrashedIDs{}, err
+	}
+	if err := db.Unscoped().Model(&IdentificationHelper{}).Where("deleted_at IS NOT NULL").Pluck("id", &ids.IdentificationHelpers).Error; err != nil {
+		return TrashedIDs{}, err
+	}
+	return ids, nil
+}
+
+// RestoreNode takes everything deleted together with a node out of the
+// trash. Relationships are only restored once both of their nodes are.
+func (r *repository) RestoreNode(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var node Node
+		if err := tx.Unscoped().Where("id = ?", id).First(&node).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		restored := map[string]any{"deleted_at": nil, "deletion_id": nil}
+		var nodes []Node
+		if err := tx.Unscoped().Where("deletion_id = ?", id).Find(&nodes).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&Node{}).Where("deletion_id = ?", id).Updates(restored).Error; err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			node.DeletedAt, node.DeletionID = gorm.DeletedAt{}, nil
+			if err := recordRowVersion(tx, rowVersionNode, node.ID, node, now); err != nil {
+				return err
+			}
+		}
+
+		var helpers []IdentificationHelper
+		if err := tx.Unscoped().Where("deletion_id = ?", id).Find(&helpers).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&IdentificationHelper{}).Where("deletion_id = ?", id).Updates(restored).Error; err != nil {
+			return err
+		}
+		for _, helper := range helpers {
+			if err := indexIdentificationHelper(tx, helper); err != nil {
+				return err
+			}
+			helper.DeletedAt, helper.DeletionID = gorm.DeletedAt{}, nil
+			if err := recordRowVersion(tx, rowVersionHelper, helper.ID, helper, now); err != nil {
+				return err
+			}
+		}
+
+		// Relationships trashed with another node wait for its restore, all
+		// others can be restored once both nodes exist again
+		var relationships []Relationship
+		if err := tx.Unscoped().
+			Where("deleted_at IS NOT NULL").
+			Where("source_node_id IN (SELECT id FROM nodes WHERE deleted_at IS NULL)").
+			Where("target_node_id IN (SELECT id FROM nodes WHERE deleted_at IS NULL)").
+			Where("deletion_id NOT IN (SELECT id FROM nodes WHERE deleted_at IS NOT NULL)").
+			Find(&relationships).Error; err != nil {
+			return err
+		}
+		for _, rel := range relationships {
+			if err := tx.Unscoped().Model(&Relationship{}).Where("id = ?", rel.ID).Updates(restored).Error; err != nil {
+				return err
+			}
+			rel.DeletedAt, rel.DeletionID = gorm.DeletedAt{}, nil
+			if err := recordRowVersion(tx, rowVersionRelationship, rel.ID, rel, now); err != nil {
+				return err
+			}
+		}
+
+		// Restored nodes may in turn reference nodes still in the trash
+		if err := restoreTrashedReferences(tx, id, now); err != nil {
+			return err
+		}
+		if err := clearTrashedReferences(tx, now); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, AuditRestore, nodeAuditSubject(node), nil, nodeAuditFields(node))
+	})
+}
+
+// PurgeNode permanently deletes a node in the trash together with its
+// descendants, their identification helpers and relationships.
+func (r *repository) PurgeNode(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var node Node
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&node).Error; err != nil {
+			return err
+		}
+
+		var ids []string
+		if err := tx.Raw(fmt.Sprintf(subtreeQuery, "NOT"), id).Scan(&ids).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&Relationship{}, "source_node_id IN ? OR target_node_id IN ?", ids, ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&IdentifierIndex{}, "node_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&IdentificationHelper{}, "node_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&Node{}, "id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&TrashedReference{}, "deletion_id = ? OR node_id IN ?", id, ids).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, AuditPurge, nodeAuditSubject(node), nodeAuditFields(node), nil)
+	})
+}
+
 func (r *repository) CreateRelationship(ctx context.Context, rel Relationship) (Relationship, error) {
-	if err := r.db.WithContext(ctx).Create(&rel).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rel).Error; err != nil {
+			return err
+		}
+		if err := recordRowVersion(tx, rowVersionRelationship, rel.ID, rel, time.Now().UTC()); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditCreate, relationshipAuditSubject(rel), nil, relationshipAuditFields(rel))
+	})
+	if err != nil {
 		return Relationship{}, err
 	}
 	return rel, nil
@@ -149,19 +649,78 @@ func (r *repository) GetRelationshipByID(ctx context.Context, id string) (Relati
 }
 
 func (r *repository) UpdateRelationship(ctx context.Context, rel Relationship) error {
-	return r.db.WithContext(ctx).Save(&rel).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old Relationship
+		err := tx.Where("id = ?", rel.ID).First(&old).Error
+		found := !errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && found {
+			return err
+		}
+		if err := tx.Save(&rel).Error; err != nil {
+			return err
+		}
+		if err := recordRowVersion(tx, rowVersionRelationship, rel.ID, rel, time.Now().UTC()); err != nil {
+			return err
+		}
+		if !found {
+			return recordAudit(ctx, tx, AuditCreate, relationshipAuditSubject(rel), nil, relationshipAuditFields(rel))
+		}
+		return recordAudit(ctx, tx, AuditUpdate, relationshipAuditSubject(rel), relationshipAuditFields(old), relationshipAuditFields(rel))
+	})
 }
 
 func (r *repository) DeleteRelationship(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Delete(&Relationship{}, "id = ?", id).Error
+	return r.deleteRelationships(ctx, "id = ?", id)
 }
 
 func (r *repository) DeleteRelationshipsBySourceAndCategory(ctx context.Context, sourceNodeID, category string) error {
-	return r.db.WithContext(ctx).Delete(&Relationship{}, "source_node_id = ? AND category = ?", sourceNodeID, category).Error
+	return r.deleteRelationships(ctx, "source_node_id = ? AND category = ?", sourceNodeID, category)
+}
+
+// deleteRelationships deletes the relationships matching the condition and
+// records a delete for each of them.
+func (r *repository) deleteRelationships(ctx context.Context, query string, args ...any) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rels []Relationship
+		if err := tx.Where(query, args...).Find(&rels).Error; err != nil {
+			return err
+		}
+		if len(rels) == 0 {
+			return nil
+		}
+		if err := tx.Unscoped().Where(query, args...).Delete(&Relationship{}).Error; err != nil {
+			return err
+		}
+		ids := make([]string, len(rels))
+		for i, rel := range rels {
+			ids[i] = rel.ID
+		}
+		if err := endRowVersions(tx, rowVersionRelationship, ids, time.Now().UTC()); err != nil {
+			return err
+		}
+		for _, rel := range rels {
+			if err := recordAudit(ctx, tx, AuditDelete, relationshipAuditSubject(rel), relationshipAuditFields(rel), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (r *repository) CreateIdentificationHelper(ctx context.Context, helper IdentificationHelper) (IdentificationHelper, error) {
-	if err := r.db.WithContext(ctx).Create(&helper).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&helper).Error; err != nil {
+			return err
+		}
+		if err := indexIdentificationHelper(tx, helper); err != nil {
+			return err
+		}
+		if err := recordRowVersion(tx, rowVersionHelper, helper.ID, helper, time.Now().UTC()); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditCreate, helperAuditSubject(helper), nil, helperAuditFields(helper))
+	})
+	if err != nil {
 		return IdentificationHelper{}, err
 	}
 	return helper, nil
@@ -177,11 +736,62 @@ func (r *repository) GetIdentificationHelperByID(ctx context.Context, id string)
 }
 
 func (r *repository) UpdateIdentificationHelper(ctx context.Context, helper IdentificationHelper) error {
-	return r.db.WithContext(ctx).Save(&helper).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old IdentificationHelper
+		err := tx.Where("id = ?", helper.ID).First(&old).Error
+		found := !errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && found {
+			return err
+		}
+		if err := tx.Save(&helper).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&IdentifierIndex{}, "helper_id = ?", helper.ID).Error; err != nil {
+			return err
+		}
+		if err := indexIdentificationHelper(tx, helper); err != nil {
+			return err
+		}
+		if err := recordRowVersion(tx, rowVersionHelper, helper.ID, helper, time.Now().UTC()); err != nil {
+			return err
+		}
+		if !found {
+			return recordAudit(ctx, tx, AuditCreate, helperAuditSubject(helper), nil, helperAuditFields(helper))
+		}
+		return recordAudit(ctx, tx, AuditUpdate, helperAuditSubject(helper), helperAuditFields(old), helperAuditFields(helper))
+	})
 }
 
 func (r *repository) DeleteIdentificationHelper(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Delete(&IdentificationHelper{}, "id = ?", id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old IdentificationHelper
+		if err := tx.Where("id = ?", id).First(&old).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Delete(&IdentifierIndex{}, "helper_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&IdentificationHelper{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := endRowVersions(tx, rowVersionHelper, []string{id}, time.Now().UTC()); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditDelete, helperAuditSubject(old), helperAuditFields(old), nil)
+	})
+}
+
+// indexIdentificationHelper adds the identifiers of a helper to the lookup
+// index.
+func indexIdentificationHelper(tx *gorm.DB, helper IdentificationHelper) error {
+	entries := identifierIndexEntries(helper)
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.Create(&entries).Error
 }
 
 func (r *repository) GetIdentificationHelpersByProductVersion(ctx context.Context, productVersionID string) ([]IdentificationHelper, error) {
@@ -195,6 +805,17 @@ func (r *repository) GetIdentificationHelpersByProductVersion(ctx context.Contex
 	return helpers, nil
 }
 
+func (r *repository) GetIdentificationHelpersByCategory(ctx context.Context, category string) ([]IdentificationHelper, error) {
+	var helpers []IdentificationHelper
+	err := r.db.WithContext(ctx).
+		Where("category = ?", category).
+		Find(&helpers).Error
+	if err != nil {
+		return nil, err
+	}
+	return helpers, nil
+}
+
 func (r *repository) GetRelationshipsBySourceAndCategory(ctx context.Context, sourceNodeID, category string) ([]Relationship, error) {
 	var relationships []Relationship
 	err := r.db.WithContext(ctx).
@@ -207,3 +828,411 @@ func (r *repository) GetRelationshipsBySourceAndCategory(ctx context.Context, so
 	}
 	return relationships, nil
 }
+
+func (r *repository) GetRelationshipsByNodeIDs(ctx context.Context, nodeIDs []string) ([]Relationship, error) {
+	var relationships []Relationship
+	if len(nodeIDs) == 0 {
+		return relationships, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Where("source_node_id IN ? OR target_node_id IN ?", nodeIDs, nodeIDs).
+		Preload("SourceNode").
+		Preload("TargetNode").
+		Find(&relationships).Error
+	if err != nil {
+		return nil, err
+	}
+	return relationships, nil
+}
+
+func (r *repository) GetAllRelationships(ctx context.Context) ([]Relationship, error) {
+	var relationships []Relationship
+	if err := r.db.WithContext(ctx).Order("id").Find(&relationships).Error; err != nil {
+		return nil, err
+	}
+	return relationships, nil
+}
+
+func (r *repository) GetAllIdentificationHelpers(ctx context.Context) ([]IdentificationHelper, error) {
+	var helpers []IdentificationHelper
+	if err := r.db.WithContext(ctx).Order("id").Find(&helpers).Error; err != nil {
+		return nil, err
+	}
+	return helpers, nil
+}
+
+// LookupIdentifier returns the index entries of an identifier. Entries of
+// helpers that no longer exist are left out.
+func (r *repository) LookupIdentifier(ctx context.Context, identifierType, value string) ([]IdentifierIndex, error) {
+	var entries []IdentifierIndex
+	err := r.db.WithContext(ctx).
+		Joins("JOIN identification_helpers ON identification_helpers.id = identifier_indices.helper_id AND identification_helpers.deleted_at IS NULL").
+		Where("identifier_indices.type = ? AND identifier_indices.value = ?", identifierType, value).
+		Order("identifier_indices.id").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// RebuildIdentifierIndex replaces the lookup index with the identifiers of
+// all identification helpers.
+func (r *repository) RebuildIdentifierIndex(ctx context.Context) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&IdentifierIndex{}).Error; err != nil {
+			return err
+		}
+
+		var helpers []IdentificationHelper
+		if err := tx.Find(&helpers).Error; err != nil {
+			return err
+		}
+
+		var entries []IdentifierIndex
+		for _, helper := range helpers {
+			entries = append(entries, identifierIndexEntries(helper)...)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&entries, 500).Error
+	})
+}
+
+// searchTable is the SQLite FTS5 table over node names, descriptions and the
+// identifiers of their helpers. Its rowid is the rowid of the node and it is
+// kept up to date by triggers.
+const searchTable = "node_search"
+
+// searchRow selects the search columns of the nodes that are not in the
+// trash. Conditions are appended with AND.
+const searchRow = `SELECT n.rowid, n.id, n.name, n.description,
+	(SELECT group_concat(value, ' ') FROM identifier_indices WHERE node_id = n.id)
+	FROM nodes n WHERE n.deleted_at IS NULL`
+
+var searchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS node_search USING fts5(
+		node_id UNINDEXED, name, description, identifiers,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS node_search_insert AFTER INSERT ON nodes BEGIN
+		INSERT INTO node_search (rowid, node_id, name, description, identifiers) ` + searchRow + ` AND n.id = NEW.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS node_search_update AFTER UPDATE ON nodes BEGIN
+		DELETE FROM node_search WHERE rowid = OLD.rowid;
+		INSERT INTO node_search (rowid, node_id, name, description, identifiers) ` + searchRow + ` AND n.id = NEW.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS node_search_delete AFTER DELETE ON nodes BEGIN
+		DELETE FROM node_search WHERE rowid = OLD.rowid;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS node_search_identifier_insert AFTER INSERT ON identifier_indices BEGIN
+		DELETE FROM node_search WHERE rowid = (SELECT rowid FROM nodes WHERE id = NEW.node_id);
+		INSERT INTO node_search (rowid, node_id, name, description, identifiers) ` + searchRow + ` AND n.id = NEW.node_id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS node_search_identifier_delete AFTER DELETE ON identifier_indices BEGIN
+		DELETE FROM node_search WHERE rowid = (SELECT rowid FROM nodes WHERE id = OLD.node_id);
+		INSERT INTO node_search (rowid, node_id, name, description, identifiers) ` + searchRow + ` AND n.id = OLD.node_id;
+	END`,
+}
+
+// NodeSearchHit is a node matched by a full-text search. A higher score is a
+// better match, the snippet marks the matched terms with <mark>.
+type NodeSearchHit struct {
+	NodeID  string
+	Score   float64
+	Snippet string
+}
+
+var searchTriggers = []string{"insert", "update", "delete", "identifier_insert", "identifier_delete"}
+
+// RebuildSearchIndex creates the full-text search table with its triggers
+// and fills it with all nodes. SQLite builds without FTS5 keep no index and
+// SearchNodes falls back to pattern matching. Triggers left behind by a build
+// with FTS5 are dropped, as they would make every node write fail, and
+// triggers of older versions are replaced.
+func (r *repository) RebuildSearchIndex(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	for _, trigger := range searchTriggers {
+		if err := db.Exec("DROP TRIGGER IF EXISTS node_search_" + trigger).Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Exec(searchSchema[0]).Error; err != nil {
+		if !isMissingFTS5(err) {
+			return err
+		}
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range searchSchema[1:] {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM node_search").Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO node_search (rowid, node_id, name, description, identifiers) " + searchRow).Error
+	})
+}
+
+// SearchNodes returns the nodes best matching the search terms in query,
+// ranked by BM25 with names weighted highest. Every term has to match, the
+// last one as a prefix. Without FTS5 the nodes are matched by pattern.
+func (r *repository) SearchNodes(ctx context.Context, query string, limit int) ([]NodeSearchHit, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if !r.db.Migrator().HasTable(searchTable) {
+		return r.searchNodesByPattern(ctx, terms, limit)
+	}
+
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	phrases[len(phrases)-1] += "*"
+
+	var hits []NodeSearchHit
+	err := r.db.WithContext(ctx).Raw(`SELECT node_id,
+			-bm25(node_search, 0, 10, 1, 5) AS score,
+			snippet(node_search, -1, '<mark>', '</mark>', '…', 12) AS snippet
+		FROM node_search WHERE node_search MATCH ?
+		ORDER BY bm25(node_search, 0, 10, 1, 5) LIMIT ?`, strings.Join(phrases, " "), limit).
+		Scan(&hits).Error
+	if isMissingFTS5(err) {
+		return r.searchNodesByPattern(ctx, terms, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+func isMissingFTS5(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such module: fts5")
+}
+
+// searchNodesByPattern is the search without FTS5. Every term has to occur in
+// the name, description or an identifier of a node and the hits are scored
+// by where the terms occur.
+func (r *repository) searchNodesByPattern(ctx context.Context, terms []string, limit int) ([]NodeSearchHit, error) {
+	query := r.db.WithContext(ctx).Table("nodes").
+		Select(`nodes.id, nodes.name, nodes.description,
+			(SELECT group_concat(value, ' ') FROM identifier_indices WHERE node_id = nodes.id) AS identifiers`).
+		Where("nodes.deleted_at IS NULL")
+	for _, term := range terms {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		query = query.Where(`(nodes.name LIKE ? ESCAPE '\' OR nodes.description LIKE ? ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM identifier_indices WHERE node_id = nodes.id AND value LIKE ? ESCAPE '\'))`,
+			pattern, pattern, pattern)
+	}
+
+	var rows []struct {
+		ID          string
+		Name        string
+		Description string
+		Identifiers string
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	hits := make([]NodeSearchHit, len(rows))
+	for i, row := range rows {
+		hits[i].NodeID = row.ID
+		for _, term := range terms {
+			switch {
+			case strings.Contains(strings.ToLower(row.Name), strings.ToLower(term)):
+				hits[i].Score += 10
+			case strings.Contains(strings.ToLower(row.Identifiers), strings.ToLower(term)):
+				hits[i].Score += 5
+			default:
+				hits[i].Score++
+			}
+		}
+		if strings.EqualFold(row.Name, strings.Join(terms, " ")) {
+			hits[i].Score += 10
+		}
+		for _, field := range []string{row.Name, row.Identifiers, row.Description} {
+			if snippet, ok := markTerm(field, terms); ok {
+				hits[i].Snippet = snippet
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// markTerm marks the first of the terms found in text with <mark>.
+func markTerm(text string, terms []string) (string, bool) {
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if i := strings.Index(lower, strings.ToLower(term)); i >= 0 && len(lower) == len(text) {
+			return fmt.Sprintf("%s<mark>%s</mark>%s", text[:i], text[i:i+len(term)], text[i+len(term):]), true
+		}
+	}
+	return "", false
+}
+
+// AuditQuery selects audit entries. Zero values do not filter.
+type AuditQuery struct {
+	EntityID string
+	// NodeID selects the entries of a node and of its identification helpers
+	// and outgoing relationships.
+	NodeID string
+	Since  time.Time
+	Until  time.Time
+	Actor  string
+}
+
+// GetAuditEntries returns the audit entries matching the query, newest first.
+func (r *repository) GetAuditEntries(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	db := r.db.WithContext(ctx).Model(&AuditEntry{})
+	if query.EntityID != "" {
+		db = db.Where("entity_id = ?", query.EntityID)
+	}
+	if query.NodeID != "" {
+		db = db.Where("node_id = ?", query.NodeID)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since.UTC())
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("created_at <= ?", query.Until.UTC())
+	}
+	if query.Actor != "" {
+		db = db.Where("actor = ?", query.Actor)
+	}
+
+	var entries []AuditEntry
+	if err := db.Order("created_at DESC").Order("rowid DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetSnapshot returns the rows whose version was valid at the given time.
+func (r *repository) GetSnapshot(ctx context.Context, at time.Time) (CatalogSnapshot, error) {
+	var versions []RowVersion
+	err := r.db.WithContext(ctx).
+		Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", at.UTC(), at.UTC()).
+		Order("id").
+		Find(&versions).Error
+	if err != nil {
+		return CatalogSnapshot{}, err
+	}
+
+	var snapshot CatalogSnapshot
+	for _, version := range versions {
+		var err error
+		switch version.EntityType {
+		case rowVersionNode:
+			var node Node
+			if err = json.Unmarshal(version.Data, &node); err == nil {
+				snapshot.Nodes = append(snapshot.Nodes, node)
+			}
+		case rowVersionRelationship:
+			var rel Relationship
+			if err = json.Unmarshal(version.Data, &rel); err == nil {
+				snapshot.Relationships = append(snapshot.Relationships, rel)
+			}
+		case rowVersionHelper:
+			var helper IdentificationHelper
+			if err = json.Unmarshal(version.Data, &helper); err == nil {
+				snapshot.IdentificationHelpers = append(snapshot.IdentificationHelpers, helper)
+			}
+		default:
+			err = errors.New("unknown entity type " + version.EntityType)
+		}
+		if err != nil {
+			return CatalogSnapshot{}, fmt.Errorf("row version %d: %w", version.ID, err)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// EnsureRowVersions starts a version for every row without a current one,
+// e.g. rows written before versions were recorded. Nodes are valid since
+// their creation, all other rows since their node was created.
+func (r *repository) EnsureRowVersions(ctx context.Context) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		unversioned := func(entityType string) *gorm.DB {
+			return tx.Where("id NOT IN (?)", tx.Model(&RowVersion{}).
+				Select("entity_id").
+				Where("entity_type = ? AND valid_to IS NULL", entityType))
+		}
+
+		var nodes []Node
+		if err := unversioned(rowVersionNode).Find(&nodes).Error; err != nil {
+			return err
+		}
+		createdAt := make(map[string]time.Time, len(nodes))
+		for _, node := range nodes {
+			createdAt[node.ID] = node.CreatedAt
+			if err := recordRowVersion(tx, rowVersionNode, node.ID, node, node.CreatedAt.UTC()); err != nil {
+				return err
+			}
+		}
+
+		nodeCreatedAt := func(id string) (time.Time, error) {
+			if at, ok := createdAt[id]; ok {
+				return at, nil
+			}
+			var node Node
+			if err := tx.Select("created_at").Where("id = ?", id).Limit(1).Find(&node).Error; err != nil {
+				return time.Time{}, err
+			}
+			createdAt[id] = node.CreatedAt
+			return node.CreatedAt, nil
+		}
+
+		var relationships []Relationship
+		if err := unversioned(rowVersionRelationship).Find(&relationships).Error; err != nil {
+			return err
+		}
+		for _, rel := range relationships {
+			at, err := nodeCreatedAt(rel.SourceNodeID)
+			if err != nil {
+				return err
+			}
+			if err := recordRowVersion(tx, rowVersionRelationship, rel.ID, rel, at.UTC()); err != nil {
+				return err
+			}
+		}
+
+		var helpers []IdentificationHelper
+		if err := unversioned(rowVersionHelper).Find(&helpers).Error; err != nil {
+			return err
+		}
+		for _, helper := range helpers {
+			at, err := nodeCreatedAt(helper.NodeID)
+			if err != nil {
+				return err
+			}
+			if err := recordRowVersion(tx, rowVersionHelper, helper.ID, helper, at.UTC()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Transaction runs fn with a repository bound to a database transaction. The
+// transaction is rolled back if fn returns an error.
+func (r *repository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{db: tx})
+	})
+}
@@ -255,6 +255,53 @@ func TestRepository(t *testing.T) {
 		testutils.AssertEqual(t, true, helperIDs[helper2.ID], "Should include second helper")
 	})
 
+	t.Run("GetIdentificationHelpersByCategory", func(t *testing.T) {
+		vendor := testutils.CreateTestVendor(t, db, "Category Vendor", "A test vendor")
+		product := testutils.CreateTestProduct(t, db, "Category Product", "A test product", vendor.ID, testutils.Software)
+		version := testutils.CreateTestProductVersion(t, db, "1.0.0", "First version", product.ID, nil)
+
+		purl := testutils.CreateTestIdentificationHelper(t, db, version.ID, "purl", []byte(`{"purl": "pkg:generic/category-product@1.0.0"}`))
+		testutils.CreateTestIdentificationHelper(t, db, version.ID, "sku", []byte(`{"skus": ["SKU-1"]}`))
+
+		helpers, err := repo.GetIdentificationHelpersByCategory(ctx, "purl")
+		testutils.AssertNoError(t, err, "Should get identification helpers by category")
+
+		found := false
+		for _, helper := range helpers {
+			testutils.AssertEqual(t, IdentificationHelperCategory("purl"), helper.Category, "Should only return purl helpers")
+			if helper.ID == purl.ID {
+				found = true
+			}
+		}
+		testutils.AssertEqual(t, true, found, "Should include the created purl helper")
+	})
+
+	t.Run("GetAllRelationshipsAndIdentificationHelpers", func(t *testing.T) {
+		vendor := testutils.CreateTestVendor(t, db, "Dump Vendor", "A test vendor")
+		product := testutils.CreateTestProduct(t, db, "Dump Product", "A test product", vendor.ID, testutils.Software)
+		version := testutils.CreateTestProductVersion(t, db, "1.0.0", "First version", product.ID, nil)
+		component := testutils.CreateTestProductVersion(t, db, "2.0.0", "Second version", product.ID, nil)
+
+		rel := testutils.CreateTestRelationship(t, db, component.ID, version.ID, testutils.DefaultComponentOf)
+		helper := testutils.CreateTestIdentificationHelper(t, db, version.ID, "sku", []byte(`{"skus": ["DUMP-1"]}`))
+
+		relationships, err := repo.GetAllRelationships(ctx)
+		testutils.AssertNoError(t, err, "Should get all relationships")
+		found := false
+		for _, r := range relationships {
+			found = found || r.ID == rel.ID
+		}
+		testutils.AssertEqual(t, true, found, "Should include the created relationship")
+
+		helpers, err := repo.GetAllIdentificationHelpers(ctx)
+		testutils.AssertNoError(t, err, "Should get all identification helpers")
+		found = false
+		for _, h := range helpers {
+			found = found || h.ID == helper.ID
+		}
+		testutils.AssertEqual(t, true, found, "Should include the created identification helper")
+	})
+
 	t.Run("CreateIdentificationHelper", func(t *testing.T) {
 		// Create a test node
 		vendor := testutils.CreateTestVendor(t, db, "Test Vendor", "A test vendor")
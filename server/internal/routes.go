@@ -3,11 +3,41 @@ package internal
 import (
 	"github.com/go-fuego/fuego"
 	"github.com/go-fuego/fuego/option"
+	"github.com/go-fuego/fuego/param"
 )
 
+// listOptions documents the paging and sorting parameters of the list
+// endpoints. Without limit all rows are returned.
+var listOptions = option.Group(
+	option.QueryInt("limit", "Maximum number of rows, at most 1000. All rows if 0", param.Default(0)),
+	option.QueryInt("offset", "Number of rows to skip", param.Default(0)),
+	option.Query("sort", "Sort field: name, released_at or created_at. Insertion order, for versions the order of the version scheme, if empty"),
+	option.Query("order", "Sort order: asc or desc", param.Default("asc")),
+	option.Query("name", "Only rows whose name contains the value, ignoring case"),
+	option.ResponseHeader("X-Total-Count", "Number of rows matching the filters, independent of limit and offset"),
+)
+
+// productFilterOptions documents the filters of the product lists.
+var productFilterOptions = option.Group(
+	option.Query("type", "Only products of this type: software, hardware or firmware"),
+	option.Query("family_id", "Only products of this family or one of its subfamilies"),
+	option.Query("has_versions", "Only products with (true) or without (false) versions"),
+)
+
+// historyOptions documents the filters of the history endpoints.
+var historyOptions = option.Group(
+	option.Query("since", "Only changes at or after this time, as RFC 3339 timestamp or date"),
+	option.Query("until", "Only changes at or before this time, as RFC 3339 timestamp or date"),
+	option.Query("actor", "Only changes made by this actor"),
+)
+
+// asOfOption documents the as_of parameter of the read endpoints.
+var asOfOption = option.Query("as_of", "Read the catalog as it was at this time, as RFC 3339 timestamp or date (end of that day)")
+
 func RegisterRoutes(s *fuego.Server, svc *Service) {
 	h := NewHandler(svc)
 	api := fuego.Group(s, "/api/v1")
+	fuego.Use(api, AuditMiddleware(svc.trustProxyHeaders))
 
 	fuego.Get(api, "/health", func(c fuego.ContextNoBody) (string, error) {
 		return "OK", nil
@@ -19,13 +49,16 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Tags("vendors"),
 	)
 
-	fuego.Get(vendors, "", h.ListVendors,
+	fuego.Get(vendors, "", asOf(h, (*Handler).ListVendors),
 		option.Summary("List all vendors"),
-		option.Description("Returns a list of all vendors in the system"))
+		option.Description("Returns a list of all vendors in the system"),
+		listOptions,
+		asOfOption)
 
-	fuego.Get(vendors, "/{id}", h.GetVendor,
+	fuego.Get(vendors, "/{id}", asOf(h, (*Handler).GetVendor),
 		option.Summary("Get vendor by ID"),
-		option.Description("Returns details for a specific vendor"))
+		option.Description("Returns details for a specific vendor"),
+		asOfOption)
 
 	fuego.Put(vendors, "/{id}", h.UpdateVendor,
 		option.Summary("Update vendor"),
@@ -39,9 +72,17 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Summary("Create vendor"),
 		option.Description("Creates a new vendor"))
 
-	fuego.Get(vendors, "/{id}/products", h.ListVendorProducts,
+	fuego.Get(vendors, "/{id}/products", asOf(h, (*Handler).ListVendorProducts),
 		option.Summary("List vendor products"),
-		option.Description("Returns all products associated with a vendor"))
+		option.Description("Returns all products associated with a vendor"),
+		listOptions,
+		productFilterOptions,
+		asOfOption)
+
+	fuego.Get(vendors, "/{id}/history", h.History(AuditEntityVendor),
+		option.Summary("Get vendor history"),
+		option.Description("Returns all recorded changes of a vendor and its identification helpers, newest first"),
+		historyOptions)
 
 	products := fuego.Group(api, "/products",
 		option.Summary("Product operations"),
@@ -49,21 +90,32 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Tags("products"),
 	)
 
-	fuego.Get(products, "", h.ListProducts,
+	fuego.Get(products, "", asOf(h, (*Handler).ListProducts),
 		option.Summary("List all products"),
-		option.Description("Returns a list of all products in the system"))
+		option.Description("Returns a list of all products in the system"),
+		listOptions,
+		productFilterOptions,
+		option.Query("vendor_id", "Only products of this vendor"),
+		asOfOption)
 
-	fuego.Get(products, "/{id}", h.GetProduct,
+	fuego.Get(products, "/{id}", asOf(h, (*Handler).GetProduct),
 		option.Summary("Get product by ID"),
-		option.Description("Returns details for a specific product"))
+		option.Description("Returns details for a specific product"),
+		asOfOption)
 
-	fuego.Post(products, "/export", h.ExportProductTree,
+	fuego.Post(products, "/export", asOf(h, (*Handler).ExportProductTree),
 		option.Summary("Export products in CSAF format"),
-		option.Description("Exports the tree structure of a product in CSAF format"))
+		option.Description("Exports the tree structure of the selected products in CSAF format. Products can be selected by ID, by vendor, by family including all subfamilies, by single versions or all at once with 'all'. Set 'mode' to 'document' to receive a complete CSAF 2.0 document including publisher and tracking information. 'product_groups' adds a CSAF product group per family, vendor or product listing all of its exported versions, or with 'lifecycle' per support status."),
+		asOfOption)
+
+	fuego.Post(products, "/import", h.ImportProductTree,
+		option.Summary("Import products from a CSAF product tree"),
+		option.Description("Creates or matches vendors, product families, products, versions, identification helpers and relationships described by the product_tree of a CSAF document. With 'dry_run' nothing is persisted and the report lists what would be created, matched or conflicted."),
+		option.QueryBool("dry_run", "Only report the changes without persisting them"))
 
 	fuego.Put(products, "/{id}", h.UpdateProduct,
 		option.Summary("Update product"),
-		option.Description("Updates an existing product's information"))
+		option.Description("Updates an existing product's information. The lifecycle dates and support status are the defaults of the versions that do not set their own; empty values remove them."))
 
 	fuego.Delete(products, "/{id}", h.DeleteProduct,
 		option.Summary("Delete product"),
@@ -71,11 +123,23 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 
 	fuego.Post(products, "", h.CreateProduct,
 		option.Summary("Create product"),
-		option.Description("Creates a new product under a vendor"))
+		option.Description("Creates a new product under a vendor. The lifecycle dates and support status are the defaults of the versions that do not set their own."))
 
-	fuego.Get(products, "/{id}/versions", h.ListProductVersions,
+	fuego.Get(products, "/{id}/versions", asOf(h, (*Handler).ListProductVersions),
 		option.Summary("List product versions"),
-		option.Description("Returns all versions associated with a specific product"))
+		option.Description("Returns all versions associated with a specific product"),
+		listOptions,
+		asOfOption)
+
+	fuego.Get(products, "/{id}/version-ranges", asOf(h, (*Handler).ListProductVersionRanges),
+		option.Summary("List product version ranges"),
+		option.Description("Returns all version ranges of a product"),
+		asOfOption)
+
+	fuego.Get(products, "/{id}/history", h.History(AuditEntityProduct),
+		option.Summary("Get product history"),
+		option.Description("Returns all recorded changes of a product, its identification helpers and relationships, newest first"),
+		historyOptions)
 
 	productVersions := fuego.Group(api, "/product-versions",
 		option.Summary("Product version operations"),
@@ -83,13 +147,21 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Tags("product-versions"),
 	)
 
-	fuego.Get(productVersions, "/{id}", h.GetProductVersion,
+	fuego.Get(productVersions, "/lifecycle", asOf(h, (*Handler).ListUpcomingLifecycleMilestones),
+		option.Summary("List upcoming lifecycle milestones"),
+		option.Description("Returns the versions reaching the milestone today or within the given number of days, soonest first. Lifecycle dates not set on a version are taken from its product."),
+		option.Query("milestone", "Milestone: end_of_support, end_of_security_updates or end_of_life", param.Default(string(MilestoneEndOfSupport))),
+		option.QueryInt("days", "Number of days to look ahead", param.Default(DefaultLifecycleDays)),
+		asOfOption)
+
+	fuego.Get(productVersions, "/{id}", asOf(h, (*Handler).GetProductVersion),
 		option.Summary("Get product version by ID"),
-		option.Description("Returns details for a specific product version"))
+		option.Description("Returns details for a specific product version"),
+		asOfOption)
 
 	fuego.Put(productVersions, "/{id}", h.UpdateProductVersion,
 		option.Summary("Update product version"),
-		option.Description("Updates an existing product version's information"))
+		option.Description("Updates an existing product version's information. Lifecycle dates must follow the release in the order end of support, end of security updates, end of life; empty values remove them. The predecessor must be another version of the same product without another successor and must not succeed the version; an empty predecessor_id removes it. Moving a version to another product removes its predecessor."))
 
 	fuego.Delete(productVersions, "/{id}", h.DeleteProductVersion,
 		option.Summary("Delete product version"),
@@ -97,19 +169,79 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 
 	fuego.Post(productVersions, "", h.CreateProductVersion,
 		option.Summary("Create product version"),
-		option.Description("Creates a new version for a specific product"))
+		option.Description("Creates a new version for a specific product, optionally succeeding a version of the same product without another successor. Lifecycle dates must follow the release in the order end of support, end of security updates, end of life."))
+
+	fuego.Get(productVersions, "/{id}/lineage", asOf(h, (*Handler).GetProductVersionLineage),
+		option.Summary("Get product version lineage"),
+		option.Description("Returns the version with all versions it succeeds, oldest first, and all versions succeeding it, nearest first. A fix in this version is also contained in all of its descendants."),
+		asOfOption)
 
-	fuego.Get(productVersions, "/{id}/relationships", h.ListRelationshipsByProductVersion,
+	fuego.Get(productVersions, "/{id}/relationships", asOf(h, (*Handler).ListRelationshipsByProductVersion),
 		option.Summary("List version relationships"),
-		option.Description("Returns all relationships associated with a product version"))
+		option.Description("Returns all relationships associated with a product version"),
+		asOfOption)
 
 	fuego.Delete(productVersions, "/{id}/relationships/{category}", h.DeleteRelationshipsByVersionAndCategory,
 		option.Summary("Delete relationship for product version and category"),
 		option.Description("Removes a relationship for a product version and category"))
 
-	fuego.Get(productVersions, "/{id}/identification-helpers", h.ListIdentificationHelpersByProductVersion,
+	fuego.Get(productVersions, "/{id}/identification-helpers", asOf(h, (*Handler).ListIdentificationHelpersByProductVersion),
 		option.Summary("List identification helpers"),
-		option.Description("Returns all identification helpers for a product version"))
+		option.Description("Returns all identification helpers for a product version"),
+		asOfOption)
+
+	fuego.Get(productVersions, "/{id}/sbom", asOf(h, (*Handler).ExportProductVersionSBOM),
+		option.Summary("Export SBOM"),
+		option.Description("Renders the composition of a product version as CycloneDX 1.5 or SPDX 2.3 JSON SBOM. Components are collected recursively through default_component_of and optional_component_of relationships, purl, CPE and hashes are taken from the identification helpers."),
+		option.Query("format", "SBOM format, either 'cyclonedx' or 'spdx'", param.Default(SBOMFormatCycloneDX)),
+		asOfOption)
+
+	fuego.Post(productVersions, "/{id}/sbom", h.ImportProductVersionSBOM,
+		option.Summary("Import SBOM"),
+		option.Description("Imports a CycloneDX JSON, SPDX 2.3 JSON or SPDX 2.3 tag-value SBOM for a product version. Every component or package is created or matched by its purl, CPE or hashes and gets purl, CPE and hashes identification helpers. Components are attached as default_component_of the version, nested components, CONTAINS and DEPENDS_ON relationships as default_component_of and SPDX OPTIONAL_* relationships as optional_component_of the containing component. With 'dry_run' nothing is persisted and the report lists what would be created, matched or conflicted."),
+		option.RequestBody(fuego.RequestBody{
+			Type:         map[string]interface{}{},
+			ContentTypes: []string{"application/json", "text/spdx"},
+		}),
+		option.QueryBool("dry_run", "Only report the changes without persisting them"))
+
+	fuego.Get(productVersions, "/{id}/history", h.History(AuditEntityProductVersion),
+		option.Summary("Get product version history"),
+		option.Description("Returns all recorded changes of a product version, its identification helpers and relationships, newest first"),
+		historyOptions)
+
+	versionRanges := fuego.Group(api, "/version-ranges",
+		option.Summary("Version range operations"),
+		option.Description("Operations for managing version ranges of products"),
+		option.Tags("version-ranges"),
+	)
+
+	fuego.Get(versionRanges, "/{id}", asOf(h, (*Handler).GetVersionRange),
+		option.Summary("Get version range by ID"),
+		option.Description("Returns details for a specific version range"),
+		asOfOption)
+
+	fuego.Put(versionRanges, "/{id}", h.UpdateVersionRange,
+		option.Summary("Update version range"),
+		option.Description("Updates a version range. Setting vers or min/max replaces the whole range"))
+
+	fuego.Delete(versionRanges, "/{id}", h.DeleteVersionRange,
+		option.Summary("Delete version range"),
+		option.Description("Removes a version range"))
+
+	fuego.Post(versionRanges, "", h.CreateVersionRange,
+		option.Summary("Create version range"),
+		option.Description("Creates a version range for a product, given either as vers expression or as min/max bounds. Lower bounds are inclusive and upper bounds exclusive by default"))
+
+	fuego.Get(versionRanges, "/{id}/versions", asOf(h, (*Handler).ListVersionRangeVersions),
+		option.Summary("List covered versions"),
+		option.Description("Returns the concrete product versions currently covered by the version range"),
+		asOfOption)
+
+	fuego.Get(versionRanges, "/{id}/history", h.History(AuditEntityVersionRange),
+		option.Summary("Get version range history"),
+		option.Description("Returns all recorded changes of a version range, newest first"),
+		historyOptions)
 
 	relationships := fuego.Group(api, "/relationships",
 		option.Summary("Relationship operations"),
@@ -117,9 +249,10 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Tags("relationships"),
 	)
 
-	fuego.Get(relationships, "/{id}", h.GetRelationship,
+	fuego.Get(relationships, "/{id}", asOf(h, (*Handler).GetRelationship),
 		option.Summary("Get relationship by ID"),
-		option.Description("Returns details for a specific relationship"))
+		option.Description("Returns details for a specific relationship"),
+		asOfOption)
 
 	fuego.Post(relationships, "", h.CreateRelationship,
 		option.Summary("Create relationships"),
@@ -129,15 +262,21 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Summary("Update relationships"),
 		option.Description("Updates the relationship among product versions. Works similar to the create operation but will remove any relationships of node IDs that are not present in the body. Requires 'oldCategory' query parameter."))
 
+	fuego.Get(relationships, "/{id}/history", h.History(AuditEntityRelationship),
+		option.Summary("Get relationship history"),
+		option.Description("Returns all recorded changes of a relationship, newest first"),
+		historyOptions)
+
 	identificationHelpers := fuego.Group(api, "/identification-helper",
 		option.Summary("Identification helper operations"),
 		option.Description("Operations for managing identification helpers"),
 		option.Tags("identification-helpers"),
 	)
 
-	fuego.Get(identificationHelpers, "/{id}", h.GetIdentificationHelper,
+	fuego.Get(identificationHelpers, "/{id}", asOf(h, (*Handler).GetIdentificationHelper),
 		option.Summary("Get identification helper by ID"),
-		option.Description("Returns details for a specific identification helper"))
+		option.Description("Returns details for a specific identification helper"),
+		asOfOption)
 
 	fuego.Put(identificationHelpers, "/{id}", h.UpdateIdentificationHelper,
 		option.Summary("Update identification helper"),
@@ -151,19 +290,62 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 		option.Summary("Create identification helper"),
 		option.Description("Creates a new identification helper for a product version"))
 
+	fuego.Get(identificationHelpers, "/{id}/history", h.History(AuditEntityIdentificationHelper),
+		option.Summary("Get identification helper history"),
+		option.Description("Returns all recorded changes of an identification helper, newest first"),
+		historyOptions)
+
+	fuego.Get(api, "/lookup", asOf(h, (*Handler).Lookup),
+		option.Summary("Look up product versions by identifier"),
+		option.Description("Returns the product versions, with product and vendor, whose identification helpers contain the given purl, CPE, hash, SKU, serial number, model number or URI. Without 'type' the type is derived from the identifier. Package URLs are compared in canonical form, hashes without algorithm prefix and all other identifiers case-insensitive."),
+		option.Tags("identification-helpers"),
+		option.Query("identifier", "The identifier to look up, e.g. 'pkg:npm/foo@1.2.3' or a SHA-256 hash", param.Required()),
+		option.Query("type", "Identifier type: purl, cpe, hash, sku, serial, model, uri or sbom_url"),
+		asOfOption)
+
+	fuego.Get(api, "/lookup/cpe", asOf(h, (*Handler).MatchCPE),
+		option.Summary("Match product versions against a CPE name"),
+		option.Description("Returns the product versions whose CPE identification helper is matched by the given CPE 2.3 formatted string under the CPE name matching specification. Attributes may be ANY ('*'), NA ('-') or contain the wildcards '*' and '?'. The optional version bounds follow the versionStart*/versionEnd* fields of NVD applicability statements and only match stored CPEs with a concrete version."),
+		option.Tags("identification-helpers"),
+		option.Query("cpe", "The CPE 2.3 formatted string to match, e.g. 'cpe:2.3:a:openssl:openssl:*:*:*:*:*:*:*:*'", param.Required()),
+		option.Query("version_start_including", "Lowest matching version, inclusive"),
+		option.Query("version_start_excluding", "Lowest matching version, exclusive"),
+		option.Query("version_end_including", "Highest matching version, inclusive"),
+		option.Query("version_end_excluding", "Highest matching version, exclusive"),
+		asOfOption)
+
+	fuego.Get(api, "/lookup/purl", asOf(h, (*Handler).MatchPurl),
+		option.Summary("Match product versions against a package URL"),
+		option.Description("Returns the product versions whose purl identification helper is matched by the given package URL. Package URLs are compared with the type-specific rules of the purl specification, e.g. case-insensitive npm and pypi names, and independent of qualifier order. A purl without version matches every version of the package, qualifiers and subpath of the query must be present in the stored purl. With 'vers' only stored versions within the range match."),
+		option.Tags("identification-helpers"),
+		option.Query("purl", "The package URL to match, e.g. 'pkg:npm/lodash' or 'pkg:pypi/django@4.2.1'", param.Required()),
+		option.Query("vers", "Version range of matching versions, e.g. 'vers:npm/>=1.0.0|<2.0.0'"),
+		asOfOption)
+
+	fuego.Get(api, "/search", asOf(h, (*Handler).Search),
+		option.Summary("Search vendors, product families, products and versions"),
+		option.Description("Full-text search over the names and descriptions of all nodes and the identifiers of their identification helpers. Every term has to match, the last one also as a prefix. Hits are ranked with names weighted highest and carry a snippet with the matched terms in <mark> as well as their category and path from the vendor down to the hit."),
+		option.Tags("search"),
+		option.Query("q", "The search terms, e.g. 'acme router 2.0'", param.Required()),
+		option.QueryInt("limit", "Maximum number of hits, at most 100", param.Default(DefaultSearchLimit)),
+		asOfOption)
+
 	productFamilies := fuego.Group(api, "/product-families",
 		option.Summary("Product family operations"),
 		option.Description("Operations for managing product families"),
 		option.Tags("product-families"),
 	)
 
-	fuego.Get(productFamilies, "/{id}", h.GetProductFamily,
+	fuego.Get(productFamilies, "/{id}", asOf(h, (*Handler).GetProductFamily),
 		option.Summary("Get product family by ID"),
-		option.Description("Returns details for a specific product family"))
+		option.Description("Returns details for a specific product family"),
+		asOfOption)
 
-	fuego.Get(productFamilies, "", h.ListProductFamilies,
+	fuego.Get(productFamilies, "", asOf(h, (*Handler).ListProductFamilies),
 		option.Summary("List all product families"),
-		option.Description("Returns a list of all product families in the system"))
+		option.Description("Returns a list of all product families in the system"),
+		listOptions,
+		asOfOption)
 
 	fuego.Put(productFamilies, "/{id}", h.UpdateProductFamily,
 		option.Summary("Update product family"),
@@ -176,4 +358,85 @@ func RegisterRoutes(s *fuego.Server, svc *Service) {
 	fuego.Post(productFamilies, "", h.CreateProductFamily,
 		option.Summary("Create product family"),
 		option.Description("Creates a new product family"))
+
+	fuego.Get(productFamilies, "/{id}/history", h.History(AuditEntityProductFamily),
+		option.Summary("Get product family history"),
+		option.Description("Returns all recorded changes of a product family, newest first"),
+		historyOptions)
+
+	trash := fuego.Group(api, "/trash",
+		option.Summary("Trash operations"),
+		option.Description("Operations for restoring and purging deleted nodes"),
+		option.Tags("trash"),
+	)
+
+	fuego.Get(trash, "", h.ListTrash,
+		option.Summary("List trash"),
+		option.Description("Returns the deleted vendors, product families, products, versions and version ranges, most recently deleted first. Deleting a node moves its descendants, their identification helpers and relationships to the trash along with it; they are counted in 'node_count' and restored or purged together with it. Items are purged automatically after the configured retention period."))
+
+	fuego.Post(trash, "/{id}/restore", h.RestoreFromTrash,
+		option.Summary("Restore from trash"),
+		option.Description("Restores a deleted node with its descendants, identification helpers and relationships. Relationships to nodes that are still deleted are restored together with those. Fails with 409 if the parent of the node is deleted as well."))
+
+	fuego.Delete(trash, "/{id}", h.PurgeFromTrash,
+		option.Summary("Purge from trash"),
+		option.Description("Permanently deletes a deleted node with everything deleted along with it"))
+
+	csaf := fuego.Group(api, "/csaf",
+		option.Summary("CSAF operations"),
+		option.Description("Operations for working with CSAF documents"),
+		option.Tags("csaf"),
+	)
+
+	fuego.Post(csaf, "/validate", h.ValidateCSAFDocument,
+		option.Summary("Validate CSAF document"),
+		option.Description("Validates a CSAF 2.0 document against the bundled JSON schema and returns all violations. No network access is required."))
+
+	catalog := fuego.Group(api, "/catalog",
+		option.Summary("Catalog operations"),
+		option.Description("Bulk operations on the whole product catalog"),
+		option.Tags("catalog"),
+	)
+
+	fuego.Get(catalog, "/csv", asOf(h, (*Handler).ExportCatalogCSV),
+		option.Summary("Export catalog as CSV"),
+		option.Description("Exports all vendors, products with type and family path, versions with release date and their CPE, purl and SKU identification helpers as CSV. Every version is a row, multiple SKUs are separated by '|'. Cells starting with '=', '+', '-' or '@' are prefixed with \"'\", '/' and '\\\\' in family names are escaped with '\\\\'."),
+		asOfOption)
+
+	fuego.Post(catalog, "/csv", h.ImportCatalogCSV,
+		option.Summary("Import catalog from CSV"),
+		option.Description("Upserts vendors, products, versions and identification helpers from a CSV with the columns of the export. Rows are matched by vendor, product and version name. Invalid rows are reported with their line number and skipped while all other rows are imported. With 'dry_run' nothing is persisted."),
+		option.RequestBody(fuego.RequestBody{
+			Type:         "",
+			ContentTypes: []string{"text/csv"},
+		}),
+		option.QueryBool("dry_run", "Only report the changes without persisting them"))
+
+	fuego.Get(catalog, "/dump", asOf(h, (*Handler).DumpCatalog),
+		option.Summary("Dump database"),
+		option.Description("Exports all nodes, relationships and identification helpers in a versioned, portable JSON format that can be restored into another instance."),
+		asOfOption)
+
+	fuego.Post(catalog, "/dump", h.RestoreCatalogDump,
+		option.Summary("Restore database dump"),
+		option.Description("Restores a dump created by the dump endpoint. With mode 'empty' the database must not contain any data, with mode 'merge' the dump is merged into the existing data by ID. Rows whose ID already exists with different content are kept ('skip'), replaced ('overwrite') or imported with a new ID ('new_id'). With 'dry_run' nothing is persisted."),
+		option.RequestBody(fuego.RequestBody{
+			Type:         DumpDTO{},
+			ContentTypes: []string{"application/json"},
+		}),
+		option.Query("mode", "Restore into an empty database or merge into existing data", param.Default(RestoreModeEmpty)),
+		option.Query("on_conflict", "How rows with an existing ID and different content are handled: skip, overwrite or new_id", param.Default(OnConflictSkip)),
+		option.QueryBool("dry_run", "Only report the changes without persisting them"))
+
+	fuego.Post(catalog, "/cpe-dictionary", h.ImportCPEDictionary,
+		option.Summary("Import from NVD CPE dictionary"),
+		option.Description("Creates vendors, products and versions with CPE identification helpers from an NVD CPE dictionary (XML or CPE API JSON) or CPE Match feed (1.0 or CPE Match API JSON), optionally gzip compressed. Only CPE names of the given vendors and products are imported, names are taken from the CPE attributes. Files larger than 2 GiB are rejected with 413 and can be imported with the 'import-cpe' command of the server binary."),
+		option.RequestBody(fuego.RequestBody{
+			Type:         "",
+			ContentTypes: []string{"application/json", "application/xml", "application/gzip"},
+		}),
+		option.Query("vendor", "Comma separated CPE vendors to import", param.Required()),
+		option.Query("product", "Comma separated CPE products to import, all products of the vendors if empty"),
+		option.QueryBool("include_deprecated", "Also import deprecated CPE names"),
+		option.QueryBool("dry_run", "Only report the changes without persisting them"))
 }
@@ -0,0 +1,476 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-fuego/fuego"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatSPDX      = "spdx"
+)
+
+// sbomExportComponent is a product version that is part of an exported SBOM
+// together with the versions it is composed of.
+type sbomExportComponent struct {
+	Node       Node
+	Vendor     string
+	Product    string
+	Type       ProductType
+	Required   bool
+	Purl       string
+	CPE        string
+	Hashes     []sbomHash
+	Components []sbomExportEdge
+}
+
+type sbomExportEdge struct {
+	ID       string
+	Optional bool
+}
+
+// ExportSBOM renders the composition of a product version as CycloneDX 1.5
+// or SPDX 2.3 JSON. Components are collected recursively through the
+// default_component_of and optional_component_of relationships.
+func (s *Service) ExportSBOM(ctx context.Context, productVersionID, format string) (map[string]interface{}, error) {
+	if format == "" {
+		format = SBOMFormatCycloneDX
+	}
+	if format != SBOMFormatCycloneDX && format != SBOMFormatSPDX {
+		return nil, fuego.BadRequestError{
+			Title: "Invalid SBOM format",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "format",
+					Reason: "Format must be 'cyclonedx' or 'spdx'",
+				},
+			},
+		}
+	}
+
+	root, components, err := s.collectSBOMComponents(ctx, productVersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == SBOMFormatSPDX {
+		return spdxFromComponents(root, components), nil
+	}
+	return cycloneDXFromComponents(root, components), nil
+}
+
+// collectSBOMComponents walks the component relationships starting at the
+// given product version. It returns the version itself and all of its direct
+// and transitive components sorted by vendor, product and version.
+func (s *Service) collectSBOMComponents(ctx context.Context, productVersionID string) (*sbomExportComponent, []*sbomExportComponent, error) {
+	rootNode, err := s.repo.GetNodeByID(ctx, productVersionID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fuego.InternalServerError{
+			Title: "Failed to fetch product version",
+			Err:   err,
+		}
+	}
+	if err != nil || rootNode.Category != ProductVersion {
+		return nil, nil, fuego.NotFoundError{
+			Title: "Product version not found",
+		}
+	}
+
+	nodes := make(map[string]Node)
+	for _, category := range []NodeCategory{Vendor, ProductName} {
+		categoryNodes, err := s.repo.GetNodesByCategory(ctx, category)
+		if err != nil {
+			return nil, nil, fuego.InternalServerError{
+				Title: "Failed to fetch products",
+				Err:   err,
+			}
+		}
+		for _, node := range categoryNodes {
+			nodes[node.ID] = node
+		}
+	}
+
+	visited := make(map[string]*sbomExportComponent)
+	describe := func(node Node) (*sbomExportComponent, error) {
+		component := &sbomExportComponent{Node: node, Type: Software}
+		if node.ParentID != nil {
+			if product, ok := nodes[*node.ParentID]; ok {
+				component.Product = product.Name
+				if product.ProductType != "" {
+					component.Type = product.ProductType
+				}
+				if product.ParentID != nil {
+					component.Vendor = nodes[*product.ParentID].Name
+				}
+			}
+		}
+
+		helpers, err := s.repo.GetIdentificationHelpersByProductVersion(ctx, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		component.setIdentifiers(helpers)
+
+		visited[node.ID] = component
+		return component, nil
+	}
+
+	root, err := describe(rootNode)
+	if err != nil {
+		return nil, nil, fuego.InternalServerError{
+			Title: "Failed to fetch identification helpers",
+			Err:   err,
+		}
+	}
+	root.Required = true
+
+	var components []*sbomExportComponent
+	frontier := []string{rootNode.ID}
+	for len(frontier) > 0 {
+		relationships, err := s.repo.GetRelationshipsByNodeIDs(ctx, frontier)
+		if err != nil {
+			return nil, nil, fuego.InternalServerError{
+				Title: "Failed to fetch relationships",
+				Err:   err,
+			}
+		}
+
+		inFrontier := make(map[string]bool, len(frontier))
+		for _, id := range frontier {
+			inFrontier[id] = true
+		}
+
+		var next []string
+		for _, rel := range relationships {
+			if rel.Category != DefaultComponentOf && rel.Category != OptionalComponentOf {
+				continue
+			}
+			if !inFrontier[rel.TargetNodeID] || rel.SourceNode == nil || rel.SourceNode.Category != ProductVersion {
+				continue
+			}
+
+			optional := rel.Category == OptionalComponentOf
+			parent := visited[rel.TargetNodeID]
+			parent.Components = append(parent.Components, sbomExportEdge{ID: rel.SourceNodeID, Optional: optional})
+
+			component, ok := visited[rel.SourceNodeID]
+			if !ok {
+				if component, err = describe(*rel.SourceNode); err != nil {
+					return nil, nil, fuego.InternalServerError{
+						Title: "Failed to fetch identification helpers",
+						Err:   err,
+					}
+				}
+				components = append(components, component)
+				next = append(next, rel.SourceNodeID)
+			}
+			if !optional {
+				component.Required = true
+			}
+		}
+		frontier = next
+	}
+
+	for _, component := range append([]*sbomExportComponent{root}, components...) {
+		sort.Slice(component.Components, func(i, j int) bool {
+			return component.Components[i].ID < component.Components[j].ID
+		})
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		a, b := components[i], components[j]
+		if a.Vendor != b.Vendor {
+			return a.Vendor < b.Vendor
+		}
+		if a.Product != b.Product {
+			return a.Product < b.Product
+		}
+		if c := compareVersions(a.Node.Name, b.Node.Name); c != 0 {
+			return c < 0
+		}
+		return a.Node.ID < b.Node.ID
+	})
+
+	return root, components, nil
+}
+
+// setIdentifiers takes the purl, CPE and hashes from the identification
+// helpers of the version.
+func (c *sbomExportComponent) setIdentifiers(helpers []IdentificationHelper) {
+	for _, helper := range helpers {
+		switch helper.Category {
+		case "purl":
+			var value struct {
+				Purl string `json:"purl"`
+			}
+			if json.Unmarshal(helper.Metadata, &value) == nil && c.Purl == "" {
+				c.Purl = value.Purl
+			}
+		case "cpe":
+			var value struct {
+				CPE string `json:"cpe"`
+			}
+			if json.Unmarshal(helper.Metadata, &value) == nil && c.CPE == "" {
+				c.CPE = value.CPE
+			}
+		case "hashes":
+			var value struct {
+				FileHashes []struct {
+					Items []struct {
+						Algorithm string `json:"algorithm"`
+						Value     string `json:"value"`
+					} `json:"items"`
+				} `json:"file_hashes"`
+			}
+			if json.Unmarshal(helper.Metadata, &value) != nil {
+				continue
+			}
+			for _, file := range value.FileHashes {
+				for _, item := range file.Items {
+					c.Hashes = append(c.Hashes, sbomHash{Algorithm: normalizeHashAlgorithm(item.Algorithm), Value: item.Value})
+				}
+			}
+		}
+	}
+}
+
+// cycloneDXHashAlgorithms maps the hash algorithm names used in the database
+// to the names defined by CycloneDX.
+var cycloneDXHashAlgorithms = map[string]string{
+	"md5":         "MD5",
+	"sha1":        "SHA-1",
+	"sha256":      "SHA-256",
+	"sha384":      "SHA-384",
+	"sha512":      "SHA-512",
+	"sha3-256":    "SHA3-256",
+	"sha3-384":    "SHA3-384",
+	"sha3-512":    "SHA3-512",
+	"blake2b-256": "BLAKE2b-256",
+	"blake2b-384": "BLAKE2b-384",
+	"blake2b-512": "BLAKE2b-512",
+	"blake3":      "BLAKE3",
+}
+
+// spdxChecksumAlgorithms maps the hash algorithm names used in the database
+// to the checksum algorithms defined by SPDX.
+var spdxChecksumAlgorithms = map[string]string{
+	"md5":         "MD5",
+	"sha1":        "SHA1",
+	"sha256":      "SHA256",
+	"sha384":      "SHA384",
+	"sha512":      "SHA512",
+	"sha3-256":    "SHA3-256",
+	"sha3-384":    "SHA3-384",
+	"sha3-512":    "SHA3-512",
+	"blake2b-256": "BLAKE2b-256",
+	"blake2b-384": "BLAKE2b-384",
+	"blake2b-512": "BLAKE2b-512",
+	"blake3":      "BLAKE3",
+}
+
+func cycloneDXFromComponents(root *sbomExportComponent, components []*sbomExportComponent) map[string]interface{} {
+	toCycloneDX := func(c *sbomExportComponent) map[string]interface{} {
+		component := map[string]interface{}{
+			"bom-ref": c.Node.ID,
+			"type":    cycloneDXComponentType(c.Type),
+			"name":    c.Product,
+			"version": c.Node.Name,
+		}
+		if c.Vendor != "" {
+			component["supplier"] = map[string]interface{}{"name": c.Vendor}
+		}
+		if c.Node.Description != "" {
+			component["description"] = c.Node.Description
+		}
+		if c.Purl != "" {
+			component["purl"] = c.Purl
+		}
+		if c.CPE != "" {
+			component["cpe"] = c.CPE
+		}
+
+		var hashes []interface{}
+		for _, hash := range c.Hashes {
+			if algorithm, ok := cycloneDXHashAlgorithms[hash.Algorithm]; ok {
+				hashes = append(hashes, map[string]interface{}{"alg": algorithm, "content": hash.Value})
+			}
+		}
+		if len(hashes) > 0 {
+			component["hashes"] = hashes
+		}
+		return component
+	}
+
+	dependencies := []interface{}{}
+	bomComponents := []interface{}{}
+	for _, c := range append([]*sbomExportComponent{root}, components...) {
+		dependsOn := []interface{}{}
+		for _, edge := range c.Components {
+			dependsOn = append(dependsOn, edge.ID)
+		}
+		dependencies = append(dependencies, map[string]interface{}{
+			"ref":       c.Node.ID,
+			"dependsOn": dependsOn,
+		})
+
+		if c != root {
+			component := toCycloneDX(c)
+			component["scope"] = "required"
+			if !c.Required {
+				component["scope"] = "optional"
+			}
+			bomComponents = append(bomComponents, component)
+		}
+	}
+
+	return map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuid.New().String(),
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": map[string]interface{}{
+				"components": []interface{}{
+					map[string]interface{}{"type": "application", "name": "product-database"},
+				},
+			},
+			"component": toCycloneDX(root),
+		},
+		"components":   bomComponents,
+		"dependencies": dependencies,
+	}
+}
+
+func cycloneDXComponentType(productType ProductType) string {
+	switch productType {
+	case Hardware:
+		return "device"
+	case Firmware:
+		return "firmware"
+	default:
+		return "application"
+	}
+}
+
+func spdxFromComponents(root *sbomExportComponent, components []*sbomExportComponent) map[string]interface{} {
+	spdxID := func(id string) string {
+		return "SPDXRef-Package-" + id
+	}
+
+	packages := []interface{}{}
+	relationships := []interface{}{
+		map[string]interface{}{
+			"spdxElementId":      "SPDXRef-DOCUMENT",
+			"relatedSpdxElement": spdxID(root.Node.ID),
+			"relationshipType":   "DESCRIBES",
+		},
+	}
+
+	for _, c := range append([]*sbomExportComponent{root}, components...) {
+		pkg := map[string]interface{}{
+			"SPDXID":                spdxID(c.Node.ID),
+			"name":                  c.Product,
+			"versionInfo":           c.Node.Name,
+			"downloadLocation":      "NOASSERTION",
+			"filesAnalyzed":         false,
+			"licenseConcluded":      "NOASSERTION",
+			"licenseDeclared":       "NOASSERTION",
+			"copyrightText":         "NOASSERTION",
+			"primaryPackagePurpose": spdxPackagePurpose(c.Type),
+		}
+		if c.Vendor != "" {
+			pkg["supplier"] = "Organization: " + c.Vendor
+		}
+		if c.Node.Description != "" {
+			pkg["description"] = c.Node.Description
+		}
+
+		var externalRefs []interface{}
+		if c.Purl != "" {
+			externalRefs = append(externalRefs, map[string]interface{}{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  c.Purl,
+			})
+		}
+		if c.CPE != "" {
+			referenceType := "cpe22Type"
+			if strings.HasPrefix(c.CPE, "cpe:2.3:") {
+				referenceType = "cpe23Type"
+			}
+			externalRefs = append(externalRefs, map[string]interface{}{
+				"referenceCategory": "SECURITY",
+				"referenceType":     referenceType,
+				"referenceLocator":  c.CPE,
+			})
+		}
+		if len(externalRefs) > 0 {
+			pkg["externalRefs"] = externalRefs
+		}
+
+		var checksums []interface{}
+		for _, hash := range c.Hashes {
+			if algorithm, ok := spdxChecksumAlgorithms[hash.Algorithm]; ok {
+				checksums = append(checksums, map[string]interface{}{"algorithm": algorithm, "checksumValue": hash.Value})
+			}
+		}
+		if len(checksums) > 0 {
+			pkg["checksums"] = checksums
+		}
+
+		packages = append(packages, pkg)
+
+		for _, edge := range c.Components {
+			component := spdxID(edge.ID)
+			if edge.Optional {
+				relationships = append(relationships, map[string]interface{}{
+					"spdxElementId":      component,
+					"relatedSpdxElement": spdxID(c.Node.ID),
+					"relationshipType":   "OPTIONAL_COMPONENT_OF",
+				})
+				continue
+			}
+			relationships = append(relationships, map[string]interface{}{
+				"spdxElementId":      spdxID(c.Node.ID),
+				"relatedSpdxElement": component,
+				"relationshipType":   "CONTAINS",
+			})
+		}
+	}
+
+	name := strings.TrimSpace(root.Vendor + " " + root.Product + " " + root.Node.Name)
+
+	return map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              name,
+		"documentNamespace": "urn:uuid:" + uuid.New().String(),
+		"creationInfo": map[string]interface{}{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []interface{}{"Tool: product-database"},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+func spdxPackagePurpose(productType ProductType) string {
+	switch productType {
+	case Hardware:
+		return "DEVICE"
+	case Firmware:
+		return "FIRMWARE"
+	default:
+		return "APPLICATION"
+	}
+}
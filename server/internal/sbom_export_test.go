@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestExportSBOM(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	createVersion := func(t *testing.T, vendorName, productName, productType, version string) ProductVersionDTO {
+		vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: vendorName})
+		testutils.AssertNoError(t, err, "Should create vendor")
+		product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: productName, VendorID: vendor.ID, Type: productType})
+		testutils.AssertNoError(t, err, "Should create product")
+		created, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: version, ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+		return created
+	}
+
+	gateway := createVersion(t, "Acme", "Gateway", "firmware", "2.0.0")
+	openssl := createVersion(t, "OpenSSL Project", "openssl", "software", "3.0.13")
+	libcrypto := createVersion(t, "OpenSSL Foundation", "libcrypto", "software", "3.0.13")
+	zlib := createVersion(t, "zlib", "zlib", "software", "1.3")
+
+	relate := func(t *testing.T, category RelationshipCategory, source, target string) {
+		err := svc.CreateRelationship(ctx, CreateRelationshipDTO{
+			Category:      string(category),
+			SourceNodeIDs: []string{source},
+			TargetNodeIDs: []string{target},
+		})
+		testutils.AssertNoError(t, err, "Should create relationship")
+	}
+	relate(t, DefaultComponentOf, openssl.ID, gateway.ID)
+	relate(t, DefaultComponentOf, libcrypto.ID, openssl.ID)
+	relate(t, OptionalComponentOf, zlib.ID, gateway.ID)
+	// Cycles must not make the export recurse endlessly
+	relate(t, DefaultComponentOf, gateway.ID, libcrypto.ID)
+
+	for category, metadata := range map[string]string{
+		"purl":   `{"purl": "pkg:generic/openssl@3.0.13"}`,
+		"cpe":    `{"cpe": "cpe:2.3:a:openssl:openssl:3.0.13:*:*:*:*:*:*:*"}`,
+		"hashes": `{"file_hashes": [{"filename": "libssl.so", "items": [{"algorithm": "sha256", "value": "8852575300000000000000000000000000000000000000000000000000000000"}]}]}`,
+	} {
+		_, err := svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+			ProductVersionID: openssl.ID,
+			Category:         category,
+			Metadata:         metadata,
+		})
+		testutils.AssertNoError(t, err, "Should create identification helper")
+	}
+
+	t.Run("CycloneDX", func(t *testing.T) {
+		bom, err := svc.ExportSBOM(ctx, gateway.ID, "")
+		testutils.AssertNoError(t, err, "Should export CycloneDX")
+		testutils.AssertEqual(t, "CycloneDX", bom["bomFormat"], "BOM format")
+
+		root := bom["metadata"].(map[string]interface{})["component"].(map[string]interface{})
+		testutils.AssertEqual(t, "Gateway", root["name"], "Described component")
+		testutils.AssertEqual(t, "firmware", root["type"], "Described component type")
+
+		components := bom["components"].([]interface{})
+		testutils.AssertCount(t, 3, len(components), "Direct and nested components")
+
+		byName := make(map[string]map[string]interface{})
+		for _, c := range components {
+			component := c.(map[string]interface{})
+			byName[component["name"].(string)] = component
+		}
+		testutils.AssertEqual(t, "required", byName["libcrypto"]["scope"], "Nested component scope")
+		testutils.AssertEqual(t, "optional", byName["zlib"]["scope"], "Optional component scope")
+		testutils.AssertEqual(t, "pkg:generic/openssl@3.0.13", byName["openssl"]["purl"], "purl")
+		testutils.AssertEqual(t, "OpenSSL Project", byName["openssl"]["supplier"].(map[string]interface{})["name"], "Supplier")
+
+		hashes := byName["openssl"]["hashes"].([]interface{})
+		testutils.AssertEqual(t, "SHA-256", hashes[0].(map[string]interface{})["alg"], "Hash algorithm")
+
+		dependsOn := make(map[string][]interface{})
+		for _, d := range bom["dependencies"].([]interface{}) {
+			dependency := d.(map[string]interface{})
+			dependsOn[dependency["ref"].(string)] = dependency["dependsOn"].([]interface{})
+		}
+		testutils.AssertCount(t, 2, len(dependsOn[gateway.ID]), "Direct components of the version")
+		testutils.AssertCount(t, 1, len(dependsOn[openssl.ID]), "Nested component")
+		testutils.AssertEqual(t, libcrypto.ID, dependsOn[openssl.ID][0], "Nested component reference")
+	})
+
+	t.Run("SPDX", func(t *testing.T) {
+		doc, err := svc.ExportSBOM(ctx, gateway.ID, SBOMFormatSPDX)
+		testutils.AssertNoError(t, err, "Should export SPDX")
+		testutils.AssertEqual(t, "SPDX-2.3", doc["spdxVersion"], "SPDX version")
+		testutils.AssertCount(t, 4, len(doc["packages"].([]interface{})), "Version and its components")
+
+		relationships := make(map[string]bool)
+		for _, r := range doc["relationships"].([]interface{}) {
+			rel := r.(map[string]interface{})
+			relationships[rel["spdxElementId"].(string)+" "+rel["relationshipType"].(string)+" "+rel["relatedSpdxElement"].(string)] = true
+		}
+		ref := func(id string) string { return "SPDXRef-Package-" + id }
+		testutils.AssertEqual(t, true, relationships["SPDXRef-DOCUMENT DESCRIBES "+ref(gateway.ID)], "Document describes the version")
+		testutils.AssertEqual(t, true, relationships[ref(gateway.ID)+" CONTAINS "+ref(openssl.ID)], "Default component")
+		testutils.AssertEqual(t, true, relationships[ref(openssl.ID)+" CONTAINS "+ref(libcrypto.ID)], "Nested component")
+		testutils.AssertEqual(t, true, relationships[ref(zlib.ID)+" OPTIONAL_COMPONENT_OF "+ref(gateway.ID)], "Optional component")
+
+		for _, p := range doc["packages"].([]interface{}) {
+			pkg := p.(map[string]interface{})
+			if pkg["name"] != "openssl" {
+				continue
+			}
+			testutils.AssertCount(t, 2, len(pkg["externalRefs"].([]interface{})), "purl and CPE references")
+			checksum := pkg["checksums"].([]interface{})[0].(map[string]interface{})
+			testutils.AssertEqual(t, "SHA256", checksum["algorithm"], "Checksum algorithm")
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		bom, err := svc.ExportSBOM(ctx, gateway.ID, SBOMFormatCycloneDX)
+		testutils.AssertNoError(t, err, "Should export CycloneDX")
+
+		var decoded map[string]interface{}
+		raw, _ := json.Marshal(bom)
+		testutils.AssertNoError(t, json.Unmarshal(raw, &decoded), "Should decode BOM")
+
+		report, err := svc.ImportCycloneDXSBOM(ctx, gateway.ID, decoded, true)
+		testutils.AssertNoError(t, err, "Should import the exported BOM")
+		for _, item := range report.Items {
+			if item.Category == string(ProductVersion) && item.Action != ImportActionMatched {
+				t.Errorf("Expected exported component %v to be matched, got %s", item.Path, item.Action)
+			}
+		}
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		_, err := svc.ExportSBOM(ctx, gateway.ID, "swid")
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Errorf("Expected BadRequestError, got %v", err)
+		}
+
+		_, err = svc.ExportSBOM(ctx, "00000000-0000-0000-0000-000000000000", SBOMFormatCycloneDX)
+		var notFound fuego.NotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("Expected NotFoundError, got %v", err)
+		}
+	})
+
+	t.Run("ExportEndpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("GET", "/api/v1/product-versions/"+gateway.ID+"/sbom?format=spdx", nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var doc map[string]interface{}
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &doc), "Should decode SBOM")
+		testutils.AssertEqual(t, "SPDX-2.3", doc["spdxVersion"], "SPDX version")
+	})
+}
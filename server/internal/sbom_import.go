@@ -0,0 +1,479 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// sbomComponent is a single component of an SBOM independent of the SBOM
+// format it was read from.
+type sbomComponent struct {
+	Ref     string
+	Vendor  string
+	Name    string
+	Version string
+	Type    ProductType
+	Purl    string
+	CPE     string
+	Hashes  []sbomHash
+}
+
+type sbomHash struct {
+	Algorithm string
+	Value     string
+}
+
+type sbomHelper struct {
+	category string
+	metadata map[string]interface{}
+}
+
+// sbomDependency states that the component DependsOn is a component of Ref.
+// An empty Ref stands for the product version the SBOM is imported for.
+type sbomDependency struct {
+	Ref       string
+	DependsOn string
+	Category  RelationshipCategory
+}
+
+type cycloneDXBOM struct {
+	BOMFormat   string `json:"bomFormat"`
+	SpecVersion string `json:"specVersion"`
+	Metadata    struct {
+		Component *cycloneDXComponent `json:"component"`
+	} `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+	Dependencies []struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	} `json:"dependencies"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef       string                 `json:"bom-ref"`
+	Type         string                 `json:"type"`
+	Supplier     *cycloneDXOrganization `json:"supplier"`
+	Manufacturer *cycloneDXOrganization `json:"manufacturer"`
+	Publisher    string                 `json:"publisher"`
+	Group        string                 `json:"group"`
+	Name         string                 `json:"name"`
+	Version      string                 `json:"version"`
+	Purl         string                 `json:"purl"`
+	CPE          string                 `json:"cpe"`
+	Hashes       []cycloneDXHash        `json:"hashes"`
+	Components   []cycloneDXComponent   `json:"components"`
+}
+
+type cycloneDXOrganization struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// ImportSBOM detects the format of an uploaded SBOM and imports it for the
+// given product version. CycloneDX JSON as well as SPDX 2.3 JSON and
+// tag-value documents are supported.
+func (s *Service) ImportSBOM(ctx context.Context, productVersionID string, data []byte, dryRun bool) (ImportReportDTO, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		var document map[string]interface{}
+		if err := json.Unmarshal(trimmed, &document); err != nil {
+			return ImportReportDTO{}, fuego.BadRequestError{
+				Title: "Invalid SBOM",
+				Err:   err,
+			}
+		}
+		if _, ok := document["spdxVersion"]; ok {
+			return s.ImportSPDXSBOM(ctx, productVersionID, trimmed, dryRun)
+		}
+		return s.ImportCycloneDXSBOM(ctx, productVersionID, document, dryRun)
+	}
+
+	if bytes.Contains(trimmed, []byte("SPDXVersion:")) {
+		return s.ImportSPDXSBOM(ctx, productVersionID, trimmed, dryRun)
+	}
+
+	return ImportReportDTO{}, fuego.BadRequestError{
+		Title: "Invalid SBOM",
+		Errors: []fuego.ErrorItem{
+			{
+				Name:   "sbom",
+				Reason: "SBOM must be CycloneDX JSON, SPDX JSON or SPDX tag-value",
+			},
+		},
+	}
+}
+
+// ImportCycloneDXSBOM creates or matches a product version for every
+// component of a CycloneDX JSON SBOM and records them as default components
+// of the given product version. purl, CPE and hashes of the components are
+// attached as identification helpers.
+func (s *Service) ImportCycloneDXSBOM(ctx context.Context, productVersionID string, sbom map[string]interface{}, dryRun bool) (ImportReportDTO, error) {
+	var bom cycloneDXBOM
+	raw, err := json.Marshal(sbom)
+	if err == nil {
+		err = json.Unmarshal(raw, &bom)
+	}
+	if err == nil && bom.BOMFormat != "CycloneDX" {
+		err = errors.New("bomFormat must be 'CycloneDX'")
+	}
+	if err != nil {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid CycloneDX SBOM",
+			Err:   err,
+		}
+	}
+
+	components, dependencies := bom.flatten()
+	return s.importSBOM(ctx, productVersionID, components, dependencies, dryRun)
+}
+
+// flatten returns all components in pre-order together with the
+// dependencies between them. Top-level components and dependencies of the
+// described component belong to the imported product version, nested
+// components to the component containing them.
+func (bom cycloneDXBOM) flatten() ([]sbomComponent, []sbomDependency) {
+	var components []sbomComponent
+	var dependencies []sbomDependency
+
+	var walk func(list []cycloneDXComponent, parent string)
+	walk = func(list []cycloneDXComponent, parent string) {
+		for _, component := range list {
+			if component.BOMRef == "" {
+				component.BOMRef = fmt.Sprintf("component-%d", len(components))
+			}
+			components = append(components, component.toSBOMComponent())
+			dependencies = append(dependencies, sbomDependency{Ref: parent, DependsOn: component.BOMRef, Category: DefaultComponentOf})
+			walk(component.Components, component.BOMRef)
+		}
+	}
+	walk(bom.Components, "")
+
+	var root string
+	if bom.Metadata.Component != nil {
+		root = bom.Metadata.Component.BOMRef
+	}
+
+	for _, dependency := range bom.Dependencies {
+		ref := dependency.Ref
+		if ref == "" {
+			continue
+		}
+		if ref == root {
+			ref = ""
+		}
+		for _, dependsOn := range dependency.DependsOn {
+			dependencies = append(dependencies, sbomDependency{Ref: ref, DependsOn: dependsOn, Category: DefaultComponentOf})
+		}
+	}
+
+	return components, dependencies
+}
+
+func (c cycloneDXComponent) toSBOMComponent() sbomComponent {
+	component := sbomComponent{
+		Ref:     c.BOMRef,
+		Name:    c.Name,
+		Version: c.Version,
+		Purl:    c.Purl,
+		CPE:     c.CPE,
+	}
+
+	switch {
+	case c.Supplier != nil && c.Supplier.Name != "":
+		component.Vendor = c.Supplier.Name
+	case c.Manufacturer != nil && c.Manufacturer.Name != "":
+		component.Vendor = c.Manufacturer.Name
+	case c.Publisher != "":
+		component.Vendor = c.Publisher
+	case c.Group != "":
+		component.Vendor = c.Group
+	}
+
+	switch c.Type {
+	case "device":
+		component.Type = Hardware
+	case "firmware":
+		component.Type = Firmware
+	default:
+		component.Type = Software
+	}
+
+	for _, hash := range c.Hashes {
+		component.Hashes = append(component.Hashes, sbomHash{Algorithm: hash.Algorithm, Value: hash.Content})
+	}
+
+	return component
+}
+
+// importSBOM imports the components of an SBOM and the relationships
+// between them below the given product version.
+func (s *Service) importSBOM(ctx context.Context, productVersionID string, components []sbomComponent, dependencies []sbomDependency, dryRun bool) (ImportReportDTO, error) {
+	target, err := s.repo.GetNodeByID(ctx, productVersionID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return ImportReportDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch product version",
+			Err:   err,
+		}
+	}
+	if err != nil || target.Category != ProductVersion {
+		return ImportReportDTO{}, fuego.NotFoundError{
+			Title: "Product version not found",
+		}
+	}
+
+	return s.runImport(ctx, "Failed to import SBOM", dryRun, func(repo Repository, report *ImportReportDTO) error {
+		importer, err := newNodeImporter(ctx, repo, report)
+		if err != nil {
+			return err
+		}
+
+		versionIDs := map[string]string{"": target.ID}
+		names := make(map[string]string)
+		skipped := make(map[string]bool)
+		for _, component := range components {
+			version, ok, err := importer.importSBOMComponent(component)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				skipped[component.Ref] = true
+				continue
+			}
+			versionIDs[component.Ref] = version.ID
+			names[component.Ref] = component.Name + " " + component.Version
+		}
+
+		seen := make(map[sbomDependency]bool)
+		for _, dependency := range dependencies {
+			if seen[dependency] || skipped[dependency.Ref] || skipped[dependency.DependsOn] {
+				continue
+			}
+			seen[dependency] = true
+
+			sourceID, hasSource := versionIDs[dependency.DependsOn]
+			targetID, hasTarget := versionIDs[dependency.Ref]
+			if !hasSource || !hasTarget {
+				importer.add(ImportActionSkipped, "relationship", dependency.DependsOn, nil, "",
+					"Relationship references a component that is not part of the SBOM")
+				continue
+			}
+			if sourceID == targetID {
+				continue
+			}
+			if err := importer.importRelationship(dependency.Category, sourceID, targetID, names[dependency.DependsOn]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// importSBOMComponent matches a component against the existing versions by
+// its purl, CPE or hashes and falls back to vendor, product and version
+// names. The boolean is false if the component was skipped.
+func (im *nodeImporter) importSBOMComponent(component sbomComponent) (Node, bool, error) {
+	path := []string{component.Vendor, component.Name, component.Version}
+
+	if component.Version == "" {
+		im.add(ImportActionSkipped, string(ProductVersion), component.Name, path, "", "Component has no version")
+		return Node{}, false, nil
+	}
+
+	version, found, err := im.findVersionByIdentifiers(component)
+	if err != nil {
+		return Node{}, false, err
+	}
+
+	if found {
+		im.add(ImportActionMatched, string(ProductVersion), component.Version, path, version.ID,
+			"Matched by purl, CPE or hash")
+	} else {
+		if component.Vendor == "" {
+			if p, err := parsePurl(component.Purl); err == nil && p.Namespace != "" {
+				component.Vendor = p.Namespace
+				path[0] = p.Namespace
+			}
+		}
+		if component.Vendor == "" || component.Name == "" {
+			im.add(ImportActionSkipped, string(ProductVersion), component.Version, path, "",
+				"Vendor and product of the component could not be determined")
+			return Node{}, false, nil
+		}
+
+		vendor, err := im.importVendor(component.Vendor, path[:1])
+		if err != nil {
+			return Node{}, false, err
+		}
+		product, err := im.importProduct(component.Name, vendor, nil, component.Type, path[:2])
+		if err != nil {
+			return Node{}, false, err
+		}
+		if version, err = im.importVersion(component.Version, product, path); err != nil {
+			return Node{}, false, err
+		}
+	}
+
+	if err := im.importSBOMHelpers(version, component, path); err != nil {
+		return Node{}, false, err
+	}
+
+	return version, true, nil
+}
+
+func (im *nodeImporter) importSBOMHelpers(version Node, component sbomComponent, path []string) error {
+	var helpers []sbomHelper
+
+	if component.Purl != "" {
+		helpers = append(helpers, sbomHelper{"purl", map[string]interface{}{"purl": component.Purl}})
+	}
+	if component.CPE != "" {
+		helpers = append(helpers, sbomHelper{"cpe", map[string]interface{}{"cpe": component.CPE}})
+	}
+	if len(component.Hashes) > 0 {
+		items := make([]interface{}, len(component.Hashes))
+		for i, hash := range component.Hashes {
+			items[i] = map[string]interface{}{
+				"algorithm": normalizeHashAlgorithm(hash.Algorithm),
+				"value":     strings.ToLower(hash.Value),
+			}
+		}
+		helpers = append(helpers, sbomHelper{"hashes", map[string]interface{}{
+			"file_hashes": []interface{}{map[string]interface{}{
+				"filename": component.Name,
+				"items":    items,
+			}},
+		}})
+	}
+
+	for _, helper := range helpers {
+		metadata, err := json.Marshal(helper.metadata)
+		if err != nil {
+			return err
+		}
+		if err := im.importHelper(version, helper.category, metadata, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findVersionByIdentifiers looks up a product version that already carries
+// the purl, CPE or one of the hashes of the component.
+func (im *nodeImporter) findVersionByIdentifiers(component sbomComponent) (Node, bool, error) {
+	if im.identifiers == nil {
+		if err := im.loadIdentifiers(); err != nil {
+			return Node{}, false, err
+		}
+	}
+
+	keys := []string{}
+	if component.Purl != "" {
+		keys = append(keys, "purl:"+component.Purl)
+	}
+	if component.CPE != "" {
+		keys = append(keys, "cpe:"+strings.ToLower(component.CPE))
+	}
+	for _, hash := range component.Hashes {
+		keys = append(keys, "hash:"+normalizeHashAlgorithm(hash.Algorithm)+":"+strings.ToLower(hash.Value))
+	}
+
+	for _, key := range keys {
+		id, ok := im.identifiers[key]
+		if !ok {
+			continue
+		}
+		for _, version := range im.nodes[ProductVersion] {
+			if version.ID == id {
+				return version, true, nil
+			}
+		}
+	}
+
+	return Node{}, false, nil
+}
+
+// loadIdentifiers indexes the purl, CPE and hash identification helpers of
+// all product versions.
+func (im *nodeImporter) loadIdentifiers() error {
+	im.identifiers = make(map[string]string)
+
+	for _, category := range []string{"purl", "cpe", "hashes"} {
+		helpers, err := im.repo.GetIdentificationHelpersByCategory(im.ctx, category)
+		if err != nil {
+			return err
+		}
+
+		for _, helper := range helpers {
+			for _, key := range identifierKeys(category, helper.Metadata) {
+				if _, exists := im.identifiers[key]; !exists {
+					im.identifiers[key] = helper.NodeID
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func identifierKeys(category string, metadata []byte) []string {
+	var keys []string
+
+	switch category {
+	case "purl":
+		var value struct {
+			Purl string `json:"purl"`
+		}
+		if json.Unmarshal(metadata, &value) == nil && value.Purl != "" {
+			keys = append(keys, "purl:"+value.Purl)
+		}
+	case "cpe":
+		var value struct {
+			CPE string `json:"cpe"`
+		}
+		if json.Unmarshal(metadata, &value) == nil && value.CPE != "" {
+			keys = append(keys, "cpe:"+strings.ToLower(value.CPE))
+		}
+	case "hashes":
+		var value struct {
+			FileHashes []struct {
+				Items []struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"items"`
+			} `json:"file_hashes"`
+		}
+		if json.Unmarshal(metadata, &value) == nil {
+			for _, file := range value.FileHashes {
+				for _, item := range file.Items {
+					keys = append(keys, "hash:"+normalizeHashAlgorithm(item.Algorithm)+":"+strings.ToLower(item.Value))
+				}
+			}
+		}
+	}
+
+	return keys
+}
+
+// normalizeHashAlgorithm maps hash algorithm names such as "SHA-256" to the
+// names used by CSAF, e.g. "sha256".
+func normalizeHashAlgorithm(algorithm string) string {
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	if rest, ok := strings.CutPrefix(algorithm, "sha-"); ok {
+		return "sha" + rest
+	}
+	return algorithm
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+const testCycloneDXSBOM = `{
+	"bomFormat": "CycloneDX",
+	"specVersion": "1.5",
+	"metadata": {
+		"component": {"bom-ref": "gateway", "type": "firmware", "name": "Gateway", "version": "2.0.0"}
+	},
+	"components": [
+		{
+			"bom-ref": "openssl",
+			"type": "library",
+			"supplier": {"name": "OpenSSL Project"},
+			"name": "openssl",
+			"version": "3.0.13",
+			"cpe": "cpe:2.3:a:openssl:openssl:3.0.13:*:*:*:*:*:*:*",
+			"hashes": [{"alg": "SHA-256", "content": "88525753F1777573A7BD3BD6E4A8A6A8A7A8C7A6E4A8A6A8A7A8C7A6E4A8A6A8"}],
+			"components": [
+				{"bom-ref": "libcrypto", "type": "library", "supplier": {"name": "OpenSSL Project"}, "name": "libcrypto", "version": "3.0.13"}
+			]
+		},
+		{
+			"bom-ref": "log4j",
+			"type": "library",
+			"name": "log4j-core",
+			"version": "2.17.1",
+			"purl": "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1"
+		},
+		{"bom-ref": "unversioned", "type": "library", "name": "unversioned"}
+	],
+	"dependencies": [
+		{"ref": "gateway", "dependsOn": ["openssl", "log4j"]},
+		{"ref": "log4j", "dependsOn": ["openssl"]}
+	]
+}`
+
+func TestImportCycloneDXSBOM(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Gateway", VendorID: vendor.ID, Type: "firmware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	gateway, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+
+	var sbom map[string]interface{}
+	testutils.AssertNoError(t, json.Unmarshal([]byte(testCycloneDXSBOM), &sbom), "Should decode SBOM")
+
+	repo := NewRepository(db)
+	componentsOf := func(t *testing.T, versionID string) map[string]bool {
+		relationships, err := repo.GetRelationshipsByNodeIDs(ctx, []string{versionID})
+		testutils.AssertNoError(t, err, "Should list relationships")
+
+		components := make(map[string]bool)
+		for _, rel := range relationships {
+			if rel.Category == DefaultComponentOf && rel.TargetNodeID == versionID {
+				components[rel.SourceNodeID] = true
+			}
+		}
+		return components
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := svc.ImportCycloneDXSBOM(ctx, gateway.ID, sbom, true)
+		testutils.AssertNoError(t, err, "Should run dry run")
+		testutils.AssertEqual(t, true, report.DryRun, "Dry run flag")
+		if report.Summary.Created == 0 {
+			t.Error("Dry run should report created items")
+		}
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 1, len(vendors), "Dry run must not persist vendors")
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		report, err := svc.ImportCycloneDXSBOM(ctx, gateway.ID, sbom, false)
+		testutils.AssertNoError(t, err, "Should import SBOM")
+		testutils.AssertEqual(t, 1, report.Summary.Skipped, "Component without version is skipped")
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 3, len(vendors), "Supplier and purl namespace become vendors")
+
+		versionIDs := make(map[string]string)
+		for _, item := range report.Items {
+			if item.Category == string(ProductVersion) && item.Action == ImportActionCreated {
+				versionIDs[item.Path[1]] = item.ID
+			}
+		}
+		testutils.AssertCount(t, 3, len(versionIDs), "Created component versions")
+
+		components := componentsOf(t, gateway.ID)
+		testutils.AssertEqual(t, true, components[versionIDs["openssl"]], "Top-level component belongs to the version")
+		testutils.AssertEqual(t, true, components[versionIDs["log4j-core"]], "Top-level component belongs to the version")
+		testutils.AssertEqual(t, false, components[versionIDs["libcrypto"]], "Nested component belongs to its parent")
+
+		testutils.AssertEqual(t, true, componentsOf(t, versionIDs["openssl"])[versionIDs["libcrypto"]], "Nested component belongs to its parent")
+		testutils.AssertEqual(t, true, componentsOf(t, versionIDs["log4j-core"])[versionIDs["openssl"]], "Dependency is a component of the dependent")
+
+		opensslID := versionIDs["openssl"]
+		helpers, err := svc.GetIdentificationHelpersByProductVersion(ctx, opensslID)
+		testutils.AssertNoError(t, err, "Should list helpers")
+		categories := make(map[string]string)
+		for _, helper := range helpers {
+			categories[helper.Category] = helper.Metadata
+		}
+		if _, ok := categories["cpe"]; !ok {
+			t.Error("Expected a cpe helper")
+		}
+		var hashes struct {
+			FileHashes []struct {
+				Items []struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"items"`
+			} `json:"file_hashes"`
+		}
+		testutils.AssertNoError(t, json.Unmarshal([]byte(categories["hashes"]), &hashes), "Should decode hashes helper")
+		testutils.AssertEqual(t, "sha256", hashes.FileHashes[0].Items[0].Algorithm, "Hash algorithm uses CSAF names")
+	})
+
+	t.Run("MatchByIdentifiers", func(t *testing.T) {
+		// The components are matched by their identifiers, even though the
+		// names differ from the existing products
+		renamed := `{"bomFormat": "CycloneDX", "components": [
+			{"bom-ref": "a", "name": "OpenSSL", "version": "3.0.13-r1", "hashes": [{"alg": "SHA-256", "content": "88525753f1777573a7bd3bd6e4a8a6a8a7a8c7a6e4a8a6a8a7a8c7a6e4a8a6a8"}]},
+			{"bom-ref": "b", "name": "log4j", "version": "2.17.1", "purl": "pkg:maven/org.apache.logging.log4j/log4j-core@2.17.1"}
+		]}`
+		var renamedSBOM map[string]interface{}
+		testutils.AssertNoError(t, json.Unmarshal([]byte(renamed), &renamedSBOM), "Should decode SBOM")
+
+		report, err := svc.ImportCycloneDXSBOM(ctx, gateway.ID, renamedSBOM, false)
+		testutils.AssertNoError(t, err, "Should import SBOM")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Nothing new is created")
+		testutils.AssertEqual(t, 0, report.Summary.Skipped, "Nothing is skipped")
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		_, err := svc.ImportCycloneDXSBOM(ctx, gateway.ID, map[string]interface{}{"spdxVersion": "SPDX-2.3"}, false)
+		var badRequest fuego.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Errorf("Expected BadRequestError, got %v", err)
+		}
+
+		_, err = svc.ImportCycloneDXSBOM(ctx, product.ID, sbom, false)
+		var notFound fuego.NotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("Expected NotFoundError for a product ID, got %v", err)
+		}
+	})
+
+	t.Run("ImportEndpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("POST", "/api/v1/product-versions/"+gateway.ID+"/sbom?dry_run=true", bytes.NewBufferString(testCycloneDXSBOM))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var report ImportReportDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &report), "Should decode report")
+		testutils.AssertEqual(t, true, report.DryRun, "Dry run flag")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Everything was imported before")
+	})
+}
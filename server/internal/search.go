@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// Limits of the number of search hits.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// Search returns the vendors, product families, products and versions best
+// matching the search terms in query. Names, descriptions and the
+// identifiers of identification helpers are searched, every hit carries its
+// path from the vendor down to the node itself.
+func (s *Service) Search(ctx context.Context, query string, limit int) (SearchResultDTO, error) {
+	if strings.TrimSpace(query) == "" {
+		return SearchResultDTO{}, fuego.BadRequestError{
+			Title: "Invalid search",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "q",
+					Reason: "Search query is required",
+				},
+			},
+		}
+	}
+	if limit < 1 || limit > MaxSearchLimit {
+		return SearchResultDTO{}, fuego.BadRequestError{
+			Title: "Invalid search",
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "limit",
+					Reason: "Limit must be between 1 and 100",
+				},
+			},
+		}
+	}
+
+	hits, err := s.repo.SearchNodes(ctx, query, limit)
+	if err != nil {
+		return SearchResultDTO{}, fuego.InternalServerError{
+			Title: "Failed to search",
+			Err:   err,
+		}
+	}
+
+	nodes := make(map[string]Node)
+	getNode := func(id string) (Node, error) {
+		if node, ok := nodes[id]; ok {
+			return node, nil
+		}
+		node, err := s.repo.GetNodeByID(ctx, id)
+		if err != nil {
+			return Node{}, err
+		}
+		nodes[id] = node
+		return node, nil
+	}
+
+	result := SearchResultDTO{
+		Query: query,
+		Hits:  []SearchHitDTO{},
+	}
+	for _, hit := range hits {
+		node, err := getNode(hit.NodeID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return SearchResultDTO{}, fuego.InternalServerError{
+				Title: "Failed to fetch search hit",
+				Err:   err,
+			}
+		}
+
+		path, err := searchPath(node, getNode, 0)
+		if err != nil {
+			return SearchResultDTO{}, fuego.InternalServerError{
+				Title: "Failed to fetch path of search hit",
+				Err:   err,
+			}
+		}
+
+		names := make([]string, len(path))
+		for i, item := range path {
+			names[i] = item.Name
+		}
+
+		result.Hits = append(result.Hits, SearchHitDTO{
+			ID:          node.ID,
+			Category:    node.Category,
+			Name:        node.Name,
+			Description: node.Description,
+			Snippet:     hit.Snippet,
+			Score:       hit.Score,
+			Path:        path,
+			PathLabel:   strings.Join(names, " › "),
+		})
+	}
+
+	return result, nil
+}
+
+// searchPath returns the path of a node: vendor, product families, product
+// and version, as far as they apply to the node's category. Missing parents
+// are left out and depth guards against cyclic family hierarchies.
+func searchPath(node Node, getNode func(string) (Node, error), depth int) ([]SearchPathItemDTO, error) {
+	self := SearchPathItemDTO{ID: node.ID, Category: node.Category, Name: node.Name}
+	if depth > 32 {
+		return []SearchPathItemDTO{self}, nil
+	}
+
+	var prefix []SearchPathItemDTO
+	appendPath := func(id *string) error {
+		if id == nil {
+			return nil
+		}
+		parent, err := getNode(*id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := searchPath(parent, getNode, depth+1)
+		if err != nil {
+			return err
+		}
+		prefix = append(prefix, path...)
+		return nil
+	}
+
+	var err error
+	switch node.Category {
+	case ProductName:
+		if err = appendPath(node.ParentID); err == nil {
+			err = appendPath(node.ProductFamilyID)
+		}
+	case ProductFamily, ProductVersion, ProductVersionRange:
+		err = appendPath(node.ParentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(prefix, self), nil
+}
+
+// RebuildSearchIndex recreates the full-text search index from all nodes.
+func (s *Service) RebuildSearchIndex(ctx context.Context) error {
+	return s.repo.RebuildSearchIndex(ctx)
+}
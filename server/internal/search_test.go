@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestSearch(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+	testutils.AssertNoError(t, svc.RebuildSearchIndex(ctx), "Should build search index")
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	network, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Network"})
+	testutils.AssertNoError(t, err, "Should create family")
+	routers, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Routers", ParentID: &network.ID})
+	testutils.AssertNoError(t, err, "Should create nested family")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{
+		Name:        "Edge Router",
+		Description: "Compact device for branch offices",
+		VendorID:    vendor.ID,
+		Type:        "hardware",
+		FamilyID:    &routers.ID,
+	})
+	testutils.AssertNoError(t, err, "Should create product")
+	version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+		ProductVersionID: version.ID,
+		Category:         "sku",
+		Metadata:         `{"skus": ["ER-2000"]}`,
+	})
+	testutils.AssertNoError(t, err, "Should create identification helper")
+
+	search := func(t *testing.T, query string) []SearchHitDTO {
+		result, err := svc.Search(ctx, query, DefaultSearchLimit)
+		testutils.AssertNoError(t, err, "Search should succeed")
+		return result.Hits
+	}
+	find := func(hits []SearchHitDTO, id string) *SearchHitDTO {
+		for i := range hits {
+			if hits[i].ID == id {
+				return &hits[i]
+			}
+		}
+		return nil
+	}
+
+	t.Run("Name", func(t *testing.T) {
+		hits := search(t, "edge router")
+		testutils.AssertCount(t, 1, len(hits), "Hits")
+		testutils.AssertEqual(t, product.ID, hits[0].ID, "Hit")
+		testutils.AssertEqual(t, ProductName, hits[0].Category, "Category")
+		testutils.AssertEqual(t, "Acme › Network › Routers › Edge Router", hits[0].PathLabel, "Path")
+		testutils.AssertEqual(t, true, strings.Contains(hits[0].Snippet, "<mark>"), "Snippet marks the match")
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		hits := search(t, "rout")
+		if find(hits, product.ID) == nil || find(hits, routers.ID) == nil {
+			t.Errorf("Expected product and family, got %+v", hits)
+		}
+		family := find(hits, routers.ID)
+		if family != nil {
+			testutils.AssertEqual(t, "Network › Routers", family.PathLabel, "Family path")
+		}
+	})
+
+	t.Run("Description", func(t *testing.T) {
+		hits := search(t, "branch")
+		testutils.AssertCount(t, 1, len(hits), "Hits")
+		testutils.AssertEqual(t, product.ID, hits[0].ID, "Hit")
+	})
+
+	t.Run("Identifier", func(t *testing.T) {
+		hits := search(t, "ER-2000")
+		testutils.AssertCount(t, 1, len(hits), "Hits")
+		testutils.AssertEqual(t, version.ID, hits[0].ID, "Hit")
+		testutils.AssertEqual(t, ProductVersion, hits[0].Category, "Category")
+		testutils.AssertEqual(t, 5, len(hits[0].Path), "Path length")
+		testutils.AssertEqual(t, vendor.ID, hits[0].Path[0].ID, "Path starts at the vendor")
+	})
+
+	t.Run("NameRanksFirst", func(t *testing.T) {
+		_, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Globex", Description: "Makes an acme of routers"})
+		testutils.AssertNoError(t, err, "Should create vendor")
+
+		hits := search(t, "acme")
+		testutils.AssertCount(t, 2, len(hits), "Hits")
+		testutils.AssertEqual(t, vendor.ID, hits[0].ID, "Name match first")
+		if hits[0].Score <= hits[1].Score {
+			t.Errorf("Expected descending scores, got %v and %v", hits[0].Score, hits[1].Score)
+		}
+	})
+
+	t.Run("FollowsChanges", func(t *testing.T) {
+		name := "Initech"
+		_, err := svc.UpdateVendor(ctx, vendor.ID, UpdateVendorDTO{Name: &name})
+		testutils.AssertNoError(t, err, "Should update vendor")
+		testutils.AssertCount(t, 1, len(search(t, "initech")), "Renamed vendor")
+
+		testutils.AssertNoError(t, svc.DeleteProduct(ctx, product.ID), "Should delete product")
+		testutils.AssertCount(t, 0, len(search(t, "branch")), "Deleted product")
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		for _, input := range []struct {
+			query string
+			limit int
+		}{{"", DefaultSearchLimit}, {"acme", 0}, {"acme", MaxSearchLimit + 1}} {
+			_, err := svc.Search(ctx, input.query, input.limit)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %v, got %v", input, err)
+			}
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("GET", "/api/v1/search?q=network&limit=1", nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var result SearchResultDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Should decode result")
+		testutils.AssertCount(t, 1, len(result.Hits), "Hits")
+		testutils.AssertEqual(t, network.ID, result.Hits[0].ID, "Hit")
+		testutils.AssertEqual(t, ProductFamily, result.Hits[0].Category, "Category")
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-fuego/fuego"
@@ -14,11 +16,29 @@ import (
 )
 
 type Service struct {
-	repo Repository
+	repo              Repository
+	csaf              CSAFConfig
+	trashRetention    time.Duration
+	snapshots         *snapshotCache
+	trustProxyHeaders bool
+	// asOf is the time a snapshot service reads the catalog at
+	asOf time.Time
 }
 
-func NewService(repository Repository) *Service {
-	return &Service{repo: repository}
+type ServiceOption func(*Service)
+
+func WithCSAFConfig(config CSAFConfig) ServiceOption {
+	return func(s *Service) {
+		s.csaf = config
+	}
+}
+
+func NewService(repository Repository, opts ...ServiceOption) *Service {
+	s := &Service{repo: repository, csaf: DefaultCSAFConfig(), trashRetention: DefaultTrashRetention, snapshots: newSnapshotCache()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Vendor
@@ -49,22 +69,8 @@ func (s *Service) CreateVendor(ctx context.Context, vendor CreateVendorDTO) (Ven
 }
 
 func (s *Service) ListVendors(ctx context.Context) ([]VendorDTO, error) {
-	nodes, err := s.repo.GetNodesByCategory(ctx, Vendor)
-	if err != nil {
-		return nil, err
-	}
-
-	vendors := make([]VendorDTO, len(nodes))
-	for i, node := range nodes {
-		vendors[i] = VendorDTO{
-			ID:           node.ID,
-			Name:         node.Name,
-			Description:  node.Description,
-			ProductCount: len(node.Children),
-		}
-	}
-
-	return vendors, nil
+	vendors, _, err := s.QueryVendors(ctx, ListQuery{})
+	return vendors, err
 }
 
 func (s *Service) GetVendorByID(ctx context.Context, id string) (VendorDTO, error) {
@@ -91,7 +97,7 @@ func (s *Service) GetVendorByID(ctx context.Context, id string) (VendorDTO, erro
 
 	products := make([]ProductDTO, len(vendor.Children))
 	for i, product := range vendor.Children {
-		products[i] = NodeToProductDTO(product)
+		products[i] = nodeToProductDTO(product, s.now())
 	}
 
 	return VendorDTO{
@@ -180,7 +186,31 @@ func (s *Service) DeleteVendor(ctx context.Context, id string) error {
 
 // Products
 
-func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string) (map[string]interface{}, error) {
+func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string, opts ...ExportOption) (map[string]interface{}, error) {
+	tree, err := s.buildCSAFProductTree(ctx, productIDs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCSAFExport(tree); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+func (s *Service) buildCSAFProductTree(ctx context.Context, productIDs []string, opts ...ExportOption) (map[string]interface{}, error) {
+	options := ExportOptions{ProductIDPrefix: s.csaf.ProductIDPrefix}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	selection, err := s.resolveExportSelection(ctx, productIDs, options)
+	if err != nil {
+		return nil, err
+	}
+	productIDs = selection.ProductIDs
+
 	// Get all families upfront for path resolution
 	allFamilies, err := s.repo.GetNodesByCategory(ctx, ProductFamily)
 	if err != nil {
@@ -196,7 +226,30 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 		Products   []interface{}
 	}
 
-	vendorGroups := make(map[string][]ProductGroup) // vendorName -> ProductGroups
+	vendorGroups := make(map[string][]ProductGroup) // vendor ID -> ProductGroups
+	vendorNames := make(map[string]string)          // vendor ID -> name
+
+	// Products that are only referenced through relationships are exported as
+	// well, so every relationship can point at a product of the tree.
+	// Related products without vendor are left out with their relationships.
+	relationships, relatedProductIDs, err := s.collectCSAFRelationships(ctx, &selection)
+	if err != nil {
+		return nil, err
+	}
+	productIDs = uniqueStrings(selection.ProductIDs)
+	related := make(map[string]bool, len(relatedProductIDs))
+	for _, id := range relatedProductIDs {
+		related[id] = true
+	}
+
+	fullNames := make(map[string]string) // version ID -> full product name
+	productGroups := newCSAFProductGroups(options.ProductGroups, allFamilies)
+
+	// Database IDs that may become product or group IDs of the tree
+	databaseIDs := make(map[string]bool)
+	for _, family := range allFamilies {
+		databaseIDs[family.ID] = true
+	}
 
 	for _, id := range productIDs {
 		p, err := s.GetProductByID(ctx, id)
@@ -205,6 +258,9 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 		}
 
 		if p.VendorID == nil {
+			if related[p.ID] {
+				continue
+			}
 			return nil, fuego.NotFoundError{
 				Title: "Vendor not found",
 				Err:   nil,
@@ -220,6 +276,13 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 		if err != nil {
 			return nil, err
 		}
+		selectedVersions := vers[:0]
+		for _, ver := range vers {
+			if selection.includesVersion(p.ID, ver.ID) {
+				selectedVersions = append(selectedVersions, ver)
+			}
+		}
+		vers = selectedVersions
 
 		// Build version nodes
 		var versionNodes []interface{}
@@ -233,6 +296,7 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 				"name":       v.Name + " " + p.Name + " " + ver.Name,
 				"product_id": ver.ID,
 			}
+			fullNames[ver.ID] = v.Name + " " + p.Name + " " + ver.Name
 
 			csafHelpers := s.convertIdentificationHelpersToCSAF(helpers)
 			if len(csafHelpers) > 0 {
@@ -246,17 +310,58 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 			})
 		}
 
-		// Create the product node
+		var groupProductIDs []string
+		for _, ver := range vers {
+			groupProductIDs = append(groupProductIDs, ver.ID)
+		}
+
+		// Version ranges follow the concrete versions
+		ranges, err := s.ListProductVersionRanges(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, versionRange := range ranges {
+			if !selection.includesVersion(p.ID, versionRange.ID) {
+				continue
+			}
+
+			fullNames[versionRange.ID] = v.Name + " " + p.Name + " " + versionRange.Vers
+			groupProductIDs = append(groupProductIDs, versionRange.ID)
+
+			versionNodes = append(versionNodes, map[string]interface{}{
+				"category": "product_version_range",
+				"name":     versionRange.Vers,
+				"product": map[string]interface{}{
+					"name":       fullNames[versionRange.ID],
+					"product_id": versionRange.ID,
+				},
+			})
+		}
+
+		// Create the product node. CSAF only allows either a product or
+		// further branches, so the product itself is only listed when it has
+		// no versions.
 		productNode := map[string]interface{}{
 			"category": "product_name",
 			"name":     p.Name,
-			"product": map[string]interface{}{
-				"name":       v.Name + " " + p.Name,
-				"product_id": p.ID,
-			},
 		}
 		if len(versionNodes) > 0 {
 			productNode["branches"] = versionNodes
+		} else {
+			productNode["product"] = map[string]interface{}{
+				"name":       v.Name + " " + p.Name,
+				"product_id": p.ID,
+			}
+			groupProductIDs = []string{p.ID}
+		}
+		// The groups describe the selection, related products are left out
+		if !related[p.ID] {
+			productGroups.add(v, p, groupProductIDs)
+			productGroups.addLifecycle(vers)
+		}
+		databaseIDs[v.ID], databaseIDs[p.ID] = true, true
+		for _, id := range groupProductIDs {
+			databaseIDs[id] = true
 		}
 
 		// Determine family path
@@ -266,8 +371,8 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 		}
 
 		// Find or create group for this vendor and family path
-		vendorName := v.Name
-		groups := vendorGroups[vendorName]
+		vendorNames[v.ID] = v.Name
+		groups := vendorGroups[v.ID]
 
 		// Look for existing group with same family path
 		var foundGroup *ProductGroup
@@ -287,20 +392,40 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 				FamilyPath: familyPath,
 				Products:   []interface{}{productNode},
 			}
-			vendorGroups[vendorName] = append(vendorGroups[vendorName], newGroup)
+			vendorGroups[v.ID] = append(vendorGroups[v.ID], newGroup)
 		}
 	}
 
-	// Build the final tree structure
+	// Build the final tree structure, sorted by name and ID so that repeated
+	// exports of the same data produce identical documents
+	vendorIDs := make([]string, 0, len(vendorGroups))
+	for vendorID := range vendorGroups {
+		vendorIDs = append(vendorIDs, vendorID)
+	}
+	sort.Slice(vendorIDs, func(i, j int) bool {
+		a, b := vendorNames[vendorIDs[i]], vendorNames[vendorIDs[j]]
+		if a != b {
+			return a < b
+		}
+		return vendorIDs[i] < vendorIDs[j]
+	})
+
 	var vendorNodes []interface{}
-	for vendorName, groups := range vendorGroups {
+	for _, vendorID := range vendorIDs {
 		vendorNode := map[string]interface{}{
 			"category": "vendor",
-			"name":     vendorName,
+			"name":     vendorNames[vendorID],
 		}
 
+		groups := vendorGroups[vendorID]
+		sort.SliceStable(groups, func(i, j int) bool {
+			return strings.Join(groups[i].FamilyPath, "\x00") < strings.Join(groups[j].FamilyPath, "\x00")
+		})
+
 		var vendorBranches []interface{}
 		for _, group := range groups {
+			sortCSAFBranches(group.Products)
+
 			if len(group.FamilyPath) == 0 {
 				// Direct products (no family)
 				vendorBranches = append(vendorBranches, group.Products...)
@@ -317,10 +442,25 @@ func (s *Service) ExportCSAFProductTree(ctx context.Context, productIDs []string
 		vendorNodes = append(vendorNodes, vendorNode)
 	}
 
+	productTree := map[string]interface{}{
+		"branches": vendorNodes,
+	}
+
+	csafRelationships := s.convertRelationshipsToCSAF(relationships, fullNames)
+	if len(csafRelationships) > 0 {
+		productTree["relationships"] = csafRelationships
+	}
+
+	if csafProductGroups := productGroups.toCSAF(); len(csafProductGroups) > 0 {
+		productTree["product_groups"] = csafProductGroups
+	}
+
+	if options.ProductIDScheme == ProductIDSchemeShort {
+		applyShortCSAFProductIDs(productTree, options.ProductIDPrefix, databaseIDs)
+	}
+
 	return map[string]interface{}{
-		"product_tree": map[string]interface{}{
-			"branches": vendorNodes,
-		},
+		"product_tree": productTree,
 	}, nil
 }
 
@@ -383,6 +523,18 @@ func (s *Service) CreateProduct(ctx context.Context, product CreateProductDTO) (
 		}
 	}
 
+	if err := validateVersionScheme("CreateProductDTO", VersionScheme(product.VersionScheme), product.VersionPattern); err != nil {
+		return ProductDTO{}, err
+	}
+
+	var defaults lifecycle
+	if err := defaults.update("CreateProductDTO", product.EndOfSupport, product.EndOfSecurityUpdates, product.EndOfLife, product.SupportStatus); err != nil {
+		return ProductDTO{}, err
+	}
+	if err := defaults.validate("CreateProductDTO", sql.NullTime{}); err != nil {
+		return ProductDTO{}, err
+	}
+
 	node := Node{
 		ID:              uuid.New().String(),
 		Name:            product.Name,
@@ -391,7 +543,10 @@ func (s *Service) CreateProduct(ctx context.Context, product CreateProductDTO) (
 		ParentID:        &vendorNode.ID,
 		ProductType:     ProductType(product.Type),
 		ProductFamilyID: product.FamilyID,
+		VersionScheme:   VersionScheme(product.VersionScheme),
+		VersionPattern:  product.VersionPattern,
 	}
+	defaults.setOn(&node)
 
 	createdNode, err := s.repo.CreateNode(ctx, node)
 
@@ -402,14 +557,7 @@ func (s *Service) CreateProduct(ctx context.Context, product CreateProductDTO) (
 		}
 	}
 
-	return ProductDTO{
-		ID:          createdNode.ID,
-		VendorID:    createdNode.ParentID,
-		Name:        createdNode.Name,
-		Description: createdNode.Description,
-		Type:        string(createdNode.ProductType),
-		FamilyID:    createdNode.ProductFamilyID,
-	}, nil
+	return nodeToProductDTO(createdNode, s.now()), nil
 }
 
 func (s *Service) UpdateProduct(ctx context.Context, id string, update UpdateProductDTO) (ProductDTO, error) {
@@ -444,6 +592,24 @@ func (s *Service) UpdateProduct(ctx context.Context, id string, update UpdatePro
 		product.ProductType = ProductType(*update.Type)
 	}
 	product.ProductFamilyID = update.FamilyID
+	if update.VersionScheme != nil {
+		product.VersionScheme = VersionScheme(*update.VersionScheme)
+	}
+	if update.VersionPattern != nil {
+		product.VersionPattern = *update.VersionPattern
+	}
+	if err := validateVersionScheme("UpdateProductDTO", product.VersionScheme, product.VersionPattern); err != nil {
+		return ProductDTO{}, err
+	}
+
+	defaults := lifecycleOf(product)
+	if err := defaults.update("UpdateProductDTO", update.EndOfSupport, update.EndOfSecurityUpdates, update.EndOfLife, update.SupportStatus); err != nil {
+		return ProductDTO{}, err
+	}
+	if err := defaults.validate("UpdateProductDTO", sql.NullTime{}); err != nil {
+		return ProductDTO{}, err
+	}
+	defaults.setOn(&product)
 
 	if err := s.repo.UpdateNode(ctx, product); err != nil {
 		return ProductDTO{}, fuego.InternalServerError{
@@ -453,12 +619,19 @@ func (s *Service) UpdateProduct(ctx context.Context, id string, update UpdatePro
 	}
 
 	return ProductDTO{
-		ID:          product.ID,
-		VendorID:    product.ParentID,
-		Name:        product.Name,
-		Description: product.Description,
-		FamilyID:    product.ProductFamilyID,
-		Type:        string(product.ProductType),
+		ID:             product.ID,
+		VendorID:       product.ParentID,
+		Name:           product.Name,
+		Description:    product.Description,
+		FamilyID:       product.ProductFamilyID,
+		Type:           string(product.ProductType),
+		VersionScheme:  string(productVersionOrder(product).scheme),
+		VersionPattern: product.VersionPattern,
+
+		EndOfSupport:         formatDate(product.EndOfSupport),
+		EndOfSecurityUpdates: formatDate(product.EndOfSecurityUpdates),
+		EndOfLife:            formatDate(product.EndOfLife),
+		SupportStatus:        string(product.SupportStatus),
 	}, nil
 }
 
@@ -495,17 +668,8 @@ func (s *Service) DeleteProduct(ctx context.Context, id string) error {
 }
 
 func (s *Service) ListProducts(ctx context.Context) ([]ProductDTO, error) {
-	nodes, err := s.repo.GetNodesByCategory(ctx, ProductName, WithParent(), WithChildren())
-	if err != nil {
-		return nil, err
-	}
-
-	products := make([]ProductDTO, len(nodes))
-	for i, node := range nodes {
-		products[i] = NodeToProductDTO(node)
-	}
-
-	return products, nil
+	products, _, err := s.QueryProducts(ctx, ListQuery{})
+	return products, err
 }
 
 func (s *Service) ListVendorProducts(ctx context.Context, vendorID string) ([]ProductDTO, error) {
@@ -544,7 +708,7 @@ func (s *Service) ListVendorProducts(ctx context.Context, vendorID string) ([]Pr
 }
 
 func (s *Service) GetProductByID(ctx context.Context, id string) (ProductDTO, error) {
-	product, err := s.repo.GetNodeByID(ctx, id, WithParent())
+	product, err := s.repo.GetNodeByID(ctx, id, WithParent(), WithChildren())
 	notFoundError := fuego.NotFoundError{
 		Title: "Product not found",
 		Err:   nil,
@@ -565,7 +729,7 @@ func (s *Service) GetProductByID(ctx context.Context, id string) (ProductDTO, er
 		return ProductDTO{}, notFoundError
 	}
 
-	return NodeToProductDTO(product), nil
+	return nodeToProductDTO(product, s.now()), nil
 }
 
 // Product Versions
@@ -616,6 +780,24 @@ func (s *Service) CreateProductVersion(ctx context.Context, version CreateProduc
 		ReleasedAt: releasedAt,
 	}
 
+	if version.PredecessorID != nil && *version.PredecessorID != "" {
+		if err := s.validatePredecessor(ctx, "CreateProductVersionDTO", node, *version.PredecessorID); err != nil {
+			return ProductVersionDTO{}, err
+		}
+		node.PredecessorID = version.PredecessorID
+	}
+
+	var ownLifecycle lifecycle
+	if err := ownLifecycle.update("CreateProductVersionDTO", version.EndOfSupport, version.EndOfSecurityUpdates, version.EndOfLife, version.SupportStatus); err != nil {
+		return ProductVersionDTO{}, err
+	}
+	ownLifecycle.setOn(&node)
+	// Only the dates of the version itself are ordered, the defaults of the
+	// product need not fit every version
+	if err := ownLifecycle.validate("CreateProductVersionDTO", node.ReleasedAt); err != nil {
+		return ProductVersionDTO{}, err
+	}
+
 	createdNode, err := s.repo.CreateNode(ctx, node)
 
 	if err != nil {
@@ -625,12 +807,14 @@ func (s *Service) CreateProductVersion(ctx context.Context, version CreateProduc
 		}
 	}
 
-	return ProductVersionDTO{
-		ID:          createdNode.ID,
-		ProductID:   createdNode.ParentID,
-		Name:        createdNode.Name,
-		Description: createdNode.Description,
-	}, nil
+	dto, err := s.newVersionDTOs().convert(ctx, createdNode)
+	if err != nil {
+		return ProductVersionDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch product version",
+			Err:   err,
+		}
+	}
+	return dto, nil
 }
 
 func (s *Service) UpdateProductVersion(ctx context.Context, id string, update UpdateProductVersionDTO) (ProductVersionDTO, error) {
@@ -658,24 +842,7 @@ func (s *Service) UpdateProductVersion(ctx context.Context, id string, update Up
 		version.Name = *update.Version
 	}
 
-	if update.PredecessorID != nil {
-		predecessor, err := s.repo.GetNodeByID(ctx, *update.PredecessorID)
-		if err != nil || predecessor.Category != ProductVersion {
-			return ProductVersionDTO{}, fuego.BadRequestError{
-				Title: "Invalid predecessor ID",
-				Err:   err,
-				Errors: []fuego.ErrorItem{
-					{
-						Name:   "UpdateProductVersionDTO.PredecessorID",
-						Reason: "Predecessor ID must be a valid product version ID",
-					},
-				},
-			}
-		}
-		version.SuccessorID = &predecessor.ID
-	}
-
-	if update.ProductID != nil {
+	if update.ProductID != nil && (version.ParentID == nil || *update.ProductID != *version.ParentID) {
 		product, err := s.repo.GetNodeByID(ctx, *update.ProductID)
 		if err != nil || product.Category != ProductName {
 			return ProductVersionDTO{}, fuego.BadRequestError{
@@ -689,7 +856,36 @@ func (s *Service) UpdateProductVersion(ctx context.Context, id string, update Up
 				},
 			}
 		}
+
+		// The lineage is limited to a product, so a moved version leaves it
+		if version.ParentID != nil {
+			oldProduct, err := s.repo.GetNodeByID(ctx, *version.ParentID, WithChildren())
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return ProductVersionDTO{}, fuego.InternalServerError{
+					Title: "Failed to fetch product versions",
+					Err:   err,
+				}
+			}
+			if successor, ok := newVersionLineage(versionsOf(oldProduct)).successors[version.ID]; ok {
+				return ProductVersionDTO{}, fuego.ConflictError{
+					Title:  "Version has a successor",
+					Detail: "Remove the version with ID " + successor + " from the lineage before moving the version to another product",
+				}
+			}
+		}
 		version.ParentID = &product.ID
+		version.PredecessorID = nil
+	}
+
+	if update.PredecessorID != nil {
+		if *update.PredecessorID == "" {
+			version.PredecessorID = nil
+		} else {
+			if err := s.validatePredecessor(ctx, "UpdateProductVersionDTO", version, *update.PredecessorID); err != nil {
+				return ProductVersionDTO{}, err
+			}
+			version.PredecessorID = update.PredecessorID
+		}
 	}
 
 	if update.ReleaseDate != nil {
@@ -712,6 +908,15 @@ func (s *Service) UpdateProductVersion(ctx context.Context, id string, update Up
 		}
 	}
 
+	ownLifecycle := lifecycleOf(version)
+	if err := ownLifecycle.update("UpdateProductVersionDTO", update.EndOfSupport, update.EndOfSecurityUpdates, update.EndOfLife, update.SupportStatus); err != nil {
+		return ProductVersionDTO{}, err
+	}
+	ownLifecycle.setOn(&version)
+	if err := ownLifecycle.validate("UpdateProductVersionDTO", version.ReleasedAt); err != nil {
+		return ProductVersionDTO{}, err
+	}
+
 	if err := s.repo.UpdateNode(ctx, version); err != nil {
 		return ProductVersionDTO{}, fuego.InternalServerError{
 			Title: "Failed to update product version",
@@ -719,12 +924,14 @@ func (s *Service) UpdateProductVersion(ctx context.Context, id string, update Up
 		}
 	}
 
-	return ProductVersionDTO{
-		ID:          version.ID,
-		ProductID:   version.ParentID,
-		Name:        version.Name,
-		Description: version.Description,
-	}, nil
+	dto, err := s.newVersionDTOs().convert(ctx, version)
+	if err != nil {
+		return ProductVersionDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch product version",
+			Err:   err,
+		}
+	}
+	return dto, nil
 }
 
 func (s *Service) DeleteProductVersion(ctx context.Context, id string) error {
@@ -780,19 +987,7 @@ func (s *Service) ListProductVersions(ctx context.Context, productID string) ([]
 		return nil, notFoundError
 	}
 
-	versions := make([]ProductVersionDTO, len(product.Children))
-	for i, version := range product.Children {
-		if version.Category != ProductVersion {
-			continue
-		}
-		versions[i] = ProductVersionDTO{
-			ID:          version.ID,
-			ProductID:   version.ParentID,
-			Name:        version.Name,
-			Description: version.Description,
-		}
-	}
-
+	versions, _ := productVersionDTOs(product, versionsOf(product), s.now())
 	return versions, nil
 }
 
@@ -817,7 +1012,7 @@ func (s *Service) GetProductVersionByID(ctx context.Context, id string) (Product
 		return ProductVersionDTO{}, notFoundError
 	}
 
-	product, err := s.repo.GetNodeByID(ctx, *version.ParentID)
+	product, err := s.repo.GetNodeByID(ctx, *version.ParentID, WithChildren())
 
 	if err != nil || product.Category != ProductName {
 		return ProductVersionDTO{}, fuego.InternalServerError{
@@ -825,7 +1020,7 @@ func (s *Service) GetProductVersionByID(ctx context.Context, id string) (Product
 		}
 	}
 
-	return NodeToProductVersionDTO(version), nil
+	return newVersionDetails(product, s.now()).dto(version), nil
 }
 
 // Relationships
@@ -853,6 +1048,7 @@ func (s *Service) GetRelationshipsByProductVersion(ctx context.Context, versionI
 
 	// Group by category first, then by product within each category
 	categoryGroups := make(map[string]map[string]*RelationshipGroupItemDTO)
+	versionDTOs := s.newVersionDTOs()
 
 	for _, rel := range version.SourceRelationships {
 		// We need to make sure the target node and its parent exist
@@ -871,15 +1067,22 @@ func (s *Service) GetRelationshipsByProductVersion(ctx context.Context, versionI
 		// Get or create the product group item
 		if categoryGroups[category][productID] == nil {
 			categoryGroups[category][productID] = &RelationshipGroupItemDTO{
-				Product:              NodeToProductDTO(*rel.TargetNode.Parent),
+				Product:              nodeToProductDTO(*rel.TargetNode.Parent, s.now()),
 				VersionRelationships: []ProductionVersionRelationshipDTO{},
 			}
 		}
 
 		// Add the version relationship to the existing product group
+		target, err := versionDTOs.convert(ctx, *rel.TargetNode)
+		if err != nil {
+			return nil, fuego.InternalServerError{
+				Title: "Failed to fetch product version",
+				Err:   err,
+			}
+		}
 		versionRelationship := ProductionVersionRelationshipDTO{
 			RelationshipID: rel.ID,
-			Version:        NodeToProductVersionDTO(*rel.TargetNode),
+			Version:        target,
 		}
 		categoryGroups[category][productID].VersionRelationships = append(
 			categoryGroups[category][productID].VersionRelationships,
@@ -990,7 +1193,18 @@ func (s *Service) GetRelationshipByID(ctx context.Context, id string) (Relations
 		}
 	}
 
-	return RelationshipToDTO(relationship), nil
+	dto := RelationshipToDTO(relationship)
+	versionDTOs := s.newVersionDTOs()
+	if dto.Source, err = versionDTOs.convert(ctx, *sourceNode); err == nil {
+		dto.Target, err = versionDTOs.convert(ctx, *targetNode)
+	}
+	if err != nil {
+		return RelationshipDTO{}, fuego.InternalServerError{
+			Title: "Failed to fetch product version",
+			Err:   err,
+		}
+	}
+	return dto, nil
 }
 
 func (s *Service) UpdateRelationship(ctx context.Context, update UpdateRelationshipDTO) error {
@@ -1584,32 +1798,6 @@ func (s *Service) DeleteProductFamily(ctx context.Context, id string) error {
 }
 
 func (s *Service) ListProductFamilies(ctx context.Context) ([]ProductFamilyDTO, error) {
-	nodes, err := s.repo.GetNodesByCategory(ctx, ProductFamily, WithParent(), WithChildren())
-	if err != nil {
-		return nil, fuego.InternalServerError{
-			Title: "Failed to list product families",
-			Err:   err,
-		}
-	}
-
-	families := make([]*ProductFamilyDTO, len(nodes))
-	for i, node := range nodes {
-		families[i] = &ProductFamilyDTO{
-			ID:       node.ID,
-			Name:     node.Name,
-			ParentID: node.ParentID,
-		}
-	}
-	err = s.fillPathOfFamilies(ctx, families)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert []*ProductFamilyDTO to []ProductFamilyDTO
-	result := make([]ProductFamilyDTO, len(families))
-	for i, family := range families {
-		result[i] = *family
-	}
-
-	return result, nil
+	families, _, err := s.QueryProductFamilies(ctx, ListQuery{})
+	return families, err
 }
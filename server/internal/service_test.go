@@ -5,6 +5,7 @@ import (
 	"errors"
 	"product-database-api/testutils"
 	"testing"
+	"time"
 
 	"github.com/go-fuego/fuego"
 	"gorm.io/gorm"
@@ -81,9 +82,67 @@ func (m *mockRepository) DeleteIdentificationHelper(ctx context.Context, id stri
 func (m *mockRepository) GetIdentificationHelpersByProductVersion(ctx context.Context, productVersionID string) ([]IdentificationHelper, error) {
 	return nil, nil
 }
+func (m *mockRepository) GetIdentificationHelpersByCategory(ctx context.Context, category string) ([]IdentificationHelper, error) {
+	return nil, nil
+}
 func (m *mockRepository) GetRelationshipsBySourceAndCategory(ctx context.Context, sourceNodeID, category string) ([]Relationship, error) {
 	return nil, nil
 }
+func (m *mockRepository) GetRelationshipsByNodeIDs(ctx context.Context, nodeIDs []string) ([]Relationship, error) {
+	return nil, nil
+}
+func (m *mockRepository) GetAllRelationships(ctx context.Context) ([]Relationship, error) {
+	return nil, nil
+}
+func (m *mockRepository) GetAllIdentificationHelpers(ctx context.Context) ([]IdentificationHelper, error) {
+	return nil, nil
+}
+func (m *mockRepository) LookupIdentifier(ctx context.Context, identifierType, value string) ([]IdentifierIndex, error) {
+	return nil, nil
+}
+func (m *mockRepository) RebuildIdentifierIndex(ctx context.Context) error {
+	return nil
+}
+func (m *mockRepository) QueryNodes(ctx context.Context, query NodeQuery, opts ...LoadOption) ([]Node, int64, error) {
+	if m.getNodesByCategoryFunc != nil {
+		nodes, err := m.getNodesByCategoryFunc(ctx, query.Category, opts...)
+		return nodes, int64(len(nodes)), err
+	}
+	return nil, 0, nil
+}
+func (m *mockRepository) RebuildSearchIndex(ctx context.Context) error {
+	return nil
+}
+func (m *mockRepository) SearchNodes(ctx context.Context, query string, limit int) ([]NodeSearchHit, error) {
+	return nil, nil
+}
+func (m *mockRepository) GetTrash(ctx context.Context) ([]TrashEntry, error) {
+	return nil, nil
+}
+func (m *mockRepository) GetDeletedNodeByID(ctx context.Context, id string) (Node, error) {
+	return Node{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepository) GetTrashedIDs(ctx context.Context) (TrashedIDs, error) {
+	return TrashedIDs{}, nil
+}
+func (m *mockRepository) RestoreNode(ctx context.Context, id string) error {
+	return nil
+}
+func (m *mockRepository) PurgeNode(ctx context.Context, id string) error {
+	return nil
+}
+func (m *mockRepository) GetSnapshot(ctx context.Context, at time.Time) (CatalogSnapshot, error) {
+	return CatalogSnapshot{}, nil
+}
+func (m *mockRepository) EnsureRowVersions(ctx context.Context) error {
+	return nil
+}
+func (m *mockRepository) GetAuditEntries(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	return nil, nil
+}
+func (m *mockRepository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return fn(m)
+}
 
 func TestService(t *testing.T) {
 	db := testutils.SetupTestDB(t)
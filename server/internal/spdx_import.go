@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-fuego/fuego"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DocumentDescribes []string           `json:"documentDescribes"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxPackage struct {
+	SPDXID                string            `json:"SPDXID"`
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo"`
+	Supplier              string            `json:"supplier"`
+	Originator            string            `json:"originator"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+	Checksums             []spdxChecksum    `json:"checksums"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+	RelationshipType   string `json:"relationshipType"`
+}
+
+// ImportSPDXSBOM imports an SPDX 2.3 document given as JSON or tag-value for
+// the given product version. Packages become product versions, purl and CPE
+// external references and checksums identification helpers. CONTAINS and
+// DEPENDS_ON relationships are imported as default_component_of, the
+// OPTIONAL_* relationships as optional_component_of.
+func (s *Service) ImportSPDXSBOM(ctx context.Context, productVersionID string, data []byte, dryRun bool) (ImportReportDTO, error) {
+	var document spdxDocument
+	var err error
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		err = json.Unmarshal(trimmed, &document)
+	} else {
+		document, err = parseSPDXTagValue(trimmed)
+	}
+	if err == nil && !strings.HasPrefix(document.SPDXVersion, "SPDX-2.") {
+		err = errors.New("spdxVersion must be SPDX-2.x")
+	}
+	if err != nil {
+		return ImportReportDTO{}, fuego.BadRequestError{
+			Title: "Invalid SPDX SBOM",
+			Err:   err,
+		}
+	}
+
+	components, dependencies := document.flatten()
+	return s.importSBOM(ctx, productVersionID, components, dependencies, dryRun)
+}
+
+// flatten turns the packages into components. Packages described by the
+// document stand for the imported product version itself. Packages that are
+// no component of another package belong to the imported version directly.
+// Relationships involving files, snippets or external documents are ignored.
+func (document spdxDocument) flatten() ([]sbomComponent, []sbomDependency) {
+	packages := make(map[string]bool, len(document.Packages))
+	for _, pkg := range document.Packages {
+		packages[pkg.SPDXID] = true
+	}
+
+	roots := make(map[string]bool)
+	for _, id := range document.DocumentDescribes {
+		roots[id] = true
+	}
+	for _, rel := range document.Relationships {
+		switch strings.ToUpper(rel.RelationshipType) {
+		case "DESCRIBES":
+			roots[rel.RelatedSPDXElement] = true
+		case "DESCRIBED_BY":
+			roots[rel.SPDXElementID] = true
+		}
+	}
+
+	ref := func(id string) string {
+		if roots[id] {
+			return ""
+		}
+		return id
+	}
+
+	var dependencies []sbomDependency
+	isComponent := make(map[string]bool)
+	for _, rel := range document.Relationships {
+		a, b := rel.SPDXElementID, rel.RelatedSPDXElement
+		if !packages[a] || !packages[b] {
+			continue
+		}
+
+		var dependency sbomDependency
+		switch strings.ToUpper(rel.RelationshipType) {
+		case "CONTAINS", "DEPENDS_ON":
+			dependency = sbomDependency{Ref: ref(a), DependsOn: ref(b), Category: DefaultComponentOf}
+		case "CONTAINED_BY", "DEPENDENCY_OF":
+			dependency = sbomDependency{Ref: ref(b), DependsOn: ref(a), Category: DefaultComponentOf}
+		case "OPTIONAL_COMPONENT_OF", "OPTIONAL_DEPENDENCY_OF":
+			dependency = sbomDependency{Ref: ref(b), DependsOn: ref(a), Category: OptionalComponentOf}
+		default:
+			continue
+		}
+		if dependency.DependsOn == "" {
+			continue
+		}
+
+		isComponent[dependency.DependsOn] = true
+		dependencies = append(dependencies, dependency)
+	}
+
+	var components []sbomComponent
+	for _, pkg := range document.Packages {
+		if roots[pkg.SPDXID] {
+			continue
+		}
+		components = append(components, pkg.toSBOMComponent())
+		if !isComponent[pkg.SPDXID] {
+			dependencies = append(dependencies, sbomDependency{DependsOn: pkg.SPDXID, Category: DefaultComponentOf})
+		}
+	}
+
+	return components, dependencies
+}
+
+func (pkg spdxPackage) toSBOMComponent() sbomComponent {
+	component := sbomComponent{
+		Ref:     pkg.SPDXID,
+		Name:    pkg.Name,
+		Version: spdxValue(pkg.VersionInfo),
+		Vendor:  spdxActor(pkg.Supplier),
+		Type:    Software,
+	}
+	if component.Vendor == "" {
+		component.Vendor = spdxActor(pkg.Originator)
+	}
+
+	switch strings.ToUpper(pkg.PrimaryPackagePurpose) {
+	case "FIRMWARE":
+		component.Type = Firmware
+	case "DEVICE":
+		component.Type = Hardware
+	}
+
+	for _, ref := range pkg.ExternalRefs {
+		switch ref.ReferenceType {
+		case "purl":
+			if component.Purl == "" {
+				component.Purl = ref.ReferenceLocator
+			}
+		case "cpe23Type":
+			component.CPE = ref.ReferenceLocator
+		case "cpe22Type":
+			if component.CPE == "" {
+				component.CPE = ref.ReferenceLocator
+			}
+		}
+	}
+
+	for _, checksum := range pkg.Checksums {
+		component.Hashes = append(component.Hashes, sbomHash{Algorithm: checksum.Algorithm, Value: checksum.ChecksumValue})
+	}
+
+	return component
+}
+
+// spdxValue returns an empty string for NOASSERTION and NONE.
+func spdxValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "NOASSERTION" || value == "NONE" {
+		return ""
+	}
+	return value
+}
+
+var spdxActorEmail = regexp.MustCompile(`\s*\([^)]*\)\s*$`)
+
+// spdxActor returns the name of an SPDX actor such as
+// "Organization: ExampleCodeInspect (contact@example.com)".
+func spdxActor(actor string) string {
+	actor = spdxValue(actor)
+	if _, name, ok := strings.Cut(actor, ":"); ok {
+		actor = name
+	}
+	return strings.TrimSpace(spdxActorEmail.ReplaceAllString(actor, ""))
+}
+
+// parseSPDXTagValue reads the parts of an SPDX tag-value document that are
+// needed for the import.
+func parseSPDXTagValue(data []byte) (spdxDocument, error) {
+	var document spdxDocument
+	var pkg *spdxPackage
+	inPackage := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tag, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		// Multi-line values are wrapped in <text> tags
+		if strings.HasPrefix(value, "<text>") {
+			for !strings.Contains(value, "</text>") && scanner.Scan() {
+				value += "\n" + scanner.Text()
+			}
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "<text>"), "</text>")
+		}
+
+		switch tag {
+		case "SPDXVersion":
+			document.SPDXVersion = value
+		case "PackageName":
+			document.Packages = append(document.Packages, spdxPackage{Name: value})
+			pkg = &document.Packages[len(document.Packages)-1]
+			inPackage = true
+		case "FileName", "SnippetSPDXID", "LicenseID":
+			inPackage = false
+		case "SPDXID":
+			if inPackage && pkg.SPDXID == "" {
+				pkg.SPDXID = value
+			}
+		case "PackageVersion":
+			if inPackage {
+				pkg.VersionInfo = value
+			}
+		case "PackageSupplier":
+			if inPackage {
+				pkg.Supplier = value
+			}
+		case "PackageOriginator":
+			if inPackage {
+				pkg.Originator = value
+			}
+		case "PrimaryPackagePurpose":
+			if inPackage {
+				pkg.PrimaryPackagePurpose = value
+			}
+		case "PackageChecksum":
+			if algorithm, checksum, ok := strings.Cut(value, ":"); ok && inPackage {
+				pkg.Checksums = append(pkg.Checksums, spdxChecksum{
+					Algorithm:     strings.TrimSpace(algorithm),
+					ChecksumValue: strings.TrimSpace(checksum),
+				})
+			}
+		case "ExternalRef":
+			if fields := strings.Fields(value); len(fields) >= 3 && inPackage {
+				pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{
+					ReferenceCategory: fields[0],
+					ReferenceType:     fields[1],
+					ReferenceLocator:  fields[2],
+				})
+			}
+		case "Relationship":
+			if fields := strings.Fields(value); len(fields) == 3 {
+				document.Relationships = append(document.Relationships, spdxRelationship{
+					SPDXElementID:      fields[0],
+					RelationshipType:   fields[1],
+					RelatedSPDXElement: fields[2],
+				})
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return spdxDocument{}, err
+	}
+	if document.SPDXVersion == "" {
+		return spdxDocument{}, errors.New("SPDXVersion is missing")
+	}
+
+	return document, nil
+}
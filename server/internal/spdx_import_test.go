@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-database-api/testutils"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+const testSPDXTagValue = `SPDXVersion: SPDX-2.3
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentName: gateway-2.0.0
+DocumentComment: <text>Generated for
+the gateway firmware</text>
+
+PackageName: Gateway
+SPDXID: SPDXRef-gateway
+PackageVersion: 2.0.0
+PackageSupplier: Organization: Acme
+
+PackageName: busybox
+SPDXID: SPDXRef-busybox
+PackageVersion: 1.36.1
+PackageSupplier: Organization: BusyBox Project (info@busybox.net)
+PackageChecksum: SHA256: 4F2A5A4B4C4D4E4F505152535455565758595A5B5C5D5E5F6061626364656667
+ExternalRef: SECURITY cpe23Type cpe:2.3:a:busybox:busybox:1.36.1:*:*:*:*:*:*:*
+ExternalRef: PACKAGE-MANAGER purl pkg:generic/busybox@1.36.1
+
+FileName: ./bin/busybox
+SPDXID: SPDXRef-file-busybox
+
+PackageName: musl
+SPDXID: SPDXRef-musl
+PackageVersion: 1.2.4
+PackageOriginator: Person: Rich Felker
+
+PackageName: dropbear
+SPDXID: SPDXRef-dropbear
+PackageVersion: 2022.83
+PackageSupplier: Organization: Dropbear
+
+PackageName: unknown
+SPDXID: SPDXRef-unknown
+PackageVersion: NOASSERTION
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-gateway
+Relationship: SPDXRef-gateway CONTAINS SPDXRef-busybox
+Relationship: SPDXRef-busybox DEPENDS_ON SPDXRef-musl
+Relationship: SPDXRef-dropbear OPTIONAL_COMPONENT_OF SPDXRef-gateway
+Relationship: SPDXRef-busybox CONTAINS SPDXRef-file-busybox
+Relationship: SPDXRef-musl GENERATED_FROM SPDXRef-busybox
+`
+
+func TestImportSPDXSBOM(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Gateway", VendorID: vendor.ID, Type: "firmware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	gateway, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := svc.ImportSPDXSBOM(ctx, gateway.ID, []byte(testSPDXTagValue), true)
+		testutils.AssertNoError(t, err, "Should run dry run")
+		testutils.AssertEqual(t, true, report.DryRun, "Dry run flag")
+		if report.Summary.Created == 0 {
+			t.Error("Dry run should report created items")
+		}
+
+		vendors, err := svc.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 1, len(vendors), "Dry run must not persist vendors")
+	})
+
+	t.Run("TagValue", func(t *testing.T) {
+		report, err := svc.ImportSPDXSBOM(ctx, gateway.ID, []byte(testSPDXTagValue), false)
+		testutils.AssertNoError(t, err, "Should import SPDX tag-value")
+		testutils.AssertEqual(t, 1, report.Summary.Skipped, "Package without version is skipped")
+
+		versionIDs := make(map[string]string)
+		for _, item := range report.Items {
+			if item.Category == string(ProductVersion) && item.Action == ImportActionCreated {
+				versionIDs[item.Path[1]] = item.ID
+				if item.Path[1] == "busybox" {
+					testutils.AssertEqual(t, "BusyBox Project", item.Path[0], "Supplier without contact")
+				}
+				if item.Path[1] == "musl" {
+					testutils.AssertEqual(t, "Rich Felker", item.Path[0], "Originator as fallback vendor")
+				}
+			}
+		}
+		testutils.AssertCount(t, 3, len(versionIDs), "Packages except the described one")
+
+		relationships, err := repo.GetRelationshipsByNodeIDs(ctx, []string{gateway.ID, versionIDs["busybox"]})
+		testutils.AssertNoError(t, err, "Should list relationships")
+
+		found := make(map[string]RelationshipCategory)
+		for _, rel := range relationships {
+			found[rel.SourceNodeID+">"+rel.TargetNodeID] = rel.Category
+		}
+		testutils.AssertEqual(t, DefaultComponentOf, found[versionIDs["busybox"]+">"+gateway.ID], "CONTAINS")
+		testutils.AssertEqual(t, DefaultComponentOf, found[versionIDs["musl"]+">"+versionIDs["busybox"]], "DEPENDS_ON")
+		testutils.AssertEqual(t, OptionalComponentOf, found[versionIDs["dropbear"]+">"+gateway.ID], "OPTIONAL_COMPONENT_OF")
+		if _, ok := found[versionIDs["musl"]+">"+gateway.ID]; ok {
+			t.Error("Nested package must not be a direct component of the version")
+		}
+
+		helpers, err := svc.GetIdentificationHelpersByProductVersion(ctx, versionIDs["busybox"])
+		testutils.AssertNoError(t, err, "Should list helpers")
+		testutils.AssertCount(t, 3, len(helpers), "purl, CPE and checksum helpers")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		// The exported SPDX document is matched completely
+		doc, err := svc.ExportSBOM(ctx, gateway.ID, SBOMFormatSPDX)
+		testutils.AssertNoError(t, err, "Should export SPDX")
+		data, err := json.Marshal(doc)
+		testutils.AssertNoError(t, err, "Should encode SPDX")
+
+		report, err := svc.ImportSPDXSBOM(ctx, gateway.ID, data, true)
+		testutils.AssertNoError(t, err, "Should import SPDX JSON")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Nothing new is created")
+		testutils.AssertEqual(t, 0, report.Summary.Conflicts, "No conflicts")
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		for _, data := range []string{
+			"PackageName: missing version header",
+			`{"spdxVersion": "SPDX-3.0"}`,
+			"not an SBOM",
+		} {
+			_, err := svc.ImportSBOM(ctx, gateway.ID, []byte(data), true)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %q, got %v", data, err)
+			}
+		}
+	})
+
+	t.Run("ImportEndpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("POST", "/api/v1/product-versions/"+gateway.ID+"/sbom?dry_run=true", bytes.NewBufferString(testSPDXTagValue))
+		req.Header.Set("Content-Type", "text/spdx")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var report ImportReportDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &report), "Should decode report")
+		testutils.AssertEqual(t, 0, report.Summary.Created, "Everything was imported before")
+	})
+}
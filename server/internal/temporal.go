@@ -0,0 +1,386 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// Entity types of row versions.
+const (
+	rowVersionNode         = "node"
+	rowVersionRelationship = "relationship"
+	rowVersionHelper       = "identification_helper"
+)
+
+// rowVersionData returns the JSON of a row without its associations.
+func rowVersionData(row any) ([]byte, error) {
+	switch v := row.(type) {
+	case Node:
+		v.Parent, v.Children, v.Predecessor = nil, nil, nil
+		v.SourceRelationships, v.TargetRelationships = nil, nil
+		return json.Marshal(v)
+	case Relationship:
+		v.SourceNode, v.TargetNode = nil, nil
+		return json.Marshal(v)
+	case IdentificationHelper:
+		v.Node = nil
+		return json.Marshal(v)
+	}
+	return nil, fmt.Errorf("unsupported row version type %T", row)
+}
+
+// recordRowVersion ends the current version of a row at the given time and
+// starts a new one with the row. Unchanged rows keep their version.
+func recordRowVersion(tx *gorm.DB, entityType, id string, row any, at time.Time) error {
+	data, err := rowVersionData(row)
+	if err != nil {
+		return err
+	}
+
+	var current RowVersion
+	err = tx.Where("entity_type = ? AND entity_id = ? AND valid_to IS NULL", entityType, id).
+		Order("id DESC").Limit(1).Find(&current).Error
+	if err != nil {
+		return err
+	}
+	if current.ID != 0 {
+		if bytes.Equal(current.Data, data) {
+			return nil
+		}
+		if err := tx.Model(&current).Update("valid_to", at).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&RowVersion{EntityType: entityType, EntityID: id, Data: data, ValidFrom: at}).Error
+}
+
+// endRowVersions ends the current versions of deleted rows.
+func endRowVersions(tx *gorm.DB, entityType string, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&RowVersion{}).
+		Where("entity_type = ? AND entity_id IN ? AND valid_to IS NULL", entityType, ids).
+		Update("valid_to", at).Error
+}
+
+// CatalogSnapshot is the content of the catalog at a point in time.
+type CatalogSnapshot struct {
+	Nodes                 []Node
+	Relationships         []Relationship
+	IdentificationHelpers []IdentificationHelper
+}
+
+// snapshotCacheSize is the number of reconstructed catalogs kept for as_of
+// reads, maxSnapshotBuilds the number reconstructed at the same time.
+const (
+	snapshotCacheSize = 4
+	maxSnapshotBuilds = 2
+)
+
+// snapshotCache shares the reconstructed catalogs of past times between
+// as_of reads. The catalog of a past time never changes, as all writes
+// record row versions valid from the time of the write.
+type snapshotCache struct {
+	mu sync.Mutex
+	// entries are ordered from least to most recently used.
+	entries []*catalogSnapshotDB
+	builds  chan struct{}
+}
+
+func newSnapshotCache() *snapshotCache {
+	return &snapshotCache{builds: make(chan struct{}, maxSnapshotBuilds)}
+}
+
+// catalogSnapshotDB is the in-memory database of the catalog at a time. It
+// is closed once it left the cache and the last read released it.
+type catalogSnapshotDB struct {
+	at      time.Time
+	ready   chan struct{}
+	repo    *snapshotRepository
+	err     error
+	refs    int
+	evicted bool
+}
+
+// acquire returns the catalog at the given time, reconstructing it with
+// build unless it is cached or being reconstructed already. Every catalog
+// returned has to be released.
+func (c *snapshotCache) acquire(ctx context.Context, at time.Time, build func() (*gorm.DB, error)) (*catalogSnapshotDB, error) {
+	c.mu.Lock()
+	for i, entry := range c.entries {
+		if entry.at.Equal(at) {
+			c.entries = append(append(c.entries[:i:i], c.entries[i+1:]...), entry)
+			entry.refs++
+			c.mu.Unlock()
+			return c.wait(ctx, entry)
+		}
+	}
+	entry := &catalogSnapshotDB{at: at, ready: make(chan struct{}), refs: 1}
+	c.entries = append(c.entries, entry)
+	for len(c.entries) > snapshotCacheSize {
+		c.evict(c.entries[0])
+	}
+	c.mu.Unlock()
+
+	go func() {
+		defer close(entry.ready)
+		c.builds <- struct{}{}
+		defer func() { <-c.builds }()
+
+		db, err := build()
+		if err != nil {
+			entry.err = err
+			c.mu.Lock()
+			c.evict(entry)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Lock()
+		entry.repo = &snapshotRepository{Repository: NewRepository(db), db: db}
+		c.closeUnused(entry)
+		c.mu.Unlock()
+	}()
+	return c.wait(ctx, entry)
+}
+
+func (c *snapshotCache) wait(ctx context.Context, entry *catalogSnapshotDB) (*catalogSnapshotDB, error) {
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		c.release(entry)
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		c.release(entry)
+		return nil, entry.err
+	}
+	return entry, nil
+}
+
+// release ends a read of a catalog.
+func (c *snapshotCache) release(entry *catalogSnapshotDB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	c.closeUnused(entry)
+}
+
+// evict removes a catalog from the cache. The caller holds the lock.
+func (c *snapshotCache) evict(entry *catalogSnapshotDB) {
+	for i, cached := range c.entries {
+		if cached == entry {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			break
+		}
+	}
+	entry.evicted = true
+	c.closeUnused(entry)
+}
+
+// closeUnused closes the database of an evicted catalog without reads. The
+// caller holds the lock.
+func (c *snapshotCache) closeUnused(entry *catalogSnapshotDB) {
+	if !entry.evicted || entry.refs > 0 || entry.repo == nil {
+		return
+	}
+	if sqlDB, err := entry.repo.db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	entry.repo = nil
+}
+
+// snapshotRepository reads a reconstructed catalog. The database only
+// accepts queries, the full-text search index is built by the first search.
+type snapshotRepository struct {
+	Repository
+	db         *gorm.DB
+	searchOnce sync.Once
+	searchErr  error
+}
+
+func (r *snapshotRepository) SearchNodes(ctx context.Context, query string, limit int) ([]NodeSearchHit, error) {
+	r.searchOnce.Do(func() {
+		r.searchErr = r.rebuildSearchIndex(context.WithoutCancel(ctx))
+	})
+	if r.searchErr != nil {
+		return nil, r.searchErr
+	}
+	return r.Repository.SearchNodes(ctx, query, limit)
+}
+
+func (r *snapshotRepository) rebuildSearchIndex(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Exec("PRAGMA query_only = OFF").Error; err != nil {
+		return err
+	}
+	defer db.Exec("PRAGMA query_only = ON")
+	return r.Repository.RebuildSearchIndex(ctx)
+}
+
+// AsOf returns a copy of the service that reads the catalog as it was at the
+// given time, reconstructed from the row versions into a read-only in-memory
+// database. The catalogs of recently read times are cached; times that are
+// not in the past read the current catalog. The returned function ends the
+// read. Rows that existed before versions were recorded are valid since
+// their creation, or forever if that is unknown.
+func (s *Service) AsOf(ctx context.Context, at time.Time) (*Service, func(), error) {
+	if !at.Before(time.Now()) {
+		return s, func() {}, nil
+	}
+
+	entry, err := s.snapshots.acquire(ctx, at.UTC(), func() (*gorm.DB, error) {
+		return s.openSnapshot(context.WithoutCancel(ctx), at)
+	})
+	if err != nil {
+		return nil, nil, fuego.InternalServerError{
+			Title: "Failed to reconstruct catalog",
+			Err:   err,
+		}
+	}
+
+	snapshotService := *s
+	snapshotService.repo = entry.repo
+	snapshotService.asOf = at
+	return &snapshotService, func() { s.snapshots.release(entry) }, nil
+}
+
+// now returns the time the catalog is read at: the as_of time of a snapshot
+// service or else the current time.
+func (s *Service) now() time.Time {
+	if !s.asOf.IsZero() {
+		return s.asOf
+	}
+	return time.Now()
+}
+
+// openSnapshot reconstructs the catalog at the given time with its
+// identifier index.
+func (s *Service) openSnapshot(ctx context.Context, at time.Time) (*gorm.DB, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openSnapshotDB(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	err = NewRepository(db).RebuildIdentifierIndex(ctx)
+	if err == nil {
+		err = db.Exec("PRAGMA query_only = ON").Error
+	}
+	if err != nil {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+	return db, nil
+}
+
+// openSnapshotDB creates an in-memory database with the rows of a snapshot.
+func openSnapshotDB(ctx context.Context, snapshot CatalogSnapshot) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to :memory: opens a database of its own
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	err = func() error {
+		if err := db.AutoMigrate(Models()...); err != nil {
+			return err
+		}
+		db = db.WithContext(ctx).Omit(clause.Associations)
+		if len(snapshot.Nodes) > 0 {
+			if err := db.CreateInBatches(snapshot.Nodes, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(snapshot.Relationships) > 0 {
+			if err := db.CreateInBatches(snapshot.Relationships, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(snapshot.IdentificationHelpers) > 0 {
+			if err := db.CreateInBatches(snapshot.IdentificationHelpers, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return db.Session(&gorm.Session{NewDB: true}), nil
+}
+
+// asOfQuery reads the as_of query parameter. A date is the end of that day.
+func asOfQuery(c interface{ QueryParam(string) string }) (time.Time, error) {
+	at, err := parseHistoryTime(c.QueryParam("as_of"), true)
+	if err != nil {
+		return time.Time{}, fuego.BadRequestError{
+			Title: "Invalid as_of",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "as_of",
+					Reason: "as_of must be an RFC 3339 timestamp or a date",
+				},
+			},
+		}
+	}
+	return at, nil
+}
+
+// asOf wraps a read handler to run against the catalog as it was at the
+// time given by the as_of query parameter, or the current catalog without.
+func asOf[T any, C interface {
+	QueryParam(string) string
+	Request() *http.Request
+}](h *Handler, handler func(*Handler, C) (T, error)) func(C) (T, error) {
+	return func(c C) (T, error) {
+		var zero T
+		at, err := asOfQuery(c)
+		if err != nil {
+			return zero, err
+		}
+		if at.IsZero() {
+			return handler(h, c)
+		}
+
+		svc, release, err := h.svc.AsOf(c.Request().Context(), at)
+		if err != nil {
+			return zero, err
+		}
+		defer release()
+		return handler(NewHandler(svc), c)
+	}
+}
+
+// EnsureRowVersions records the current state of rows written before row
+// versions were recorded, so they can be read with as_of.
+func (s *Service) EnsureRowVersions(ctx context.Context) error {
+	return s.repo.EnsureRowVersions(ctx)
+}
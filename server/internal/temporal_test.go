@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestAsOf(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+		ProductVersionID: version.ID,
+		Category:         "cpe",
+		Metadata:         `{"cpe": "cpe:2.3:h:acme:router:1.0:*:*:*:*:*:*:*"}`,
+	})
+	testutils.AssertNoError(t, err, "Should create identification helper")
+
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	name := "Acme Corp"
+	_, err = svc.UpdateVendor(ctx, vendor.ID, UpdateVendorDTO{Name: &name})
+	testutils.AssertNoError(t, err, "Should update vendor")
+	_, err = svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	testutils.AssertNoError(t, svc.DeleteProductVersion(ctx, version.ID), "Should delete version")
+
+	t.Run("Service", func(t *testing.T) {
+		past, release, err := svc.AsOf(ctx, before)
+		testutils.AssertNoError(t, err, "Should reconstruct catalog")
+		defer release()
+
+		got, err := past.GetVendorByID(ctx, vendor.ID)
+		testutils.AssertNoError(t, err, "Vendor existed")
+		testutils.AssertEqual(t, "Acme", got.Name, "Old vendor name")
+
+		versions, err := past.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		testutils.AssertCount(t, 1, len(versions), "Only the deleted version existed")
+		testutils.AssertEqual(t, version.ID, versions[0].ID, "Deleted version")
+
+		result, err := past.Lookup(ctx, "cpe:2.3:h:acme:router:1.0:*:*:*:*:*:*:*", "")
+		testutils.AssertNoError(t, err, "Lookup should succeed")
+		testutils.AssertCount(t, 1, len(result.Matches), "Identifier of the deleted version")
+
+		current, err := svc.GetVendorByID(ctx, vendor.ID)
+		testutils.AssertNoError(t, err, "Vendor exists")
+		testutils.AssertEqual(t, name, current.Name, "Current catalog is unchanged")
+	})
+
+	t.Run("BeforeCreation", func(t *testing.T) {
+		past, release, err := svc.AsOf(ctx, before.Add(-time.Hour))
+		testutils.AssertNoError(t, err, "Should reconstruct catalog")
+		defer release()
+
+		vendors, err := past.ListVendors(ctx)
+		testutils.AssertNoError(t, err, "Should list vendors")
+		testutils.AssertCount(t, 0, len(vendors), "Nothing existed")
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		past, release, err := svc.AsOf(ctx, before)
+		testutils.AssertNoError(t, err, "Should reconstruct catalog")
+		defer release()
+		again, releaseAgain, err := svc.AsOf(ctx, before)
+		testutils.AssertNoError(t, err, "Should reuse catalog")
+		defer releaseAgain()
+		testutils.AssertEqual(t, past.repo, again.repo, "Catalog of the same time is shared")
+
+		_, err = past.CreateVendor(ctx, CreateVendorDTO{Name: "Globex"})
+		testutils.AssertError(t, err, "Reconstructed catalog is read-only")
+
+		result, err := past.Search(ctx, "router", 10)
+		testutils.AssertNoError(t, err, "Should search reconstructed catalog")
+		testutils.AssertEqual(t, true, len(result.Hits) > 0, "Search index is built on demand")
+
+		current, releaseCurrent, err := svc.AsOf(ctx, time.Now().Add(time.Hour))
+		testutils.AssertNoError(t, err, "Should read current catalog")
+		defer releaseCurrent()
+		testutils.AssertEqual(t, svc, current, "Future times read the current catalog")
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		asOf := url.QueryEscape(before.UTC().Format(time.RFC3339Nano))
+		req := httptest.NewRequest("GET", "/api/v1/products/"+product.ID+"/versions?as_of="+asOf, nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var versions []ProductVersionDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &versions), "Should decode versions")
+		testutils.AssertCount(t, 1, len(versions), "Versions at that time")
+		testutils.AssertEqual(t, "1.0", versions[0].Name, "Old version")
+
+		req = httptest.NewRequest("GET", "/api/v1/products/"+product.ID+"/versions", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &versions), "Should decode versions")
+		testutils.AssertCount(t, 1, len(versions), "Current versions")
+		testutils.AssertEqual(t, "2.0", versions[0].Name, "New version")
+
+		req = httptest.NewRequest("GET", "/api/v1/vendors/"+vendor.ID+"?as_of=yesterday", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusBadRequest, w.Code, "Status code of invalid as_of")
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		asOf := url.QueryEscape(before.UTC().Format(time.RFC3339Nano))
+		body := `{"product_ids": ["` + product.ID + `"]}`
+		req := httptest.NewRequest("POST", "/api/v1/products/export?as_of="+asOf, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+		testutils.AssertEqual(t, false, bytes.Contains(w.Body.Bytes(), []byte("Acme Corp")), "Renamed vendor is not exported")
+		testutils.AssertEqual(t, true, bytes.Contains(w.Body.Bytes(), []byte("cpe:2.3:h:acme:router:1.0")), "Old identifier is exported")
+	})
+}
+
+func TestEnsureRowVersions(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	createdAt := time.Now().Add(-time.Hour)
+	testutils.AssertNoError(t, db.Create(&testutils.Node{ID: "7d3e1f5a-2b4c-4d6e-8f90-1a2b3c4d5e6f", Name: "Acme", Category: "vendor", CreatedAt: createdAt}).Error, "Should insert vendor")
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+	testutils.AssertNoError(t, svc.EnsureRowVersions(ctx), "Should record row versions")
+	testutils.AssertNoError(t, svc.EnsureRowVersions(ctx), "Should skip versioned rows")
+
+	var count int64
+	db.Model(&testutils.RowVersion{}).Count(&count)
+	testutils.AssertEqual(t, int64(1), count, "One version per row")
+
+	past, release, err := svc.AsOf(ctx, createdAt.Add(time.Minute))
+	testutils.AssertNoError(t, err, "Should reconstruct catalog")
+	defer release()
+	_, err = past.GetVendorByID(ctx, "7d3e1f5a-2b4c-4d6e-8f90-1a2b3c4d5e6f")
+	testutils.AssertNoError(t, err, "Vendor existed since its creation")
+}
+
+func TestAsOfLifecycle(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	day := func(days int) time.Time {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, days)
+	}
+	endOfSupport := day(-10).Format("2006-01-02")
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware", EndOfSupport: &endOfSupport})
+	testutils.AssertNoError(t, err, "Should create product")
+	for _, name := range []string{"1.0", "2.0"} {
+		version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+		testutils.AssertEqual(t, "unsupported", version.SupportStatus, "Current status")
+	}
+
+	// The catalog existed unchanged for a month
+	err = db.Model(&testutils.RowVersion{}).Where("1 = 1").Update("valid_from", day(-30)).Error
+	testutils.AssertNoError(t, err, "Should backdate row versions")
+	asOf := day(-20)
+
+	t.Run("Versions", func(t *testing.T) {
+		past, release, err := svc.AsOf(ctx, asOf)
+		testutils.AssertNoError(t, err, "Should reconstruct catalog")
+		defer release()
+
+		versions, err := past.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		testutils.AssertEqual(t, "supported", versions[0].SupportStatus, "Status at as_of")
+
+		upcoming, err := past.UpcomingLifecycleMilestones(ctx, MilestoneEndOfSupport, 30)
+		testutils.AssertNoError(t, err, "Should list milestones")
+		testutils.AssertCount(t, 2, len(upcoming), "Milestones after as_of")
+		testutils.AssertEqual(t, 10, upcoming[0].DaysLeft, "Days left at as_of")
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		body := `{"product_ids": ["` + product.ID + `"], "product_groups": ["lifecycle"]}`
+		req := httptest.NewRequest("POST", "/api/v1/products/export?as_of="+asOf.Format("2006-01-02T15:04:05Z"), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+		testutils.AssertEqual(t, true, bytes.Contains(w.Body.Bytes(), []byte(`"lifecycle-supported"`)), "Supported at as_of")
+		testutils.AssertEqual(t, false, bytes.Contains(w.Body.Bytes(), []byte(`"lifecycle-unsupported"`)), "Not yet unsupported at as_of")
+	})
+}
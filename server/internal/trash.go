@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-fuego/fuego"
+	"gorm.io/gorm"
+)
+
+// DefaultTrashRetention is how long deleted nodes are kept in the trash
+// before they are purged.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// WithTrashRetention sets how long deleted nodes are kept in the trash. With
+// 0 they are kept until purged manually.
+func WithTrashRetention(retention time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.trashRetention = retention
+	}
+}
+
+// TrashRetentionFromEnv reads the TRASH_RETENTION_DAYS environment variable
+// and falls back to the default if it is unset.
+func TrashRetentionFromEnv() (time.Duration, error) {
+	value := os.Getenv("TRASH_RETENTION_DAYS")
+	if value == "" {
+		return DefaultTrashRetention, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("TRASH_RETENTION_DAYS must be a non-negative number of days, got %q", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// ListTrash returns the deleted vendors, product families, products,
+// versions and version ranges, most recently deleted first.
+func (s *Service) ListTrash(ctx context.Context) ([]TrashItemDTO, error) {
+	entries, err := s.repo.GetTrash(ctx)
+	if err != nil {
+		return nil, fuego.InternalServerError{
+			Title: "Failed to list trash",
+			Err:   err,
+		}
+	}
+
+	items := make([]TrashItemDTO, len(entries))
+	for i, entry := range entries {
+		items[i] = TrashEntryToDTO(entry, s.trashRetention)
+	}
+
+	return items, nil
+}
+
+// getTrashedNode returns a node deleted by the user. Nodes that were only
+// deleted along with one of their ancestors cannot be restored or purged on
+// their own.
+func (s *Service) getTrashedNode(ctx context.Context, id string) (Node, error) {
+	node, err := s.repo.GetDeletedNodeByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Node{}, fuego.NotFoundError{
+				Title: "Trash item not found",
+			}
+		}
+		return Node{}, fuego.InternalServerError{
+			Title: "Failed to fetch trash item",
+			Err:   err,
+		}
+	}
+
+	if node.DeletionID != nil && *node.DeletionID != node.ID {
+		return Node{}, fuego.ConflictError{
+			Title:  "Deleted together with another node",
+			Detail: "Restore or purge the node with ID " + *node.DeletionID + " instead",
+		}
+	}
+
+	return node, nil
+}
+
+// RestoreFromTrash restores a deleted node with its descendants,
+// identification helpers and relationships. The parent of the node must
+// not be in the trash.
+func (s *Service) RestoreFromTrash(ctx context.Context, id string) error {
+	node, err := s.getTrashedNode(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if node.ParentID != nil {
+		if _, err := s.repo.GetNodeByID(ctx, *node.ParentID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fuego.ConflictError{
+					Title:  "Parent is deleted",
+					Detail: "Restore the parent with ID " + *node.ParentID + " first",
+				}
+			}
+			return fuego.InternalServerError{
+				Title: "Failed to fetch parent",
+				Err:   err,
+			}
+		}
+	}
+
+	if node.Category == ProductVersion && node.PredecessorID != nil && node.ParentID != nil {
+		if err := s.checkRestoredPredecessor(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.RestoreNode(ctx, node.ID); err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to restore trash item",
+			Err:   err,
+		}
+	}
+
+	return nil
+}
+
+// PurgeFromTrash permanently deletes a node in the trash with everything
+// deleted along with it.
+func (s *Service) PurgeFromTrash(ctx context.Context, id string) error {
+	node, err := s.getTrashedNode(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.PurgeNode(ctx, node.ID); err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to purge trash item",
+			Err:   err,
+		}
+	}
+
+	return nil
+}
+
+// PurgeExpiredTrash permanently deletes the nodes that were deleted longer
+// than the trash retention ago and returns their number. Nothing is purged
+// if the retention is 0.
+func (s *Service) PurgeExpiredTrash(ctx context.Context) (int, error) {
+	if s.trashRetention <= 0 {
+		return 0, nil
+	}
+
+	entries, err := s.repo.GetTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	before := time.Now().Add(-s.trashRetention)
+	purged := 0
+	for _, entry := range entries {
+		if entry.Node.DeletedAt.Time.After(before) {
+			continue
+		}
+		if err := s.repo.PurgeNode(ctx, entry.Node.ID); err != nil {
+			// Already purged together with a deleted ancestor
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
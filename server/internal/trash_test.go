@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"product-database-api/testutils"
+	"testing"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestTrash(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	repo := NewRepository(db)
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware"})
+	testutils.AssertNoError(t, err, "Should create product")
+	version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	_, err = svc.CreateIdentificationHelper(ctx, CreateIdentificationHelperDTO{
+		ProductVersionID: version.ID,
+		Category:         "purl",
+		Metadata:         `{"purl": "pkg:generic/acme/router@1.0"}`,
+	})
+	testutils.AssertNoError(t, err, "Should create identification helper")
+
+	other, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Globex"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	agent, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Agent", VendorID: other.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create product")
+	agentVersion, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0", ProductID: agent.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	err = svc.CreateRelationship(ctx, CreateRelationshipDTO{
+		Category:      string(InstalledOn),
+		SourceNodeIDs: []string{agentVersion.ID},
+		TargetNodeIDs: []string{version.ID},
+	})
+	testutils.AssertNoError(t, err, "Should create relationship")
+
+	relationshipCount := func(t *testing.T) int {
+		relationships, err := repo.GetRelationshipsByNodeIDs(ctx, []string{agentVersion.ID})
+		testutils.AssertNoError(t, err, "Should fetch relationships")
+		return len(relationships)
+	}
+	lookupCount := func(t *testing.T) int {
+		result, err := svc.Lookup(ctx, "pkg:generic/acme/router@1.0", "")
+		testutils.AssertNoError(t, err, "Lookup should succeed")
+		return len(result.Matches)
+	}
+	isNotFound := func(err error) bool {
+		var notFound fuego.NotFoundError
+		return errors.As(err, &notFound)
+	}
+	isConflict := func(err error) bool {
+		var conflict fuego.ConflictError
+		return errors.As(err, &conflict)
+	}
+
+	t.Run("DeleteMovesSubtreeToTrash", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteVendor(ctx, vendor.ID), "Should delete vendor")
+
+		_, err := svc.GetVendorByID(ctx, vendor.ID)
+		testutils.AssertEqual(t, true, isNotFound(err), "Vendor is hidden")
+		_, err = svc.GetProductByID(ctx, product.ID)
+		testutils.AssertEqual(t, true, isNotFound(err), "Product is hidden")
+		testutils.AssertEqual(t, 0, lookupCount(t), "Identifiers are not found")
+		testutils.AssertEqual(t, 0, relationshipCount(t), "Relationships to the version are hidden")
+
+		items, err := svc.ListTrash(ctx)
+		testutils.AssertNoError(t, err, "Should list trash")
+		testutils.AssertCount(t, 1, len(items), "Trash items")
+		testutils.AssertEqual(t, vendor.ID, items[0].ID, "Deleted vendor")
+		testutils.AssertEqual(t, int64(3), items[0].NodeCount, "Vendor, product and version")
+		testutils.AssertEqual(t, true, items[0].PurgeAt.Sub(items[0].DeletedAt) == DefaultTrashRetention, "Purge time")
+	})
+
+	t.Run("OnlyDeletedNodesCanBeRestored", func(t *testing.T) {
+		testutils.AssertEqual(t, true, isConflict(svc.RestoreFromTrash(ctx, product.ID)), "Product was deleted with the vendor")
+		testutils.AssertEqual(t, true, isNotFound(svc.RestoreFromTrash(ctx, other.ID)), "Vendor is not deleted")
+	})
+
+	t.Run("RestoreReattachesEverything", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, vendor.ID), "Should restore vendor")
+
+		versions, err := svc.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Product is restored")
+		testutils.AssertCount(t, 1, len(versions), "Versions are restored")
+		testutils.AssertEqual(t, 1, lookupCount(t), "Identifiers are indexed again")
+		testutils.AssertEqual(t, 1, relationshipCount(t), "Relationships are restored")
+
+		items, err := svc.ListTrash(ctx)
+		testutils.AssertNoError(t, err, "Should list trash")
+		testutils.AssertCount(t, 0, len(items), "Trash is empty")
+	})
+
+	t.Run("RestoreNeedsParent", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteProduct(ctx, product.ID), "Should delete product")
+		testutils.AssertNoError(t, svc.DeleteVendor(ctx, vendor.ID), "Should delete vendor")
+		testutils.AssertNoError(t, svc.DeleteProductVersion(ctx, agentVersion.ID), "Should delete other version")
+
+		testutils.AssertEqual(t, true, isConflict(svc.RestoreFromTrash(ctx, product.ID)), "Vendor is deleted")
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, vendor.ID), "Should restore vendor")
+		_, err := svc.GetProductByID(ctx, product.ID)
+		testutils.AssertEqual(t, true, isNotFound(err), "Separately deleted product stays deleted")
+
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, product.ID), "Should restore product")
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, agentVersion.ID), "Should restore other version")
+		testutils.AssertEqual(t, 1, relationshipCount(t), "Relationship is restored with both versions")
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteVendor(ctx, vendor.ID), "Should delete vendor")
+		testutils.AssertNoError(t, svc.PurgeFromTrash(ctx, vendor.ID), "Should purge vendor")
+
+		var count int64
+		db.Unscoped().Model(&testutils.Node{}).Where("id IN ?", []string{vendor.ID, product.ID, version.ID}).Count(&count)
+		testutils.AssertEqual(t, int64(0), count, "Nodes are deleted permanently")
+		db.Unscoped().Model(&testutils.Relationship{}).Count(&count)
+		testutils.AssertEqual(t, int64(0), count, "Relationships are deleted permanently")
+		testutils.AssertEqual(t, true, isNotFound(svc.RestoreFromTrash(ctx, vendor.ID)), "Vendor cannot be restored")
+	})
+
+	t.Run("PurgeExpired", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteProduct(ctx, agent.ID), "Should delete product")
+
+		purged, err := NewService(repo, WithTrashRetention(0)).PurgeExpiredTrash(ctx)
+		testutils.AssertNoError(t, err, "Should purge")
+		testutils.AssertEqual(t, 0, purged, "Retention 0 keeps the trash")
+
+		purged, err = svc.PurgeExpiredTrash(ctx)
+		testutils.AssertNoError(t, err, "Should purge")
+		testutils.AssertEqual(t, 0, purged, "Recently deleted")
+
+		purged, err = NewService(repo, WithTrashRetention(time.Nanosecond)).PurgeExpiredTrash(ctx)
+		testutils.AssertNoError(t, err, "Should purge")
+		testutils.AssertEqual(t, 1, purged, "Expired")
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteVendor(ctx, other.ID), "Should delete vendor")
+
+		app := fuego.NewServer()
+		RegisterRoutes(app, svc)
+
+		req := httptest.NewRequest("GET", "/api/v1/trash", nil)
+		w := httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code")
+
+		var items []TrashItemDTO
+		testutils.AssertNoError(t, json.Unmarshal(w.Body.Bytes(), &items), "Should decode trash")
+		testutils.AssertCount(t, 1, len(items), "Trash items")
+		testutils.AssertEqual(t, other.ID, items[0].ID, "Deleted vendor")
+
+		req = httptest.NewRequest("POST", "/api/v1/trash/"+other.ID+"/restore", nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusOK, w.Code, "Status code of restore")
+
+		req = httptest.NewRequest("DELETE", "/api/v1/trash/"+other.ID, nil)
+		w = httptest.NewRecorder()
+		app.Mux.ServeHTTP(w, req)
+		testutils.AssertEqual(t, http.StatusNotFound, w.Code, "Status code of purging a restored vendor")
+	})
+}
+
+func TestTrashReferences(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	family, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Networking"})
+	testutils.AssertNoError(t, err, "Should create family")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Router", VendorID: vendor.ID, Type: "hardware", FamilyID: &family.ID})
+	testutils.AssertNoError(t, err, "Should create product in family")
+	v1, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "1.0", ProductID: product.ID})
+	testutils.AssertNoError(t, err, "Should create version")
+	v2, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: "2.0", ProductID: product.ID, PredecessorID: &v1.ID})
+	testutils.AssertNoError(t, err, "Should create successor")
+
+	familyProducts := func(t *testing.T) int64 {
+		_, total, err := svc.QueryProducts(ctx, ListQuery{FamilyID: family.ID})
+		testutils.AssertNoError(t, err, "Should query products")
+		return total
+	}
+
+	t.Run("DeleteFamilyWithProducts", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteProductFamily(ctx, family.ID), "Should delete family")
+
+		found, err := svc.GetProductByID(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Product is kept")
+		testutils.AssertEqual(t, true, found.FamilyID == nil, "Family is absent")
+		testutils.AssertEqual(t, int64(0), familyProducts(t), "Filter does not match the deleted family")
+
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, family.ID), "Should restore family")
+		found, err = svc.GetProductByID(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should get product")
+		testutils.AssertEqual(t, family.ID, *found.FamilyID, "Family is set again")
+		testutils.AssertEqual(t, int64(1), familyProducts(t), "Filter matches the restored family")
+	})
+
+	t.Run("DeletePredecessor", func(t *testing.T) {
+		testutils.AssertNoError(t, svc.DeleteProductVersion(ctx, v1.ID), "Should delete predecessor")
+
+		var stored testutils.Node
+		testutils.AssertNoError(t, db.First(&stored, "id = ?", v2.ID).Error, "Should load version")
+		testutils.AssertEqual(t, true, stored.PredecessorID == nil, "Predecessor is cleared")
+
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, v1.ID), "Should restore predecessor")
+		version, err := svc.GetProductVersionByID(ctx, v2.ID)
+		testutils.AssertNoError(t, err, "Should get version")
+		testutils.AssertEqual(t, v1.ID, *version.PredecessorID, "Predecessor is set again")
+	})
+
+	t.Run("ChangedReferenceIsKept", func(t *testing.T) {
+		other, err := svc.CreateProductFamily(ctx, CreateProductFamilyDTO{Name: "Security"})
+		testutils.AssertNoError(t, err, "Should create family")
+		testutils.AssertNoError(t, svc.DeleteProductFamily(ctx, family.ID), "Should delete family")
+		_, err = svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{FamilyID: &other.ID})
+		testutils.AssertNoError(t, err, "Should move product")
+
+		testutils.AssertNoError(t, svc.RestoreFromTrash(ctx, family.ID), "Should restore family")
+		found, err := svc.GetProductByID(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should get product")
+		testutils.AssertEqual(t, other.ID, *found.FamilyID, "New family is kept")
+	})
+}
+
+func TestTrashRetentionFromEnv(t *testing.T) {
+	defer os.Unsetenv("TRASH_RETENTION_DAYS")
+
+	retention, err := TrashRetentionFromEnv()
+	testutils.AssertNoError(t, err, "Unset")
+	testutils.AssertEqual(t, DefaultTrashRetention, retention, "Default")
+
+	os.Setenv("TRASH_RETENTION_DAYS", "7")
+	retention, err = TrashRetentionFromEnv()
+	testutils.AssertNoError(t, err, "Days")
+	testutils.AssertEqual(t, 7*24*time.Hour, retention, "Retention")
+
+	os.Setenv("TRASH_RETENTION_DAYS", "-1")
+	_, err = TrashRetentionFromEnv()
+	testutils.AssertError(t, err, "Negative days")
+}
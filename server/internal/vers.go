@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// versGenericScheme is used for version ranges that do not belong to a
+// specific package ecosystem.
+const versGenericScheme = "generic"
+
+// versConstraint is a single comparator and version of a vers expression,
+// e.g. "<4.2.1". The comparator "*" matches every version.
+type versConstraint struct {
+	Comparator string
+	Version    string
+}
+
+var versComparators = []string{"<=", ">=", "!=", "<", ">", "="}
+
+// parseVers parses a vers expression such as "vers:generic/>=1.0|<4.2.1" into
+// its scheme and constraints. The constraints are sorted by version.
+func parseVers(vers string) (string, []versConstraint, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(vers), "vers:")
+	if !ok {
+		return "", nil, fmt.Errorf("vers must start with 'vers:'")
+	}
+
+	scheme, rawConstraints, ok := strings.Cut(rest, "/")
+	if !ok || scheme == "" {
+		return "", nil, fmt.Errorf("vers must contain a versioning scheme")
+	}
+	scheme = strings.ToLower(scheme)
+
+	rawConstraints = strings.ReplaceAll(rawConstraints, " ", "")
+	if rawConstraints == "" {
+		return "", nil, fmt.Errorf("vers must contain at least one constraint")
+	}
+	if rawConstraints == "*" {
+		return scheme, []versConstraint{{Comparator: "*"}}, nil
+	}
+
+	var constraints []versConstraint
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(rawConstraints, "|") {
+		constraint := versConstraint{Comparator: "="}
+		for _, comparator := range versComparators {
+			if strings.HasPrefix(raw, comparator) {
+				constraint.Comparator = comparator
+				raw = raw[len(comparator):]
+				break
+			}
+		}
+
+		version, err := url.PathUnescape(raw)
+		if err != nil || version == "" || version == "*" {
+			return "", nil, fmt.Errorf("invalid vers constraint %q", raw)
+		}
+		if seen[version] {
+			return "", nil, fmt.Errorf("version %q is used in more than one constraint", version)
+		}
+		seen[version] = true
+
+		constraint.Version = version
+		constraints = append(constraints, constraint)
+	}
+
+	sortVersConstraints(constraints, compareVersions)
+
+	return scheme, constraints, nil
+}
+
+// sortVersConstraints sorts constraints by their versions in the given
+// version order.
+func sortVersConstraints(constraints []versConstraint, compare func(a, b string) int) {
+	sort.SliceStable(constraints, func(i, j int) bool {
+		return compare(constraints[i].Version, constraints[j].Version) < 0
+	})
+}
+
+// formatVers renders constraints as a normalized vers expression.
+func formatVers(scheme string, constraints []versConstraint) string {
+	parts := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		if constraint.Comparator == "*" {
+			parts[i] = "*"
+			continue
+		}
+
+		comparator := constraint.Comparator
+		if comparator == "=" {
+			comparator = ""
+		}
+		parts[i] = comparator + url.PathEscape(constraint.Version)
+	}
+	return "vers:" + scheme + "/" + strings.Join(parts, "|")
+}
+
+// versFromBounds builds a vers expression from an optional lower and upper
+// bound, which must be in order by compare.
+func versFromBounds(scheme, min, max string, minInclusive, maxInclusive bool, compare func(a, b string) int) (string, error) {
+	if min == "" && max == "" {
+		return "", fmt.Errorf("at least one bound is required")
+	}
+	if scheme == "" {
+		scheme = versGenericScheme
+	}
+
+	var constraints []versConstraint
+	if min != "" {
+		comparator := ">"
+		if minInclusive {
+			comparator = ">="
+		}
+		constraints = append(constraints, versConstraint{Comparator: comparator, Version: min})
+	}
+	if max != "" {
+		if min != "" && compare(min, max) > 0 {
+			return "", fmt.Errorf("lower bound %q is greater than upper bound %q", min, max)
+		}
+		comparator := "<"
+		if maxInclusive {
+			comparator = "<="
+		}
+		constraints = append(constraints, versConstraint{Comparator: comparator, Version: max})
+	}
+
+	return formatVers(scheme, constraints), nil
+}
+
+// versBounds returns the lower and upper bound of constraints that describe a
+// single interval. ok is false for anything more complex.
+func versBounds(constraints []versConstraint) (min, max *versConstraint, ok bool) {
+	switch len(constraints) {
+	case 1:
+		switch constraints[0].Comparator {
+		case ">", ">=":
+			return &constraints[0], nil, true
+		case "<", "<=":
+			return nil, &constraints[0], true
+		}
+	case 2:
+		lower, upper := constraints[0].Comparator, constraints[1].Comparator
+		if (lower == ">" || lower == ">=") && (upper == "<" || upper == "<=") {
+			return &constraints[0], &constraints[1], true
+		}
+	}
+	return nil, nil, false
+}
+
+// versContains reports whether version is part of the range described by the
+// constraints sorted by compare, following the containment algorithm of the
+// vers specification.
+func versContains(constraints []versConstraint, version string, compare func(a, b string) int) bool {
+	if len(constraints) == 1 && constraints[0].Comparator == "*" {
+		return true
+	}
+
+	var ranges []versConstraint
+	hasNotEqual := false
+	for _, constraint := range constraints {
+		equal := compare(version, constraint.Version) == 0
+
+		switch constraint.Comparator {
+		case "=":
+			if equal {
+				return true
+			}
+		case "!=":
+			if equal {
+				return false
+			}
+			hasNotEqual = true
+		case "<=", ">=":
+			if equal {
+				return true
+			}
+			ranges = append(ranges, constraint)
+		default:
+			ranges = append(ranges, constraint)
+		}
+	}
+
+	if len(ranges) == 0 {
+		return hasNotEqual
+	}
+	if len(ranges) == 1 {
+		return versSatisfies(version, ranges[0], compare)
+	}
+
+	for i := 0; i < len(ranges)-1; i++ {
+		current, next := ranges[i], ranges[i+1]
+
+		if i == 0 && isVersUpperBound(current) && versSatisfies(version, current, compare) {
+			return true
+		}
+		if i == len(ranges)-2 && !isVersUpperBound(next) && versSatisfies(version, next, compare) {
+			return true
+		}
+		if !isVersUpperBound(current) && isVersUpperBound(next) &&
+			versSatisfies(version, current, compare) && versSatisfies(version, next, compare) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isVersUpperBound(constraint versConstraint) bool {
+	return constraint.Comparator == "<" || constraint.Comparator == "<="
+}
+
+func versSatisfies(version string, constraint versConstraint, compare func(a, b string) int) bool {
+	c := compare(version, constraint.Version)
+	switch constraint.Comparator {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	}
+	return false
+}
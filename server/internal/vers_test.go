@@ -0,0 +1,91 @@
+package internal
+
+import "testing"
+
+func TestParseVers(t *testing.T) {
+	tests := []struct {
+		input      string
+		normalized string
+		valid      bool
+	}{
+		{"vers:generic/<4.2.1", "vers:generic/<4.2.1", true},
+		{"vers:npm/<2.0.0|>=1.0.0", "vers:npm/>=1.0.0|<2.0.0", true},
+		{"vers:Generic/ 1.0 | 1.1 ", "vers:generic/1.0|1.1", true},
+		{"vers:generic/*", "vers:generic/*", true},
+		{"vers:generic/!=1.5|>=1.0|<2.0", "vers:generic/>=1.0|!=1.5|<2.0", true},
+		{"<4.2.1", "", false},
+		{"vers:/<1.0", "", false},
+		{"vers:generic/", "", false},
+		{"vers:generic/<1.0|>1.0", "", false},
+		{"vers:generic/>=", "", false},
+	}
+
+	for _, tt := range tests {
+		scheme, constraints, err := parseVers(tt.input)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseVers(%q) error = %v, expected valid = %v", tt.input, err, tt.valid)
+			continue
+		}
+		if tt.valid {
+			if got := formatVers(scheme, constraints); got != tt.normalized {
+				t.Errorf("formatVers(parseVers(%q)) = %q, expected %q", tt.input, got, tt.normalized)
+			}
+		}
+	}
+}
+
+func TestVersContains(t *testing.T) {
+	tests := []struct {
+		vers     string
+		version  string
+		expected bool
+	}{
+		{"vers:generic/<4.2.1", "4.2.0", true},
+		{"vers:generic/<4.2.1", "4.2.1", false},
+		{"vers:generic/<=4.2.1", "4.2.1", true},
+		{"vers:generic/>=1.0|<2.0", "1.0", true},
+		{"vers:generic/>=1.0|<2.0", "1.10", true},
+		{"vers:generic/>=1.0|<2.0", "2.0", false},
+		{"vers:generic/>=1.0|<2.0", "0.9", false},
+		{"vers:generic/>=1.0|<2.0|>=3.0", "2.5", false},
+		{"vers:generic/>=1.0|<2.0|>=3.0", "3.1", true},
+		{"vers:generic/<1.0|>2.0", "1.5", false},
+		{"vers:generic/<1.0|>2.0", "0.5", true},
+		{"vers:generic/<1.0|>2.0", "2.5", true},
+		{"vers:generic/1.0|1.1", "1.1", true},
+		{"vers:generic/1.0|1.1", "1.2", false},
+		{"vers:generic/>=1.0|!=1.5|<2.0", "1.5", false},
+		{"vers:generic/>=1.0|!=1.5|<2.0", "1.6", true},
+		{"vers:generic/!=1.5", "1.6", true},
+		{"vers:generic/*", "99", true},
+	}
+
+	for _, tt := range tests {
+		_, constraints, err := parseVers(tt.vers)
+		if err != nil {
+			t.Fatalf("parseVers(%q) failed: %v", tt.vers, err)
+		}
+		if got := versContains(constraints, tt.version, compareVersions); got != tt.expected {
+			t.Errorf("versContains(%q, %q) = %v, expected %v", tt.vers, tt.version, got, tt.expected)
+		}
+	}
+}
+
+func TestVersFromBounds(t *testing.T) {
+	vers, err := versFromBounds("", "1.0", "4.2.1", true, false, compareVersions)
+	if err != nil || vers != "vers:generic/>=1.0|<4.2.1" {
+		t.Errorf("versFromBounds = %q, %v", vers, err)
+	}
+
+	vers, err = versFromBounds("npm", "", "2.0.0", true, true, compareVersions)
+	if err != nil || vers != "vers:npm/<=2.0.0" {
+		t.Errorf("versFromBounds = %q, %v", vers, err)
+	}
+
+	if _, err := versFromBounds("", "2.0", "1.0", true, false, compareVersions); err == nil {
+		t.Error("Expected error for inverted bounds")
+	}
+	if _, err := versFromBounds("", "", "", true, false, compareVersions); err == nil {
+		t.Error("Expected error without bounds")
+	}
+}
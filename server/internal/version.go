@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// compareVersions orders version names the way humans expect: numeric parts
+// are compared by value and a pre-release suffix ("1.0.0-rc1") sorts before
+// the release it belongs to. It returns -1, 0 or 1.
+func compareVersions(a, b string) int {
+	coreA, preA := splitPreRelease(a)
+	coreB, preB := splitPreRelease(b)
+
+	if c := compareNatural(coreA, coreB); c != 0 {
+		return c
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return compareNatural(preA, preB)
+}
+
+// splitPreRelease strips a leading "v" and build metadata and separates the
+// pre-release part of a version name.
+func splitPreRelease(version string) (string, string) {
+	version = strings.TrimSpace(version)
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') && unicode.IsDigit(rune(version[1])) {
+		version = version[1:]
+	}
+	if i := strings.IndexByte(version, '+'); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.IndexByte(version, '-'); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+// compareNatural compares two strings segment by segment, treating runs of
+// digits as numbers and everything else case-insensitively.
+func compareNatural(a, b string) int {
+	segmentsA := splitSegments(a)
+	segmentsB := splitSegments(b)
+
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		if c := compareSegment(segmentsA[i], segmentsB[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(segmentsA) < len(segmentsB):
+		return -1
+	case len(segmentsA) > len(segmentsB):
+		return 1
+	}
+	return 0
+}
+
+func compareSegment(a, b string) int {
+	numA, errA := strconv.ParseUint(a, 10, 64)
+	numB, errB := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case errA == nil && errB == nil:
+		if numA < numB {
+			return -1
+		}
+		if numA > numB {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		// Numbers sort before words, e.g. "1.0.1" < "1.0.beta"
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// splitSegments splits a version into runs of digits and runs of letters,
+// dropping separators such as dots, dashes and underscores.
+func splitSegments(version string) []string {
+	var segments []string
+	var current strings.Builder
+	currentIsDigit := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range version {
+		isDigit := unicode.IsDigit(r)
+		if !isDigit && !unicode.IsLetter(r) {
+			flush()
+			continue
+		}
+		if current.Len() > 0 && isDigit != currentIsDigit {
+			flush()
+		}
+		currentIsDigit = isDigit
+		current.WriteRune(r)
+	}
+	flush()
+
+	return segments
+}
@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/go-fuego/fuego"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Version ranges are stored as product_version_range nodes below a product.
+// The node name holds the normalized vers expression.
+
+func (s *Service) CreateVersionRange(ctx context.Context, create CreateVersionRangeDTO) (VersionRangeDTO, error) {
+	productNode, err := s.repo.GetNodeByID(ctx, create.ProductID)
+	if err != nil || productNode.Category != ProductName {
+		return VersionRangeDTO{}, fuego.BadRequestError{
+			Title: "Invalid product node ID",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   "CreateVersionRangeDTO.ProductID",
+					Reason: "Product ID must be a valid product ID",
+				},
+			},
+		}
+	}
+
+	vers, err := normalizeVersionRange("CreateVersionRangeDTO", create.Vers, create.Scheme, create.Min, create.Max, create.MinInclusive, create.MaxInclusive, productVersionOrder(productNode))
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	node := Node{
+		ID:          uuid.New().String(),
+		Name:        vers,
+		Description: create.Description,
+		Category:    ProductVersionRange,
+		ParentID:    &productNode.ID,
+	}
+
+	createdNode, err := s.repo.CreateNode(ctx, node)
+	if err != nil {
+		return VersionRangeDTO{}, fuego.InternalServerError{
+			Title: "Failed to create version range",
+			Err:   err,
+		}
+	}
+
+	return NodeToVersionRangeDTO(createdNode), nil
+}
+
+func (s *Service) GetVersionRangeByID(ctx context.Context, id string) (VersionRangeDTO, error) {
+	versionRange, err := s.getVersionRangeNode(ctx, id)
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	return NodeToVersionRangeDTO(versionRange), nil
+}
+
+func (s *Service) ListProductVersionRanges(ctx context.Context, productID string) ([]VersionRangeDTO, error) {
+	product, err := s.repo.GetNodeByID(ctx, productID, WithChildren())
+	notFoundError := fuego.NotFoundError{
+		Title: "Product not found",
+		Err:   nil,
+	}
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFoundError
+		}
+		return nil, fuego.InternalServerError{
+			Title: "Failed to fetch product",
+			Err:   err,
+		}
+	}
+
+	if product.Category != ProductName {
+		return nil, notFoundError
+	}
+
+	ranges := make([]VersionRangeDTO, 0)
+	for _, child := range product.Children {
+		if child.Category == ProductVersionRange {
+			ranges = append(ranges, NodeToVersionRangeDTO(child))
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Vers < ranges[j].Vers
+	})
+
+	return ranges, nil
+}
+
+func (s *Service) UpdateVersionRange(ctx context.Context, id string, update UpdateVersionRangeDTO) (VersionRangeDTO, error) {
+	versionRange, err := s.getVersionRangeNode(ctx, id)
+	if err != nil {
+		return VersionRangeDTO{}, err
+	}
+
+	if update.Vers != nil || update.Min != nil || update.Max != nil {
+		var product Node
+		if versionRange.ParentID != nil {
+			if product, err = s.repo.GetNodeByID(ctx, *versionRange.ParentID); err != nil {
+				return VersionRangeDTO{}, fuego.InternalServerError{
+					Title: "Failed to fetch product",
+					Err:   err,
+				}
+			}
+		}
+		vers, err := normalizeVersionRange("UpdateVersionRangeDTO", update.Vers, update.Scheme, update.Min, update.Max, update.MinInclusive, update.MaxInclusive, productVersionOrder(product))
+		if err != nil {
+			return VersionRangeDTO{}, err
+		}
+		versionRange.Name = vers
+	}
+
+	if update.Description != nil {
+		versionRange.Description = *update.Description
+	}
+
+	if err := s.repo.UpdateNode(ctx, versionRange); err != nil {
+		return VersionRangeDTO{}, fuego.InternalServerError{
+			Title: "Failed to update version range",
+			Err:   err,
+		}
+	}
+
+	return NodeToVersionRangeDTO(versionRange), nil
+}
+
+func (s *Service) DeleteVersionRange(ctx context.Context, id string) error {
+	versionRange, err := s.getVersionRangeNode(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteNode(ctx, versionRange.ID); err != nil {
+		return fuego.InternalServerError{
+			Title: "Failed to delete version range",
+			Err:   err,
+		}
+	}
+
+	return nil
+}
+
+// ResolveVersionRange returns the concrete versions of the product that are
+// currently covered by the range.
+func (s *Service) ResolveVersionRange(ctx context.Context, id string) ([]ProductVersionDTO, error) {
+	versionRange, err := s.getVersionRangeNode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if versionRange.ParentID == nil {
+		return []ProductVersionDTO{}, nil
+	}
+
+	product, err := s.repo.GetNodeByID(ctx, *versionRange.ParentID, WithChildren())
+	if err != nil {
+		return nil, fuego.InternalServerError{
+			Title: "Failed to fetch product",
+			Err:   err,
+		}
+	}
+	versions, _ := productVersionDTOs(product, versionsOf(product), s.now())
+
+	_, constraints, err := parseVers(versionRange.Name)
+	if err != nil {
+		return nil, fuego.InternalServerError{
+			Title: "Stored version range is invalid",
+			Err:   err,
+		}
+	}
+
+	// Versions are compared in the version order of the product
+	order := productVersionOrder(product)
+	sortVersConstraints(constraints, order.compare)
+
+	covered := make([]ProductVersionDTO, 0, len(versions))
+	for _, version := range versions {
+		if versContains(constraints, version.Name, order.compare) {
+			covered = append(covered, version)
+		}
+	}
+
+	// Versions are already sorted by the version scheme of the product
+	return covered, nil
+}
+
+func (s *Service) getVersionRangeNode(ctx context.Context, id string) (Node, error) {
+	versionRange, err := s.repo.GetNodeByID(ctx, id)
+	notFoundError := fuego.NotFoundError{
+		Title: "Version range not found",
+	}
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Node{}, notFoundError
+		}
+		return Node{}, fuego.InternalServerError{
+			Title: "Failed to fetch version range",
+			Err:   err,
+		}
+	}
+
+	if versionRange.Category != ProductVersionRange {
+		return Node{}, notFoundError
+	}
+
+	return versionRange, nil
+}
+
+// normalizeVersionRange turns either a vers expression or a lower and upper
+// bound into a normalized vers expression. Lower bounds are inclusive and
+// upper bounds exclusive unless stated otherwise. Bounds must be in the
+// version order of the product.
+func normalizeVersionRange(dtoName string, vers *string, scheme string, min, max *string, minInclusive, maxInclusive *bool, order versionOrder) (string, error) {
+	invalid := func(field, reason string, err error) error {
+		return fuego.BadRequestError{
+			Title: "Invalid version range",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   dtoName + "." + field,
+					Reason: reason,
+				},
+			},
+		}
+	}
+
+	if vers != nil {
+		if min != nil || max != nil {
+			return "", invalid("Vers", "Either vers or min/max can be set, not both", nil)
+		}
+		parsedScheme, constraints, err := parseVers(*vers)
+		if err != nil {
+			return "", invalid("Vers", "Vers must be a valid vers expression: "+err.Error(), err)
+		}
+		return formatVers(parsedScheme, constraints), nil
+	}
+
+	var lower, upper string
+	if min != nil {
+		lower = *min
+	}
+	if max != nil {
+		upper = *max
+	}
+
+	includeLower := minInclusive == nil || *minInclusive
+	includeUpper := maxInclusive != nil && *maxInclusive
+
+	result, err := versFromBounds(scheme, lower, upper, includeLower, includeUpper, order.compare)
+	if err != nil {
+		return "", invalid("Min", "Vers or min and max must describe a valid range: "+err.Error(), err)
+	}
+	return result, nil
+}
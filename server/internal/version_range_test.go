@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"product-database-api/testutils"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestVersionRanges(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{Name: "Gateway", VendorID: vendor.ID, Type: "software"})
+	testutils.AssertNoError(t, err, "Should create product")
+	for _, name := range []string{"1.0.0", "4.2.0", "4.2.1", "4.10.0"} {
+		_, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version")
+	}
+
+	stringPtr := func(s string) *string { return &s }
+
+	t.Run("CreateFromBounds", func(t *testing.T) {
+		versionRange, err := svc.CreateVersionRange(ctx, CreateVersionRangeDTO{
+			ProductID: product.ID,
+			Min:       stringPtr("4.2.0"),
+			Max:       stringPtr("4.10.0"),
+		})
+		testutils.AssertNoError(t, err, "Should create version range")
+		testutils.AssertEqual(t, "vers:generic/>=4.2.0|<4.10.0", versionRange.Vers, "Vers from bounds")
+		testutils.AssertEqual(t, "4.2.0", *versionRange.Min, "Min")
+		testutils.AssertEqual(t, true, *versionRange.MinInclusive, "Min is inclusive by default")
+		testutils.AssertEqual(t, false, *versionRange.MaxInclusive, "Max is exclusive by default")
+
+		versions, err := svc.ResolveVersionRange(ctx, versionRange.ID)
+		testutils.AssertNoError(t, err, "Should resolve version range")
+		testutils.AssertCount(t, 2, len(versions), "Covered versions")
+		testutils.AssertEqual(t, "4.2.0", versions[0].Name, "First covered version")
+		testutils.AssertEqual(t, "4.2.1", versions[1].Name, "Second covered version")
+	})
+
+	t.Run("CreateFromVers", func(t *testing.T) {
+		versionRange, err := svc.CreateVersionRange(ctx, CreateVersionRangeDTO{
+			ProductID: product.ID,
+			Vers:      stringPtr("vers:generic/<4.2.1"),
+		})
+		testutils.AssertNoError(t, err, "Should create version range")
+		if versionRange.Min != nil {
+			t.Error("Open lower bound should not be set")
+		}
+
+		versions, err := svc.ResolveVersionRange(ctx, versionRange.ID)
+		testutils.AssertNoError(t, err, "Should resolve version range")
+		testutils.AssertCount(t, 2, len(versions), "Versions below 4.2.1")
+
+		updated, err := svc.UpdateVersionRange(ctx, versionRange.ID, UpdateVersionRangeDTO{Vers: stringPtr("vers:generic/4.10.0")})
+		testutils.AssertNoError(t, err, "Should update version range")
+		testutils.AssertEqual(t, "vers:generic/4.10.0", updated.Vers, "Updated vers")
+
+		err = svc.DeleteVersionRange(ctx, versionRange.ID)
+		testutils.AssertNoError(t, err, "Should delete version range")
+		_, err = svc.GetVersionRangeByID(ctx, versionRange.ID)
+		var notFound fuego.NotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("Expected NotFoundError after delete, got %v", err)
+		}
+	})
+
+	t.Run("InvalidRanges", func(t *testing.T) {
+		for _, create := range []CreateVersionRangeDTO{
+			{ProductID: product.ID},
+			{ProductID: product.ID, Vers: stringPtr("<4.2.1")},
+			{ProductID: product.ID, Vers: stringPtr("vers:generic/<1.0"), Max: stringPtr("1.0")},
+			{ProductID: product.ID, Min: stringPtr("2.0"), Max: stringPtr("1.0")},
+			{ProductID: vendor.ID, Max: stringPtr("1.0")},
+		} {
+			_, err := svc.CreateVersionRange(ctx, create)
+			var badRequest fuego.BadRequestError
+			if !errors.As(err, &badRequest) {
+				t.Errorf("Expected BadRequestError for %+v, got %v", create, err)
+			}
+		}
+	})
+
+	t.Run("RangesAreNotVersions", func(t *testing.T) {
+		versions, err := svc.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		testutils.AssertCount(t, 4, len(versions), "Ranges must not be listed as versions")
+
+		ranges, err := svc.ListProductVersionRanges(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list version ranges")
+		testutils.AssertCount(t, 1, len(ranges), "Version ranges")
+	})
+
+	t.Run("ExportAndImport", func(t *testing.T) {
+		result, err := svc.ExportCSAFProductTree(ctx, []string{product.ID})
+		testutils.AssertNoError(t, err, "Should export product tree")
+
+		ranges := csafBranchNames(result, "product_version_range")
+		testutils.AssertCount(t, 1, len(ranges), "Exported version ranges")
+		testutils.AssertEqual(t, "vers:generic/>=4.2.0|<4.10.0", ranges[0], "Range branch name")
+
+		// Importing the export into an empty database recreates the range
+		importDB := testutils.SetupTestDB(t)
+		defer testutils.CleanupTestDB(t, importDB)
+		importSvc := NewService(NewRepository(importDB))
+
+		var productTree map[string]interface{}
+		data, _ := json.Marshal(result["product_tree"])
+		testutils.AssertNoError(t, json.Unmarshal(data, &productTree), "Should decode product tree")
+
+		_, err = importSvc.ImportCSAFProductTree(ctx, productTree, false)
+		testutils.AssertNoError(t, err, "Should import product tree")
+
+		products, err := importSvc.ListProducts(ctx)
+		testutils.AssertNoError(t, err, "Should list products")
+		imported, err := importSvc.ListProductVersionRanges(ctx, products[0].ID)
+		testutils.AssertNoError(t, err, "Should list imported ranges")
+		testutils.AssertCount(t, 1, len(imported), "Imported version ranges")
+		testutils.AssertEqual(t, ranges[0], imported[0].Vers, "Imported vers")
+	})
+}
@@ -0,0 +1,444 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-fuego/fuego"
+)
+
+// VersionScheme is how the versions of a product are ordered.
+type VersionScheme string
+
+const (
+	// VersionSchemeNatural orders versions with compareVersions. It is the
+	// scheme of products without one.
+	VersionSchemeNatural VersionScheme = "natural"
+	// VersionSchemeSemver orders versions by Semantic Versioning 2.0.0.
+	VersionSchemeSemver VersionScheme = "semver"
+	// VersionSchemeCalver orders calendar versions such as "2024.03.1" or
+	// "24.04", two-digit years are years of this century.
+	VersionSchemeCalver VersionScheme = "calver"
+	// VersionSchemeNumeric orders dotted numbers such as "10.0.19045.1234",
+	// missing trailing parts count as 0.
+	VersionSchemeNumeric VersionScheme = "numeric"
+	// VersionSchemeCustom orders versions by the capture groups of the
+	// version pattern of the product, e.g. "^R(\d+)(?:SP(\d+))?$".
+	VersionSchemeCustom VersionScheme = "custom"
+)
+
+var (
+	semverPattern  = regexp.MustCompile(`^[vV]?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+	calverPattern  = regexp.MustCompile(`^[vV]?(\d{4}|\d{2})((?:[._-]\d+)*)(?:[._+-]?([A-Za-z][0-9A-Za-z.-]*))?$`)
+	numericPattern = regexp.MustCompile(`^[vV]?\d+(?:\.\d+)*$`)
+)
+
+// versionOrder compares the versions of a product under its version scheme
+// and assigns them to release lines, e.g. the major version for semver.
+type versionOrder struct {
+	scheme  VersionScheme
+	pattern *regexp.Regexp
+}
+
+// newVersionOrder validates a version scheme and the pattern of a custom
+// scheme. An empty scheme is the natural order.
+func newVersionOrder(scheme VersionScheme, pattern string) (versionOrder, error) {
+	switch scheme {
+	case "", VersionSchemeNatural:
+		return versionOrder{scheme: VersionSchemeNatural}, nil
+	case VersionSchemeSemver, VersionSchemeCalver, VersionSchemeNumeric:
+		return versionOrder{scheme: scheme}, nil
+	case VersionSchemeCustom:
+		if pattern == "" {
+			return versionOrder{}, errors.New("the custom version scheme requires a version pattern")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return versionOrder{}, fmt.Errorf("invalid version pattern: %w", err)
+		}
+		if re.NumSubexp() == 0 {
+			return versionOrder{}, errors.New("the version pattern must contain at least one capture group")
+		}
+		return versionOrder{scheme: scheme, pattern: re}, nil
+	}
+	return versionOrder{}, fmt.Errorf("unknown version scheme %q", scheme)
+}
+
+// productVersionOrder returns the version order of a product. An invalid
+// scheme falls back to the natural order.
+func productVersionOrder(product Node) versionOrder {
+	order, err := newVersionOrder(product.VersionScheme, product.VersionPattern)
+	if err != nil {
+		return versionOrder{scheme: VersionSchemeNatural}
+	}
+	return order
+}
+
+// parsedVersion is a version split into the parts compared by a scheme.
+type parsedVersion struct {
+	parts      []string
+	preRelease []string
+	line       string
+}
+
+// parse splits a version under the scheme. Versions that do not follow the
+// scheme are not ok.
+func (o versionOrder) parse(version string) (parsedVersion, bool) {
+	version = strings.TrimSpace(version)
+
+	switch o.scheme {
+	case VersionSchemeSemver:
+		m := semverPattern.FindStringSubmatch(version)
+		if m == nil {
+			return parsedVersion{}, false
+		}
+		parsed := parsedVersion{parts: m[1:4], line: m[1]}
+		// Before 1.0.0 every minor version is a line of its own
+		if m[1] == "0" {
+			parsed.line = "0." + m[2]
+		}
+		if m[4] != "" {
+			parsed.preRelease = strings.Split(m[4], ".")
+		}
+		return parsed, true
+
+	case VersionSchemeCalver:
+		m := calverPattern.FindStringSubmatch(version)
+		if m == nil {
+			return parsedVersion{}, false
+		}
+		year := m[1]
+		if len(year) == 2 {
+			year = "20" + year
+		}
+		parsed := parsedVersion{parts: []string{year}, line: year}
+		parsed.parts = append(parsed.parts, strings.FieldsFunc(m[2], func(r rune) bool {
+			return r == '.' || r == '_' || r == '-'
+		})...)
+		if m[3] != "" {
+			parsed.preRelease = splitSegments(m[3])
+		}
+		return parsed, true
+
+	case VersionSchemeNumeric:
+		if !numericPattern.MatchString(version) {
+			return parsedVersion{}, false
+		}
+		parts := strings.Split(strings.TrimLeft(version, "vV"), ".")
+		// Trailing zeros do not change the version, "1.0" equals "1.0.0"
+		for len(parts) > 1 && isZeroPart(parts[len(parts)-1]) {
+			parts = parts[:len(parts)-1]
+		}
+		line := strings.TrimLeft(parts[0], "0")
+		if line == "" {
+			line = "0"
+		}
+		return parsedVersion{parts: parts, line: line}, true
+
+	case VersionSchemeCustom:
+		m := o.pattern.FindStringSubmatch(version)
+		if m == nil {
+			return parsedVersion{}, false
+		}
+		return parsedVersion{parts: m[1:], line: m[1]}, true
+	}
+
+	core, preRelease := splitPreRelease(version)
+	parsed := parsedVersion{parts: splitSegments(core)}
+	if len(parsed.parts) > 0 {
+		parsed.line = strings.ToLower(parsed.parts[0])
+	}
+	if preRelease != "" {
+		parsed.preRelease = splitSegments(preRelease)
+	}
+	return parsed, true
+}
+
+func isZeroPart(part string) bool {
+	return strings.Trim(part, "0") == ""
+}
+
+// compare orders two versions and returns -1, 0 or 1. Versions that do not
+// follow the scheme sort before all others and among each other naturally.
+func (o versionOrder) compare(a, b string) int {
+	if o.scheme == VersionSchemeNatural {
+		return compareVersions(a, b)
+	}
+
+	parsedA, okA := o.parse(a)
+	parsedB, okB := o.parse(b)
+	switch {
+	case !okA && !okB:
+		return compareVersions(a, b)
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := 0; i < len(parsedA.parts) || i < len(parsedB.parts); i++ {
+		var partA, partB string
+		if i < len(parsedA.parts) {
+			partA = parsedA.parts[i]
+		}
+		if i < len(parsedB.parts) {
+			partB = parsedB.parts[i]
+		}
+		if c := compareVersionPart(partA, partB); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(parsedA.preRelease) == 0 && len(parsedB.preRelease) == 0:
+		return 0
+	case len(parsedA.preRelease) == 0:
+		return 1
+	case len(parsedB.preRelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(parsedA.preRelease) && i < len(parsedB.preRelease); i++ {
+		if c := compareSegment(parsedA.preRelease[i], parsedB.preRelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(parsedA.preRelease), len(parsedB.preRelease))
+}
+
+// compareVersionPart compares two parts of parsed versions. A missing part,
+// e.g. an optional capture group, sorts before any other.
+func compareVersionPart(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	// Numbers of any length are compared by value
+	if isDigits(a) && isDigits(b) {
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if c := compareInts(len(a), len(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	}
+	return compareNatural(a, b)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// isPreRelease reports whether a version is a pre-release under the scheme.
+func (o versionOrder) isPreRelease(version string) bool {
+	parsed, ok := o.parse(version)
+	return ok && len(parsed.preRelease) > 0
+}
+
+// line returns the release line of a version, e.g. its major version. Versions
+// that do not follow the scheme are assigned to lines naturally.
+func (o versionOrder) line(version string) string {
+	if parsed, ok := o.parse(version); ok {
+		return parsed.line
+	}
+	return versionOrder{scheme: VersionSchemeNatural}.line(version)
+}
+
+// sortVersions sorts version nodes in ascending order.
+func (o versionOrder) sortVersions(versions []Node) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return o.compare(versions[i].Name, versions[j].Name) < 0
+	})
+}
+
+// latestVersions are the latest versions of a product overall and of each
+// of its release lines.
+type latestVersions struct {
+	// Latest is the ID of the latest version.
+	Latest string
+	// Lines are the IDs of the latest version of every release line, the
+	// newest line first.
+	Lines []string
+}
+
+// latest determines the latest versions of a product. Pre-releases are only
+// the latest if there is no release to choose instead.
+func (o versionOrder) latest(versions []Node) latestVersions {
+	sorted := append([]Node(nil), versions...)
+	o.sortVersions(sorted)
+
+	var result latestVersions
+	var latestIsPreRelease bool
+	lineIndex := make(map[string]int)
+	linePreRelease := make(map[string]bool)
+	for _, version := range sorted {
+		preRelease := o.isPreRelease(version.Name)
+
+		if result.Latest == "" || !preRelease || latestIsPreRelease {
+			result.Latest, latestIsPreRelease = version.ID, preRelease
+		}
+
+		line := o.line(version.Name)
+		i, ok := lineIndex[line]
+		if !ok {
+			lineIndex[line] = len(result.Lines)
+			result.Lines = append(result.Lines, version.ID)
+			linePreRelease[line] = preRelease
+			continue
+		}
+		if !preRelease || linePreRelease[line] {
+			result.Lines[i] = version.ID
+			linePreRelease[line] = preRelease
+		}
+	}
+
+	// Lines were added oldest first
+	for i, j := 0, len(result.Lines)-1; i < j; i, j = i+1, j-1 {
+		result.Lines[i], result.Lines[j] = result.Lines[j], result.Lines[i]
+	}
+	return result
+}
+
+// apply sets the latest flags of a version.
+func (l latestVersions) apply(version *ProductVersionDTO) {
+	version.IsLatest = version.ID == l.Latest
+	for _, id := range l.Lines {
+		if id == version.ID {
+			version.IsLatestInLine = true
+			return
+		}
+	}
+}
+
+// versionDetails converts versions of a product with the fields that depend
+// on its other versions: the latest flags, the lineage and the inherited
+// lifecycle. The product must be loaded with its children.
+type versionDetails struct {
+	product Node
+	latest  latestVersions
+	lineage versionLineage
+	now     time.Time
+}
+
+func newVersionDetails(product Node, now time.Time) versionDetails {
+	versions := versionsOf(product)
+	return versionDetails{
+		product: product,
+		latest:  productVersionOrder(product).latest(versions),
+		lineage: newVersionLineage(versions),
+		now:     now,
+	}
+}
+
+// dto converts a version of the product.
+func (d versionDetails) dto(version Node) ProductVersionDTO {
+	dto := NodeToProductVersionDTO(version)
+	dto.ReleasedAt = formatDate(version.ReleasedAt)
+	d.latest.apply(&dto)
+	d.lineage.apply(&dto)
+	versionLifecycle(d.product, version).apply(&dto, d.now)
+	return dto
+}
+
+// productVersionDTOs converts the versions of a product sorted by its version
+// order and returns the latest version of every release line, newest line
+// first.
+func productVersionDTOs(product Node, versions []Node, now time.Time) ([]ProductVersionDTO, []ProductVersionDTO) {
+	sorted := append([]Node(nil), versions...)
+	productVersionOrder(product).sortVersions(sorted)
+	details := newVersionDetails(product, now)
+
+	dtos := make([]ProductVersionDTO, len(sorted))
+	byID := make(map[string]ProductVersionDTO, len(sorted))
+	for i, version := range sorted {
+		dtos[i] = details.dto(version)
+		byID[version.ID] = dtos[i]
+	}
+
+	lines := make([]ProductVersionDTO, 0, len(details.latest.Lines))
+	for _, id := range details.latest.Lines {
+		lines = append(lines, byID[id])
+	}
+	return dtos, lines
+}
+
+// versionDTOs converts versions of any products, loading every product with
+// its versions once.
+type versionDTOs struct {
+	repo     Repository
+	now      time.Time
+	products map[string]versionDetails
+}
+
+func (s *Service) newVersionDTOs() *versionDTOs {
+	return &versionDTOs{repo: s.repo, now: s.now(), products: make(map[string]versionDetails)}
+}
+
+// convert returns the details of a version like GetProductVersionByID.
+func (c *versionDTOs) convert(ctx context.Context, version Node) (ProductVersionDTO, error) {
+	if version.ParentID == nil {
+		dto := NodeToProductVersionDTO(version)
+		lifecycleOf(version).apply(&dto, c.now)
+		return dto, nil
+	}
+	details, ok := c.products[*version.ParentID]
+	if !ok {
+		product, err := c.repo.GetNodeByID(ctx, *version.ParentID, WithChildren())
+		if err != nil {
+			return ProductVersionDTO{}, err
+		}
+		details = newVersionDetails(product, c.now)
+		c.products[product.ID] = details
+	}
+	return details.dto(version), nil
+}
+
+// versionsOf returns the product versions among the children of a product.
+func versionsOf(product Node) []Node {
+	var versions []Node
+	for _, child := range product.Children {
+		if child.Category == ProductVersion {
+			versions = append(versions, child)
+		}
+	}
+	return versions
+}
+
+// validateVersionScheme checks the version scheme and pattern of a product.
+func validateVersionScheme(dtoName string, scheme VersionScheme, pattern string) error {
+	if _, err := newVersionOrder(scheme, pattern); err != nil {
+		return fuego.BadRequestError{
+			Title: "Invalid version scheme",
+			Err:   err,
+			Errors: []fuego.ErrorItem{
+				{
+					Name:   dtoName + ".VersionScheme",
+					Reason: err.Error(),
+				},
+			},
+		}
+	}
+	return nil
+}
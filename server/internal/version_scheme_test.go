@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"product-database-api/testutils"
+	"strings"
+	"testing"
+
+	"github.com/go-fuego/fuego"
+)
+
+func TestVersionOrder(t *testing.T) {
+	tests := []struct {
+		scheme   VersionScheme
+		pattern  string
+		a, b     string
+		expected int
+	}{
+		{VersionSchemeSemver, "", "1.2.3", "1.10.0", -1},
+		{VersionSchemeSemver, "", "v2.0.0", "2.0.0", 0},
+		{VersionSchemeSemver, "", "1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{VersionSchemeSemver, "", "1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{VersionSchemeSemver, "", "1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{VersionSchemeSemver, "", "1.0.0-rc.1", "1.0.0", -1},
+		{VersionSchemeSemver, "", "1.0.0+build.1", "1.0.0+build.2", 0},
+		{VersionSchemeSemver, "", "1.0", "0.0.1", -1},
+		{VersionSchemeCalver, "", "2023.12", "2024.01", -1},
+		{VersionSchemeCalver, "", "24.04", "2023.10", 1},
+		{VersionSchemeCalver, "", "2024.03", "2024.03.1", -1},
+		{VersionSchemeCalver, "", "2024.03-rc1", "2024.03", -1},
+		{VersionSchemeNumeric, "", "10.0.19045.1234", "10.0.19045.999", 1},
+		{VersionSchemeNumeric, "", "1.0", "1.0.0", 0},
+		{VersionSchemeNumeric, "", "1.0", "1.0.1", -1},
+		{VersionSchemeNumeric, "", "1.0-beta", "0.1", -1},
+		{VersionSchemeCustom, `^R(\d+)(?:SP(\d+))?$`, "R9SP3", "R10", -1},
+		{VersionSchemeCustom, `^R(\d+)(?:SP(\d+))?$`, "R10", "R10SP1", -1},
+		{VersionSchemeCustom, `^R(\d+)(?:SP(\d+))?$`, "unknown", "R1", -1},
+		{VersionSchemeNatural, "", "2.0-rc1", "2.0", -1},
+		{"", "", "1.10", "1.9", 1},
+	}
+
+	for _, tt := range tests {
+		order, err := newVersionOrder(tt.scheme, tt.pattern)
+		testutils.AssertNoError(t, err, "Valid scheme "+string(tt.scheme))
+		if got := order.compare(tt.a, tt.b); got != tt.expected {
+			t.Errorf("%s: compare(%q, %q) = %d, expected %d", tt.scheme, tt.a, tt.b, got, tt.expected)
+		}
+		if got := order.compare(tt.b, tt.a); got != -tt.expected {
+			t.Errorf("%s: compare(%q, %q) = %d, expected %d", tt.scheme, tt.b, tt.a, got, -tt.expected)
+		}
+	}
+}
+
+func TestNewVersionOrderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		scheme  VersionScheme
+		pattern string
+	}{
+		{"roman", ""},
+		{VersionSchemeCustom, ""},
+		{VersionSchemeCustom, `^R(\d+`},
+		{VersionSchemeCustom, `^R\d+$`},
+	} {
+		_, err := newVersionOrder(tt.scheme, tt.pattern)
+		testutils.AssertError(t, err, "Invalid scheme "+string(tt.scheme)+" "+tt.pattern)
+	}
+}
+
+func TestLatestVersions(t *testing.T) {
+	nodes := func(names ...string) []Node {
+		versions := make([]Node, len(names))
+		for i, name := range names {
+			versions[i] = Node{ID: name, Name: name, Category: ProductVersion}
+		}
+		return versions
+	}
+
+	tests := []struct {
+		name     string
+		scheme   VersionScheme
+		versions []Node
+		latest   string
+		lines    string
+	}{
+		{"Sequential", VersionSchemeSemver, nodes("1.0.0", "1.1.0", "2.0.0"), "2.0.0", "2.0.0,1.1.0"},
+		{"ParallelBranches", VersionSchemeSemver, nodes("2.0.0", "1.4.2", "2.1.0", "1.4.10", "3.0.0-rc.1"), "2.1.0", "3.0.0-rc.1,2.1.0,1.4.10"},
+		{"PreReleaseLine", VersionSchemeSemver, nodes("1.0.0-rc.1", "1.0.0-rc.2"), "1.0.0-rc.2", "1.0.0-rc.2"},
+		{"ZeroMajor", VersionSchemeSemver, nodes("0.1.0", "0.1.5", "0.2.0"), "0.2.0", "0.2.0,0.1.5"},
+		{"Calver", VersionSchemeCalver, nodes("22.04", "24.04", "22.04.4", "24.10"), "24.10", "24.10,22.04.4"},
+		{"Natural", "", nodes("1.9", "1.10", "2"), "2", "2,1.10"},
+		{"Empty", VersionSchemeNumeric, nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := newVersionOrder(tt.scheme, "")
+			testutils.AssertNoError(t, err, "Valid scheme")
+			latest := order.latest(tt.versions)
+			testutils.AssertEqual(t, tt.latest, latest.Latest, "Latest version")
+			testutils.AssertEqual(t, tt.lines, strings.Join(latest.Lines, ","), "Latest versions of the lines")
+		})
+	}
+}
+
+func TestProductVersionScheme(t *testing.T) {
+	db := testutils.SetupTestDB(t)
+	defer testutils.CleanupTestDB(t, db)
+
+	svc := NewService(NewRepository(db))
+	ctx := context.Background()
+
+	vendor, err := svc.CreateVendor(ctx, CreateVendorDTO{Name: "Acme"})
+	testutils.AssertNoError(t, err, "Should create vendor")
+	product, err := svc.CreateProduct(ctx, CreateProductDTO{
+		Name:          "Gateway",
+		VendorID:      vendor.ID,
+		Type:          "software",
+		VersionScheme: string(VersionSchemeSemver),
+	})
+	testutils.AssertNoError(t, err, "Should create product")
+	testutils.AssertEqual(t, "semver", product.VersionScheme, "Version scheme")
+
+	ids := make(map[string]string)
+	for _, name := range []string{"2.0.0", "1.10.0", "1.9.0", "2.1.0-beta.1"} {
+		version, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: product.ID})
+		testutils.AssertNoError(t, err, "Should create version "+name)
+		ids[name] = version.ID
+	}
+
+	names := func(versions []ProductVersionDTO) string {
+		result := make([]string, len(versions))
+		for i, version := range versions {
+			result[i] = version.Name
+			if version.IsLatest {
+				result[i] += "*"
+			}
+		}
+		return strings.Join(result, ",")
+	}
+
+	t.Run("List", func(t *testing.T) {
+		versions, err := svc.ListProductVersions(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should list versions")
+		testutils.AssertEqual(t, "1.9.0,1.10.0,2.0.0*,2.1.0-beta.1", names(versions), "Sorted versions")
+		testutils.AssertEqual(t, true, versions[1].IsLatestInLine, "Latest of 1.x")
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		versions, total, err := svc.QueryProductVersions(ctx, product.ID, ListQuery{Limit: 2, Offset: 1, Order: "desc"})
+		testutils.AssertNoError(t, err, "Should query versions")
+		testutils.AssertEqual(t, int64(4), total, "Total")
+		testutils.AssertEqual(t, "2.0.0*,1.10.0", names(versions), "Page in descending version order")
+
+		versions, _, err = svc.QueryProductVersions(ctx, product.ID, ListQuery{Sort: SortByCreatedAt})
+		testutils.AssertNoError(t, err, "Should query versions")
+		testutils.AssertEqual(t, "2.0.0*,1.10.0,1.9.0,2.1.0-beta.1", names(versions), "Creation order")
+	})
+
+	t.Run("Product", func(t *testing.T) {
+		got, err := svc.GetProductByID(ctx, product.ID)
+		testutils.AssertNoError(t, err, "Should get product")
+		testutils.AssertEqual(t, "2.0.0*,1.10.0", names(got.LatestVersions), "Latest versions per major line")
+
+		version, err := svc.GetProductVersionByID(ctx, ids["2.0.0"])
+		testutils.AssertNoError(t, err, "Should get version")
+		testutils.AssertEqual(t, true, version.IsLatest, "Version is latest")
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		calver, err := svc.CreateProduct(ctx, CreateProductDTO{
+			Name:          "Firewall",
+			VendorID:      vendor.ID,
+			Type:          "software",
+			VersionScheme: string(VersionSchemeCalver),
+		})
+		testutils.AssertNoError(t, err, "Should create product")
+		for _, name := range []string{"23.10", "2024.04", "24.10"} {
+			_, err := svc.CreateProductVersion(ctx, CreateProductVersionDTO{Version: name, ProductID: calver.ID})
+			testutils.AssertNoError(t, err, "Should create version "+name)
+		}
+
+		vers := "vers:generic/>=2024.01"
+		versionRange, err := svc.CreateVersionRange(ctx, CreateVersionRangeDTO{ProductID: calver.ID, Vers: &vers})
+		testutils.AssertNoError(t, err, "Should create range")
+
+		versions, err := svc.ResolveVersionRange(ctx, versionRange.ID)
+		testutils.AssertNoError(t, err, "Should resolve range")
+		testutils.AssertEqual(t, "2024.04,24.10*", names(versions), "Versions in the calendar order")
+
+		min, max := "24.10", "2024.04"
+		_, err = svc.CreateVersionRange(ctx, CreateVersionRangeDTO{ProductID: calver.ID, Min: &min, Max: &max})
+		var badRequest fuego.BadRequestError
+		testutils.AssertEqual(t, true, errors.As(err, &badRequest), "Bounds out of calendar order")
+	})
+
+	t.Run("CustomScheme", func(t *testing.T) {
+		scheme, pattern := string(VersionSchemeCustom), `^(\d+)`
+		_, err := svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{VersionScheme: &scheme, VersionPattern: &pattern})
+		testutils.AssertNoError(t, err, "Should update scheme")
+
+		invalid := `^\d+$`
+		_, err = svc.UpdateProduct(ctx, product.ID, UpdateProductDTO{VersionPattern: &invalid})
+		var badRequest fuego.BadRequestError
+		testutils.AssertEqual(t, true, errors.As(err, &badRequest), "Pattern without capture group")
+
+		_, err = svc.CreateProduct(ctx, CreateProductDTO{Name: "Switch", VendorID: vendor.ID, Type: "hardware", VersionScheme: string(VersionSchemeCustom)})
+		testutils.AssertEqual(t, true, errors.As(err, &badRequest), "Custom scheme without pattern")
+	})
+}
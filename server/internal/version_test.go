@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0", "1.9.9", 1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0+build5", "1.0.0", 0},
+		{"1.0", "1.0.1", -1},
+		{"2023.10", "2023.9", 1},
+		{"R2", "r10", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.expected {
+			t.Errorf("compareVersions(%q, %q) = %d, expected %d", tt.b, tt.a, got, -tt.expected)
+		}
+	}
+
+	versions := []string{"10.0", "2.0", "2.0-rc1", "1.0", "2.1"}
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) < 0 })
+	expected := []string{"1.0", "2.0-rc1", "2.0", "2.1", "10.0"}
+	for i := range expected {
+		if versions[i] != expected[i] {
+			t.Fatalf("Unexpected order %v, expected %v", versions, expected)
+		}
+	}
+}
@@ -62,8 +62,20 @@ type Node struct {
 	ProductFamilyID *string     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	ReleasedAt      sql.NullTime
 
-	SuccessorID *string
-	Successor   *Node `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	EndOfSupport         sql.NullTime
+	EndOfSecurityUpdates sql.NullTime
+	EndOfLife            sql.NullTime
+	SupportStatus        string
+
+	VersionScheme  string
+	VersionPattern string
+
+	PredecessorID *string `gorm:"index"`
+	Predecessor   *Node   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+
+	CreatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	DeletionID *string        `gorm:"index"`
 }
 
 // Relationship represents a relationship between nodes for testing
@@ -76,6 +88,9 @@ type Relationship struct {
 
 	TargetNodeID string
 	TargetNode   *Node `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	DeletionID *string        `gorm:"index"`
 }
 
 // IdentificationHelper represents an identification helper for testing
@@ -86,6 +101,52 @@ type IdentificationHelper struct {
 
 	NodeID string
 	Node   *Node `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	DeletionID *string        `gorm:"index"`
+}
+
+// IdentifierIndex represents an indexed identifier of a helper for testing
+type IdentifierIndex struct {
+	ID    uint   `gorm:"primaryKey"`
+	Type  string `gorm:"index:idx_identifier_lookup,priority:1"`
+	Value string `gorm:"index:idx_identifier_lookup,priority:2"`
+
+	HelperID string                `gorm:"index"`
+	Helper   *IdentificationHelper `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	NodeID   string
+}
+
+// AuditEntry represents an audit log entry for testing
+type AuditEntry struct {
+	ID         string `gorm:"primaryKey"`
+	EntityType string `gorm:"index:idx_audit_entity,priority:1"`
+	EntityID   string `gorm:"index:idx_audit_entity,priority:2"`
+	NodeID     string `gorm:"index"`
+	Action     string
+	Changes    []byte `gorm:"serializer:json"`
+	Actor      string
+	RequestID  string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// RowVersion represents a temporal row version for testing
+type RowVersion struct {
+	ID         uint   `gorm:"primaryKey"`
+	EntityType string `gorm:"index:idx_row_version_entity,priority:1"`
+	EntityID   string `gorm:"index:idx_row_version_entity,priority:2"`
+	Data       []byte
+	ValidFrom  time.Time  `gorm:"index"`
+	ValidTo    *time.Time `gorm:"index"`
+}
+
+// TrashedReference represents a cleared reference to a node in the trash for testing
+type TrashedReference struct {
+	ID         uint   `gorm:"primaryKey"`
+	DeletionID string `gorm:"index"`
+	NodeID     string `gorm:"index"`
+	Field      string
+	TargetID   string
 }
 
 // SetupTestDB creates an in-memory SQLite database for testing
@@ -98,7 +159,7 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&Node{}, &Relationship{}, &IdentificationHelper{})
+	err = db.AutoMigrate(&Node{}, &Relationship{}, &IdentificationHelper{}, &IdentifierIndex{}, &AuditEntry{}, &RowVersion{}, &TrashedReference{})
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}